@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"msgraphtool/internal/common/dualstack"
+	commontls "msgraphtool/internal/common/tls"
 	"msgraphtool/internal/common/validation"
 )
 
@@ -26,7 +28,7 @@ type Config struct {
 	Username    string
 	Password    string
 	AccessToken string // OAuth2 access token for XOAUTH2 authentication
-	AuthMethod  string // PLAIN, LOGIN, XOAUTH2, or "auto"
+	AuthMethod  string // PLAIN, LOGIN, SCRAM-SHA-1/256(-PLUS), NTLM, XOAUTH2, or "auto"
 
 	// TLS configuration
 	IMAPS      bool   // Use IMAPS (implicit TLS on port 993)
@@ -34,10 +36,21 @@ type Config struct {
 	SkipVerify bool   // Skip TLS certificate verification
 	TLSVersion string // TLS version to use: 1.2, 1.3
 
+	// Certificate trust and mutual TLS
+	CACert         string   // PEM bundle of trusted roots, replacing the system roots
+	ClientCert     string   // Client certificate for mutual TLS: PEM, or PKCS#12 (.pfx/.p12)
+	ClientKey      string   // PEM private key for ClientCert (not used with PKCS#12)
+	ClientCertPass string   // PKCS#12 password
+	PinSHA256      []string // Base64 SHA-256 SPKI pins; one must match the server chain
+	SaveChain      string   // Directory the presented certificate chain is saved to
+	Fingerprints   string   // JSON file with additional server fingerprint signatures
+
 	// Network configuration
 	ProxyURL   string
 	MaxRetries int
 	RetryDelay time.Duration
+	IPVersion  string // 4, 6 or both (empty: whichever address connects first)
+	SourceIP   string // Local address to connect from
 
 	// Runtime configuration
 	VerboseMode  bool
@@ -106,18 +119,27 @@ func parseAndConfigureFlags() *Config {
 	username := flag.String("username", "", "Username for authentication (env: IMAPUSERNAME)")
 	password := flag.String("password", "", "Password for authentication (env: IMAPPASSWORD)")
 	accessToken := flag.String("accesstoken", "", "OAuth2 access token for XOAUTH2 (env: IMAPACCESSTOKEN)")
-	authMethod := flag.String("authmethod", "auto", "Auth method: auto, PLAIN, LOGIN, XOAUTH2 (env: IMAPAUTHMETHOD)")
+	authMethod := flag.String("authmethod", "auto", "Auth method: auto, PLAIN, LOGIN, SCRAM-SHA-1, SCRAM-SHA-256, SCRAM-SHA-1-PLUS, SCRAM-SHA-256-PLUS, NTLM, XOAUTH2 (env: IMAPAUTHMETHOD)")
 
 	// TLS configuration
 	imaps := flag.Bool("imaps", false, "Use IMAPS (implicit TLS on port 993) (env: IMAPIMAPS)")
 	startTLS := flag.Bool("starttls", false, "Force STARTTLS upgrade (env: IMAPSTARTTLS)")
 	skipVerify := flag.Bool("skipverify", false, "Skip TLS certificate verification (env: IMAPSKIPVERIFY)")
 	tlsVersion := flag.String("tlsversion", "1.2", "TLS version: 1.2, 1.3 (env: IMAPTLSVERSION)")
+	caCert := flag.String("cacert", "", "PEM bundle of trusted CA certificates, replacing the system roots (env: IMAPCACERT)")
+	clientCert := flag.String("clientcert", "", "Client certificate for mutual TLS: PEM, or PKCS#12 .pfx/.p12 (env: IMAPCLIENTCERT)")
+	clientKey := flag.String("clientkey", "", "PEM private key for -clientcert (env: IMAPCLIENTKEY)")
+	clientCertPass := flag.String("clientcertpass", "", "Password for a PKCS#12 -clientcert (env: IMAPCLIENTCERTPASS)")
+	pinSHA256 := flag.String("pinsha256", "", "Comma-separated base64 SHA-256 SPKI pins of the server or CA key (env: IMAPPINSHA256)")
+	saveChain := flag.String("savechain", "", "Directory to save the presented and verified certificate chains to as PEM/DER, with a JSON summary (env: IMAPSAVECHAIN)")
+	fingerprints := flag.String("fingerprints", "", "JSON file with additional server fingerprint signatures (env: IMAPFINGERPRINTS)")
 
 	// Network configuration
 	proxyURL := flag.String("proxy", "", "Proxy URL (env: IMAPPROXY)")
 	maxRetries := flag.Int("maxretries", 3, "Maximum retry attempts (env: IMAPMAXRETRIES)")
 	retryDelay := flag.Int("retrydelay", 2000, "Retry delay in milliseconds (env: IMAPRETRYDELAY)")
+	ipVersion := flag.String("ipversion", "", "IP version: 4, 6, or both to test every address of the host (env: IMAPIPVERSION)")
+	sourceIP := flag.String("sourceip", "", "Local IP address to connect from (env: IMAPSOURCEIP)")
 
 	// Runtime configuration
 	verbose := flag.Bool("verbose", false, "Enable verbose output")
@@ -142,9 +164,20 @@ func parseAndConfigureFlags() *Config {
 	config.StartTLS = *startTLS
 	config.SkipVerify = *skipVerify
 	config.TLSVersion = *tlsVersion
+	config.CACert = *caCert
+	config.ClientCert = *clientCert
+	config.ClientKey = *clientKey
+	config.ClientCertPass = *clientCertPass
+	if *pinSHA256 != "" {
+		config.PinSHA256 = strings.Split(*pinSHA256, ",")
+	}
+	config.SaveChain = *saveChain
+	config.Fingerprints = *fingerprints
 	config.ProxyURL = *proxyURL
 	config.MaxRetries = *maxRetries
 	config.RetryDelay = time.Duration(*retryDelay) * time.Millisecond
+	config.IPVersion = *ipVersion
+	config.SourceIP = *sourceIP
 	config.VerboseMode = *verbose
 	config.LogLevel = *logLevel
 	config.OutputFormat = *output
@@ -204,6 +237,27 @@ func applyEnvOverrides(config *Config) {
 	if v := os.Getenv("IMAPTLSVERSION"); v != "" {
 		config.TLSVersion = v
 	}
+	if v := os.Getenv("IMAPCACERT"); v != "" && config.CACert == "" {
+		config.CACert = v
+	}
+	if v := os.Getenv("IMAPCLIENTCERT"); v != "" && config.ClientCert == "" {
+		config.ClientCert = v
+	}
+	if v := os.Getenv("IMAPCLIENTKEY"); v != "" && config.ClientKey == "" {
+		config.ClientKey = v
+	}
+	if v := os.Getenv("IMAPCLIENTCERTPASS"); v != "" && config.ClientCertPass == "" {
+		config.ClientCertPass = v
+	}
+	if v := os.Getenv("IMAPPINSHA256"); v != "" && len(config.PinSHA256) == 0 {
+		config.PinSHA256 = strings.Split(v, ",")
+	}
+	if v := os.Getenv("IMAPSAVECHAIN"); v != "" && config.SaveChain == "" {
+		config.SaveChain = v
+	}
+	if v := os.Getenv("IMAPFINGERPRINTS"); v != "" && config.Fingerprints == "" {
+		config.Fingerprints = v
+	}
 	if v := os.Getenv("IMAPPROXY"); v != "" && config.ProxyURL == "" {
 		config.ProxyURL = v
 	}
@@ -217,6 +271,12 @@ func applyEnvOverrides(config *Config) {
 			config.RetryDelay = time.Duration(delay) * time.Millisecond
 		}
 	}
+	if v := os.Getenv("IMAPIPVERSION"); v != "" && config.IPVersion == "" {
+		config.IPVersion = v
+	}
+	if v := os.Getenv("IMAPSOURCEIP"); v != "" && config.SourceIP == "" {
+		config.SourceIP = v
+	}
 	if v := os.Getenv("IMAPOUTPUT"); v != "" {
 		config.OutputFormat = v
 	}
@@ -230,6 +290,17 @@ func applyEnvOverrides(config *Config) {
 	}
 }
 
+// TrustOptions returns the CA bundle, client certificate and pinning settings.
+func (c *Config) TrustOptions() *commontls.TrustOptions {
+	return &commontls.TrustOptions{
+		CAFile:             c.CACert,
+		ClientCertFile:     c.ClientCert,
+		ClientKeyFile:      c.ClientKey,
+		ClientCertPassword: c.ClientCertPass,
+		Pins:               c.PinSHA256,
+	}
+}
+
 // parseBoolEnv parses a boolean environment variable.
 func parseBoolEnv(key string) bool {
 	v := strings.ToLower(os.Getenv(key))
@@ -281,11 +352,38 @@ func validateConfiguration(config *Config) error {
 		return fmt.Errorf("invalid proxy URL: %w", err)
 	}
 
+	// Validate IP version and source address
+	if err := dualstack.Validate(config.IPVersion, config.SourceIP); err != nil {
+		return err
+	}
+	if config.IPVersion != "" && config.ProxyURL != "" {
+		return fmt.Errorf("-ipversion cannot be combined with -proxy (the proxy resolves and connects to the server)")
+	}
+
+	// Validate retry settings
+	if config.MaxRetries < 0 {
+		return fmt.Errorf("-maxretries must not be negative, got %d", config.MaxRetries)
+	}
+	if config.RetryDelay < 0 {
+		return fmt.Errorf("-retrydelay must not be negative, got %v", config.RetryDelay)
+	}
+
 	// Validate mutual exclusion
 	if config.IMAPS && config.StartTLS {
 		return fmt.Errorf("cannot use both -imaps and -starttls; choose one")
 	}
 
+	// Validate CA bundle, client certificate and pins (if provided)
+	if err := config.TrustOptions().Validate(); err != nil {
+		return fmt.Errorf("invalid TLS trust settings: %w", err)
+	}
+	if err := validation.ValidateDirPath(config.SaveChain, "chain directory"); err != nil {
+		return fmt.Errorf("invalid -savechain: %w", err)
+	}
+	if err := validation.ValidateFilePath(config.Fingerprints, "fingerprint signatures"); err != nil {
+		return fmt.Errorf("invalid -fingerprints: %w", err)
+	}
+
 	// Action-specific validation
 	switch config.Action {
 	case ActionTestAuth, ActionListFolders:
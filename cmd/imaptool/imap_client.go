@@ -1,16 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/emersion/go-imap/v2"
 	"github.com/emersion/go-imap/v2/imapclient"
 	"github.com/emersion/go-sasl"
 
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/proxy"
 	"msgraphtool/internal/common/ratelimit"
+	"msgraphtool/internal/common/retry"
+	commonsasl "msgraphtool/internal/common/sasl"
+	"msgraphtool/internal/common/timing"
+	commontls "msgraphtool/internal/common/tls"
 	imapprotocol "msgraphtool/internal/imap/protocol"
 )
 
@@ -23,6 +35,8 @@ type IMAPClient struct {
 	caps     *imapprotocol.Capabilities
 	limiter  *ratelimit.Limiter
 	tlsState *tls.ConnectionState
+	greeting *greetingRecorder
+	rec      *timing.Recorder // Phase timings from the Connect context (nil when not timed)
 }
 
 // MailboxInfo holds information about a mailbox.
@@ -48,7 +62,8 @@ func NewIMAPClient(config *Config) *IMAPClient {
 	}
 }
 
-// Connect establishes a connection to the IMAP server.
+// Connect establishes a connection to the IMAP server. The phases of the
+// session are recorded in the context's timing.Recorder.
 func (c *IMAPClient) Connect(ctx context.Context) error {
 	if c.limiter != nil {
 		if err := c.limiter.Wait(ctx); err != nil {
@@ -57,33 +72,85 @@ func (c *IMAPClient) Connect(ctx context.Context) error {
 	}
 
 	address := fmt.Sprintf("%s:%d", c.host, c.port)
+	c.rec = timing.FromContext(ctx)
 
+	c.greeting = &greetingRecorder{}
 	options := &imapclient.Options{
 		TLSConfig: &tls.Config{
 			ServerName:         c.host,
 			InsecureSkipVerify: c.config.SkipVerify,
-			MinVersion:         parseTLSVersion(c.config.TLSVersion),
+			MinVersion:         commontls.ParseTLSVersion(c.config.TLSVersion),
 		},
+		// go-imap does not expose the greeting text, only its capabilities
+		DebugWriter: c.greeting,
+	}
+	if c.config.StartTLS {
+		// go-imap performs the STARTTLS handshake internally, so the state is
+		// captured when the connection is verified. Set before the trust
+		// options so SPKI pins are checked first.
+		options.TLSConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			c.tlsState = &state
+			return nil
+		}
+	}
+	if err := c.config.TrustOptions().Apply(options.TLSConfig); err != nil {
+		return err
+	}
+	var capture *commontls.ChainCapture
+	if c.config.SaveChain != "" && (c.config.IMAPS || c.config.StartTLS) {
+		capture = &commontls.ChainCapture{}
+		capture.Apply(options.TLSConfig)
 	}
 
+	// Connections are dialed here rather than with imapclient.Dial*, whose
+	// Options.Dialer is a *net.Dialer and cannot go through the -proxy.
+	dialer, err := proxy.NewDialer(c.config.ProxyURL, &net.Dialer{
+		Timeout:   c.config.Timeout,
+		LocalAddr: dualstack.LocalAddr(c.config.SourceIP),
+	})
+	if err != nil {
+		return err
+	}
+	dialer = dualstack.Wrap(dialer, c.config.IPVersion)
+
 	var client *imapclient.Client
-	var err error
+	var connected time.Time
 
 	if c.config.IMAPS {
-		// Implicit TLS (IMAPS)
-		client, err = imapclient.DialTLS(address, options)
-		if err == nil {
-			c.tlsState = &tls.ConnectionState{} // Mark as TLS connection
-		}
-	} else if c.config.StartTLS {
-		// Explicit TLS via STARTTLS
-		client, err = imapclient.DialStartTLS(address, options)
+		// Implicit TLS (IMAPS). The connection state is kept for SCRAM
+		// channel binding.
+		tlsConfig := options.TLSConfig.Clone()
+		tlsConfig.NextProtos = []string{"imap"}
+		var conn *tls.Conn
+		conn, err = proxy.DialTLS(ctx, dialer, "tcp", address, tlsConfig, c.config.Timeout)
 		if err == nil {
-			c.tlsState = &tls.ConnectionState{} // Mark as TLS connection
+			state := conn.ConnectionState()
+			c.tlsState = &state
+			connected = time.Now()
+			client = imapclient.New(conn, options)
 		}
 	} else {
-		// Plain connection
-		client, err = imapclient.DialInsecure(address, options)
+		var conn net.Conn
+		conn, err = timing.Dial(ctx, dialer, "tcp", address)
+		connected = time.Now()
+		if err == nil && c.config.StartTLS {
+			// Explicit TLS via STARTTLS. go-imap waits for the greeting
+			// first, so the handshake is the time after it.
+			client, err = imapclient.NewStartTLS(conn, options)
+			if greeted := c.greeting.receivedAt(); !greeted.IsZero() {
+				c.rec.Add(timing.Greeting, greeted.Sub(connected))
+				c.rec.Since(timing.TLS, greeted)
+			}
+			if err != nil {
+				c.tlsState = nil
+			}
+		} else if err == nil {
+			// Plain connection
+			client = imapclient.New(conn, options)
+		}
+	}
+	if capture != nil {
+		capture.SaveAndPrint(os.Stdout, c.config.SaveChain, fmt.Sprintf("%s_%d", c.host, c.port))
 	}
 
 	if err != nil {
@@ -92,20 +159,42 @@ func (c *IMAPClient) Connect(ctx context.Context) error {
 
 	c.client = client
 
-	// Parse capabilities from greeting
+	if !c.config.StartTLS {
+		if client.WaitGreeting() == nil {
+			c.rec.Since(timing.Greeting, connected)
+		}
+	}
+
+	// Parse capabilities from greeting, or from CAPABILITY when the
+	// greeting has none
+	start := time.Now()
 	if caps := client.Caps(); caps != nil {
 		c.caps = convertCaps(caps)
 	}
+	c.rec.Since(timing.Capabilities, start)
 
 	return nil
 }
 
-// GetGreeting returns the server greeting (capabilities from greeting).
+// GetGreeting returns the server greeting, e.g. "* OK [CAPABILITY ...] Dovecot ready.".
 func (c *IMAPClient) GetGreeting() string {
-	if c.caps != nil {
-		return c.caps.String()
+	if c.greeting == nil {
+		return ""
 	}
-	return ""
+	return c.greeting.String()
+}
+
+// ID exchanges RFC 2971 identification with the server and returns what
+// the server reports about itself (nil fields if it does not say).
+func (c *IMAPClient) ID() (*imap.IDData, error) {
+	if c.caps == nil || !c.caps.SupportsID() {
+		return nil, fmt.Errorf("server does not support ID")
+	}
+	id, err := c.client.ID(nil).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("ID failed: %w", err)
+	}
+	return id, nil
 }
 
 // GetCapabilities returns the server capabilities.
@@ -134,19 +223,13 @@ func (c *IMAPClient) Auth(ctx context.Context, username, password, accessToken s
 		}
 	}
 
+	defer c.rec.Since(timing.Auth, time.Now())
+
 	method := c.config.AuthMethod
 
 	// Auto-select auth method
 	if strings.EqualFold(method, "auto") {
-		if accessToken != "" && c.caps != nil && c.caps.SupportsXOAUTH2() {
-			method = "XOAUTH2"
-		} else if c.caps != nil && c.caps.SupportsPlain() {
-			method = "PLAIN"
-		} else if c.caps != nil && c.caps.SupportsLogin() {
-			method = "LOGIN"
-		} else {
-			method = "LOGIN" // Fallback
-		}
+		method = selectAuthMethod(c.caps, accessToken != "", c.ChannelBindingAvailable())
 	}
 
 	switch strings.ToUpper(method) {
@@ -157,10 +240,45 @@ func (c *IMAPClient) Auth(ctx context.Context, username, password, accessToken s
 	case "LOGIN":
 		return c.authLogin(username, password)
 	default:
+		if commonsasl.IsSupported(method) {
+			return c.authSASL(method, username, password)
+		}
 		return fmt.Errorf("unsupported auth method: %s", method)
 	}
 }
 
+// ChannelBindingAvailable returns true if the TLS connection state needed for
+// SCRAM -PLUS mechanisms is known. The state captured during a STARTTLS
+// handshake is incomplete, so channel binding is only available with -imaps.
+func (c *IMAPClient) ChannelBindingAvailable() bool {
+	return c.tlsState != nil && c.tlsState.HandshakeComplete
+}
+
+// selectAuthMethod picks the strongest mechanism advertised in the AUTH=
+// capabilities, falling back to the LOGIN command.
+func selectAuthMethod(caps *imapprotocol.Capabilities, hasAccessToken, channelBinding bool) string {
+	if caps == nil {
+		return "LOGIN"
+	}
+	if hasAccessToken && caps.SupportsXOAUTH2() {
+		return "XOAUTH2"
+	}
+
+	available := commonsasl.Usable(caps.GetAuthMechanisms(), channelBinding)
+	for _, preferred := range commonsasl.PasswordPreference {
+		// CRAM-MD5 is not implemented; LOGIN is the command fallback below
+		if preferred == "CRAM-MD5" || preferred == "LOGIN" {
+			continue
+		}
+		for _, mech := range available {
+			if strings.EqualFold(mech, preferred) {
+				return preferred
+			}
+		}
+	}
+	return "LOGIN"
+}
+
 // authPlain performs PLAIN authentication.
 func (c *IMAPClient) authPlain(username, password string) error {
 	saslClient := sasl.NewPlainClient("", username, password)
@@ -178,6 +296,37 @@ func (c *IMAPClient) authLogin(username, password string) error {
 	return nil
 }
 
+// authSASL performs SCRAM or NTLM authentication.
+func (c *IMAPClient) authSASL(mechanism, username, password string) error {
+	mechanism = strings.ToUpper(mechanism)
+	var state *tls.ConnectionState
+	if c.ChannelBindingAvailable() {
+		state = c.tlsState
+	} else if commonsasl.IsChannelBinding(mechanism) {
+		return fmt.Errorf("%s requires -imaps (channel binding is not available over STARTTLS or plain connections)", mechanism)
+	}
+	var advertised []string
+	if c.caps != nil {
+		advertised = c.caps.GetAuthMechanisms()
+	}
+
+	saslClient, err := commonsasl.NewClient(mechanism, username, password, state, advertised)
+	if err != nil {
+		return err
+	}
+	if err := c.client.Authenticate(saslClient); err != nil {
+		return fmt.Errorf("%s authentication failed: %w", mechanism, err)
+	}
+	// go-imap passes no data with the tagged OK, so SCRAM must have received
+	// the server signature as a challenge
+	if completer, ok := saslClient.(commonsasl.Completer); ok {
+		if err := completer.Complete(nil); err != nil {
+			return fmt.Errorf("%s authentication failed: %w", mechanism, err)
+		}
+	}
+	return nil
+}
+
 // authXOAUTH2 performs XOAUTH2 authentication.
 func (c *IMAPClient) authXOAUTH2(username, accessToken string) error {
 	saslClient := sasl.NewOAuthBearerClient(&sasl.OAuthBearerOptions{
@@ -199,12 +348,23 @@ func (c *IMAPClient) ListMailboxes(ctx context.Context) ([]MailboxInfo, error) {
 	}
 
 	// List all mailboxes
+	start := time.Now()
 	listCmd := c.client.List("", "*", nil)
 	mailboxes, err := listCmd.Collect()
+	c.step("LIST", start)
 	if err != nil {
 		return nil, fmt.Errorf("LIST failed: %w", err)
 	}
 
+	// The STATUS commands are timed together; one step per mailbox would
+	// bury the other phases
+	start = time.Now()
+	defer func() {
+		if len(mailboxes) > 0 {
+			c.step(fmt.Sprintf("STATUS (%d mailboxes)", len(mailboxes)), start)
+		}
+	}()
+
 	var result []MailboxInfo
 	for _, mb := range mailboxes {
 		info := MailboxInfo{
@@ -233,6 +393,13 @@ func (c *IMAPClient) ListMailboxes(ctx context.Context) ([]MailboxInfo, error) {
 	return result, nil
 }
 
+// step records a command of the action as a transaction step.
+func (c *IMAPClient) step(command string, start time.Time) {
+	d := time.Since(start)
+	c.rec.Add(timing.Transaction, d)
+	c.rec.Step(command, d)
+}
+
 // Logout sends the LOGOUT command and closes the connection.
 func (c *IMAPClient) Logout() error {
 	if c.client != nil {
@@ -249,22 +416,6 @@ func (c *IMAPClient) Close() error {
 	return nil
 }
 
-// parseTLSVersion parses a TLS version string to a constant.
-func parseTLSVersion(version string) uint16 {
-	switch version {
-	case "1.3":
-		return tls.VersionTLS13
-	case "1.2":
-		return tls.VersionTLS12
-	case "1.1":
-		return tls.VersionTLS11
-	case "1.0":
-		return tls.VersionTLS10
-	default:
-		return tls.VersionTLS12
-	}
-}
-
 // convertCaps converts go-imap capabilities to our protocol.Capabilities.
 func convertCaps(caps imap.CapSet) *imapprotocol.Capabilities {
 	var capsList []string
@@ -282,3 +433,54 @@ func convertMailboxAttrs(attrs []imap.MailboxAttr) []string {
 	}
 	return result
 }
+
+// greetingRecorder keeps the first line of the session, the server
+// greeting, from the traffic go-imap copies to Options.DebugWriter.
+type greetingRecorder struct {
+	mu   sync.Mutex
+	buf  []byte
+	done bool
+	at   time.Time // When the greeting line was complete
+}
+
+func (g *greetingRecorder) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.done {
+		g.buf = append(g.buf, p...)
+		if i := bytes.IndexByte(g.buf, '\n'); i >= 0 {
+			g.buf, g.done, g.at = g.buf[:i], true, time.Now()
+		} else if len(g.buf) > 4096 {
+			g.buf, g.done = nil, true // Not a greeting line
+		}
+	}
+	return len(p), nil
+}
+
+// String returns the greeting without its line ending.
+func (g *greetingRecorder) String() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.done {
+		return ""
+	}
+	return strings.TrimRight(string(g.buf), "\r")
+}
+
+// receivedAt returns when the greeting was received, or the zero time.
+func (g *greetingRecorder) receivedAt() time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.at
+}
+
+// classifyIMAPError marks NO responses whose response code (RFC 5530)
+// reports a temporary condition as retryable; go-imap's error type does not
+// say so itself.
+func classifyIMAPError(err error) error {
+	var imapErr *imap.Error
+	if errors.As(err, &imapErr) && (imapErr.Code == imap.ResponseCodeUnavailable || imapErr.Code == imap.ResponseCodeInUse) {
+		return retry.Transient(err)
+	}
+	return err
+}
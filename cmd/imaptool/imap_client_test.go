@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	imapprotocol "msgraphtool/internal/imap/protocol"
+)
+
+func TestSelectAuthMethod(t *testing.T) {
+	tests := []struct {
+		name           string
+		caps           []string
+		hasAccessToken bool
+		channelBinding bool
+		expected       string
+	}{
+		{"SCRAM-SHA-256 preferred", []string{"IMAP4rev1", "AUTH=PLAIN", "AUTH=SCRAM-SHA-1", "AUTH=SCRAM-SHA-256"}, false, false, "SCRAM-SHA-256"},
+		{"PLUS variant with channel binding", []string{"AUTH=SCRAM-SHA-256", "AUTH=SCRAM-SHA-256-PLUS"}, false, true, "SCRAM-SHA-256-PLUS"},
+		{"PLUS variant skipped without channel binding", []string{"AUTH=SCRAM-SHA-256", "AUTH=SCRAM-SHA-256-PLUS"}, false, false, "SCRAM-SHA-256"},
+		{"NTLM over PLAIN", []string{"AUTH=NTLM", "AUTH=GSSAPI", "AUTH=PLAIN"}, false, false, "NTLM"},
+		{"XOAUTH2 with token", []string{"AUTH=XOAUTH2", "AUTH=SCRAM-SHA-256"}, true, false, "XOAUTH2"},
+		{"CRAM-MD5 only falls back to LOGIN", []string{"AUTH=CRAM-MD5"}, false, false, "LOGIN"},
+		{"No AUTH capabilities", []string{"IMAP4rev1"}, false, false, "LOGIN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := imapprotocol.NewCapabilities(tt.caps)
+			if got := selectAuthMethod(caps, tt.hasAccessToken, tt.channelBinding); got != tt.expected {
+				t.Errorf("selectAuthMethod() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if got := selectAuthMethod(nil, false, false); got != "LOGIN" {
+		t.Errorf("selectAuthMethod(nil) = %q, want LOGIN", got)
+	}
+}
+
+func TestIMAPClient_GreetingAndID(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "* OK [CAPABILITY IMAP4rev1 ID] Dovecot ready.\r\n")
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			switch strings.ToUpper(fields[1]) {
+			case "ID":
+				fmt.Fprintf(conn, "* ID (\"name\" \"Dovecot\" \"version\" \"2.3.21\")\r\n%s OK ID completed\r\n", fields[0])
+			case "LOGOUT":
+				fmt.Fprintf(conn, "* BYE Logging out\r\n%s OK Logout completed\r\n", fields[0])
+				return
+			default:
+				fmt.Fprintf(conn, "%s BAD Unexpected command\r\n", fields[0])
+			}
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	client := NewIMAPClient(&Config{Host: "127.0.0.1", Port: port, Timeout: 5 * time.Second})
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer func() { _ = client.Logout() }()
+
+	if got := client.GetGreeting(); got != "* OK [CAPABILITY IMAP4rev1 ID] Dovecot ready." {
+		t.Errorf("GetGreeting() = %q", got)
+	}
+	id, err := client.ID()
+	if err != nil {
+		t.Fatalf("ID() error: %v", err)
+	}
+	if id.Name != "Dovecot" || id.Version != "2.3.21" {
+		t.Errorf("ID() = %+v, want Dovecot 2.3.21", id)
+	}
+}
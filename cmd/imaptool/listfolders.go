@@ -48,17 +48,7 @@ func listFolders(ctx context.Context, config *Config, csvLogger logger.Logger, s
 	// Determine auth method
 	authMethod := config.AuthMethod
 	if strings.EqualFold(authMethod, "auto") {
-		if config.AccessToken != "" {
-			if caps != nil && caps.SupportsXOAUTH2() {
-				authMethod = "XOAUTH2"
-			} else {
-				authMethod = "PLAIN"
-			}
-		} else if caps != nil && caps.SupportsPlain() {
-			authMethod = "PLAIN"
-		} else {
-			authMethod = "LOGIN"
-		}
+		authMethod = selectAuthMethod(caps, config.AccessToken != "", client.ChannelBindingAvailable())
 	}
 
 	fmt.Printf("Authenticating with method: %s\n", authMethod)
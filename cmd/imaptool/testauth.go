@@ -59,18 +59,10 @@ func testAuth(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 	// Determine auth method
 	authMethod := config.AuthMethod
 	if strings.EqualFold(authMethod, "auto") {
-		if config.AccessToken != "" {
-			if caps != nil && caps.SupportsXOAUTH2() {
-				authMethod = "XOAUTH2"
-			} else {
-				logger.LogWarn(slogLogger, "Access token provided but XOAUTH2 not supported by server")
-				authMethod = "PLAIN"
-			}
-		} else if caps != nil && caps.SupportsPlain() {
-			authMethod = "PLAIN"
-		} else {
-			authMethod = "LOGIN"
+		if config.AccessToken != "" && (caps == nil || !caps.SupportsXOAUTH2()) {
+			logger.LogWarn(slogLogger, "Access token provided but XOAUTH2 not supported by server")
 		}
+		authMethod = selectAuthMethod(caps, config.AccessToken != "", client.ChannelBindingAvailable())
 	}
 
 	fmt.Printf("Authenticating with method: %s\n", authMethod)
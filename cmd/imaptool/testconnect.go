@@ -4,17 +4,27 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
 	"strings"
 
+	"msgraphtool/internal/common/fingerprint"
 	"msgraphtool/internal/common/logger"
+	commontls "msgraphtool/internal/common/tls"
 )
 
 // testConnect tests basic IMAP connectivity.
 func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
 	fmt.Printf("Testing IMAP connection to %s:%d...\n", config.Host, config.Port)
 
+	registry, err := fingerprint.LoadRegistry(config.Fingerprints)
+	if err != nil {
+		return err
+	}
+
 	// CSV columns for testconnect
-	columns := []string{"Action", "Status", "Server", "Port", "Connected", "Capabilities", "TLS_Version", "Error"}
+	columns := []string{"Action", "Status", "Server", "Port", "Connected", "Capabilities", "Fingerprint"}
+	columns = append(columns, commontls.CSVColumns...)
+	columns = append(columns, "Error")
 	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
 		if err := csvLogger.WriteHeader(columns); err != nil {
 			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
@@ -30,10 +40,9 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 			"host", config.Host,
 			"port", config.Port)
 
-		if logErr := csvLogger.WriteRow([]string{
-			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			"false", "", "", err.Error(),
-		}); logErr != nil {
+		row := []string{config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port), "false", "", ""}
+		row = append(row, make([]string, len(commontls.CSVColumns))...)
+		if logErr := csvLogger.WriteRow(append(row, err.Error())); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
 		return fmt.Errorf("connection failed: %w", err)
@@ -41,16 +50,15 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 	defer func() { _ = client.Logout() }()
 
 	fmt.Printf("✓ Connected to %s:%d\n", config.Host, config.Port)
+	if greeting := client.GetGreeting(); greeting != "" {
+		fmt.Printf("  Greeting: %s\n", greeting)
+	}
 
-	// Get TLS info if connected via IMAPS or STARTTLS
-	tlsVersion := ""
+	// Analyze the TLS connection if connected via IMAPS or STARTTLS
+	var tlsReport *commontls.Report
 	if state := client.GetTLSState(); state != nil {
-		if config.IMAPS {
-			tlsVersion = "IMAPS"
-		} else if config.StartTLS {
-			tlsVersion = "STARTTLS"
-		}
-		fmt.Printf("  TLS: %s\n", tlsVersion)
+		tlsReport = commontls.NewReport(state, config.Host, config.SkipVerify)
+		fmt.Printf("  TLS: %s\n", tlsReport.TLS.Version)
 	}
 
 	caps := client.GetCapabilities()
@@ -87,15 +95,38 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 		}
 	}
 
+	// Identify the server software from the greeting, capabilities and ID
+	evidence := fingerprint.Evidence{Protocol: fingerprint.ProtocolIMAP, Banner: client.GetGreeting()}
+	if caps != nil {
+		evidence.Capabilities = caps.All()
+		if caps.SupportsID() {
+			if id, err := client.ID(); err != nil {
+				logger.LogWarn(slogLogger, "ID command failed", "error", err)
+			} else if id != nil {
+				evidence.Implementation = strings.TrimSpace(id.Name + " " + id.Version)
+				if evidence.Implementation != "" {
+					fmt.Printf("  Server ID: %s\n", evidence.Implementation)
+				}
+			}
+		}
+	}
+	matches := registry.Identify(evidence)
+	fmt.Println()
+	fingerprint.Print(os.Stdout, matches)
+
+	if tlsReport != nil {
+		fmt.Println()
+		tlsReport.Print(os.Stdout)
+	}
+
 	logger.LogInfo(slogLogger, "Connection test successful",
 		"host", config.Host,
 		"port", config.Port,
 		"capabilities", capsStr)
 
-	if logErr := csvLogger.WriteRow([]string{
-		config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port),
-		"true", capsStr, tlsVersion, "",
-	}); logErr != nil {
+	row := []string{config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port), "true", capsStr, fingerprint.Best(matches)}
+	row = append(row, tlsReport.CSVFields()...)
+	if logErr := csvLogger.WriteRow(append(row, "")); logErr != nil {
 		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 	}
 
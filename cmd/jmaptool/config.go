@@ -7,6 +7,9 @@ import (
 	"strconv"
 	"strings"
 
+	"msgraphtool/internal/common/dualstack"
+	commontls "msgraphtool/internal/common/tls"
+	"msgraphtool/internal/common/validation"
 	"msgraphtool/internal/common/version"
 )
 
@@ -22,11 +25,22 @@ type Config struct {
 	// Action
 	Action string
 
+	// Network settings
+	IPVersion string // 4, 6 or both (empty: whichever address connects first)
+	SourceIP  string // Local address to connect from
+
 	// Authentication
 	AuthMethod string // auto, basic, bearer
 
 	// TLS settings
-	SkipVerify bool
+	SkipVerify     bool
+	CACert         string   // PEM bundle of trusted roots, replacing the system roots
+	ClientCert     string   // Client certificate for mutual TLS: PEM, or PKCS#12 (.pfx/.p12)
+	ClientKey      string   // PEM private key for ClientCert (not used with PKCS#12)
+	ClientCertPass string   // PKCS#12 password
+	PinSHA256      []string // Base64 SHA-256 SPKI pins; one must match the server chain
+	SaveChain      string   // Directory the presented certificate chain is saved to
+	Fingerprints   string   // JSON file with additional server fingerprint signatures
 
 	// Logging
 	VerboseMode bool
@@ -59,7 +73,17 @@ func parseAndConfigureFlags() *Config {
 	flag.StringVar(&config.Password, "password", "", "Password for authentication (env: JMAPPASSWORD)")
 	flag.StringVar(&config.AccessToken, "accesstoken", "", "Access token for Bearer authentication (env: JMAPACCESSTOKEN)")
 	flag.StringVar(&config.AuthMethod, "authmethod", "auto", "Authentication method: auto, basic, bearer (env: JMAPAUTHMETHOD)")
+	flag.StringVar(&config.IPVersion, "ipversion", "", "IP version: 4, 6, or both to test every address of the host (env: JMAPIPVERSION)")
+	flag.StringVar(&config.SourceIP, "sourceip", "", "Local IP address to connect from (env: JMAPSOURCEIP)")
 	flag.BoolVar(&config.SkipVerify, "skipverify", false, "Skip TLS certificate verification (env: JMAPSKIPVERIFY)")
+	flag.StringVar(&config.CACert, "cacert", "", "PEM bundle of trusted CA certificates, replacing the system roots (env: JMAPCACERT)")
+	flag.StringVar(&config.ClientCert, "clientcert", "", "Client certificate for mutual TLS: PEM, or PKCS#12 .pfx/.p12 (env: JMAPCLIENTCERT)")
+	flag.StringVar(&config.ClientKey, "clientkey", "", "PEM private key for -clientcert (env: JMAPCLIENTKEY)")
+	flag.StringVar(&config.ClientCertPass, "clientcertpass", "", "Password for a PKCS#12 -clientcert (env: JMAPCLIENTCERTPASS)")
+	var pinSHA256 string
+	flag.StringVar(&pinSHA256, "pinsha256", "", "Comma-separated base64 SHA-256 SPKI pins of the server or CA key (env: JMAPPINSHA256)")
+	flag.StringVar(&config.SaveChain, "savechain", "", "Directory to save the presented and verified certificate chains to as PEM/DER, with a JSON summary (env: JMAPSAVECHAIN)")
+	flag.StringVar(&config.Fingerprints, "fingerprints", "", "JSON file with additional server fingerprint signatures (env: JMAPFINGERPRINTS)")
 	flag.BoolVar(&config.VerboseMode, "verbose", false, "Enable verbose output (env: JMAPVERBOSE)")
 	flag.StringVar(&config.LogLevel, "loglevel", "info", "Log level: debug, info, warn, error (env: JMAPLOGLEVEL)")
 	flag.StringVar(&config.LogFormat, "logformat", "csv", "Log format: csv, json (env: JMAPLOGFORMAT)")
@@ -82,7 +106,14 @@ func parseAndConfigureFlags() *Config {
 		fmt.Fprintf(os.Stderr, "  JMAPPASSWORD    Password\n")
 		fmt.Fprintf(os.Stderr, "  JMAPACCESSTOKEN Access token\n")
 		fmt.Fprintf(os.Stderr, "  JMAPAUTHMETHOD  Authentication method\n")
+		fmt.Fprintf(os.Stderr, "  JMAPIPVERSION   IP version (4, 6 or both)\n")
+		fmt.Fprintf(os.Stderr, "  JMAPSOURCEIP    Local IP address to connect from\n")
 		fmt.Fprintf(os.Stderr, "  JMAPSKIPVERIFY  Skip TLS verification (true/false)\n")
+		fmt.Fprintf(os.Stderr, "  JMAPCACERT      CA bundle (PEM)\n")
+		fmt.Fprintf(os.Stderr, "  JMAPCLIENTCERT  Client certificate (PEM or PKCS#12)\n")
+		fmt.Fprintf(os.Stderr, "  JMAPCLIENTKEY   Client private key (PEM)\n")
+		fmt.Fprintf(os.Stderr, "  JMAPCLIENTCERTPASS PKCS#12 password\n")
+		fmt.Fprintf(os.Stderr, "  JMAPPINSHA256   SPKI pins (comma-separated)\n")
 		fmt.Fprintf(os.Stderr, "  JMAPVERBOSE     Verbose output (true/false)\n")
 		fmt.Fprintf(os.Stderr, "  JMAPLOGLEVEL    Log level\n")
 		fmt.Fprintf(os.Stderr, "  JMAPLOGFORMAT   Log format\n")
@@ -91,6 +122,7 @@ func parseAndConfigureFlags() *Config {
 		fmt.Fprintf(os.Stderr, "  jmaptool -action testconnect -host jmap.fastmail.com\n")
 		fmt.Fprintf(os.Stderr, "  jmaptool -action testauth -host jmap.fastmail.com -username user@example.com -accesstoken \"token\"\n")
 		fmt.Fprintf(os.Stderr, "  jmaptool -action getmailboxes -host jmap.fastmail.com -username user@example.com -accesstoken \"token\"\n")
+		fmt.Fprintf(os.Stderr, "  jmaptool -action testconnect -host jmap.fastmail.com -ipversion both\n")
 	}
 
 	flag.Parse()
@@ -135,11 +167,59 @@ func parseAndConfigureFlags() *Config {
 			config.AuthMethod = envAuthMethod
 		}
 	}
+	if !providedFlags["ipversion"] {
+		if envIPVersion := os.Getenv("JMAPIPVERSION"); envIPVersion != "" {
+			config.IPVersion = envIPVersion
+		}
+	}
+	if !providedFlags["sourceip"] {
+		if envSourceIP := os.Getenv("JMAPSOURCEIP"); envSourceIP != "" {
+			config.SourceIP = envSourceIP
+		}
+	}
 	if !providedFlags["skipverify"] {
 		if envSkipVerify := os.Getenv("JMAPSKIPVERIFY"); envSkipVerify != "" {
 			config.SkipVerify = strings.EqualFold(envSkipVerify, "true") || envSkipVerify == "1"
 		}
 	}
+	if !providedFlags["cacert"] {
+		if envCACert := os.Getenv("JMAPCACERT"); envCACert != "" {
+			config.CACert = envCACert
+		}
+	}
+	if !providedFlags["clientcert"] {
+		if envClientCert := os.Getenv("JMAPCLIENTCERT"); envClientCert != "" {
+			config.ClientCert = envClientCert
+		}
+	}
+	if !providedFlags["clientkey"] {
+		if envClientKey := os.Getenv("JMAPCLIENTKEY"); envClientKey != "" {
+			config.ClientKey = envClientKey
+		}
+	}
+	if !providedFlags["clientcertpass"] {
+		if envClientCertPass := os.Getenv("JMAPCLIENTCERTPASS"); envClientCertPass != "" {
+			config.ClientCertPass = envClientCertPass
+		}
+	}
+	if !providedFlags["pinsha256"] {
+		if envPins := os.Getenv("JMAPPINSHA256"); envPins != "" {
+			pinSHA256 = envPins
+		}
+	}
+	if pinSHA256 != "" {
+		config.PinSHA256 = strings.Split(pinSHA256, ",")
+	}
+	if !providedFlags["savechain"] {
+		if envSaveChain := os.Getenv("JMAPSAVECHAIN"); envSaveChain != "" {
+			config.SaveChain = envSaveChain
+		}
+	}
+	if !providedFlags["fingerprints"] {
+		if envFingerprints := os.Getenv("JMAPFINGERPRINTS"); envFingerprints != "" {
+			config.Fingerprints = envFingerprints
+		}
+	}
 	if !providedFlags["verbose"] {
 		if envVerbose := os.Getenv("JMAPVERBOSE"); envVerbose != "" {
 			config.VerboseMode = strings.EqualFold(envVerbose, "true") || envVerbose == "1"
@@ -164,6 +244,17 @@ func parseAndConfigureFlags() *Config {
 	return config
 }
 
+// TrustOptions returns the CA bundle, client certificate and pinning settings.
+func (c *Config) TrustOptions() *commontls.TrustOptions {
+	return &commontls.TrustOptions{
+		CAFile:             c.CACert,
+		ClientCertFile:     c.ClientCert,
+		ClientKeyFile:      c.ClientKey,
+		ClientCertPassword: c.ClientCertPass,
+		Pins:               c.PinSHA256,
+	}
+}
+
 // validateConfiguration validates the configuration.
 func validateConfiguration(config *Config) error {
 	// Validate action
@@ -189,6 +280,22 @@ func validateConfiguration(config *Config) error {
 		return fmt.Errorf("invalid port: %d (must be 1-65535)", config.Port)
 	}
 
+	// Validate IP version and source address
+	if err := dualstack.Validate(config.IPVersion, config.SourceIP); err != nil {
+		return err
+	}
+
+	// Validate CA bundle, client certificate and pins (if provided)
+	if err := config.TrustOptions().Validate(); err != nil {
+		return fmt.Errorf("invalid TLS trust settings: %w", err)
+	}
+	if err := validation.ValidateDirPath(config.SaveChain, "chain directory"); err != nil {
+		return fmt.Errorf("invalid -savechain: %w", err)
+	}
+	if err := validation.ValidateFilePath(config.Fingerprints, "fingerprint signatures"); err != nil {
+		return fmt.Errorf("invalid -fingerprints: %w", err)
+	}
+
 	// Validate auth method
 	config.AuthMethod = strings.ToLower(config.AuthMethod)
 	validAuthMethods := map[string]bool{
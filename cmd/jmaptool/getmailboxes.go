@@ -23,7 +23,10 @@ func getMailboxes(ctx context.Context, config *Config, csvLogger logger.Logger,
 		}
 	}
 
-	client := NewJMAPClient(config)
+	client, err := NewJMAPClient(config)
+	if err != nil {
+		return fmt.Errorf("failed to create JMAP client: %w", err)
+	}
 
 	// First discover the session
 	session, err := client.Discover(ctx)
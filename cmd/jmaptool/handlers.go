@@ -4,12 +4,78 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
+	"msgraphtool/internal/common/dualstack"
 	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/common/timing"
 )
 
-// executeAction dispatches to the appropriate handler based on action.
+// executeAction runs the action, once per address of the discovery host with
+// -ipversion both.
 func executeAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	if config.IPVersion == dualstack.Both {
+		return executeEachAddress(ctx, config, csvLogger, slogLogger)
+	}
+	return runTimedAction(ctx, config, withAddress(csvLogger, ""), slogLogger)
+}
+
+// withAddress adds the IP_Address column before Error, naming address. Rows
+// of a run without -ipversion both leave it empty, so that runs with and
+// without it can share the action's daily log file.
+func withAddress(csvLogger logger.Logger, address string) logger.Logger {
+	return logger.WithColumns(csvLogger, []string{"IP_Address"}, func() []string { return []string{address} })
+}
+
+// executeEachAddress runs the action against every IPv4 and IPv6 address of
+// the discovery host in turn and prints the outcome per address. Each row
+// names the address in an IP_Address column before Error. An API host that
+// differs from the discovery host is connected to as usual.
+func executeEachAddress(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	u, err := url.Parse((&JMAPClient{config: config}).GetDiscoveryURL())
+	if err != nil {
+		return fmt.Errorf("invalid discovery URL: %w", err)
+	}
+	host := u.Hostname()
+
+	lookupCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	addresses, err := dualstack.Lookup(lookupCtx, nil, host, dualstack.Both)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	fmt.Printf("Testing %d address(es) of %s: %s\n", len(addresses), host, strings.Join(addresses, ", "))
+
+	results := dualstack.ForEach(ctx, os.Stdout, host, addresses, func(ctx context.Context, address string) error {
+		return runTimedAction(ctx, config, withAddress(csvLogger, address), slogLogger)
+	})
+	dualstack.Print(os.Stdout, host, results)
+	for _, r := range results {
+		if r.Err != nil {
+			logger.LogWarn(slogLogger, "Address failed", "host", host, "address", r.Address, "error", r.Err)
+		}
+	}
+	return dualstack.Err(host, results)
+}
+
+// runTimedAction runs the action and breaks its duration down into phases:
+// printed after the action, and added to each CSV/JSON row as the
+// DNS_ms ... Transaction_ms columns before Error.
+func runTimedAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	rec := &timing.Recorder{}
+	err := runAction(timing.WithRecorder(ctx, rec), config, logger.WithColumns(csvLogger, timing.Columns(), rec.Values), slogLogger)
+	rec.Print(os.Stdout)
+	if !rec.Empty() {
+		logger.LogInfo(slogLogger, "Connection timing", "phases", rec.String())
+	}
+	return err
+}
+
+// runAction dispatches to the appropriate handler based on action.
+func runAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
 	switch config.Action {
 	case "testconnect":
 		return testConnect(ctx, config, csvLogger, slogLogger)
@@ -7,10 +7,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"os"
 	"strings"
 	"time"
 
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/timing"
+	commontls "msgraphtool/internal/common/tls"
 	"msgraphtool/internal/jmap/protocol"
 )
 
@@ -19,14 +25,33 @@ type JMAPClient struct {
 	config     *Config
 	httpClient *http.Client
 	session    *protocol.Session
+	tlsState   *tls.ConnectionState    // TLS state of the discovery request
+	server     string                  // Server header of the discovery response
+	chain      *commontls.ChainCapture // Records the presented chain for -savechain
 }
 
-// NewJMAPClient creates a new JMAP client.
-func NewJMAPClient(config *Config) *JMAPClient {
+// NewJMAPClient creates a new JMAP client. It fails if the CA bundle or
+// client certificate cannot be loaded.
+func NewJMAPClient(config *Config) (*JMAPClient, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.SkipVerify,
+	}
+	if err := config.TrustOptions().Apply(tlsConfig); err != nil {
+		return nil, err
+	}
+	var chain *commontls.ChainCapture
+	if config.SaveChain != "" {
+		chain = &commontls.ChainCapture{}
+		chain.Apply(tlsConfig)
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		LocalAddr: dualstack.LocalAddr(config.SourceIP),
+	}
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: config.SkipVerify,
-		},
+		DialContext:     dualstack.Wrap(dialer, config.IPVersion).DialContext,
+		TLSClientConfig: tlsConfig,
 	}
 
 	return &JMAPClient{
@@ -35,7 +60,8 @@ func NewJMAPClient(config *Config) *JMAPClient {
 			Transport: transport,
 			Timeout:   30 * time.Second,
 		},
-	}
+		chain: chain,
+	}, nil
 }
 
 // GetDiscoveryURL returns the JMAP discovery URL.
@@ -51,9 +77,27 @@ func (c *JMAPClient) GetDiscoveryURL() string {
 	return protocol.DiscoveryURL(host)
 }
 
-// Discover fetches the JMAP session from the well-known URL.
+// traced returns ctx with the httptrace hooks of its timing.Recorder, so that
+// new connections record their DNS lookup, TCP connect and TLS handshake.
+func traced(ctx context.Context) context.Context {
+	if rec := timing.FromContext(ctx); rec != nil {
+		return httptrace.WithClientTrace(ctx, rec.ClientTrace())
+	}
+	return ctx
+}
+
+// Discover fetches the JMAP session from the well-known URL. In the
+// context's timing.Recorder, the time from the connection to the response
+// headers counts as the greeting and reading the session as capabilities;
+// the credentials travel with the request, so there is no separate
+// authentication phase.
 func (c *JMAPClient) Discover(ctx context.Context) (*protocol.Session, error) {
 	url := c.GetDiscoveryURL()
+	rec := timing.FromContext(ctx)
+	var connected time.Time
+	ctx = httptrace.WithClientTrace(traced(ctx), &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { connected = time.Now() },
+	})
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -64,17 +108,29 @@ func (c *JMAPClient) Discover(ctx context.Context) (*protocol.Session, error) {
 	c.addAuth(req)
 
 	resp, err := c.httpClient.Do(req)
+	if err == nil && !connected.IsZero() {
+		rec.Since(timing.Greeting, connected)
+	}
+	if c.chain != nil {
+		// Saved whether or not the handshake succeeded
+		c.chain.SaveAndPrint(os.Stdout, c.config.SaveChain, fmt.Sprintf("%s_%d", c.config.Host, c.config.Port))
+		c.chain = nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch session: %w", err)
 	}
 	defer resp.Body.Close()
+	c.tlsState = resp.TLS
+	c.server = resp.Header.Get("Server")
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("discovery failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
+	start := time.Now()
 	data, err := io.ReadAll(resp.Body)
+	rec.Since(timing.Capabilities, start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
@@ -117,6 +173,17 @@ func (c *JMAPClient) addAuth(req *http.Request) {
 	}
 }
 
+// GetTLSState returns the TLS connection state of the discovery request
+// (nil if it was not made over HTTPS).
+func (c *JMAPClient) GetTLSState() *tls.ConnectionState {
+	return c.tlsState
+}
+
+// GetServerHeader returns the Server header of the discovery response.
+func (c *JMAPClient) GetServerHeader() string {
+	return c.server
+}
+
 // GetSession returns the discovered session.
 func (c *JMAPClient) GetSession() *protocol.Session {
 	return c.session
@@ -183,12 +250,24 @@ func (c *JMAPClient) GetMailboxes(ctx context.Context) ([]protocol.Mailbox, erro
 	return mailboxes, nil
 }
 
-// makeAPIRequest sends a JMAP request to the API endpoint.
+// makeAPIRequest sends a JMAP request to the API endpoint. The request is
+// recorded as a transaction step named after its method calls.
 func (c *JMAPClient) makeAPIRequest(ctx context.Context, request protocol.Request) (*protocol.Response, error) {
 	if c.session == nil {
 		return nil, fmt.Errorf("no session available")
 	}
 
+	var methods []string
+	for _, call := range request.MethodCalls {
+		methods = append(methods, call.Name)
+	}
+	defer func(rec *timing.Recorder, start time.Time) {
+		d := time.Since(start)
+		rec.Add(timing.Transaction, d)
+		rec.Step(strings.Join(methods, ", "), d)
+	}(timing.FromContext(ctx), time.Now())
+	ctx = traced(ctx)
+
 	body, err := json.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -23,7 +23,10 @@ func testAuth(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 		}
 	}
 
-	client := NewJMAPClient(config)
+	client, err := NewJMAPClient(config)
+	if err != nil {
+		return fmt.Errorf("failed to create JMAP client: %w", err)
+	}
 	authMethod := client.GetAuthMethod()
 
 	fmt.Printf("Username: %s\n", config.Username)
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"os"
 	"strings"
 
+	"msgraphtool/internal/common/fingerprint"
 	"msgraphtool/internal/common/logger"
+	commontls "msgraphtool/internal/common/tls"
 	"msgraphtool/internal/jmap/protocol"
 )
 
@@ -16,15 +20,25 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 	fmt.Printf("Testing JMAP connectivity to %s...\n", config.Host)
 	fmt.Printf("Discovery URL: %s\n", discoveryURL)
 
+	registry, err := fingerprint.LoadRegistry(config.Fingerprints)
+	if err != nil {
+		return err
+	}
+
 	// CSV columns for testconnect
-	columns := []string{"Action", "Status", "Server", "Port", "Discovery_URL", "API_URL", "Capabilities", "Accounts", "Error"}
+	columns := []string{"Action", "Status", "Server", "Port", "Discovery_URL", "API_URL", "Capabilities", "Accounts", "Fingerprint"}
+	columns = append(columns, commontls.CSVColumns...)
+	columns = append(columns, "Error")
 	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
 		if err := csvLogger.WriteHeader(columns); err != nil {
 			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
 		}
 	}
 
-	client := NewJMAPClient(config)
+	client, err := NewJMAPClient(config)
+	if err != nil {
+		return fmt.Errorf("failed to create JMAP client: %w", err)
+	}
 
 	// Try to discover the session (without auth for connectivity test)
 	session, err := client.Discover(ctx)
@@ -33,10 +47,9 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 			"error", err,
 			"host", config.Host)
 
-		if logErr := csvLogger.WriteRow([]string{
-			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			discoveryURL, "", "", "", err.Error(),
-		}); logErr != nil {
+		row := []string{config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port), discoveryURL, "", "", "", ""}
+		row = append(row, make([]string, len(commontls.CSVColumns))...)
+		if logErr := csvLogger.WriteRow(append(row, err.Error())); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
 		return fmt.Errorf("JMAP discovery failed: %w", err)
@@ -63,12 +76,36 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 		}
 	}
 
+	// Identify the server software from the session and Server header
+	matches := registry.Identify(fingerprint.Evidence{
+		Protocol:     fingerprint.ProtocolJMAP,
+		Banner:       client.GetServerHeader(),
+		Capabilities: caps,
+		URL:          session.APIURL,
+	})
+	fmt.Println()
+	fingerprint.Print(os.Stdout, matches)
+
+	// Analyze the HTTPS connection used for discovery
+	var tlsReport *commontls.Report
+	if state := client.GetTLSState(); state != nil {
+		hostname := config.Host
+		if u, err := url.Parse(client.GetDiscoveryURL()); err == nil {
+			hostname = u.Hostname()
+		}
+		tlsReport = commontls.NewReport(state, hostname, config.SkipVerify)
+		fmt.Println()
+		tlsReport.Print(os.Stdout)
+	}
+
 	// Log success to CSV
-	if logErr := csvLogger.WriteRow([]string{
+	row := []string{
 		config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port),
 		discoveryURL, session.APIURL, strings.Join(caps, "; "),
-		fmt.Sprintf("%d", session.GetAccountCount()), "",
-	}); logErr != nil {
+		fmt.Sprintf("%d", session.GetAccountCount()), fingerprint.Best(matches),
+	}
+	row = append(row, tlsReport.CSVFields()...)
+	if logErr := csvLogger.WriteRow(append(row, "")); logErr != nil {
 		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 	}
 
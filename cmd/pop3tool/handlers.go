@@ -4,12 +4,96 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
+	"strings"
+	"time"
 
+	"msgraphtool/internal/common/dualstack"
 	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/common/retry"
+	"msgraphtool/internal/common/timing"
 )
 
-// executeAction dispatches to the appropriate action handler.
+// executeAction runs the action, once per address of -host with
+// -ipversion both.
 func executeAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	if config.IPVersion == dualstack.Both {
+		return executeEachAddress(ctx, config, csvLogger, slogLogger)
+	}
+	return executeWithRetry(ctx, config, withAddress(csvLogger, ""), slogLogger)
+}
+
+// withAddress adds the IP_Address column before Error, naming address. Rows
+// of a run without -ipversion both leave it empty, so that runs with and
+// without it can share the action's daily log file.
+func withAddress(csvLogger logger.Logger, address string) logger.Logger {
+	return logger.WithColumns(csvLogger, []string{"IP_Address"}, func() []string { return []string{address} })
+}
+
+// executeEachAddress runs the action against every IPv4 and IPv6 address of
+// -host in turn and prints the outcome per address. Each row names the
+// address in an IP_Address column before Error.
+func executeEachAddress(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	lookupCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	addresses, err := dualstack.Lookup(lookupCtx, nil, config.Host, dualstack.Both)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", config.Host, err)
+	}
+	fmt.Printf("Testing %d address(es) of %s: %s\n", len(addresses), config.Host, strings.Join(addresses, ", "))
+
+	results := dualstack.ForEach(ctx, os.Stdout, config.Host, addresses, func(ctx context.Context, address string) error {
+		return executeWithRetry(ctx, config, withAddress(csvLogger, address), slogLogger)
+	})
+	dualstack.Print(os.Stdout, config.Host, results)
+	for _, r := range results {
+		if r.Err != nil {
+			logger.LogWarn(slogLogger, "Address failed", "host", config.Host, "address", r.Address, "error", r.Err)
+		}
+	}
+	return dualstack.Err(config.Host, results)
+}
+
+// executeWithRetry runs the action, retrying transient failures up to
+// -maxretries times with backoff: refused or dropped connections, and -ERR
+// replies with a [SYS/TEMP], [IN-USE] or [LOGIN-DELAY] response code. Every
+// action is read-only, so each is safe to repeat; each attempt writes its own
+// CSV row.
+func executeWithRetry(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	if config.MaxRetries <= 0 {
+		return runTimedAction(ctx, config, csvLogger, slogLogger)
+	}
+
+	attempts := config.MaxRetries + 1
+	return retry.Do(ctx, retry.Policy{
+		MaxRetries: config.MaxRetries,
+		BaseDelay:  config.RetryDelay,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			fmt.Printf("\n⟳ Attempt %d/%d failed with a transient error: %v\n  Retrying in %v...\n\n",
+				attempt, attempts, err, delay.Round(time.Millisecond))
+			logger.LogWarn(slogLogger, "Transient failure, retrying",
+				"attempt", attempt, "attempts", attempts, "delay", delay, "error", err)
+		},
+	}, func(attempt int) error {
+		return runTimedAction(ctx, config, logger.WithAttempt(csvLogger, attempt, attempts), slogLogger)
+	})
+}
+
+// runTimedAction runs the action and breaks its duration down into phases:
+// printed after the action, and added to each CSV/JSON row as the
+// DNS_ms ... Transaction_ms columns before Error.
+func runTimedAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	rec := &timing.Recorder{}
+	err := runAction(timing.WithRecorder(ctx, rec), config, logger.WithColumns(csvLogger, timing.Columns(), rec.Values), slogLogger)
+	rec.Print(os.Stdout)
+	if !rec.Empty() {
+		logger.LogInfo(slogLogger, "Connection timing", "phases", rec.String())
+	}
+	return err
+}
+
+// runAction dispatches to the appropriate action handler.
+func runAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
 	switch config.Action {
 	case ActionTestConnect:
 		return testConnect(ctx, config, csvLogger, slogLogger)
@@ -8,10 +8,15 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 	"time"
 
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/proxy"
 	"msgraphtool/internal/common/ratelimit"
+	"msgraphtool/internal/common/timing"
+	commontls "msgraphtool/internal/common/tls"
 	"msgraphtool/internal/pop3/protocol"
 )
 
@@ -26,6 +31,7 @@ type POP3Client struct {
 	caps     *protocol.Capabilities
 	limiter  *ratelimit.Limiter
 	tlsState *tls.ConnectionState
+	rec      *timing.Recorder // Phase timings from the Connect context (nil when not timed)
 }
 
 // NewPOP3Client creates a new POP3 client.
@@ -43,7 +49,8 @@ func NewPOP3Client(config *Config) *POP3Client {
 	}
 }
 
-// Connect establishes a connection to the POP3 server.
+// Connect establishes a connection to the POP3 server. The phases of the
+// session are recorded in the context's timing.Recorder.
 func (c *POP3Client) Connect(ctx context.Context) error {
 	if c.limiter != nil {
 		if err := c.limiter.Wait(ctx); err != nil {
@@ -52,33 +59,39 @@ func (c *POP3Client) Connect(ctx context.Context) error {
 	}
 
 	address := fmt.Sprintf("%s:%d", c.host, c.port)
+	c.rec = timing.FromContext(ctx)
 
 	var conn net.Conn
 	var err error
 
-	dialer := &net.Dialer{
-		Timeout: c.config.Timeout,
+	dialer, err := proxy.NewDialer(c.config.ProxyURL, &net.Dialer{
+		Timeout:   c.config.Timeout,
+		LocalAddr: dualstack.LocalAddr(c.config.SourceIP),
+	})
+	if err != nil {
+		return err
 	}
+	dialer = dualstack.Wrap(dialer, c.config.IPVersion)
 
 	if c.config.POP3S {
 		// Implicit TLS (POP3S)
-		tlsConfig := &tls.Config{
-			ServerName:         c.host,
-			InsecureSkipVerify: c.config.SkipVerify,
-			MinVersion:         parseTLSVersion(c.config.TLSVersion),
+		tlsConfig, err := c.newTLSConfig()
+		if err != nil {
+			return err
 		}
-		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+		capture := c.captureChain(tlsConfig)
+		tlsConn, err := proxy.DialTLS(ctx, dialer, "tcp", address, tlsConfig, c.config.Timeout)
+		c.saveChain(capture)
 		if err != nil {
 			return fmt.Errorf("POP3S connection failed: %w", err)
 		}
 		// Store TLS state
-		if tlsConn, ok := conn.(*tls.Conn); ok {
-			state := tlsConn.ConnectionState()
-			c.tlsState = &state
-		}
+		state := tlsConn.ConnectionState()
+		c.tlsState = &state
+		conn = tlsConn
 	} else {
 		// Plain connection
-		conn, err = dialer.DialContext(ctx, "tcp", address)
+		conn, err = timing.Dial(ctx, dialer, "tcp", address)
 		if err != nil {
 			return fmt.Errorf("connection failed: %w", err)
 		}
@@ -88,7 +101,9 @@ func (c *POP3Client) Connect(ctx context.Context) error {
 	c.reader = bufio.NewReader(conn)
 
 	// Read server greeting
+	start := time.Now()
 	resp, err := protocol.ReadResponse(c.reader)
+	c.rec.Since(timing.Greeting, start)
 	if err != nil {
 		c.conn.Close()
 		return fmt.Errorf("failed to read greeting: %w", err)
@@ -102,6 +117,39 @@ func (c *POP3Client) Connect(ctx context.Context) error {
 	return nil
 }
 
+// newTLSConfig builds the TLS configuration for POP3S and STLS, including
+// the CA bundle, client certificate and SPKI pins.
+func (c *POP3Client) newTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.host,
+		InsecureSkipVerify: c.config.SkipVerify,
+		MinVersion:         commontls.ParseTLSVersion(c.config.TLSVersion),
+	}
+	if err := c.config.TrustOptions().Apply(tlsConfig); err != nil {
+		return nil, err
+	}
+	return tlsConfig, nil
+}
+
+// captureChain makes tlsConfig record the presented chain when -savechain
+// is set; it returns nil otherwise.
+func (c *POP3Client) captureChain(tlsConfig *tls.Config) *commontls.ChainCapture {
+	if c.config.SaveChain == "" {
+		return nil
+	}
+	capture := &commontls.ChainCapture{}
+	capture.Apply(tlsConfig)
+	return capture
+}
+
+// saveChain saves a chain recorded by captureChain, whether or not the
+// handshake succeeded.
+func (c *POP3Client) saveChain(capture *commontls.ChainCapture) {
+	if capture != nil {
+		capture.SaveAndPrint(os.Stdout, c.config.SaveChain, fmt.Sprintf("%s_%d", c.host, c.port))
+	}
+}
+
 // GetGreeting returns the server greeting.
 func (c *POP3Client) GetGreeting() string {
 	return c.greeting
@@ -121,7 +169,15 @@ func (c *POP3Client) StartTLS(tlsConfig *tls.Config) error {
 		}
 	}
 
+	if tlsConfig == nil {
+		var err error
+		if tlsConfig, err = c.newTLSConfig(); err != nil {
+			return err
+		}
+	}
+
 	// Send STLS command
+	defer c.rec.Since(timing.TLS, time.Now())
 	if _, err := c.conn.Write([]byte(protocol.STLS())); err != nil {
 		return fmt.Errorf("failed to send STLS: %w", err)
 	}
@@ -131,20 +187,15 @@ func (c *POP3Client) StartTLS(tlsConfig *tls.Config) error {
 		return fmt.Errorf("failed to read STLS response: %w", err)
 	}
 	if !resp.Success {
-		return fmt.Errorf("STLS failed: %s", resp.Message)
+		return &protocol.ResponseError{Command: "STLS", Message: resp.Message}
 	}
 
 	// Upgrade to TLS
-	if tlsConfig == nil {
-		tlsConfig = &tls.Config{
-			ServerName:         c.host,
-			InsecureSkipVerify: c.config.SkipVerify,
-			MinVersion:         parseTLSVersion(c.config.TLSVersion),
-		}
-	}
-
+	capture := c.captureChain(tlsConfig)
 	tlsConn := tls.Client(c.conn, tlsConfig)
-	if err := tlsConn.Handshake(); err != nil {
+	err = tlsConn.Handshake()
+	c.saveChain(capture)
+	if err != nil {
 		return fmt.Errorf("TLS handshake failed: %w", err)
 	}
 
@@ -164,6 +215,7 @@ func (c *POP3Client) Capabilities(ctx context.Context) (*protocol.Capabilities,
 		}
 	}
 
+	defer c.rec.Since(timing.Capabilities, time.Now())
 	if _, err := c.conn.Write([]byte(protocol.CAPA())); err != nil {
 		return nil, fmt.Errorf("failed to send CAPA: %w", err)
 	}
@@ -195,6 +247,8 @@ func (c *POP3Client) Auth(ctx context.Context, username, password, accessToken s
 		}
 	}
 
+	defer c.rec.Since(timing.Auth, time.Now())
+
 	method := c.config.AuthMethod
 
 	// Auto-select auth method
@@ -232,7 +286,7 @@ func (c *POP3Client) authUSER(username, password string) error {
 		return fmt.Errorf("failed to read USER response: %w", err)
 	}
 	if !resp.Success {
-		return fmt.Errorf("USER failed: %s", resp.Message)
+		return &protocol.ResponseError{Command: "USER", Message: resp.Message}
 	}
 
 	// Send PASS command
@@ -245,7 +299,7 @@ func (c *POP3Client) authUSER(username, password string) error {
 		return fmt.Errorf("failed to read PASS response: %w", err)
 	}
 	if !resp.Success {
-		return fmt.Errorf("PASS failed: %s", resp.Message)
+		return &protocol.ResponseError{Command: "PASS", Message: resp.Message}
 	}
 
 	return nil
@@ -272,7 +326,7 @@ func (c *POP3Client) authAPOP(username, password string) error {
 		return fmt.Errorf("failed to read APOP response: %w", err)
 	}
 	if !resp.Success {
-		return fmt.Errorf("APOP failed: %s", resp.Message)
+		return &protocol.ResponseError{Command: "APOP", Message: resp.Message}
 	}
 
 	return nil
@@ -294,7 +348,7 @@ func (c *POP3Client) authXOAUTH2(username, accessToken string) error {
 		return fmt.Errorf("failed to read AUTH response: %w", err)
 	}
 	if !resp.Success {
-		return fmt.Errorf("XOAUTH2 authentication failed: %s", resp.Message)
+		return &protocol.ResponseError{Command: "XOAUTH2 authentication", Message: resp.Message}
 	}
 
 	return nil
@@ -308,6 +362,7 @@ func (c *POP3Client) Stat(ctx context.Context) (count int, size int64, err error
 		}
 	}
 
+	defer c.step("STAT", time.Now())
 	if _, err := c.conn.Write([]byte(protocol.STAT())); err != nil {
 		return 0, 0, fmt.Errorf("failed to send STAT: %w", err)
 	}
@@ -328,6 +383,7 @@ func (c *POP3Client) List(ctx context.Context) ([]protocol.MessageInfo, error) {
 		}
 	}
 
+	defer c.step("LIST", time.Now())
 	if _, err := c.conn.Write([]byte(protocol.LIST(0))); err != nil {
 		return nil, fmt.Errorf("failed to send LIST: %w", err)
 	}
@@ -348,6 +404,7 @@ func (c *POP3Client) UIDL(ctx context.Context) ([]protocol.MessageInfo, error) {
 		}
 	}
 
+	defer c.step("UIDL", time.Now())
 	if _, err := c.conn.Write([]byte(protocol.UIDL(0))); err != nil {
 		return nil, fmt.Errorf("failed to send UIDL: %w", err)
 	}
@@ -360,6 +417,13 @@ func (c *POP3Client) UIDL(ctx context.Context) ([]protocol.MessageInfo, error) {
 	return protocol.ParseUIDLResponse(resp)
 }
 
+// step records a command of the action as a transaction step.
+func (c *POP3Client) step(command string, start time.Time) {
+	d := time.Since(start)
+	c.rec.Add(timing.Transaction, d)
+	c.rec.Step(command, d)
+}
+
 // Quit sends the QUIT command and closes the connection.
 func (c *POP3Client) Quit() error {
 	if c.conn == nil {
@@ -383,19 +447,3 @@ func (c *POP3Client) Close() error {
 	}
 	return nil
 }
-
-// parseTLSVersion parses a TLS version string to a constant.
-func parseTLSVersion(version string) uint16 {
-	switch version {
-	case "1.3":
-		return tls.VersionTLS13
-	case "1.2":
-		return tls.VersionTLS12
-	case "1.1":
-		return tls.VersionTLS11
-	case "1.0":
-		return tls.VersionTLS10
-	default:
-		return tls.VersionTLS12
-	}
-}
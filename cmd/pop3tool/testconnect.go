@@ -4,17 +4,27 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
 	"strings"
 
+	"msgraphtool/internal/common/fingerprint"
 	"msgraphtool/internal/common/logger"
+	commontls "msgraphtool/internal/common/tls"
 )
 
 // testConnect tests basic POP3 connectivity.
 func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
 	fmt.Printf("Testing POP3 connection to %s:%d...\n", config.Host, config.Port)
 
+	registry, err := fingerprint.LoadRegistry(config.Fingerprints)
+	if err != nil {
+		return err
+	}
+
 	// CSV columns for testconnect
-	columns := []string{"Action", "Status", "Server", "Port", "Connected", "Greeting", "Capabilities", "TLS_Version", "Error"}
+	columns := []string{"Action", "Status", "Server", "Port", "Connected", "Greeting", "Capabilities", "Fingerprint"}
+	columns = append(columns, commontls.CSVColumns...)
+	columns = append(columns, "Error")
 	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
 		if err := csvLogger.WriteHeader(columns); err != nil {
 			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
@@ -30,10 +40,9 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 			"host", config.Host,
 			"port", config.Port)
 
-		if logErr := csvLogger.WriteRow([]string{
-			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			"false", "", "", "", err.Error(),
-		}); logErr != nil {
+		row := []string{config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port), "false", "", "", ""}
+		row = append(row, make([]string, len(commontls.CSVColumns))...)
+		if logErr := csvLogger.WriteRow(append(row, err.Error())); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
 		return fmt.Errorf("connection failed: %w", err)
@@ -44,10 +53,8 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 	fmt.Printf("  Greeting: %s\n", client.GetGreeting())
 
 	// Get TLS info if connected via POP3S
-	tlsVersion := ""
 	if state := client.GetTLSState(); state != nil {
-		tlsVersion = getTLSVersionString(state.Version)
-		fmt.Printf("  TLS: %s\n", tlsVersion)
+		fmt.Printf("  TLS: %s\n", commontls.TLSVersionString(state.Version))
 	}
 
 	// Try STLS if not already using TLS and STARTTLS is requested
@@ -58,8 +65,7 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 			fmt.Printf("  ✗ STLS failed: %v\n", err)
 		} else {
 			if state := client.GetTLSState(); state != nil {
-				tlsVersion = getTLSVersionString(state.Version)
-				fmt.Printf("  ✓ STLS upgrade successful (TLS %s)\n", tlsVersion)
+				fmt.Printf("  ✓ STLS upgrade successful (%s)\n", commontls.TLSVersionString(state.Version))
 			}
 		}
 	}
@@ -95,35 +101,36 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 		}
 	}
 
+	// Identify the server software
+	evidence := fingerprint.Evidence{Protocol: fingerprint.ProtocolPOP3, Banner: client.GetGreeting()}
+	if caps != nil {
+		evidence.Capabilities = caps.Raw()
+		evidence.Implementation = caps.GetImplementation()
+	}
+	matches := registry.Identify(evidence)
+	fmt.Println()
+	fingerprint.Print(os.Stdout, matches)
+
+	// Analyze the TLS connection (POP3S or after STLS)
+	var tlsReport *commontls.Report
+	if state := client.GetTLSState(); state != nil {
+		tlsReport = commontls.NewReport(state, config.Host, config.SkipVerify)
+		fmt.Println()
+		tlsReport.Print(os.Stdout)
+	}
+
 	logger.LogInfo(slogLogger, "Connection test successful",
 		"host", config.Host,
 		"port", config.Port,
 		"greeting", client.GetGreeting(),
 		"capabilities", capsStr)
 
-	if logErr := csvLogger.WriteRow([]string{
-		config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port),
-		"true", client.GetGreeting(), capsStr, tlsVersion, "",
-	}); logErr != nil {
+	row := []string{config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port), "true", client.GetGreeting(), capsStr, fingerprint.Best(matches)}
+	row = append(row, tlsReport.CSVFields()...)
+	if logErr := csvLogger.WriteRow(append(row, "")); logErr != nil {
 		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 	}
 
 	fmt.Println("\n✓ Connection test successful")
 	return nil
 }
-
-// getTLSVersionString converts TLS version constant to string.
-func getTLSVersionString(version uint16) string {
-	switch version {
-	case 0x0304:
-		return "1.3"
-	case 0x0303:
-		return "1.2"
-	case 0x0302:
-		return "1.1"
-	case 0x0301:
-		return "1.0"
-	default:
-		return fmt.Sprintf("unknown (0x%04x)", version)
-	}
-}
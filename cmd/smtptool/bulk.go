@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/template"
+
+	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/common/validation"
+	"msgraphtool/internal/smtp/protocol"
+)
+
+// bulkMessage is one personalized message of a -bulk batch.
+type bulkMessage struct {
+	Line     int // Line in the CSV file, for error messages
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// prepareBulkMessages reads the -bulk recipients and renders the subject and
+// body templates for each of them. The whole batch is rendered before
+// anything is sent, so a template error cannot leave a batch half delivered.
+func prepareBulkMessages(config *Config) ([]*bulkMessage, error) {
+	rows, err := loadBulkRecipients(config.Bulk)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyText := config.Body
+	if config.Template != "" {
+		data, err := os.ReadFile(config.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		bodyText = string(data)
+	}
+	subject, err := parseBulkTemplate("subject", config.Subject)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parseBulkTemplate("body", bodyText)
+	if err != nil {
+		return nil, err
+	}
+	html, err := parseBulkTemplate("bodyhtml", config.BodyHTML)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := make([]*bulkMessage, 0, len(rows))
+	for _, row := range rows {
+		msg := &bulkMessage{Line: row.line, To: row.email}
+		for _, t := range []struct {
+			tmpl *template.Template
+			out  *string
+		}{{subject, &msg.Subject}, {body, &msg.TextBody}, {html, &msg.HTMLBody}} {
+			var b strings.Builder
+			if err := t.tmpl.Execute(&b, row.fields); err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", config.Bulk, row.line, err)
+			}
+			*t.out = b.String()
+		}
+		batch = append(batch, msg)
+	}
+	return batch, nil
+}
+
+// parseBulkTemplate parses a text/template. Fields missing from a CSV row
+// are errors rather than silently rendered as "<no value>".
+func parseBulkTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// bulkRecipient is one row of the -bulk CSV file.
+type bulkRecipient struct {
+	line   int
+	email  string
+	fields map[string]string // Column name -> value, available to the templates
+}
+
+// loadBulkRecipients reads a CSV file whose header row names the columns.
+// The "email" (or "to") column holds the recipient; every column, including
+// that one, is a template field.
+func loadBulkRecipients(path string) ([]bulkRecipient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recipients: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header row of %s: %w", path, err)
+	}
+	emailColumn := -1
+	for i, name := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")) // Excel writes a BOM
+		if emailColumn < 0 && (strings.EqualFold(header[i], "email") || strings.EqualFold(header[i], "to")) {
+			emailColumn = i
+		}
+	}
+	if emailColumn < 0 {
+		return nil, fmt.Errorf("%s has no email column (header: %s)", path, strings.Join(header, ", "))
+	}
+
+	var recipients []bulkRecipient
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		line, _ := r.FieldPos(0)
+
+		row := bulkRecipient{line: line, fields: make(map[string]string, len(header))}
+		for i, name := range header {
+			row.fields[name] = strings.TrimSpace(record[i])
+		}
+		row.email = row.fields[header[emailColumn]]
+		if err := validation.ValidateEmail(row.email); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		recipients = append(recipients, row)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%s contains no recipients", path)
+	}
+	return recipients, nil
+}
+
+// sendBulk sends each message of the batch through the open session and
+// writes one CSV row per message. A rejected message is reset (RSET) and the
+// batch continues; a connection failure stops it.
+func sendBulk(client *SMTPClient, caps protocol.Capabilities, config *Config, batch []*bulkMessage, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("\nSending %d message(s)...\n", len(batch))
+
+	sent, failed := 0, 0
+	for i, msg := range batch {
+		messageID, data, err := composeMessage(config, caps, []string{msg.To}, msg.Subject, msg.TextBody, msg.HTMLBody)
+		if err == nil {
+			err = client.SendMail(config.From, []string{msg.To}, data)
+		}
+
+		status, code, errMsg := "SUCCESS", "250", ""
+		if err != nil {
+			status, code, errMsg = "FAILURE", "", err.Error()
+			var replyErr *protocol.ReplyError
+			if errors.As(err, &replyErr) {
+				code = fmt.Sprintf("%d", replyErr.Code)
+			}
+			failed++
+			fmt.Printf("  ✗ [%d/%d] %s: %v\n", i+1, len(batch), msg.To, err)
+			logger.LogError(slogLogger, "Failed to send bulk message", "to", msg.To, "line", msg.Line, "error", err)
+		} else {
+			sent++
+			fmt.Printf("  ✓ [%d/%d] %s <%s>\n", i+1, len(batch), msg.To, messageID)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			config.From, msg.To, msg.Subject, code, messageID, "", errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+
+		if err != nil && i < len(batch)-1 {
+			// Abort the open transaction; without a reply the session is gone
+			if _, rsetErr := client.Command(protocol.RSET()); rsetErr != nil {
+				return fmt.Errorf("bulk send stopped after %d of %d message(s): %w", i+1, len(batch), rsetErr)
+			}
+		}
+	}
+
+	fmt.Printf("\n%d sent, %d failed\n", sent, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d bulk message(s) failed", failed, len(batch))
+	}
+	fmt.Println("\n✓ Bulk sending completed successfully")
+	logger.LogInfo(slogLogger, "sendmail bulk completed successfully", "messages", sent)
+	return nil
+}
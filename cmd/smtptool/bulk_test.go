@@ -0,0 +1,154 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"msgraphtool/internal/common/timing"
+)
+
+// recordingLogger is a logger.Logger that keeps the rows in memory.
+type recordingLogger struct {
+	rows [][]string
+}
+
+func (l *recordingLogger) WriteHeader(columns []string) error { return nil }
+func (l *recordingLogger) WriteRow(row []string) error        { l.rows = append(l.rows, row); return nil }
+func (l *recordingLogger) Close() error                       { return nil }
+func (l *recordingLogger) ShouldWriteHeader() (bool, error)   { return false, nil }
+
+// TestPrepareBulkMessages tests CSV parsing and template rendering
+func TestPrepareBulkMessages(t *testing.T) {
+	config := NewConfig()
+	config.Bulk = writeTestFile(t, "recipients.csv", "\ufeffName, Email ,Plan\nAlice,alice@example.com,Pro\n\"Bob, Jr.\",bob@example.com,Free\n")
+	config.Subject = "Hello {{.Name}}"
+	config.Template = writeTestFile(t, "body.tmpl", "Dear {{.Name}},\nyour plan: {{.Plan}} ({{.Email}})\n")
+
+	batch, err := prepareBulkMessages(config)
+	if err != nil {
+		t.Fatalf("prepareBulkMessages() error = %v", err)
+	}
+	if len(batch) != 2 {
+		t.Fatalf("got %d messages, want 2", len(batch))
+	}
+	if batch[1].To != "bob@example.com" || batch[1].Subject != "Hello Bob, Jr." || batch[1].Line != 3 {
+		t.Errorf("message 2 = %+v", batch[1])
+	}
+	if want := "Dear Alice,\nyour plan: Pro (alice@example.com)\n"; batch[0].TextBody != want {
+		t.Errorf("body = %q, want %q", batch[0].TextBody, want)
+	}
+}
+
+// TestPrepareBulkMessages_Errors tests that bad input is rejected before sending
+func TestPrepareBulkMessages_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		csv      string
+		subject  string
+		errorMsg string
+	}{
+		{name: "no email column", csv: "name\nAlice\n", subject: "Hi", errorMsg: "no email column"},
+		{name: "invalid address", csv: "to\nnot-an-address\n", subject: "Hi", errorMsg: "line 2"},
+		{name: "no rows", csv: "email\n", subject: "Hi", errorMsg: "no recipients"},
+		{name: "missing field", csv: "email\na@example.com\n", subject: "Hi {{.name}}", errorMsg: "line 2"},
+		{name: "template syntax", csv: "email\na@example.com\n", subject: "Hi {{.name", errorMsg: "invalid subject template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Bulk = writeTestFile(t, "recipients.csv", tt.csv)
+			config.Subject = tt.subject
+
+			_, err := prepareBulkMessages(config)
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("prepareBulkMessages() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestSendBulk tests that a rejected recipient is reset and the batch continues
+func TestSendBulk(t *testing.T) {
+	server := newFakeSMTPServer(t, []string{"ENHANCEDSTATUSCODES"}, func(cmd string) string {
+		if cmd == "RCPT TO:<missing@example.com>" {
+			return "550 5.1.1 User unknown\r\n"
+		}
+		return ""
+	})
+	config := NewConfig()
+	config.From = "sender@example.com"
+	client := connectFakeServer(t, server, config)
+
+	batch := []*bulkMessage{
+		{Line: 2, To: "missing@example.com", Subject: "One", TextBody: "first"},
+		{Line: 3, To: "bob@example.com", Subject: "Two", TextBody: "second"},
+	}
+	csvLogger := &recordingLogger{}
+	err := sendBulk(client, client.GetCapabilities(), config, batch, csvLogger, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2") {
+		t.Errorf("sendBulk() error = %v, want 1 of 2 failed", err)
+	}
+
+	if len(csvLogger.rows) != 2 {
+		t.Fatalf("got %d CSV rows, want 2", len(csvLogger.rows))
+	}
+	if row := csvLogger.rows[0]; row[1] != "FAILURE" || row[7] != "550" {
+		t.Errorf("row 1 = %q, want FAILURE with code 550", row)
+	}
+	if row := csvLogger.rows[1]; row[1] != "SUCCESS" || row[5] != "bob@example.com" || row[6] != "Two" {
+		t.Errorf("row 2 = %q, want SUCCESS for bob@example.com", row)
+	}
+
+	commands := server.recorded()
+	if !containsString(commands, "RSET") {
+		t.Errorf("rejected transaction was not reset: %q", commands)
+	}
+	server.mu.Lock()
+	data := server.data
+	server.mu.Unlock()
+	if !strings.Contains(data, "Subject: Two") || !strings.Contains(data, "To: bob@example.com") {
+		t.Errorf("second message not delivered as rendered:\n%s", data)
+	}
+}
+
+// TestRunTimedAction_BulkColumns tests that -bulk rows carry the timing columns, empty
+func TestRunTimedAction_BulkColumns(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	config := NewConfig()
+	config.Action = ActionSendMail
+	config.Host = "127.0.0.1"
+	config.Port = server.port()
+	config.Timeout = 5 * time.Second
+	config.From = "sender@example.com"
+	config.To = []string{"bob@example.com"}
+
+	single := &recordingLogger{}
+	if err := runTimedAction(context.Background(), config, single, nil); err != nil {
+		t.Fatalf("runTimedAction() error = %v", err)
+	}
+
+	config.Bulk = writeTestFile(t, "recipients.csv", "Email\nbob@example.com\n")
+	bulk := &recordingLogger{}
+	if err := runTimedAction(context.Background(), config, bulk, nil); err != nil {
+		t.Fatalf("runTimedAction() with -bulk error = %v", err)
+	}
+
+	if len(single.rows) != 1 || len(bulk.rows) != 1 {
+		t.Fatalf("got %d and %d rows, want 1 each", len(single.rows), len(bulk.rows))
+	}
+	if got, want := len(bulk.rows[0]), len(single.rows[0]); got != want {
+		t.Fatalf("-bulk row has %d columns, want %d: %q", got, want, bulk.rows[0])
+	}
+	columns := len(timing.Columns())
+	for i, v := range bulk.rows[0][len(bulk.rows[0])-1-columns : len(bulk.rows[0])-1] {
+		if v != "" {
+			t.Errorf("-bulk timing column %d = %q, want empty", i, v)
+		}
+	}
+}
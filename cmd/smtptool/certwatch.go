@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"msgraphtool/internal/common/certwatch"
+	"msgraphtool/internal/common/dns"
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/common/proxy"
+)
+
+// watchCertificates checks the certificates of all inventory endpoints
+// concurrently and reports them sorted by expiry. The result is a Nagios
+// plugin state: OK exits 0; WARNING, CRITICAL and UNKNOWN exit 1, 2 and 3.
+func watchCertificates(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	endpoints, err := certwatch.LoadInventory(config.Inventory)
+	if err != nil {
+		return &exitCodeError{code: int(certwatch.StatusUnknown), err: err}
+	}
+
+	// Roots and client certificate from -cacert and -clientcert; each
+	// endpoint's chain is verified and reported, but only expiry is graded
+	tlsConfig := &tls.Config{}
+	if err := config.TrustOptions().Apply(tlsConfig); err != nil {
+		return &exitCodeError{code: int(certwatch.StatusUnknown), err: err}
+	}
+
+	fmt.Printf("Checking certificates of %d endpoint(s) from %s (warning: %d days, critical: %d days)...\n\n",
+		len(endpoints), config.Inventory, config.WarningDays, config.CriticalDays)
+
+	// Write CSV header
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader([]string{
+			"Action", "Status", "Endpoint", "Protocol", "TLS_Mode", "Cert_State", "Days_Left",
+			"Expires", "Expiring_Subject", "Cert_Subject", "Cert_Issuer", "Verification_Status", "Error",
+		}); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	resolver := dns.NewResolver(config.DNSServer)
+	dialer, err := proxy.NewDialer(config.ProxyURL, &net.Dialer{Resolver: resolver, LocalAddr: dualstack.LocalAddr(config.SourceIP)})
+	if err != nil {
+		return &exitCodeError{code: int(certwatch.StatusUnknown), err: err}
+	}
+	dialer = dualstack.Wrap(dialer, config.IPVersion)
+
+	results := certwatch.Run(ctx, endpoints, certwatch.Options{
+		WarningDays:  config.WarningDays,
+		CriticalDays: config.CriticalDays,
+		Concurrency:  config.Concurrency,
+		Timeout:      config.Timeout,
+		Resolver:     resolver,
+		Dialer:       dialer,
+		SaveChain:    config.SaveChain,
+		TLSConfig:    tlsConfig,
+	})
+
+	overall := certwatch.StatusOK
+	counts := make(map[certwatch.Status]int)
+	fmt.Printf("%-9s %9s  %-10s  %s\n", "STATE", "DAYS LEFT", "EXPIRES", "ENDPOINT")
+	for _, r := range results {
+		overall = certwatch.Worst(overall, r.Status)
+		counts[r.Status]++
+		printCertWatchResult(r)
+
+		status := "SUCCESS"
+		if r.Status != certwatch.StatusOK {
+			status = "FAILURE"
+			logger.LogWarn(slogLogger, "Certificate check not OK", "endpoint", r.Endpoint.String(), "state", r.Status.String())
+		}
+		if logErr := csvLogger.WriteRow(certWatchRow(config, status, r)); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	summary := fmt.Sprintf("CERTWATCH %s - %d critical, %d warning, %d unknown, %d ok",
+		overall, counts[certwatch.StatusCritical], counts[certwatch.StatusWarning],
+		counts[certwatch.StatusUnknown], counts[certwatch.StatusOK])
+	if config.SaveChain != "" {
+		fmt.Printf("\nCertificate chains saved to %s\n", config.SaveChain)
+	}
+	fmt.Println()
+	fmt.Println(summary)
+
+	if overall != certwatch.StatusOK {
+		return &exitCodeError{code: int(overall), err: fmt.Errorf("%s", summary)}
+	}
+	logger.LogInfo(slogLogger, "certwatch completed successfully", "endpoints", len(results))
+	return nil
+}
+
+// printCertWatchResult prints one line of the expiry report.
+func printCertWatchResult(r *certwatch.Result) {
+	if r.Err != nil {
+		fmt.Printf("%-9s %9s  %-10s  %s: %v\n", r.Status, "-", "-", r.Endpoint, r.Err)
+		return
+	}
+	line := fmt.Sprintf("%-9s %9d  %-10s  %s", r.Status, r.DaysLeft, r.Expiry.Format("2006-01-02"), r.Endpoint)
+	if r.ExpirySubject != r.Certificate.Subject {
+		line += fmt.Sprintf(" (expiring: %s)", r.ExpirySubject)
+	}
+	if r.Verification != "valid" {
+		line += fmt.Sprintf(" [%s]", strings.ToUpper(r.Verification))
+	}
+	fmt.Println(line)
+	if r.SaveError != nil {
+		fmt.Printf("%-9s %9s  %-10s  ⚠ Certificate chain not saved: %v\n", "", "", "", r.SaveError)
+	}
+}
+
+// certWatchRow converts a result into a certwatch CSV row.
+func certWatchRow(config *Config, status string, r *certwatch.Result) []string {
+	daysLeft, expires, subject, issuer, errMsg := "", "", "", "", ""
+	if r.Certificate != nil {
+		daysLeft = fmt.Sprintf("%d", r.DaysLeft)
+		expires = r.Expiry.Format(time.RFC3339)
+		subject, issuer = r.Certificate.Subject, r.Certificate.Issuer
+	}
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+
+	return []string{
+		config.Action, status, r.Endpoint.String(), r.Endpoint.Protocol, r.Endpoint.TLSMode,
+		r.Status.String(), daysLeft, expires, r.ExpirySubject, subject, issuer, r.Verification, errMsg,
+	}
+}
@@ -0,0 +1,94 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"msgraphtool/internal/common/certwatch"
+	commontls "msgraphtool/internal/common/tls"
+)
+
+func TestValidateConfiguration_CertWatch(t *testing.T) {
+	inventory := filepath.Join(t.TempDir(), "endpoints.txt")
+	if err := os.WriteFile(inventory, []byte("mail.example.com 25 smtp\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		inventory   string
+		warnDays    int
+		critDays    int
+		concurrency int
+		pins        []string
+		errorMsg    string
+	}{
+		{name: "Inventory without host", inventory: inventory, warnDays: 30, critDays: 7, concurrency: 10},
+		{name: "Equal thresholds", inventory: inventory, warnDays: 14, critDays: 14, concurrency: 1},
+		{name: "Missing inventory", warnDays: 30, critDays: 7, concurrency: 10, errorMsg: "certwatch requires -inventory"},
+		{name: "Missing file", inventory: inventory + ".missing", warnDays: 30, critDays: 7, concurrency: 10, errorMsg: "invalid inventory file"},
+		{name: "Critical above warning", inventory: inventory, warnDays: 7, critDays: 30, concurrency: 10, errorMsg: "-critdays"},
+		{name: "Negative critical", inventory: inventory, warnDays: 30, critDays: -1, concurrency: 10, errorMsg: "-critdays"},
+		{name: "Zero concurrency", inventory: inventory, warnDays: 30, critDays: 7, errorMsg: "-concurrency"},
+		{name: "Pins rejected", inventory: inventory, warnDays: 30, critDays: 7, concurrency: 10, pins: []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}, errorMsg: "-pinsha256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionCertWatch
+			config.Inventory = tt.inventory
+			config.WarningDays = tt.warnDays
+			config.CriticalDays = tt.critDays
+			config.Concurrency = tt.concurrency
+			config.PinSHA256 = tt.pins
+
+			err := validateConfiguration(config)
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("validateConfiguration() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCertWatchRow(t *testing.T) {
+	config := NewConfig()
+	config.Action = ActionCertWatch
+	ep := certwatch.Endpoint{Host: "mail.example.com", Port: 993, Protocol: certwatch.ProtocolIMAP, TLSMode: certwatch.TLSModeImplicit}
+	expiry := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	row := certWatchRow(config, "FAILURE", &certwatch.Result{
+		Endpoint:      ep,
+		Certificate:   &commontls.CertificateInfo{Subject: "CN=mail.example.com", Issuer: "CN=Example CA"},
+		Verification:  "valid",
+		Expiry:        expiry,
+		ExpirySubject: "CN=Example Intermediate",
+		DaysLeft:      12,
+		Status:        certwatch.StatusWarning,
+	})
+	want := []string{
+		"certwatch", "FAILURE", "imap://mail.example.com:993", "imap", "implicit", "WARNING", "12",
+		"2026-11-01T00:00:00Z", "CN=Example Intermediate", "CN=mail.example.com", "CN=Example CA", "valid", "",
+	}
+	if strings.Join(row, "|") != strings.Join(want, "|") {
+		t.Errorf("row = %q\nwant  %q", row, want)
+	}
+
+	row = certWatchRow(config, "FAILURE", &certwatch.Result{Endpoint: ep, Status: certwatch.StatusUnknown, Err: errors.New("connection refused")})
+	if row[5] != "UNKNOWN" || row[6] != "" || row[12] != "connection refused" {
+		t.Errorf("failed row = %q", row)
+	}
+}
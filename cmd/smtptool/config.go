@@ -3,12 +3,17 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"msgraphtool/internal/common/dns"
+	"msgraphtool/internal/common/dualstack"
+	commontls "msgraphtool/internal/common/tls"
 	"msgraphtool/internal/common/validation"
+	"msgraphtool/internal/smtp/protocol"
 )
 
 // Config holds all smtptool configuration.
@@ -26,24 +31,81 @@ type Config struct {
 	Username    string
 	Password    string
 	AccessToken string // OAuth2 access token for XOAUTH2 authentication
-	AuthMethod  string // PLAIN, LOGIN, CRAM-MD5, XOAUTH2, or "auto"
+	AuthMethod  string // PLAIN, LOGIN, CRAM-MD5, SCRAM-SHA-1/256(-PLUS), NTLM, XOAUTH2, or "auto"
 
 	// Email configuration (for sendmail)
-	From    string
-	To      []string
-	Subject string
-	Body    string
+	From         string
+	To           []string
+	Subject      string
+	Body         string
+	BodyHTML     string   // HTML body; creates multipart/alternative together with Body
+	Attachments  []string // File paths attached as multipart/mixed parts
+	InlineImages []string // Image files embedded in the HTML body, referenced as cid:<file name>
+
+	// Pre-built and bulk messages (for sendmail)
+	EML          string // RFC 5322 message file sent as written instead of the composed test message
+	RegenHeaders bool   // Replace Message-ID and Date of the -eml message
+	Bulk         string // CSV of recipients (email column plus template fields), one message each
+	Template     string // text/template file for the bulk message body; -body is used when empty
+
+	// Delivery Status Notification (RFC 3461) configuration
+	DSNNotify string // NOTIFY keywords: NEVER or any of SUCCESS,FAILURE,DELAY
+	DSNRet    string // RET keyword: FULL or HDRS
+	EnvID     string // ENVID envelope identifier
+	DSNFile   string // .eml file containing a DSN to parse (parsedsn action)
+
+	// ESMTP transfer extensions (for sendmail)
+	Pipelining bool // Pipeline MAIL FROM/RCPT TO when PIPELINING is advertised (RFC 2920)
+	Chunking   bool // Send the message with BDAT when CHUNKING is advertised (RFC 3030)
+	ChunkSize  int  // BDAT chunk size in bytes
+	BinaryMIME bool // Declare BODY=BINARYMIME when advertised (requires Chunking)
+
+	// Greylisting (for sendmail)
+	GreylistDelay time.Duration // Wait between delivery attempts after a greylisting deferral
+	GreylistMax   time.Duration // How long to keep retrying a greylisted message (0 = fail at once)
 
 	// TLS configuration
 	StartTLS   bool   // Force STARTTLS
 	SMTPS      bool   // Use SMTPS (implicit TLS on port 465)
 	SkipVerify bool   // Skip TLS certificate verification
 	TLSVersion string // TLS version to use (exact match): 1.2, 1.3
+	DANE       bool   // Verify the server certificate against TLSA records (RFC 7672)
+	TLSSweep   bool   // Probe every TLS version and cipher suite (teststarttls)
+
+	// Certificate trust and mutual TLS
+	CACert         string   // PEM bundle of trusted roots, replacing the system roots
+	ClientCert     string   // Client certificate for mutual TLS: PEM, or PKCS#12 (.pfx/.p12)
+	ClientKey      string   // PEM private key for ClientCert (not used with PKCS#12)
+	ClientCertPass string   // PKCS#12 password
+	PinSHA256      []string // Base64 SHA-256 SPKI pins; one must match the server chain
+	SaveChain      string   // Directory the presented certificate chain is saved to
+	Fingerprints   string   // JSON file with additional server fingerprint signatures
+
+	// Revocation checks (teststarttls, testmx)
+	Revocation bool   // Query the OCSP responder and CRL of the server certificate
+	OCSPURL    string // OCSP responder URL, replacing the certificate's AIA entry
+	CRLURL     string // CRL URL, replacing the certificate's CRL distribution point
+
+	// DNS configuration
+	Domain    string // Recipient domain whose MX hosts are tested (testmx action)
+	DNSServer string // Resolver address (host[:port]); empty uses the system resolver
+	MTASTSURL string // Base URL of the MTA-STS policy host; empty uses https://mta-sts.<domain>
+
+	// Certificate expiry monitoring (certwatch action)
+	Inventory    string // File listing host, port, protocol and TLS mode per line
+	WarningDays  int    // Days before expiry that raise WARNING
+	CriticalDays int    // Days before expiry that raise CRITICAL
+	Concurrency  int    // Maximum endpoints checked at the same time
+
+	// Relay audit configuration
+	ExternalDomain string // Domain used for addresses outside the server's domain (testrelay action)
 
 	// Network configuration
 	ProxyURL   string
 	MaxRetries int
 	RetryDelay time.Duration
+	IPVersion  string // 4, 6 or both (empty: whichever address connects first)
+	SourceIP   string // Local address to connect from
 
 	// Runtime configuration
 	VerboseMode  bool
@@ -59,26 +121,58 @@ const (
 	ActionTestStartTLS = "teststarttls"
 	ActionTestAuth     = "testauth"
 	ActionSendMail     = "sendmail"
+	ActionParseDSN     = "parsedsn"
+	ActionTestMX       = "testmx"
+	ActionMTASTS       = "mtasts"
+	ActionTestRelay    = "testrelay"
+	ActionVerifyRcpt   = "verifyrcpt"
+	ActionCertWatch    = "certwatch"
+)
+
+// DefaultChunkSize is the default BDAT chunk size in bytes.
+const DefaultChunkSize = 64 * 1024
+
+// Default greylisting retry interval and window. Most greylisters accept a
+// retry after one to five minutes; retrying is opt-in through -greylistmax.
+const (
+	DefaultGreylistDelay               = time.Minute
+	DefaultGreylistMax   time.Duration = 0
+)
+
+// DefaultExternalDomain is the domain testrelay uses for addresses outside the server's domain.
+const DefaultExternalDomain = "example.org"
+
+// Default certwatch thresholds and parallelism.
+const (
+	DefaultWarningDays  = 30
+	DefaultCriticalDays = 7
+	DefaultConcurrency  = 10
 )
 
 // NewConfig creates a new Config with default values.
 func NewConfig() *Config {
 	return &Config{
-		Port:         25,
-		Timeout:      30 * time.Second,
-		AuthMethod:   "auto",
-		Subject:      "SMTP Test",
-		Body:         "This is a test message from smtptool",
-		StartTLS:     false, // Auto-detect
-		SkipVerify:   false,
-		TLSVersion:   "1.2",
-		MaxRetries:   3,
-		RetryDelay:   2000 * time.Millisecond,
-		VerboseMode:  false,
-		LogLevel:     "INFO",
-		OutputFormat: "text",
-		LogFormat:    "csv",
-		RateLimit:    0, // Unlimited by default
+		Port:          25,
+		Timeout:       30 * time.Second,
+		AuthMethod:    "auto",
+		Subject:       "SMTP Test",
+		Body:          "This is a test message from smtptool",
+		ChunkSize:     DefaultChunkSize,
+		GreylistDelay: DefaultGreylistDelay,
+		GreylistMax:   DefaultGreylistMax,
+		WarningDays:   DefaultWarningDays,
+		CriticalDays:  DefaultCriticalDays,
+		Concurrency:   DefaultConcurrency,
+		StartTLS:      false, // Auto-detect
+		SkipVerify:    false,
+		TLSVersion:    "1.2",
+		MaxRetries:    3,
+		RetryDelay:    2000 * time.Millisecond,
+		VerboseMode:   false,
+		LogLevel:      "INFO",
+		OutputFormat:  "text",
+		LogFormat:     "csv",
+		RateLimit:     0, // Unlimited by default
 	}
 }
 
@@ -99,12 +193,33 @@ func parseAndConfigureFlags() *Config {
 		fmt.Fprintf(flag.CommandLine.Output(), "  testconnect   - Test TCP connection and capabilities\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  teststarttls  - Test TLS/SSL with comprehensive diagnostics\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  testauth      - Test SMTP authentication\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  sendmail      - Send test email\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  sendmail      - Send test email\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  testmx        - Resolve a domain's MX hosts and test each one\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  mtasts        - Check a domain's MTA-STS policy against its MX hosts\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  testrelay     - Audit relay restrictions with MAIL FROM/RCPT TO probes (no message is sent)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  verifyrcpt    - Check -to addresses with VRFY, EXPN and RCPT TO probes (no message is sent)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  certwatch     - Check certificate expiry of all -inventory endpoints (Nagios exit codes)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  parsedsn      - Parse a delivery status notification (.eml) into per-recipient status\n\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "Examples:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action testconnect -host smtp.example.com -port 25\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action teststarttls -host smtp.example.com -port 587\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action testauth -host smtp.example.com -port 587 -username user@example.com -password secret\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action sendmail -host smtp.example.com -port 587 -username user@example.com -password secret -from sender@example.com -to recipient@example.com\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action sendmail -host smtp.example.com -port 587 -from sender@example.com -to recipient@example.com -bodyhtml \"<p>Hi <img src='cid:logo.png'></p>\" -inlineimages logo.png -attachments report.pdf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action sendmail -host smtp.example.com -port 587 -eml message.eml -regenheaders\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action sendmail -host smtp.example.com -port 587 -from sender@example.com -bulk recipients.csv -subject \"Hello {{.name}}\" -template body.tmpl\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action sendmail -host mx.partner.example -port 25 -from monitor@example.com -to postmaster@partner.example -greylistdelay 120 -greylistmax 3600\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action testmx -domain example.com -dnsserver 127.0.0.1:5353\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action testmx -domain example.com -ipversion both\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action mtasts -domain example.com\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action testrelay -host mail.example.com -domain example.com\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action verifyrcpt -host mail.example.com -to alice@example.com,sales@example.com\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action certwatch -inventory endpoints.txt -warndays 30 -critdays 7\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action teststarttls -host mx.example.com -port 25 -dane -dnsserver 127.0.0.1\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action teststarttls -host smtp.example.com -port 587 -tlssweep\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action teststarttls -host smtp.example.com -port 587 -revocation\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action teststarttls -host smtp.example.com -port 587 -savechain chains\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action teststarttls -host relay.corp.local -port 25 -cacert corp-ca.pem -clientcert client.pfx -clientcertpass secret\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "\nSMTPS Examples (implicit TLS on port 465):\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action testconnect -host smtp.gmail.com -port 465 -smtps\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -action teststarttls -host smtp.gmail.com -port 465 -smtps\n", os.Args[0])
@@ -116,25 +231,64 @@ func parseAndConfigureFlags() *Config {
 
 	// Define flags
 	showVersion := flag.Bool("version", false, "Show version information")
-	action := flag.String("action", "", "Action to perform (testconnect, teststarttls, testauth, sendmail)")
+	action := flag.String("action", "", "Action to perform (testconnect, teststarttls, testauth, sendmail, parsedsn, testmx, mtasts, testrelay, verifyrcpt, certwatch)")
 	host := flag.String("host", "", "SMTP server hostname or IP address (env: SMTPHOST)")
 	port := flag.Int("port", 25, "SMTP server port (env: SMTPPORT)")
 	timeout := flag.Int("timeout", 30, "Connection timeout in seconds (env: SMTPTIMEOUT)")
 	username := flag.String("username", "", "SMTP username for authentication (env: SMTPUSERNAME)")
 	password := flag.String("password", "", "SMTP password for authentication (env: SMTPPASSWORD)")
 	accessToken := flag.String("accesstoken", "", "OAuth2 access token for XOAUTH2 authentication (env: SMTPACCESSTOKEN)")
-	authMethod := flag.String("authmethod", "auto", "Authentication method: PLAIN, LOGIN, CRAM-MD5, XOAUTH2, auto (env: SMTPAUTHMETHOD)")
+	authMethod := flag.String("authmethod", "auto", "Authentication method: PLAIN, LOGIN, CRAM-MD5, SCRAM-SHA-1, SCRAM-SHA-256, SCRAM-SHA-1-PLUS, SCRAM-SHA-256-PLUS, NTLM, XOAUTH2, auto (env: SMTPAUTHMETHOD)")
 	from := flag.String("from", "", "Sender email address for sendmail (env: SMTPFROM)")
 	to := flag.String("to", "", "Comma-separated recipient email addresses (env: SMTPTO)")
 	subject := flag.String("subject", "SMTP Test", "Email subject (env: SMTPSUBJECT)")
 	body := flag.String("body", "This is a test message from smtptool", "Email body text (env: SMTPBODY)")
+	bodyHTML := flag.String("bodyhtml", "", "HTML email body; sent as multipart/alternative with -body (env: SMTPBODYHTML)")
+	attachments := flag.String("attachments", "", "Comma-separated list of file paths to attach (env: SMTPATTACHMENTS)")
+	eml := flag.String("eml", "", "RFC 5322 message file (.eml) to send as written instead of the test message (env: SMTPEML)")
+	regenHeaders := flag.Bool("regenheaders", false, "Replace the Message-ID and Date headers of the -eml message (env: SMTPREGENHEADERS)")
+	bulk := flag.String("bulk", "", "CSV file of recipients with an email column; sends one templated message per row (env: SMTPBULK)")
+	template := flag.String("template", "", "Go text/template file for the -bulk message body; CSV columns are fields, e.g. {{.name}} (env: SMTPTEMPLATE)")
+	inlineImages := flag.String("inlineimages", "", "Comma-separated image files for the HTML body, referenced as cid:<file name> (env: SMTPINLINEIMAGES)")
+	dsnNotify := flag.String("dsn-notify", "", "DSN NOTIFY keywords: NEVER or comma-separated SUCCESS,FAILURE,DELAY (env: SMTPDSNNOTIFY)")
+	dsnRet := flag.String("dsn-ret", "", "DSN RET value: FULL or HDRS (env: SMTPDSNRET)")
+	envID := flag.String("envid", "", "DSN envelope identifier (ENVID) for MAIL FROM (env: SMTPENVID)")
+	dsnFile := flag.String("dsnfile", "", "Path to a DSN .eml file for the parsedsn action (env: SMTPDSNFILE)")
+	pipelining := flag.Bool("pipelining", false, "Pipeline MAIL FROM/RCPT TO when the server advertises PIPELINING (env: SMTPPIPELINING)")
+	chunking := flag.Bool("chunking", false, "Send the message with BDAT when the server advertises CHUNKING (env: SMTPCHUNKING)")
+	chunkSize := flag.Int("chunksize", DefaultChunkSize, "BDAT chunk size in bytes (env: SMTPCHUNKSIZE)")
+	binaryMIME := flag.Bool("binarymime", false, "Declare BODY=BINARYMIME when advertised; requires -chunking (env: SMTPBINARYMIME)")
+	greylistDelay := flag.Int("greylistdelay", int(DefaultGreylistDelay/time.Second), "Seconds between sendmail attempts after a greylisting deferral (env: SMTPGREYLISTDELAY)")
+	greylistMax := flag.Int("greylistmax", int(DefaultGreylistMax/time.Second), "Seconds to keep retrying a greylisted message; 0 (default) reports greylisting as a failure (env: SMTPGREYLISTMAX)")
+	domain := flag.String("domain", "", "Recipient domain whose MX hosts are tested by testmx (env: SMTPDOMAIN)")
+	externalDomain := flag.String("externaldomain", DefaultExternalDomain, "Domain for external addresses in testrelay (env: SMTPEXTERNALDOMAIN)")
+	mtastsURL := flag.String("mtastsurl", "", "Base URL of the MTA-STS policy host, replacing https://mta-sts.<domain> (env: SMTPMTASTSURL)")
+	inventory := flag.String("inventory", "", "Endpoint inventory file for certwatch: host port protocol [tlsmode] per line (env: SMTPINVENTORY)")
+	warnDays := flag.Int("warndays", DefaultWarningDays, "Days before certificate expiry that raise WARNING in certwatch (env: SMTPWARNDAYS)")
+	critDays := flag.Int("critdays", DefaultCriticalDays, "Days before certificate expiry that raise CRITICAL in certwatch (env: SMTPCRITDAYS)")
+	concurrency := flag.Int("concurrency", DefaultConcurrency, "Maximum endpoints checked at the same time in certwatch (env: SMTPCONCURRENCY)")
+	dnsServer := flag.String("dnsserver", "", "DNS resolver address host[:port] used for lookups (env: SMTPDNSSERVER)")
 	startTLS := flag.Bool("starttls", false, "Force STARTTLS usage (env: SMTPSTARTTLS)")
 	smtps := flag.Bool("smtps", false, "Use SMTPS (implicit TLS), typically on port 465 (env: SMTPSMTPS)")
 	skipVerify := flag.Bool("skipverify", false, "Skip TLS certificate verification (insecure) (env: SMTPSKIPVERIFY)")
+	tlsSweep := flag.Bool("tlssweep", false, "Probe every TLS version (1.0-1.3) and cipher suite and print a support matrix in teststarttls (env: SMTPTLSSWEEP)")
+	dane := flag.Bool("dane", false, "Verify the server certificate against DNSSEC-signed TLSA records (DANE) in teststarttls (env: SMTPDANE)")
+	caCert := flag.String("cacert", "", "PEM bundle of trusted CA certificates, replacing the system roots (env: SMTPCACERT)")
+	clientCert := flag.String("clientcert", "", "Client certificate for mutual TLS: PEM, or PKCS#12 .pfx/.p12 (env: SMTPCLIENTCERT)")
+	clientKey := flag.String("clientkey", "", "PEM private key for -clientcert (env: SMTPCLIENTKEY)")
+	clientCertPass := flag.String("clientcertpass", "", "Password for a PKCS#12 -clientcert (env: SMTPCLIENTCERTPASS)")
+	pinSHA256 := flag.String("pinsha256", "", "Comma-separated base64 SHA-256 SPKI pins of the server or CA key (env: SMTPPINSHA256)")
+	saveChain := flag.String("savechain", "", "Directory to save the presented and verified certificate chains to as PEM/DER, with a JSON summary (env: SMTPSAVECHAIN)")
+	fingerprints := flag.String("fingerprints", "", "JSON file with additional server fingerprint signatures (env: SMTPFINGERPRINTS)")
+	revocation := flag.Bool("revocation", false, "Query the OCSP responder and CRL of the server certificate in teststarttls and testmx (env: SMTPREVOCATION)")
+	ocspURL := flag.String("ocspurl", "", "OCSP responder URL, replacing the one in the certificate; implies -revocation (env: SMTPOCSPURL)")
+	crlURL := flag.String("crlurl", "", "CRL URL, replacing the one in the certificate; implies -revocation (env: SMTPCRLURL)")
 	tlsVersion := flag.String("tlsversion", "1.2", "TLS version to use (exact): 1.2, 1.3 (env: SMTPTLSVERSION)")
-	proxyURL := flag.String("proxy", "", "HTTP/HTTPS proxy URL (env: SMTPPROXY)")
+	proxyURL := flag.String("proxy", "", "HTTP/HTTPS (CONNECT) or SOCKS5 proxy URL (env: SMTPPROXY)")
 	maxRetries := flag.Int("maxretries", 3, "Maximum retry attempts (env: SMTPMAXRETRIES)")
 	retryDelay := flag.Int("retrydelay", 2000, "Retry delay in milliseconds (env: SMTPRETRYDELAY)")
+	ipVersion := flag.String("ipversion", "", "IP version: 4, 6, or both to test every address of the host (env: SMTPIPVERSION)")
+	sourceIP := flag.String("sourceip", "", "Local IP address to connect from (env: SMTPSOURCEIP)")
 	verbose := flag.Bool("verbose", false, "Enable verbose output")
 	logLevel := flag.String("loglevel", "INFO", "Logging level: DEBUG, INFO, WARN, ERROR")
 	outputFormat := flag.String("output", "text", "Output format: text, json (env: SMTPOUTPUT)")
@@ -159,13 +313,58 @@ func parseAndConfigureFlags() *Config {
 	}
 	config.Subject = *subject
 	config.Body = *body
+	config.BodyHTML = *bodyHTML
+	if *attachments != "" {
+		config.Attachments = splitList(*attachments)
+	}
+	if *inlineImages != "" {
+		config.InlineImages = splitList(*inlineImages)
+	}
+	config.EML = *eml
+	config.RegenHeaders = *regenHeaders
+	config.Bulk = *bulk
+	config.Template = *template
+	config.DSNNotify = *dsnNotify
+	config.DSNRet = *dsnRet
+	config.EnvID = *envID
+	config.DSNFile = *dsnFile
+	config.Pipelining = *pipelining
+	config.Chunking = *chunking
+	config.ChunkSize = *chunkSize
+	config.BinaryMIME = *binaryMIME
+	config.GreylistDelay = time.Duration(*greylistDelay) * time.Second
+	config.GreylistMax = time.Duration(*greylistMax) * time.Second
+	config.Domain = *domain
+	config.DNSServer = *dnsServer
+	config.MTASTSURL = *mtastsURL
+	config.ExternalDomain = *externalDomain
+	config.Inventory = *inventory
+	config.WarningDays = *warnDays
+	config.CriticalDays = *critDays
+	config.Concurrency = *concurrency
 	config.StartTLS = *startTLS
 	config.SMTPS = *smtps
 	config.SkipVerify = *skipVerify
+	config.DANE = *dane
+	config.TLSSweep = *tlsSweep
+	config.Revocation = *revocation
+	config.OCSPURL = *ocspURL
+	config.CRLURL = *crlURL
+	config.CACert = *caCert
+	config.ClientCert = *clientCert
+	config.ClientKey = *clientKey
+	config.ClientCertPass = *clientCertPass
+	if *pinSHA256 != "" {
+		config.PinSHA256 = splitList(*pinSHA256)
+	}
+	config.SaveChain = *saveChain
+	config.Fingerprints = *fingerprints
 	config.TLSVersion = *tlsVersion
 	config.ProxyURL = *proxyURL
 	config.MaxRetries = *maxRetries
 	config.RetryDelay = time.Duration(*retryDelay) * time.Millisecond
+	config.IPVersion = *ipVersion
+	config.SourceIP = *sourceIP
 	config.VerboseMode = *verbose
 	config.LogLevel = *logLevel
 	config.OutputFormat = *outputFormat
@@ -218,6 +417,124 @@ func applyEnvironmentVariables(config *Config) {
 	if toStr := os.Getenv("SMTPTO"); toStr != "" && len(config.To) == 0 {
 		config.To = strings.Split(toStr, ",")
 	}
+	if config.BodyHTML == "" {
+		config.BodyHTML = os.Getenv("SMTPBODYHTML")
+	}
+	if v := os.Getenv("SMTPATTACHMENTS"); v != "" && len(config.Attachments) == 0 {
+		config.Attachments = splitList(v)
+	}
+	if v := os.Getenv("SMTPINLINEIMAGES"); v != "" && len(config.InlineImages) == 0 {
+		config.InlineImages = splitList(v)
+	}
+	if config.EML == "" {
+		config.EML = os.Getenv("SMTPEML")
+	}
+	if !config.RegenHeaders {
+		config.RegenHeaders = parseBoolEnv(os.Getenv("SMTPREGENHEADERS"))
+	}
+	if config.Bulk == "" {
+		config.Bulk = os.Getenv("SMTPBULK")
+	}
+	if config.Template == "" {
+		config.Template = os.Getenv("SMTPTEMPLATE")
+	}
+	if config.DSNNotify == "" {
+		config.DSNNotify = os.Getenv("SMTPDSNNOTIFY")
+	}
+	if config.DSNRet == "" {
+		config.DSNRet = os.Getenv("SMTPDSNRET")
+	}
+	if config.EnvID == "" {
+		config.EnvID = os.Getenv("SMTPENVID")
+	}
+	if config.DSNFile == "" {
+		config.DSNFile = os.Getenv("SMTPDSNFILE")
+	}
+	if config.Domain == "" {
+		config.Domain = os.Getenv("SMTPDOMAIN")
+	}
+	if config.DNSServer == "" {
+		config.DNSServer = os.Getenv("SMTPDNSSERVER")
+	}
+	if config.ProxyURL == "" {
+		config.ProxyURL = os.Getenv("SMTPPROXY")
+	}
+	if config.IPVersion == "" {
+		config.IPVersion = os.Getenv("SMTPIPVERSION")
+	}
+	if config.SourceIP == "" {
+		config.SourceIP = os.Getenv("SMTPSOURCEIP")
+	}
+	if config.MTASTSURL == "" {
+		config.MTASTSURL = os.Getenv("SMTPMTASTSURL")
+	}
+	if config.CACert == "" {
+		config.CACert = os.Getenv("SMTPCACERT")
+	}
+	if config.ClientCert == "" {
+		config.ClientCert = os.Getenv("SMTPCLIENTCERT")
+	}
+	if config.ClientKey == "" {
+		config.ClientKey = os.Getenv("SMTPCLIENTKEY")
+	}
+	if config.ClientCertPass == "" {
+		config.ClientCertPass = os.Getenv("SMTPCLIENTCERTPASS")
+	}
+	if v := os.Getenv("SMTPPINSHA256"); v != "" && len(config.PinSHA256) == 0 {
+		config.PinSHA256 = splitList(v)
+	}
+	if config.SaveChain == "" {
+		config.SaveChain = os.Getenv("SMTPSAVECHAIN")
+	}
+	if config.Fingerprints == "" {
+		config.Fingerprints = os.Getenv("SMTPFINGERPRINTS")
+	}
+	if config.Inventory == "" {
+		config.Inventory = os.Getenv("SMTPINVENTORY")
+	}
+	if v := os.Getenv("SMTPWARNDAYS"); v != "" && config.WarningDays == DefaultWarningDays {
+		if days, err := strconv.Atoi(v); err == nil {
+			config.WarningDays = days
+		}
+	}
+	if v := os.Getenv("SMTPCRITDAYS"); v != "" && config.CriticalDays == DefaultCriticalDays {
+		if days, err := strconv.Atoi(v); err == nil {
+			config.CriticalDays = days
+		}
+	}
+	if v := os.Getenv("SMTPCONCURRENCY"); v != "" && config.Concurrency == DefaultConcurrency {
+		if n, err := strconv.Atoi(v); err == nil {
+			config.Concurrency = n
+		}
+	}
+	if envDomain := os.Getenv("SMTPEXTERNALDOMAIN"); envDomain != "" && config.ExternalDomain == DefaultExternalDomain {
+		config.ExternalDomain = envDomain
+	}
+	if chunkSizeStr := os.Getenv("SMTPCHUNKSIZE"); chunkSizeStr != "" && config.ChunkSize == DefaultChunkSize {
+		if chunkSize, err := strconv.Atoi(chunkSizeStr); err == nil {
+			config.ChunkSize = chunkSize
+		}
+	}
+	if v := os.Getenv("SMTPGREYLISTDELAY"); v != "" && config.GreylistDelay == DefaultGreylistDelay {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			config.GreylistDelay = time.Duration(seconds) * time.Second
+		}
+	}
+	if v := os.Getenv("SMTPGREYLISTMAX"); v != "" && config.GreylistMax == DefaultGreylistMax {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			config.GreylistMax = time.Duration(seconds) * time.Second
+		}
+	}
+	if v := os.Getenv("SMTPMAXRETRIES"); v != "" && config.MaxRetries == 3 {
+		if maxRetries, err := strconv.Atoi(v); err == nil {
+			config.MaxRetries = maxRetries
+		}
+	}
+	if v := os.Getenv("SMTPRETRYDELAY"); v != "" && config.RetryDelay == 2000*time.Millisecond {
+		if delay, err := strconv.Atoi(v); err == nil {
+			config.RetryDelay = time.Duration(delay) * time.Millisecond
+		}
+	}
 	if rateLimitStr := os.Getenv("SMTPRATELIMIT"); rateLimitStr != "" && config.RateLimit == 0 {
 		if rateLimit, err := strconv.ParseFloat(rateLimitStr, 64); err == nil {
 			config.RateLimit = rateLimit
@@ -232,12 +549,68 @@ func applyEnvironmentVariables(config *Config) {
 	if !config.SkipVerify {
 		config.SkipVerify = parseBoolEnv(os.Getenv("SMTPSKIPVERIFY"))
 	}
+	if !config.DANE {
+		config.DANE = parseBoolEnv(os.Getenv("SMTPDANE"))
+	}
+	if !config.TLSSweep {
+		config.TLSSweep = parseBoolEnv(os.Getenv("SMTPTLSSWEEP"))
+	}
+	if !config.Revocation {
+		config.Revocation = parseBoolEnv(os.Getenv("SMTPREVOCATION"))
+	}
+	if config.OCSPURL == "" {
+		config.OCSPURL = os.Getenv("SMTPOCSPURL")
+	}
+	if config.CRLURL == "" {
+		config.CRLURL = os.Getenv("SMTPCRLURL")
+	}
+	if !config.Pipelining {
+		config.Pipelining = parseBoolEnv(os.Getenv("SMTPPIPELINING"))
+	}
+	if !config.Chunking {
+		config.Chunking = parseBoolEnv(os.Getenv("SMTPCHUNKING"))
+	}
+	if !config.BinaryMIME {
+		config.BinaryMIME = parseBoolEnv(os.Getenv("SMTPBINARYMIME"))
+	}
+}
+
+// TrustOptions returns the CA bundle, client certificate and pinning settings.
+func (c *Config) TrustOptions() *commontls.TrustOptions {
+	return &commontls.TrustOptions{
+		CAFile:             c.CACert,
+		ClientCertFile:     c.ClientCert,
+		ClientKeyFile:      c.ClientKey,
+		ClientCertPassword: c.ClientCertPass,
+		Pins:               c.PinSHA256,
+	}
+}
+
+// RevocationOptions returns the settings for online OCSP and CRL checks.
+func (c *Config) RevocationOptions() commontls.RevocationOptions {
+	return commontls.RevocationOptions{
+		OCSPURL: c.OCSPURL,
+		CRLURL:  c.CRLURL,
+		Timeout: c.Timeout,
+	}
+}
+
+// splitList splits a comma-separated flag value, trimming whitespace and
+// dropping empty entries.
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
 }
 
 // validateConfiguration validates the configuration.
 func validateConfiguration(config *Config) error {
 	// Validate action
-	validActions := []string{ActionTestConnect, ActionTestStartTLS, ActionTestAuth, ActionSendMail}
+	validActions := []string{ActionTestConnect, ActionTestStartTLS, ActionTestAuth, ActionSendMail, ActionParseDSN, ActionTestMX, ActionMTASTS, ActionTestRelay, ActionVerifyRcpt, ActionCertWatch}
 	valid := false
 	for _, a := range validActions {
 		if config.Action == a {
@@ -261,6 +634,101 @@ func validateConfiguration(config *Config) error {
 		fmt.Println()
 	}
 
+	// parsedsn works on a local file and needs no server settings
+	if config.Action == ActionParseDSN {
+		if config.DSNFile == "" {
+			return fmt.Errorf("parsedsn requires -dsnfile")
+		}
+		if err := validation.ValidateFilePath(config.DSNFile, "DSN file"); err != nil {
+			return fmt.Errorf("invalid DSN file: %w", err)
+		}
+		return nil
+	}
+
+	// Validate CA bundle, client certificate and pins (if provided)
+	if err := config.TrustOptions().Validate(); err != nil {
+		return fmt.Errorf("invalid TLS trust settings: %w", err)
+	}
+	if err := validation.ValidateDirPath(config.SaveChain, "chain directory"); err != nil {
+		return fmt.Errorf("invalid -savechain: %w", err)
+	}
+	if err := validation.ValidateFilePath(config.Fingerprints, "fingerprint signatures"); err != nil {
+		return fmt.Errorf("invalid -fingerprints: %w", err)
+	}
+
+	// Validate revocation URL overrides; either one enables -revocation
+	for _, override := range []struct{ value, flag string }{{config.OCSPURL, "-ocspurl"}, {config.CRLURL, "-crlurl"}} {
+		if override.value == "" {
+			continue
+		}
+		if u, err := url.Parse(override.value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid %s: must be an http:// or https:// URL", override.flag)
+		}
+		config.Revocation = true
+	}
+
+	// Validate DNS resolver address (if provided)
+	dnsServer, err := dns.NormalizeServer(config.DNSServer)
+	if err != nil {
+		return fmt.Errorf("invalid -dnsserver: %w", err)
+	}
+	config.DNSServer = dnsServer
+
+	// Validate IP version and source address
+	if err := dualstack.Validate(config.IPVersion, config.SourceIP); err != nil {
+		return err
+	}
+	if config.IPVersion != "" && config.ProxyURL != "" {
+		return fmt.Errorf("-ipversion cannot be combined with -proxy (the proxy resolves and connects to the server)")
+	}
+	if config.IPVersion == dualstack.Both {
+		switch config.Action {
+		case ActionMTASTS, ActionCertWatch:
+			return fmt.Errorf("-ipversion both is not supported by %s", config.Action)
+		case ActionSendMail:
+			return fmt.Errorf("-ipversion both is not supported by sendmail (the message would be sent once per address)")
+		}
+	}
+
+	// certwatch reads its endpoints from -inventory instead of -host
+	if config.Action == ActionCertWatch {
+		if config.Inventory == "" {
+			return fmt.Errorf("certwatch requires -inventory")
+		}
+		if err := validation.ValidateFilePath(config.Inventory, "inventory file"); err != nil {
+			return fmt.Errorf("invalid inventory file: %w", err)
+		}
+		if config.CriticalDays < 0 || config.WarningDays < config.CriticalDays {
+			return fmt.Errorf("-critdays must be between 0 and -warndays")
+		}
+		if config.Concurrency < 1 {
+			return fmt.Errorf("-concurrency must be at least 1")
+		}
+		if len(config.PinSHA256) > 0 {
+			return fmt.Errorf("-pinsha256 cannot be used with certwatch (endpoints present different keys)")
+		}
+		return nil
+	}
+
+	// testmx and mtasts resolve their hosts from -domain instead of -host
+	if config.Action == ActionTestMX || config.Action == ActionMTASTS {
+		if config.Domain == "" {
+			return fmt.Errorf("%s requires -domain", config.Action)
+		}
+		if err := validation.ValidateHostname(config.Domain); err != nil {
+			return fmt.Errorf("invalid domain: %w", err)
+		}
+		if config.SMTPS {
+			return fmt.Errorf("%s uses STARTTLS on port 25 and cannot be combined with -smtps", config.Action)
+		}
+		if config.MTASTSURL != "" {
+			if u, err := url.Parse(config.MTASTSURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				return fmt.Errorf("invalid -mtastsurl: must be an https:// URL")
+			}
+		}
+		return validation.ValidatePort(config.Port)
+	}
+
 	// Validate mutual exclusion: -smtps and -starttls cannot be used together
 	if config.SMTPS && config.StartTLS {
 		return fmt.Errorf("cannot use both -smtps and -starttls flags simultaneously")
@@ -289,6 +757,14 @@ func validateConfiguration(config *Config) error {
 		return fmt.Errorf("invalid proxy URL: %w", err)
 	}
 
+	// Validate retry settings
+	if config.MaxRetries < 0 {
+		return fmt.Errorf("-maxretries must not be negative, got %d", config.MaxRetries)
+	}
+	if config.RetryDelay < 0 {
+		return fmt.Errorf("-retrydelay must not be negative, got %v", config.RetryDelay)
+	}
+
 	// Action-specific validation
 	switch config.Action {
 	case ActionTestAuth:
@@ -313,14 +789,63 @@ func validateConfiguration(config *Config) error {
 			return fmt.Errorf("testauth requires -password (or -accesstoken for XOAUTH2)")
 		}
 
+	case ActionTestStartTLS:
+		if config.TLSSweep && config.DANE {
+			return fmt.Errorf("-tlssweep cannot be combined with -dane (the sweep does not verify certificates)")
+		}
+
+	case ActionTestRelay:
+		if config.ExternalDomain == "" {
+			config.ExternalDomain = DefaultExternalDomain
+		}
+		if config.Domain == "" {
+			return fmt.Errorf("testrelay requires -domain (a domain the server accepts mail for)")
+		}
+		if err := validation.ValidateHostname(config.Domain); err != nil {
+			return fmt.Errorf("invalid domain: %w", err)
+		}
+		if err := validation.ValidateHostname(config.ExternalDomain); err != nil {
+			return fmt.Errorf("invalid external domain: %w", err)
+		}
+		if strings.EqualFold(config.Domain, config.ExternalDomain) {
+			return fmt.Errorf("-externaldomain must differ from -domain")
+		}
+		if config.Username != "" && config.Password == "" && config.AccessToken == "" {
+			return fmt.Errorf("authenticated relay tests require -password (or -accesstoken for XOAUTH2)")
+		}
+
+	case ActionVerifyRcpt:
+		if len(config.To) == 0 {
+			return fmt.Errorf("verifyrcpt requires -to (addresses to verify)")
+		}
+		for _, email := range config.To {
+			if err := validation.ValidateEmail(strings.TrimSpace(email)); err != nil {
+				return fmt.Errorf("invalid address: %w", err)
+			}
+		}
+		if config.From != "" {
+			if err := validation.ValidateEmail(config.From); err != nil {
+				return fmt.Errorf("invalid sender email: %w", err)
+			}
+		}
+		if config.Username != "" && config.Password == "" && config.AccessToken == "" {
+			return fmt.Errorf("authenticated verification requires -password (or -accesstoken for XOAUTH2)")
+		}
+
 	case ActionSendMail:
-		if config.From == "" {
+		if err := validateMessageSource(config); err != nil {
+			return err
+		}
+		// An -eml message supplies the envelope from its headers when -from/-to are omitted
+		if config.From == "" && config.EML == "" {
 			return fmt.Errorf("sendmail requires -from")
 		}
-		if err := validation.ValidateEmail(config.From); err != nil {
-			return fmt.Errorf("invalid sender email: %w", err)
+		if config.From != "" {
+			if err := validation.ValidateEmail(config.From); err != nil {
+				return fmt.Errorf("invalid sender email: %w", err)
+			}
 		}
-		if len(config.To) == 0 {
+		if len(config.To) == 0 && config.EML == "" && config.Bulk == "" {
 			return fmt.Errorf("sendmail requires -to")
 		}
 		for _, email := range config.To {
@@ -328,10 +853,79 @@ func validateConfiguration(config *Config) error {
 				return fmt.Errorf("invalid recipient email: %w", err)
 			}
 		}
-		if config.Subject == "" {
+		if config.Subject == "" && config.EML == "" {
 			return fmt.Errorf("sendmail requires -subject")
 		}
+		if _, err := protocol.ParseDSNNotify(config.DSNNotify); err != nil {
+			return fmt.Errorf("invalid -dsn-notify: %w", err)
+		}
+		if _, err := protocol.ParseDSNRet(config.DSNRet); err != nil {
+			return fmt.Errorf("invalid -dsn-ret: %w", err)
+		}
+		if config.Chunking && config.ChunkSize <= 0 {
+			return fmt.Errorf("-chunksize must be positive, got %d", config.ChunkSize)
+		}
+		if config.BinaryMIME && !config.Chunking {
+			return fmt.Errorf("-binarymime requires -chunking (BINARYMIME content can only be sent with BDAT)")
+		}
+		if config.GreylistMax < 0 {
+			return fmt.Errorf("-greylistmax must not be negative, got %v", config.GreylistMax)
+		}
+		if config.GreylistMax > 0 && config.GreylistDelay < time.Second {
+			return fmt.Errorf("-greylistdelay must be at least 1 second, got %v", config.GreylistDelay)
+		}
+		for i, path := range config.Attachments {
+			if err := validation.ValidateFilePath(path, fmt.Sprintf("Attachment file #%d", i+1)); err != nil {
+				return fmt.Errorf("invalid attachment: %w", err)
+			}
+		}
+		if len(config.InlineImages) > 0 && config.BodyHTML == "" {
+			return fmt.Errorf("-inlineimages requires -bodyhtml")
+		}
+		for i, path := range config.InlineImages {
+			if err := validation.ValidateFilePath(path, fmt.Sprintf("Inline image #%d", i+1)); err != nil {
+				return fmt.Errorf("invalid inline image: %w", err)
+			}
+		}
+		if err := validateInlineImageNames(config.InlineImages); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateMessageSource validates the -eml and -bulk message sources of sendmail.
+func validateMessageSource(config *Config) error {
+	if config.EML != "" && config.Bulk != "" {
+		return fmt.Errorf("-eml cannot be combined with -bulk")
+	}
+	if config.RegenHeaders && config.EML == "" {
+		return fmt.Errorf("-regenheaders requires -eml")
+	}
+	if config.Template != "" && config.Bulk == "" {
+		return fmt.Errorf("-template requires -bulk")
+	}
+
+	if config.EML != "" {
+		if err := validation.ValidateFilePath(config.EML, "Message file"); err != nil {
+			return fmt.Errorf("invalid -eml: %w", err)
+		}
+		if config.BodyHTML != "" || len(config.Attachments) > 0 || len(config.InlineImages) > 0 {
+			return fmt.Errorf("-eml sends the message as written and cannot be combined with -bodyhtml, -attachments or -inlineimages")
+		}
 	}
 
+	if config.Bulk != "" {
+		if err := validation.ValidateFilePath(config.Bulk, "Recipient file"); err != nil {
+			return fmt.Errorf("invalid -bulk: %w", err)
+		}
+		if err := validation.ValidateFilePath(config.Template, "Template file"); err != nil {
+			return fmt.Errorf("invalid -template: %w", err)
+		}
+		if len(config.To) > 0 {
+			return fmt.Errorf("-bulk takes the recipients from the CSV file and cannot be combined with -to")
+		}
+	}
 	return nil
 }
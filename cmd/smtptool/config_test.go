@@ -4,8 +4,11 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestValidateConfiguration_SMTPSAndSTARTTLS tests mutual exclusion of SMTPS and STARTTLS flags
@@ -307,6 +310,177 @@ func TestValidateConfiguration_Actions(t *testing.T) {
 	})
 }
 
+// TestValidateConfiguration_Chunking tests validation of the BDAT-related flags
+func TestValidateConfiguration_Chunking(t *testing.T) {
+	tests := []struct {
+		name       string
+		chunking   bool
+		chunkSize  int
+		binaryMIME bool
+		errorMsg   string
+	}{
+		{name: "Chunking with default size", chunking: true, chunkSize: DefaultChunkSize},
+		{name: "BINARYMIME with chunking", chunking: true, chunkSize: 1024, binaryMIME: true},
+		{name: "Zero chunk size", chunking: true, chunkSize: 0, errorMsg: "-chunksize must be positive"},
+		{name: "BINARYMIME without chunking", chunkSize: DefaultChunkSize, binaryMIME: true, errorMsg: "-binarymime requires -chunking"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionSendMail
+			config.Host = "smtp.example.com"
+			config.From = "sender@example.com"
+			config.To = []string{"recipient@example.com"}
+			config.Chunking = tt.chunking
+			config.ChunkSize = tt.chunkSize
+			config.BinaryMIME = tt.binaryMIME
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_Greylisting tests validation of the greylisting retry window
+func TestValidateConfiguration_Greylisting(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		max      time.Duration
+		errorMsg string
+	}{
+		{name: "Defaults", delay: DefaultGreylistDelay, max: DefaultGreylistMax},
+		{name: "Disabled ignores delay", delay: 0, max: 0},
+		{name: "Negative window", delay: DefaultGreylistDelay, max: -time.Second, errorMsg: "-greylistmax must not be negative"},
+		{name: "Delay below a second", delay: 0, max: time.Minute, errorMsg: "-greylistdelay must be at least 1 second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionSendMail
+			config.Host = "smtp.example.com"
+			config.From = "sender@example.com"
+			config.To = []string{"recipient@example.com"}
+			config.GreylistDelay = tt.delay
+			config.GreylistMax = tt.max
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_MessageSource tests validation of -eml, -bulk and their companion flags
+func TestValidateConfiguration_MessageSource(t *testing.T) {
+	dir := t.TempDir()
+	emlFile := filepath.Join(dir, "message.eml")
+	csvFile := filepath.Join(dir, "recipients.csv")
+	for _, path := range []string{emlFile, csvFile} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		errorMsg string
+	}{
+		{name: "eml without envelope flags", modify: func(c *Config) { c.From, c.To, c.EML = "", nil, emlFile }},
+		{name: "eml with regenerated headers", modify: func(c *Config) { c.EML, c.RegenHeaders = emlFile, true }},
+		{name: "bulk with sender", modify: func(c *Config) { c.To, c.Bulk = nil, csvFile }},
+		{name: "eml and bulk", modify: func(c *Config) { c.To, c.EML, c.Bulk = nil, emlFile, csvFile }, errorMsg: "-eml cannot be combined with -bulk"},
+		{name: "eml with attachments", modify: func(c *Config) { c.EML, c.Attachments = emlFile, []string{csvFile} }, errorMsg: "cannot be combined with -bodyhtml"},
+		{name: "bulk with -to", modify: func(c *Config) { c.Bulk = csvFile }, errorMsg: "cannot be combined with -to"},
+		{name: "bulk without sender", modify: func(c *Config) { c.From, c.To, c.Bulk = "", nil, csvFile }, errorMsg: "sendmail requires -from"},
+		{name: "regenheaders without eml", modify: func(c *Config) { c.RegenHeaders = true }, errorMsg: "-regenheaders requires -eml"},
+		{name: "template without bulk", modify: func(c *Config) { c.Template = csvFile }, errorMsg: "-template requires -bulk"},
+		{name: "missing eml file", modify: func(c *Config) { c.EML = filepath.Join(dir, "missing.eml") }, errorMsg: "invalid -eml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionSendMail
+			config.Host = "smtp.example.com"
+			config.From = "sender@example.com"
+			config.To = []string{"recipient@example.com"}
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_IPVersion tests validation of -ipversion and -sourceip
+func TestValidateConfiguration_IPVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		errorMsg string
+	}{
+		{name: "IPv6 with IPv6 source", modify: func(c *Config) { c.IPVersion, c.SourceIP = "6", "2001:db8::25" }},
+		{name: "Both for testconnect", modify: func(c *Config) { c.Action, c.IPVersion = ActionTestConnect, "both" }},
+		{name: "Both for testmx", modify: func(c *Config) { c.Action, c.Host, c.Domain, c.IPVersion = ActionTestMX, "", "example.com", "both" }},
+		{name: "Invalid version", modify: func(c *Config) { c.IPVersion = "5" }, errorMsg: "invalid -ipversion"},
+		{name: "Invalid source", modify: func(c *Config) { c.SourceIP = "mail.example.com" }, errorMsg: "not an IP address"},
+		{name: "IPv4 with IPv6 source", modify: func(c *Config) { c.IPVersion, c.SourceIP = "4", "2001:db8::25" }, errorMsg: "not an IPv4 address"},
+		{name: "Both with source", modify: func(c *Config) { c.IPVersion, c.SourceIP = "both", "192.0.2.25" }, errorMsg: "cannot be combined with -ipversion both"},
+		{name: "Version with proxy", modify: func(c *Config) { c.IPVersion, c.ProxyURL = "6", "socks5://127.0.0.1:1080" }, errorMsg: "cannot be combined with -proxy"},
+		{name: "Both for sendmail", modify: func(c *Config) { c.IPVersion = "both" }, errorMsg: "not supported by sendmail"},
+		{name: "Both for mtasts", modify: func(c *Config) { c.Action, c.Host, c.Domain, c.IPVersion = ActionMTASTS, "", "example.com", "both" }, errorMsg: "not supported by mtasts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionSendMail
+			config.Host = "smtp.example.com"
+			config.From = "sender@example.com"
+			config.To = []string{"recipient@example.com"}
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
 // TestParseBoolEnv tests boolean environment variable parsing
 func TestParseBoolEnv(t *testing.T) {
 	tests := []struct {
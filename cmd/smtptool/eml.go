@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"msgraphtool/internal/common/validation"
+)
+
+// emlMessage is a pre-built RFC 5322 message loaded with -eml.
+type emlMessage struct {
+	Data      []byte   // Message with CRLF line endings, otherwise as written
+	From      string   // Address of the From header
+	To        []string // Addresses of the To, Cc and Bcc headers
+	Subject   string   // Decoded Subject header
+	MessageID string   // Message-ID without angle brackets
+}
+
+// loadEMLMessage reads a message file for sending. Line endings are
+// normalized to CRLF, as SMTP requires; all other bytes are sent as written.
+// With regenerate, Message-ID and Date are replaced (or added) so the server
+// does not treat a replayed message as a duplicate.
+func loadEMLMessage(path string, regenerate bool, host string) (*emlMessage, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	data := normalizeCRLF(raw)
+
+	if regenerate {
+		data = replaceHeader(data, "Date", time.Now().Format(time.RFC1123Z))
+		data = replaceHeader(data, "Message-ID", "<"+generateMessageID(host)+">")
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an RFC 5322 message: %w", path, err)
+	}
+
+	eml := &emlMessage{
+		Data:      data,
+		MessageID: strings.Trim(strings.TrimSpace(msg.Header.Get("Message-ID")), "<>"),
+	}
+	eml.Subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		eml.Subject = msg.Header.Get("Subject")
+	}
+	if from, err := msg.Header.AddressList("From"); err == nil && len(from) > 0 {
+		eml.From = from[0].Address
+	}
+	for _, field := range []string{"To", "Cc", "Bcc"} {
+		addrs, err := msg.Header.AddressList(field)
+		if err != nil {
+			continue // Missing or unparsable header; -to can supply the recipients
+		}
+		for _, addr := range addrs {
+			eml.To = append(eml.To, addr.Address)
+		}
+	}
+	return eml, nil
+}
+
+// prepareEMLMessage loads the -eml message. The envelope comes from -from
+// and -to or, when they are omitted, from the message's From, To, Cc and Bcc
+// headers; the config is updated so reports and CSV rows show it.
+func prepareEMLMessage(config *Config) (*emlMessage, error) {
+	eml, err := loadEMLMessage(config.EML, config.RegenHeaders, config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.From == "" {
+		if eml.From == "" {
+			return nil, fmt.Errorf("message %s has no From address; set -from", config.EML)
+		}
+		config.From = eml.From
+	}
+	if len(config.To) == 0 {
+		if len(eml.To) == 0 {
+			return nil, fmt.Errorf("message %s has no To, Cc or Bcc addresses; set -to", config.EML)
+		}
+		config.To = eml.To
+	}
+	for _, addr := range append([]string{config.From}, config.To...) {
+		if err := validation.ValidateEmail(addr); err != nil {
+			return nil, fmt.Errorf("invalid address in message %s: %w", config.EML, err)
+		}
+	}
+	config.Subject = eml.Subject
+	return eml, nil
+}
+
+// normalizeCRLF converts bare LF and bare CR line endings to CRLF.
+func normalizeCRLF(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(data)/40)
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '\r' && i+1 < len(data) && data[i+1] == '\n':
+			buf.WriteString("\r\n")
+			i++
+		case c == '\r' || c == '\n':
+			buf.WriteString("\r\n")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.Bytes()
+}
+
+// replaceHeader replaces every occurrence of a header field (including its
+// folded continuation lines) in the header section of a CRLF message, or
+// adds the field at the top when it is missing.
+func replaceHeader(data []byte, name, value string) []byte {
+	headerEnd := bytes.Index(data, []byte("\r\n\r\n"))
+	if headerEnd < 0 {
+		headerEnd = len(data)
+	} else {
+		headerEnd += 2 // Keep the CRLF of the last header line
+	}
+	field := []byte(name + ": " + value + "\r\n")
+
+	var out bytes.Buffer
+	replaced, skipping := false, false
+	for _, line := range bytes.SplitAfter(data[:headerEnd], []byte("\r\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if !skipping {
+				out.Write(line)
+			}
+			continue
+		}
+		fieldName, _, _ := bytes.Cut(line, []byte(":"))
+		skipping = strings.EqualFold(strings.TrimSpace(string(fieldName)), name)
+		if !skipping {
+			out.Write(line)
+		} else if !replaced {
+			out.Write(field)
+			replaced = true
+		}
+	}
+	if !replaced {
+		return append(append(field, out.Bytes()...), data[headerEnd:]...)
+	}
+	return append(out.Bytes(), data[headerEnd:]...)
+}
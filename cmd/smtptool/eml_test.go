@@ -0,0 +1,127 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestFile writes content to a file in a temporary directory.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testEML = "Message-ID: <original@example.com>\n" +
+	"Date: Mon, 12 Oct 2026 10:00:00 +0000\n" +
+	"From: Customer <customer@example.com>\n" +
+	"To: support@example.com\n" +
+	"Cc: Team <team@example.com>,\n" +
+	" other@example.com\n" +
+	"Subject: =?UTF-8?Q?Caf=C3=A9_order?=\n" +
+	"\n" +
+	"Message-ID: in the body stays\n"
+
+// TestLoadEMLMessage tests envelope extraction and CRLF normalization
+func TestLoadEMLMessage(t *testing.T) {
+	path := writeTestFile(t, "message.eml", testEML)
+
+	eml, err := loadEMLMessage(path, false, "smtp.example.com")
+	if err != nil {
+		t.Fatalf("loadEMLMessage() error = %v", err)
+	}
+	if eml.From != "customer@example.com" || eml.Subject != "Café order" || eml.MessageID != "original@example.com" {
+		t.Errorf("loadEMLMessage() = %+v", eml)
+	}
+	if want := []string{"support@example.com", "team@example.com", "other@example.com"}; !reflect.DeepEqual(eml.To, want) {
+		t.Errorf("To = %q, want %q", eml.To, want)
+	}
+	if want := strings.ReplaceAll(testEML, "\n", "\r\n"); string(eml.Data) != want {
+		t.Errorf("Data = %q, want the message with CRLF line endings", eml.Data)
+	}
+}
+
+// TestLoadEMLMessage_Regenerate tests that Message-ID and Date are replaced in the header only
+func TestLoadEMLMessage_Regenerate(t *testing.T) {
+	path := writeTestFile(t, "message.eml", testEML)
+
+	eml, err := loadEMLMessage(path, true, "smtp.example.com")
+	if err != nil {
+		t.Fatalf("loadEMLMessage() error = %v", err)
+	}
+	data := string(eml.Data)
+	if strings.Contains(data, "original@example.com") || strings.Contains(data, "12 Oct 2026") {
+		t.Errorf("original Message-ID or Date kept:\n%s", data)
+	}
+	if !strings.HasSuffix(eml.MessageID, ".smtptool@smtp.example.com") || !strings.Contains(data, "<"+eml.MessageID+">") {
+		t.Errorf("MessageID = %q, not written to the header:\n%s", eml.MessageID, data)
+	}
+	if !strings.Contains(data, "\r\n\r\nMessage-ID: in the body stays\r\n") {
+		t.Errorf("body modified:\n%s", data)
+	}
+	if strings.Count(data, "Date: ") != 1 {
+		t.Errorf("expected exactly one Date header:\n%s", data)
+	}
+}
+
+// TestReplaceHeader tests folded and missing header fields
+func TestReplaceHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "folded field",
+			input: "X-A: 1\r\nDate: Mon,\r\n 12 Oct 2026\r\nX-B: 2\r\n\r\nbody\r\n",
+			want:  "X-A: 1\r\nDate: new\r\nX-B: 2\r\n\r\nbody\r\n",
+		},
+		{
+			name:  "missing field",
+			input: "X-A: 1\r\n\r\nDate: body\r\n",
+			want:  "Date: new\r\nX-A: 1\r\n\r\nDate: body\r\n",
+		},
+		{
+			name:  "case-insensitive name",
+			input: "DATE: old\r\n\r\n",
+			want:  "Date: new\r\n\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(replaceHeader([]byte(tt.input), "Date", "new")); got != tt.want {
+				t.Errorf("replaceHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPrepareEMLMessage tests that -from and -to take precedence over the message headers
+func TestPrepareEMLMessage(t *testing.T) {
+	config := NewConfig()
+	config.EML = writeTestFile(t, "message.eml", testEML)
+	config.To = []string{"override@example.com"}
+
+	if _, err := prepareEMLMessage(config); err != nil {
+		t.Fatalf("prepareEMLMessage() error = %v", err)
+	}
+	if config.From != "customer@example.com" || !reflect.DeepEqual(config.To, []string{"override@example.com"}) {
+		t.Errorf("envelope = %s -> %q", config.From, config.To)
+	}
+
+	config.EML = writeTestFile(t, "nofrom.eml", "Subject: x\r\n\r\nbody\r\n")
+	config.From = ""
+	if _, err := prepareEMLMessage(config); err == nil || !strings.Contains(err.Error(), "set -from") {
+		t.Errorf("prepareEMLMessage() error = %v, want missing From", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/common/retry"
+	"msgraphtool/internal/smtp/protocol"
+)
+
+// deliverAfterGreylisting retries a greylisted message every GreylistDelay
+// until the server accepts it or GreylistMax has passed since the first
+// deferral, and returns how long acceptance took.
+//
+// Greylisters remember the client address, sender and recipients of the
+// deferred attempt, so every retry opens a new session with the same settings
+// and sends the same envelope and message, Message-ID included. Retries dial
+// remote, the server address of the deferred session, so that a host name
+// with several addresses is not answered by a server that has not seen the
+// first attempt; remote is empty when the address is not known. Each deferral
+// is logged as a GREYLISTED row. Transient failures of a retry (a dropped
+// connection, a 421) are retried within the window; other failures end it.
+// Errors are permanent for the action's retry loop, which would otherwise
+// start the whole window again with a new message.
+func deliverAfterGreylisting(ctx context.Context, config *Config, remote, messageID string, data []byte, first *protocol.ReplyError, csvLogger logger.Logger, slogLogger *slog.Logger) (time.Duration, error) {
+	if remote != "" {
+		ctx = dualstack.WithAddress(ctx, config.Host, remote)
+	}
+	start := time.Now()
+	deadline := start.Add(config.GreylistMax)
+
+	logDeferral := func(attempt int, reply *protocol.ReplyError) {
+		elapsed := time.Since(start)
+		fmt.Printf("\n⏳ Greylisted (attempt %d, %v after the first deferral): %v\n", attempt, elapsed.Round(time.Second), reply)
+		logger.LogWarn(slogLogger, "Message greylisted", "attempt", attempt, "elapsed", elapsed, "reply", reply.Error())
+		if logErr := csvLogger.WriteRow(sendMailRow(config, "GREYLISTED", fmt.Sprintf("%d", reply.Code), messageID, formatSeconds(elapsed), reply.Error())); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+	logDeferral(1, first)
+
+	var lastErr error = first
+	for attempt := 2; ; attempt++ {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return 0, retry.Permanent(fmt.Errorf("not accepted within -greylistmax %v: %w", config.GreylistMax, lastErr))
+		}
+		wait := min(config.GreylistDelay, remaining)
+		fmt.Printf("  Retrying in %v (%v left of -greylistmax)...\n\n", wait.Round(time.Second), remaining.Round(time.Second))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, retry.Permanent(fmt.Errorf("greylisting retry cancelled: %w", ctx.Err()))
+		case <-timer.C:
+		}
+
+		client, _, err := openMailSession(ctx, config, slogLogger)
+		if err == nil {
+			err = client.SendMail(config.From, config.To, data)
+			client.Close()
+		}
+		if err == nil {
+			accepted := time.Since(start)
+			fmt.Printf("✓ Accepted %v after the first deferral (attempt %d)\n", accepted.Round(time.Second), attempt)
+			logger.LogInfo(slogLogger, "Greylisted message accepted", "attempt", attempt, "delay", accepted)
+			return accepted, nil
+		}
+
+		var reply *protocol.ReplyError
+		switch {
+		case errors.As(err, &reply) && reply.Greylisted():
+			logDeferral(attempt, reply)
+		case retry.IsTransientError(err):
+			fmt.Printf("\n⚠ Attempt %d failed with a transient error: %v\n", attempt, err)
+			logger.LogWarn(slogLogger, "Greylisting retry failed", "attempt", attempt, "error", err)
+		default:
+			return 0, retry.Permanent(err)
+		}
+		lastErr = err
+	}
+}
+
+// formatSeconds formats a duration as whole seconds for CSV columns.
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.0f", d.Seconds())
+}
@@ -0,0 +1,134 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"msgraphtool/internal/common/retry"
+	"msgraphtool/internal/smtp/protocol"
+)
+
+// greylistingServer defers RCPT TO with a greylisting reply the first
+// deferrals times, then accepts.
+func greylistingServer(t *testing.T, deferrals int) *fakeSMTPServer {
+	var mu sync.Mutex
+	return newFakeSMTPServer(t, []string{"ENHANCEDSTATUSCODES"}, func(cmd string) string {
+		if !strings.HasPrefix(cmd, "RCPT TO:") {
+			return ""
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if deferrals > 0 {
+			deferrals--
+			return "450 4.2.0 <bob@example.com>: Recipient address rejected: Greylisted, see http://postgrey.schweikert.ch/help/example.com.html\r\n"
+		}
+		return ""
+	})
+}
+
+// greylistConfig returns a sendmail configuration for the fake server with
+// millisecond greylisting intervals.
+func greylistConfig(server *fakeSMTPServer) *Config {
+	config := NewConfig()
+	config.Action = ActionSendMail
+	config.Host = "127.0.0.1"
+	config.Port = server.port()
+	config.Timeout = 5 * time.Second
+	config.From = "alice@example.com"
+	config.To = []string{"bob@example.com"}
+	config.GreylistDelay = 10 * time.Millisecond
+	config.GreylistMax = 5 * time.Second
+	return config
+}
+
+// TestSendMail_Greylisting tests that a greylisted message is resent unchanged until accepted
+func TestSendMail_Greylisting(t *testing.T) {
+	server := greylistingServer(t, 2)
+	csvLogger := &recordingLogger{}
+
+	if err := sendMail(context.Background(), greylistConfig(server), csvLogger, nil); err != nil {
+		t.Fatalf("sendMail() error = %v", err)
+	}
+
+	if len(csvLogger.rows) != 3 {
+		t.Fatalf("got %d CSV rows, want 2 GREYLISTED and 1 SUCCESS: %q", len(csvLogger.rows), csvLogger.rows)
+	}
+	messageID := csvLogger.rows[2][8]
+	for i, row := range csvLogger.rows[:2] {
+		if row[1] != "GREYLISTED" || row[7] != "450" || row[8] != messageID || row[9] == "" {
+			t.Errorf("row %d = %q, want GREYLISTED 450 with the same Message-ID and a delay", i+1, row)
+		}
+	}
+	if row := csvLogger.rows[2]; row[1] != "SUCCESS" || row[9] == "" {
+		t.Errorf("row 3 = %q, want SUCCESS with the greylisting delay", row)
+	}
+
+	mailFrom := 0
+	for _, cmd := range server.recorded() {
+		if strings.HasPrefix(cmd, "MAIL FROM:<alice@example.com>") {
+			mailFrom++
+		}
+	}
+	if mailFrom != 3 {
+		t.Errorf("got %d transactions, want 3", mailFrom)
+	}
+	server.mu.Lock()
+	data := server.data
+	server.mu.Unlock()
+	if !strings.Contains(data, "<"+messageID+">") {
+		t.Errorf("accepted message does not carry Message-ID %s:\n%s", messageID, data)
+	}
+}
+
+// TestDeliverAfterGreylisting_SameAddress tests that retries dial the server address of the deferred session
+func TestDeliverAfterGreylisting_SameAddress(t *testing.T) {
+	server := greylistingServer(t, 0)
+	config := greylistConfig(server)
+	config.Host = "mx.invalid"
+	first := &protocol.ReplyError{Code: 450, Message: "4.2.0 Greylisted", Enhanced: "4.2.0"}
+
+	if _, err := deliverAfterGreylisting(context.Background(), config, "127.0.0.1", "<id@example.com>", []byte("Subject: test\r\n\r\nbody\r\n"), first, &recordingLogger{}, nil); err != nil {
+		t.Fatalf("deliverAfterGreylisting() error = %v, want the retry to reach 127.0.0.1", err)
+	}
+}
+
+// TestSendMail_GreylistingWindow tests that the window ends the retries with a permanent error
+func TestSendMail_GreylistingWindow(t *testing.T) {
+	server := greylistingServer(t, 1000)
+	config := greylistConfig(server)
+	config.GreylistMax = 50 * time.Millisecond
+	csvLogger := &recordingLogger{}
+
+	err := sendMail(context.Background(), config, csvLogger, nil)
+	if err == nil || !strings.Contains(err.Error(), "-greylistmax") {
+		t.Fatalf("sendMail() error = %v, want window exceeded", err)
+	}
+	if retry.IsTransientError(err) {
+		t.Errorf("error after the greylisting window is retryable: %v", err)
+	}
+	if last := csvLogger.rows[len(csvLogger.rows)-1]; last[1] != "FAILURE" {
+		t.Errorf("last row = %q, want FAILURE", last)
+	}
+}
+
+// TestSendMail_GreylistingDisabled tests that -greylistmax 0 reports greylisting as a transient failure
+func TestSendMail_GreylistingDisabled(t *testing.T) {
+	server := greylistingServer(t, 1)
+	config := greylistConfig(server)
+	config.GreylistMax = 0
+	csvLogger := &recordingLogger{}
+
+	err := sendMail(context.Background(), config, csvLogger, nil)
+	if err == nil || !retry.IsTransientError(err) {
+		t.Errorf("sendMail() error = %v, want a retryable failure", err)
+	}
+	if len(csvLogger.rows) != 1 || csvLogger.rows[0][1] != "FAILURE" {
+		t.Errorf("rows = %q, want one FAILURE", csvLogger.rows)
+	}
+}
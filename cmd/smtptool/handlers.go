@@ -4,12 +4,130 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
+	"strings"
+	"time"
 
+	"msgraphtool/internal/common/dns"
+	"msgraphtool/internal/common/dualstack"
 	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/common/retry"
+	"msgraphtool/internal/common/timing"
 )
 
-// executeAction dispatches to the appropriate action handler.
+// retryableActions are single sessions that can safely be run again. Actions
+// that report several targets or messages (verifyrcpt, testrelay, certwatch)
+// are not retried, so they never log or send anything twice.
+var retryableActions = map[string]bool{
+	ActionTestConnect:  true,
+	ActionTestStartTLS: true,
+	ActionTestAuth:     true,
+	ActionSendMail:     true,
+}
+
+// addressActions test -host itself, so that -ipversion both can run them
+// once per address, and their rows have an IP_Address column. testmx tests
+// each address of its MX hosts instead. sendmail is not one of them: it would
+// deliver the message once per address.
+var addressActions = map[string]bool{
+	ActionTestConnect:  true,
+	ActionTestStartTLS: true,
+	ActionTestAuth:     true,
+	ActionTestRelay:    true,
+	ActionVerifyRcpt:   true,
+}
+
+// executeAction runs the action, once per address of -host with
+// -ipversion both.
 func executeAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	if !addressActions[config.Action] {
+		return executeWithRetry(ctx, config, csvLogger, slogLogger)
+	}
+	if config.IPVersion == dualstack.Both {
+		return executeEachAddress(ctx, config, csvLogger, slogLogger)
+	}
+	return executeWithRetry(ctx, config, withAddress(csvLogger, ""), slogLogger)
+}
+
+// withAddress adds the IP_Address column before Error, naming address. Rows
+// of a run without -ipversion both leave it empty, so that runs with and
+// without it can share the action's daily log file.
+func withAddress(csvLogger logger.Logger, address string) logger.Logger {
+	return logger.WithColumns(csvLogger, []string{"IP_Address"}, func() []string { return []string{address} })
+}
+
+// executeEachAddress runs the action against every IPv4 and IPv6 address of
+// -host in turn and prints the outcome per address. Each row names the
+// address in an IP_Address column before Error.
+func executeEachAddress(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	lookupCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	addresses, err := dualstack.Lookup(lookupCtx, dns.NewResolver(config.DNSServer), config.Host, dualstack.Both)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", config.Host, err)
+	}
+	fmt.Printf("Testing %d address(es) of %s: %s\n", len(addresses), config.Host, strings.Join(addresses, ", "))
+
+	results := dualstack.ForEach(ctx, os.Stdout, config.Host, addresses, func(ctx context.Context, address string) error {
+		return executeWithRetry(ctx, config, withAddress(csvLogger, address), slogLogger)
+	})
+	dualstack.Print(os.Stdout, config.Host, results)
+	for _, r := range results {
+		if r.Err != nil {
+			logger.LogWarn(slogLogger, "Address failed", "host", config.Host, "address", r.Address, "error", r.Err)
+		}
+	}
+	return dualstack.Err(config.Host, results)
+}
+
+// executeWithRetry runs the action, retrying transient failures (4xx replies,
+// refused or dropped connections) up to -maxretries times with backoff.
+// Each attempt writes its own CSV row.
+func executeWithRetry(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	if !retryableActions[config.Action] || config.MaxRetries <= 0 {
+		return runTimedAction(ctx, config, csvLogger, slogLogger)
+	}
+
+	attempts := config.MaxRetries + 1
+	return retry.Do(ctx, retry.Policy{
+		MaxRetries: config.MaxRetries,
+		BaseDelay:  config.RetryDelay,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			fmt.Printf("\n⟳ Attempt %d/%d failed with a transient error: %v\n  Retrying in %v...\n\n",
+				attempt, attempts, err, delay.Round(time.Millisecond))
+			logger.LogWarn(slogLogger, "Transient failure, retrying",
+				"attempt", attempt, "attempts", attempts, "delay", delay, "error", err)
+		},
+	}, func(attempt int) error {
+		return runTimedAction(ctx, config, logger.WithAttempt(csvLogger, attempt, attempts), slogLogger)
+	})
+}
+
+// runTimedAction runs the action and, for actions that hold one session with
+// one server, breaks its duration down into phases: printed after the
+// action, and added to each CSV/JSON row as the DNS_ms ... Transaction_ms
+// columns before Error. The TLS sweep and -bulk sends hold many sessions and
+// are not broken down, but log the columns empty so that every row of the
+// action's file has the same columns.
+func runTimedAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	if !retryableActions[config.Action] {
+		return runAction(ctx, config, csvLogger, slogLogger)
+	}
+
+	rec := &timing.Recorder{}
+	if (config.Action == ActionTestStartTLS && config.TLSSweep) || (config.Action == ActionSendMail && config.Bulk != "") {
+		return runAction(ctx, config, logger.WithColumns(csvLogger, timing.Columns(), rec.Values), slogLogger)
+	}
+	err := runAction(timing.WithRecorder(ctx, rec), config, logger.WithColumns(csvLogger, timing.Columns(), rec.Values), slogLogger)
+	rec.Print(os.Stdout)
+	if !rec.Empty() {
+		logger.LogInfo(slogLogger, "Connection timing", "phases", rec.String())
+	}
+	return err
+}
+
+// runAction dispatches to the appropriate action handler.
+func runAction(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
 	switch config.Action {
 	case ActionTestConnect:
 		return testConnect(ctx, config, csvLogger, slogLogger)
@@ -19,6 +137,18 @@ func executeAction(ctx context.Context, config *Config, csvLogger logger.Logger,
 		return testAuth(ctx, config, csvLogger, slogLogger)
 	case ActionSendMail:
 		return sendMail(ctx, config, csvLogger, slogLogger)
+	case ActionParseDSN:
+		return parseDSN(config, csvLogger, slogLogger)
+	case ActionTestMX:
+		return testMX(ctx, config, csvLogger, slogLogger)
+	case ActionMTASTS:
+		return checkMTASTS(ctx, config, csvLogger, slogLogger)
+	case ActionTestRelay:
+		return testRelay(ctx, config, csvLogger, slogLogger)
+	case ActionVerifyRcpt:
+		return verifyRecipients(ctx, config, csvLogger, slogLogger)
+	case ActionCertWatch:
+		return watchCertificates(ctx, config, csvLogger, slogLogger)
 	default:
 		return fmt.Errorf("unknown action: %s", config.Action)
 	}
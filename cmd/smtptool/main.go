@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"msgraphtool/internal/common/certwatch"
 	"msgraphtool/internal/common/logger"
 	"msgraphtool/internal/common/version"
 )
@@ -14,10 +16,24 @@ import (
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitCodeError makes the tool exit with a specific status code instead of 1,
+// e.g. a Nagios plugin state.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+func (e *exitCodeError) Unwrap() error { return e.err }
+
 func run() error {
 	// Setup signal handling for graceful shutdown
 	ctx, cancel := setupSignalHandling()
@@ -36,7 +52,11 @@ func run() error {
 
 	// Validate configuration
 	if err := validateConfiguration(config); err != nil {
-		return fmt.Errorf("configuration error: %w", err)
+		err = fmt.Errorf("configuration error: %w", err)
+		if config.Action == ActionCertWatch {
+			return &exitCodeError{code: int(certwatch.StatusUnknown), err: err}
+		}
+		return err
 	}
 
 	// Setup structured logger
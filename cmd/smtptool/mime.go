@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// messageContent describes everything needed to compose an RFC 5322 message.
+// Only From, To and Subject are required; the composer picks the simplest MIME
+// structure that can carry the remaining fields:
+//
+//	text only                     -> text/plain
+//	text + HTML                   -> multipart/alternative
+//	HTML + inline images          -> multipart/related (inside alternative)
+//	any of the above + attachments -> multipart/mixed
+type messageContent struct {
+	MessageID    string // Message-ID without angle brackets (generated if empty)
+	From         string
+	To           []string
+	Subject      string
+	TextBody     string
+	HTMLBody     string
+	Attachments  []string // File paths attached with Content-Disposition: attachment
+	InlineImages []string // File paths referenced from HTML as cid:<file name>
+	UTF8Headers  bool     // Send headers as raw UTF-8 (RFC 6532); otherwise RFC 2047 encode them
+}
+
+// mimePart is one MIME entity: its own headers plus encoded body.
+type mimePart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// base64LineLength is the maximum encoded line length mandated by RFC 2045.
+const base64LineLength = 76
+
+// buildMIMEMessage constructs an RFC 5322 message with MIME structure derived
+// from the content. Plain-text messages keep the historical single-part format.
+// Defense-in-Depth: every header value (including attachment file names) passes
+// through sanitizeEmailHeader so CRLF sequences cannot inject extra headers.
+func buildMIMEMessage(content *messageContent) ([]byte, error) {
+	messageID := content.MessageID
+	if messageID == "" {
+		messageID = generateMessageID("")
+	}
+
+	from := sanitizeEmailHeader(content.From)
+	subject := sanitizeEmailHeader(content.Subject)
+	if !content.UTF8Headers {
+		// RFC 2047 encoded words; ASCII subjects are returned unchanged
+		subject = mime.QEncoding.Encode("UTF-8", subject)
+	}
+	sanitizedTo := make([]string, len(content.To))
+	for i, addr := range content.To {
+		sanitizedTo[i] = sanitizeEmailHeader(addr)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Message-ID: <%s>\r\n", sanitizeEmailHeader(messageID))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(sanitizedTo, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", subject)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	// Simple text message: no multipart structure needed
+	if content.HTMLBody == "" && len(content.Attachments) == 0 && len(content.InlineImages) == 0 {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		buf.WriteString("\r\n")
+		buf.WriteString(content.TextBody)
+		buf.WriteString("\r\n")
+		return buf.Bytes(), nil
+	}
+
+	if len(content.InlineImages) > 0 && content.HTMLBody == "" {
+		return nil, fmt.Errorf("inline images require an HTML body (-bodyhtml)")
+	}
+	if err := validateInlineImageNames(content.InlineImages); err != nil {
+		return nil, err
+	}
+
+	root, err := composeMixed(content)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if value := root.header.Get(key); value != "" {
+			fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+	buf.Write(root.body)
+
+	return buf.Bytes(), nil
+}
+
+// composeMixed wraps the message body in multipart/mixed when attachments are present.
+func composeMixed(content *messageContent) (*mimePart, error) {
+	body, err := composeAlternative(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(content.Attachments) == 0 {
+		return body, nil
+	}
+
+	parts := []*mimePart{body}
+	for _, path := range content.Attachments {
+		part, err := fileAttachmentPart(path, "attachment")
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	return multipartPart("mixed", nil, parts)
+}
+
+// composeAlternative builds the readable body: a single text part, or
+// multipart/alternative when both text and HTML representations exist.
+func composeAlternative(content *messageContent) (*mimePart, error) {
+	if content.HTMLBody == "" {
+		return textBodyPart("text/plain", content.TextBody), nil
+	}
+
+	html, err := composeRelated(content)
+	if err != nil {
+		return nil, err
+	}
+	if content.TextBody == "" {
+		return html, nil
+	}
+
+	return multipartPart("alternative", nil, []*mimePart{textBodyPart("text/plain", content.TextBody), html})
+}
+
+// composeRelated builds the HTML representation, wrapping it in
+// multipart/related together with any inline images it references.
+func composeRelated(content *messageContent) (*mimePart, error) {
+	html := textBodyPart("text/html", content.HTMLBody)
+	if len(content.InlineImages) == 0 {
+		return html, nil
+	}
+
+	parts := []*mimePart{html}
+	for _, path := range content.InlineImages {
+		part, err := fileAttachmentPart(path, "inline")
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	return multipartPart("related", map[string]string{"type": "text/html"}, parts)
+}
+
+// multipartPart assembles child parts into a multipart/<subtype> part.
+func multipartPart(subtype string, params map[string]string, parts []*mimePart) (*mimePart, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	for _, part := range parts {
+		w, err := mw.CreatePart(part.header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create MIME part: %w", err)
+		}
+		if _, err := w.Write(part.body); err != nil {
+			return nil, fmt.Errorf("failed to write MIME part: %w", err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize multipart/%s: %w", subtype, err)
+	}
+
+	typeParams := map[string]string{"boundary": mw.Boundary()}
+	for k, v := range params {
+		typeParams[k] = v
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", mime.FormatMediaType("multipart/"+subtype, typeParams))
+	return &mimePart{header: header, body: buf.Bytes()}, nil
+}
+
+// textBodyPart creates a quoted-printable encoded UTF-8 text part.
+func textBodyPart(mediaType, text string) *mimePart {
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	// Writes to a bytes.Buffer cannot fail
+	_, _ = qp.Write([]byte(normalizeLineEndings(text)))
+	_ = qp.Close()
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", mediaType+"; charset=UTF-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return &mimePart{header: header, body: buf.Bytes()}
+}
+
+// fileAttachmentPart reads a file and creates a base64 encoded part.
+// disposition is "attachment" or "inline"; inline parts also receive a
+// Content-ID equal to the file name so HTML can reference them as cid:<name>
+// (see validateInlineImageNames).
+func fileAttachmentPart(path, disposition string) (*mimePart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file %s: %w", disposition, path, err)
+	}
+
+	fileName := sanitizeEmailHeader(filepath.Base(path))
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", mime.FormatMediaType(stripMediaParams(contentType), map[string]string{"name": fileName}))
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": fileName}))
+	if disposition == "inline" {
+		header.Set("Content-ID", "<"+fileName+">")
+	}
+
+	return &mimePart{header: header, body: encodeBase64Lines(data)}, nil
+}
+
+// validateInlineImageNames checks that the file names of inline images can
+// be used as their Content-ID (RFC 2392): the HTML body references each as
+// cid:<file name>, so a name must be unique and consist of letters, digits
+// and "-_+=~" separated by single dots.
+func validateInlineImageNames(paths []string) error {
+	seen := make(map[string]int)
+	for i, path := range paths {
+		name := filepath.Base(path)
+		if !isContentIDName(name) {
+			return fmt.Errorf("inline image #%d: file name %q cannot be referenced as cid:%s (use letters, digits, dots and -_+=~ only)", i+1, name, name)
+		}
+		if first, ok := seen[name]; ok {
+			return fmt.Errorf("inline images #%d and #%d have the same file name %q, so cid:%s would be ambiguous", first, i+1, name, name)
+		}
+		seen[name] = i + 1
+	}
+	return nil
+}
+
+// isContentIDName reports whether name is a dot-atom of the characters
+// allowed by validateInlineImageNames.
+func isContentIDName(name string) bool {
+	if name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || strings.Contains(name, "..") {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune(".-_+=~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// stripMediaParams removes parameters (e.g. "; charset=utf-8") from a media type.
+func stripMediaParams(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// encodeBase64Lines base64-encodes data and wraps it at 76 characters per line.
+func encodeBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > base64LineLength {
+		buf.WriteString(encoded[:base64LineLength])
+		buf.WriteString("\r\n")
+		encoded = encoded[base64LineLength:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// normalizeLineEndings converts bare LF line endings to CRLF as required by RFC 5322.
+func normalizeLineEndings(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\n", "\r\n")
+}
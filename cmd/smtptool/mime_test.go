@@ -0,0 +1,281 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseMessage parses a composed message and returns its headers and raw body.
+func parseMessage(t *testing.T, data []byte) *mail.Message {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("composed message is not valid RFC 5322: %v\n%s", err, data)
+	}
+	return msg
+}
+
+// readParts returns the media types, raw parts and bodies of all direct children of a multipart body.
+func readParts(t *testing.T, contentType string, body io.Reader) ([]string, []*multipart.Part, [][]byte) {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("invalid Content-Type %q: %v", contentType, err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatalf("expected multipart content type, got %q", mediaType)
+	}
+
+	var types []string
+	var parts []*multipart.Part
+	var bodies [][]byte
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("failed to read part body: %v", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		types = append(types, partType)
+		parts = append(parts, part)
+		bodies = append(bodies, data)
+	}
+	return types, parts, bodies
+}
+
+func writeTempFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+	return path
+}
+
+// TestBuildMIMEMessage_PlainText verifies text-only messages keep the single-part format
+func TestBuildMIMEMessage_PlainText(t *testing.T) {
+	data, err := buildMIMEMessage(&messageContent{
+		MessageID: "123.smtptool@example.com",
+		From:      "sender@example.com",
+		To:        []string{"recipient@example.com"},
+		Subject:   "Plain",
+		TextBody:  "Hello",
+	})
+	if err != nil {
+		t.Fatalf("buildMIMEMessage() error = %v", err)
+	}
+
+	msg := parseMessage(t, data)
+	if got := msg.Header.Get("Content-Type"); got != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q, want text/plain; charset=UTF-8", got)
+	}
+	if got := msg.Header.Get("Message-ID"); got != "<123.smtptool@example.com>" {
+		t.Errorf("Message-ID = %q, want <123.smtptool@example.com>", got)
+	}
+}
+
+// TestBuildMIMEMessage_UTF8Subject verifies RFC 2047 encoding and the SMTPUTF8 raw header mode
+func TestBuildMIMEMessage_UTF8Subject(t *testing.T) {
+	content := &messageContent{
+		From:     "sender@example.com",
+		To:       []string{"recipient@example.com"},
+		Subject:  "Zażółć gęślą jaźń",
+		TextBody: "Hello",
+	}
+
+	data, err := buildMIMEMessage(content)
+	if err != nil {
+		t.Fatalf("buildMIMEMessage() error = %v", err)
+	}
+	if !bytes.Contains(data, []byte("Subject: =?UTF-8?q?")) {
+		t.Errorf("subject not RFC 2047 encoded:\n%s", data)
+	}
+	msg := parseMessage(t, data)
+	decoded, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || decoded != content.Subject {
+		t.Errorf("decoded subject = %q (err %v), want %q", decoded, err, content.Subject)
+	}
+
+	content.UTF8Headers = true
+	data, err = buildMIMEMessage(content)
+	if err != nil {
+		t.Fatalf("buildMIMEMessage() error = %v", err)
+	}
+	if !bytes.Contains(data, []byte("Subject: "+content.Subject+"\r\n")) {
+		t.Errorf("UTF8Headers subject not sent raw:\n%s", data)
+	}
+}
+
+// TestBuildMIMEMessage_Alternative verifies text + HTML produces multipart/alternative
+func TestBuildMIMEMessage_Alternative(t *testing.T) {
+	data, err := buildMIMEMessage(&messageContent{
+		From:     "sender@example.com",
+		To:       []string{"recipient@example.com"},
+		Subject:  "Alternative",
+		TextBody: "Plain version",
+		HTMLBody: "<p>HTML version</p>",
+	})
+	if err != nil {
+		t.Fatalf("buildMIMEMessage() error = %v", err)
+	}
+
+	msg := parseMessage(t, data)
+	types, _, _ := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	want := []string{"text/plain", "text/html"}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("alternative parts = %v, want %v (plain text must come first)", types, want)
+	}
+}
+
+// TestBuildMIMEMessage_AttachmentsAndInline verifies the full mixed/alternative/related tree
+func TestBuildMIMEMessage_AttachmentsAndInline(t *testing.T) {
+	attachment := writeTempFile(t, "report.txt", []byte("attachment data"))
+	image := writeTempFile(t, "logo.png", []byte("\x89PNG fake image"))
+
+	data, err := buildMIMEMessage(&messageContent{
+		From:         "sender@example.com",
+		To:           []string{"recipient@example.com"},
+		Subject:      "Everything",
+		TextBody:     "Plain",
+		HTMLBody:     `<p><img src="cid:logo.png"></p>`,
+		Attachments:  []string{attachment},
+		InlineImages: []string{image},
+	})
+	if err != nil {
+		t.Fatalf("buildMIMEMessage() error = %v", err)
+	}
+
+	msg := parseMessage(t, data)
+	mixedTypes, mixedParts, mixedBodies := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if len(mixedTypes) != 2 || mixedTypes[0] != "multipart/alternative" || mixedTypes[1] != "text/plain" {
+		t.Fatalf("mixed parts = %v, want [multipart/alternative text/plain]", mixedTypes)
+	}
+
+	attach := mixedParts[1]
+	if got := attach.Header.Get("Content-Transfer-Encoding"); got != "base64" {
+		t.Errorf("attachment encoding = %q, want base64", got)
+	}
+	if got := attach.Header.Get("Content-Disposition"); got != `attachment; filename=report.txt` {
+		t.Errorf("attachment disposition = %q", got)
+	}
+
+	altTypes, altParts, altBodies := readParts(t, mixedParts[0].Header.Get("Content-Type"), bytes.NewReader(mixedBodies[0]))
+	if len(altTypes) != 2 || altTypes[1] != "multipart/related" {
+		t.Fatalf("alternative parts = %v, want [text/plain multipart/related]", altTypes)
+	}
+
+	relatedTypes, relatedParts, _ := readParts(t, altParts[1].Header.Get("Content-Type"), bytes.NewReader(altBodies[1]))
+	if len(relatedTypes) != 2 || relatedTypes[0] != "text/html" || relatedTypes[1] != "image/png" {
+		t.Fatalf("related parts = %v, want [text/html image/png]", relatedTypes)
+	}
+	if got := relatedParts[1].Header.Get("Content-ID"); got != "<logo.png>" {
+		t.Errorf("inline Content-ID = %q, want <logo.png>", got)
+	}
+}
+
+// TestBuildMIMEMessage_Errors verifies composer error conditions
+func TestBuildMIMEMessage_Errors(t *testing.T) {
+	t.Run("Inline images without HTML", func(t *testing.T) {
+		image := writeTempFile(t, "logo.png", []byte("img"))
+		_, err := buildMIMEMessage(&messageContent{
+			From:         "sender@example.com",
+			To:           []string{"recipient@example.com"},
+			Subject:      "Test",
+			TextBody:     "Body",
+			InlineImages: []string{image},
+		})
+		if err == nil || !strings.Contains(err.Error(), "HTML body") {
+			t.Errorf("buildMIMEMessage() error = %v, want HTML body error", err)
+		}
+	})
+
+	t.Run("Missing attachment file", func(t *testing.T) {
+		_, err := buildMIMEMessage(&messageContent{
+			From:        "sender@example.com",
+			To:          []string{"recipient@example.com"},
+			Subject:     "Test",
+			TextBody:    "Body",
+			Attachments: []string{filepath.Join(t.TempDir(), "missing.pdf")},
+		})
+		if err == nil {
+			t.Error("buildMIMEMessage() expected error for missing attachment, got nil")
+		}
+	})
+}
+
+// TestValidateInlineImageNames tests that inline image names are usable and unique Content-IDs
+func TestValidateInlineImageNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    []string
+		errorMsg string
+	}{
+		{name: "Valid names", paths: []string{"img/logo.png", "chart-2026_Q1+v2.png"}},
+		{name: "Space", paths: []string{"my logo.png"}, errorMsg: `file name "my logo.png" cannot be referenced`},
+		{name: "Non-ASCII", paths: []string{"café.png"}, errorMsg: "cannot be referenced"},
+		{name: "Angle bracket", paths: []string{"a>b.png"}, errorMsg: "cannot be referenced"},
+		{name: "Leading dot", paths: []string{".logo.png"}, errorMsg: "cannot be referenced"},
+		{name: "Double dot", paths: []string{"logo..png"}, errorMsg: "cannot be referenced"},
+		{name: "Same name in two directories", paths: []string{"a/logo.png", "b/logo.png"}, errorMsg: "inline images #1 and #2 have the same file name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateInlineImageNames(tt.paths)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateInlineImageNames() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateInlineImageNames() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestBuildMIMEMessage_HeaderInjection verifies multipart messages keep header sanitization
+func TestBuildMIMEMessage_HeaderInjection(t *testing.T) {
+	data, err := buildMIMEMessage(&messageContent{
+		From:     "sender@example.com\r\nBcc: attacker@evil.com",
+		To:       []string{"recipient@example.com"},
+		Subject:  "Test\r\nX-Injected: yes",
+		TextBody: "Body",
+		HTMLBody: "<p>Body</p>",
+	})
+	if err != nil {
+		t.Fatalf("buildMIMEMessage() error = %v", err)
+	}
+
+	msg := parseMessage(t, data)
+	if msg.Header.Get("Bcc") != "" || msg.Header.Get("X-Injected") != "" {
+		t.Error("header injection succeeded in multipart message")
+	}
+}
+
+// TestEncodeBase64Lines verifies RFC 2045 line length limits
+func TestEncodeBase64Lines(t *testing.T) {
+	encoded := encodeBase64Lines(bytes.Repeat([]byte("x"), 500))
+	for _, line := range strings.Split(strings.TrimRight(string(encoded), "\r\n"), "\r\n") {
+		if len(line) > base64LineLength {
+			t.Errorf("base64 line length %d exceeds %d", len(line), base64LineLength)
+		}
+	}
+}
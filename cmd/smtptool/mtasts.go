@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"msgraphtool/internal/common/dns"
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/common/proxy"
+	"msgraphtool/internal/smtp/mtasts"
+)
+
+// checkMTASTS reads the MTA-STS policy of a domain (RFC 8461) and checks every
+// MX host against it: the host must be listed in the policy and must offer
+// STARTTLS with a certificate that validates for its name.
+func checkMTASTS(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Checking MTA-STS policy of %s...\n\n", config.Domain)
+
+	// Write CSV header
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader([]string{
+			"Action", "Status", "Domain", "Policy_ID", "Mode", "Max_Age", "MX_Host", "In_Policy",
+			"STARTTLS_Available", "TLS_Version", "Cert_Subject", "Verification_Status", "Error",
+		}); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	// writeFailure logs a domain-level failure that stops the check
+	writeFailure := func(policyID string, err error) error {
+		fmt.Printf("✗ %v\n", err)
+		logger.LogError(slogLogger, "MTA-STS check failed", "domain", config.Domain, "error", err)
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, "FAILURE", config.Domain, policyID, "", "", "", "", "", "", "", "", err.Error(),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+		return err
+	}
+
+	resolver := dns.NewResolver(config.DNSServer)
+
+	// Step 1: _mta-sts TXT record
+	txtName := "_mta-sts." + config.Domain
+	lookupCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	txt, err := resolver.LookupTXT(lookupCtx, txtName)
+	cancel()
+	if err != nil {
+		return writeFailure("", fmt.Errorf("TXT lookup for %s failed: %w", txtName, err))
+	}
+	policyID, err := mtasts.ParseTXTRecord(txt)
+	if err != nil {
+		return writeFailure("", fmt.Errorf("invalid MTA-STS TXT record at %s: %w", txtName, err))
+	}
+	fmt.Printf("✓ TXT record %s (id=%s)\n", txtName, policyID)
+
+	// Step 2: policy file over HTTPS, through -proxy like the MX probes. The
+	// -pinsha256 pins name the MX hosts' keys, so they do not apply here.
+	policyURL := mtasts.PolicyURL(config.MTASTSURL, config.Domain)
+	policyTLS := &tls.Config{InsecureSkipVerify: config.SkipVerify}
+	trust := config.TrustOptions()
+	trust.Pins = nil
+	if err := trust.Apply(policyTLS); err != nil {
+		return writeFailure(policyID, err)
+	}
+	dialer, err := proxy.NewDialer(config.ProxyURL, &net.Dialer{
+		Timeout:   config.Timeout,
+		Resolver:  resolver,
+		LocalAddr: dualstack.LocalAddr(config.SourceIP),
+	})
+	if err != nil {
+		return writeFailure(policyID, err)
+	}
+	client := &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			DialContext:     dualstack.Wrap(dialer, config.IPVersion).DialContext,
+			TLSClientConfig: policyTLS,
+		},
+	}
+	policy, err := mtasts.FetchPolicy(ctx, client, policyURL)
+	if err != nil {
+		return writeFailure(policyID, fmt.Errorf("%s: %w", policyURL, err))
+	}
+	printMTASTSPolicy(policyURL, policy)
+
+	// Step 3: MX hosts from DNS
+	lookupCtx, cancel = context.WithTimeout(ctx, config.Timeout)
+	hosts, err := dns.LookupMX(lookupCtx, resolver, config.Domain)
+	cancel()
+	if err != nil {
+		return writeFailure(policyID, err)
+	}
+
+	failed := 0
+	for _, mx := range hosts {
+		result := probeMXHost(ctx, config, mx)
+		printMXProbeResult(result)
+
+		inPolicy := policy.Matches(mx.Host)
+		var problems []string
+		if inPolicy {
+			fmt.Println("  ✓ Listed in MTA-STS policy")
+		} else {
+			fmt.Println("  ✗ Not listed in MTA-STS policy")
+			problems = append(problems, "MX not listed in MTA-STS policy")
+		}
+		if problem := result.Problem(); problem != "" {
+			problems = append(problems, problem)
+		}
+
+		status := "SUCCESS"
+		if len(problems) > 0 {
+			status = "FAILURE"
+			failed++
+			logger.LogWarn(slogLogger, "MX host fails MTA-STS policy", "host", mx.Host, "problems", strings.Join(problems, "; "))
+		}
+
+		tlsVersion, certSubject := "", ""
+		if result.TLSInfo != nil {
+			tlsVersion = result.TLSInfo.Version
+		}
+		if result.CertInfo != nil {
+			certSubject = result.CertInfo.Subject
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Domain, policyID, policy.Mode, fmt.Sprintf("%d", policy.MaxAge),
+			mx.Host, fmt.Sprintf("%t", inPolicy), fmt.Sprintf("%t", result.STARTTLS),
+			tlsVersion, certSubject, result.VerificationStatus(), strings.Join(problems, "; "),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		fmt.Printf("✗ %d of %d MX host(s) do not satisfy the MTA-STS policy\n", failed, len(hosts))
+		if policy.Mode != mtasts.ModeEnforce {
+			fmt.Printf("  Policy mode is %q: sending MTAs report but do not enforce these failures\n", policy.Mode)
+		}
+		return fmt.Errorf("%d of %d MX host(s) for %s do not satisfy the MTA-STS policy", failed, len(hosts), config.Domain)
+	}
+
+	fmt.Printf("✓ All %d MX host(s) satisfy the MTA-STS policy\n", len(hosts))
+	logger.LogInfo(slogLogger, "mtasts completed successfully", "domain", config.Domain, "mode", policy.Mode, "hosts", len(hosts))
+	return nil
+}
+
+// printMTASTSPolicy displays a fetched MTA-STS policy.
+func printMTASTSPolicy(url string, policy *mtasts.Policy) {
+	fmt.Println("\nMTA-STS Policy:")
+	fmt.Println(strings.Repeat("═", 60))
+	fmt.Printf("  URL:                 %s\n", url)
+	fmt.Printf("  Mode:                %s\n", policy.Mode)
+	fmt.Printf("  Max Age:             %d seconds\n", policy.MaxAge)
+	for _, mx := range policy.MX {
+		fmt.Printf("  MX:                  %s\n", mx)
+	}
+	fmt.Println(strings.Repeat("═", 60))
+}
@@ -0,0 +1,45 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateConfiguration_MTASTS tests mtasts-specific validation
+func TestValidateConfiguration_MTASTS(t *testing.T) {
+	tests := []struct {
+		name      string
+		domain    string
+		mtastsURL string
+		errorMsg  string
+	}{
+		{name: "Domain only", domain: "example.com"},
+		{name: "Local stand-in URL", domain: "example.com", mtastsURL: "https://127.0.0.1:8443"},
+		{name: "Missing domain", errorMsg: "mtasts requires -domain"},
+		{name: "Plain HTTP URL", domain: "example.com", mtastsURL: "http://127.0.0.1:8080", errorMsg: "invalid -mtastsurl"},
+		{name: "URL without host", domain: "example.com", mtastsURL: "https://", errorMsg: "invalid -mtastsurl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionMTASTS
+			config.Domain = tt.domain
+			config.MTASTSURL = tt.mtastsURL
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
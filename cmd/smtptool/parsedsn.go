@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/smtp/dsn"
+)
+
+// parseDSN reads a delivery status notification (.eml) and reports the
+// per-recipient delivery status. Used to verify bounce routing end to end:
+// send with -dsn-notify/-envid, then parse the DSN that comes back.
+func parseDSN(config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Parsing delivery status notification %s...\n\n", config.DSNFile)
+
+	// Write CSV header
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader([]string{
+			"Action", "Status", "File", "Reporting_MTA", "Envelope_ID",
+			"Final_Recipient", "Original_Recipient", "DSN_Action", "DSN_Status",
+			"Remote_MTA", "Diagnostic_Code", "Error",
+		}); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	file, err := os.Open(config.DSNFile)
+	if err != nil {
+		return writeDSNFailure(config, csvLogger, slogLogger, fmt.Errorf("failed to open DSN file: %w", err))
+	}
+	defer file.Close()
+
+	report, err := dsn.ParseReport(file)
+	if err != nil {
+		return writeDSNFailure(config, csvLogger, slogLogger, err)
+	}
+
+	fmt.Println("Delivery Status Notification:")
+	fmt.Println(strings.Repeat("═", 60))
+	fmt.Printf("  Subject:        %s\n", report.Subject)
+	fmt.Printf("  Reporting MTA:  %s\n", ifEmpty(report.ReportingMTA, "(not set)"))
+	fmt.Printf("  Envelope ID:    %s\n", ifEmpty(report.EnvelopeID, "(not set)"))
+	if report.ArrivalDate != "" {
+		fmt.Printf("  Arrival Date:   %s\n", report.ArrivalDate)
+	}
+	fmt.Printf("  Recipients:     %d\n", len(report.Recipients))
+	fmt.Println(strings.Repeat("═", 60))
+
+	for _, rcpt := range report.Recipients {
+		symbol := "✓"
+		if rcpt.IsFailure() {
+			symbol = "✗"
+		} else if rcpt.IsDelayed() {
+			symbol = "⚠"
+		}
+
+		fmt.Printf("\n%s %s\n", symbol, rcpt.FinalRecipient)
+		if rcpt.OriginalRecipient != "" && rcpt.OriginalRecipient != rcpt.FinalRecipient {
+			fmt.Printf("  Original Recipient: %s\n", rcpt.OriginalRecipient)
+		}
+		fmt.Printf("  Action:             %s\n", rcpt.Action)
+		fmt.Printf("  Status:             %s\n", rcpt.Status)
+		if rcpt.RemoteMTA != "" {
+			fmt.Printf("  Remote MTA:         %s\n", rcpt.RemoteMTA)
+		}
+		if rcpt.DiagnosticCode != "" {
+			fmt.Printf("  Diagnostic:         %s\n", rcpt.DiagnosticCode)
+		}
+		if rcpt.WillRetryUntil != "" {
+			fmt.Printf("  Will Retry Until:   %s\n", rcpt.WillRetryUntil)
+		}
+
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, "SUCCESS", config.DSNFile, report.ReportingMTA, report.EnvelopeID,
+			rcpt.FinalRecipient, rcpt.OriginalRecipient, rcpt.Action, rcpt.Status,
+			rcpt.RemoteMTA, rcpt.DiagnosticCode, "",
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	fmt.Println("\n✓ DSN parsed successfully")
+	logger.LogInfo(slogLogger, "parsedsn completed successfully",
+		"envelopeID", report.EnvelopeID,
+		"recipients", len(report.Recipients))
+
+	return nil
+}
+
+// writeDSNFailure logs a parsedsn failure to CSV and returns the error.
+func writeDSNFailure(config *Config, csvLogger logger.Logger, slogLogger *slog.Logger, err error) error {
+	logger.LogError(slogLogger, "Failed to parse DSN", "error", err)
+	if logErr := csvLogger.WriteRow([]string{
+		config.Action, "FAILURE", config.DSNFile, "", "", "", "", "", "", "", "", err.Error(),
+	}); logErr != nil {
+		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+	}
+	return err
+}
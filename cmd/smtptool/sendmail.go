@@ -10,7 +10,8 @@ import (
 	"time"
 
 	"msgraphtool/internal/common/logger"
-	smtptls "msgraphtool/internal/smtp/tls"
+	"msgraphtool/internal/common/retry"
+	"msgraphtool/internal/smtp/protocol"
 )
 
 // sendMail performs end-to-end email sending test.
@@ -25,31 +26,201 @@ func sendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
 		if err := csvLogger.WriteHeader([]string{
 			"Action", "Status", "Server", "Port", "From", "To",
-			"Subject", "SMTP_Response_Code", "Message_ID", "Error",
+			"Subject", "SMTP_Response_Code", "Message_ID", "Greylist_Delay_s", "Error",
 		}); err != nil {
 			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
 		}
 	}
 
+	// Load the -eml message or the -bulk batch before connecting, so file and
+	// template errors are reported without touching the server
+	var eml *emlMessage
+	var batch []*bulkMessage
+	if config.EML != "" || config.Bulk != "" {
+		var err error
+		if config.EML != "" {
+			eml, err = prepareEMLMessage(config)
+		} else {
+			batch, err = prepareBulkMessages(config)
+		}
+		if err != nil {
+			logger.LogError(slogLogger, "Failed to prepare message", "error", err)
+			if logErr := csvLogger.WriteRow(sendMailRow(config, "FAILURE", "", "", "", err.Error())); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return err
+		}
+	}
+
 	fmt.Printf("From:    %s\n", config.From)
-	fmt.Printf("To:      %s\n", strings.Join(config.To, ", "))
-	fmt.Printf("Subject: %s\n\n", config.Subject)
+	switch {
+	case eml != nil:
+		fmt.Printf("To:      %s\n", strings.Join(config.To, ", "))
+		fmt.Printf("Subject: %s\n", config.Subject)
+		fmt.Printf("Message: %s (%d bytes, sent as written", config.EML, len(eml.Data))
+		if config.RegenHeaders {
+			fmt.Printf("; new Message-ID and Date")
+		}
+		fmt.Println(")")
+	case batch != nil:
+		fmt.Printf("To:      %d recipient(s) from %s\n", len(batch), config.Bulk)
+		fmt.Printf("Subject: %s (template)\n", config.Subject)
+	default:
+		fmt.Printf("To:      %s\n", strings.Join(config.To, ", "))
+		fmt.Printf("Subject: %s\n", config.Subject)
+	}
+	if config.BodyHTML != "" {
+		fmt.Printf("HTML:    yes\n")
+	}
+	if len(config.Attachments) > 0 {
+		fmt.Printf("Attach:  %s\n", strings.Join(config.Attachments, ", "))
+	}
+	if len(config.InlineImages) > 0 {
+		fmt.Printf("Inline:  %s\n", strings.Join(config.InlineImages, ", "))
+	}
+	fmt.Println()
+
+	client, caps, err := openMailSession(ctx, config, slogLogger)
+	if err != nil {
+		if logErr := csvLogger.WriteRow(sendMailRow(config, "FAILURE", "", "", "", err.Error())); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+		return err
+	}
+	defer client.Close()
+
+	// Report whether the requested DSN parameters can be honoured
+	if dsn := buildDSNOptions(config); dsn != nil {
+		if caps.SupportsDSN() {
+			fmt.Printf("DSN: NOTIFY=%s RET=%s ENVID=%s\n",
+				ifEmpty(strings.Join(dsn.Notify, ","), "(default)"), ifEmpty(dsn.Ret, "(default)"), ifEmpty(dsn.EnvID, "(none)"))
+		} else {
+			fmt.Println("⚠ DSN parameters requested but server does not advertise DSN; sending without them")
+			logger.LogWarn(slogLogger, "DSN not advertised by server; parameters omitted")
+		}
+	}
+
+	// Report whether the requested transfer extensions can be used
+	if config.Pipelining {
+		if caps.SupportsPipelining() {
+			fmt.Println("PIPELINING: MAIL FROM/RCPT TO will be sent as one batch")
+		} else {
+			fmt.Println("⚠ -pipelining requested but server does not advertise PIPELINING; sending commands one at a time")
+			logger.LogWarn(slogLogger, "PIPELINING not advertised by server")
+		}
+	}
+	if config.Chunking {
+		if caps.SupportsChunking() {
+			fmt.Printf("CHUNKING: message will be sent with BDAT (%d-byte chunks)\n", config.ChunkSize)
+		} else {
+			fmt.Println("⚠ -chunking requested but server does not advertise CHUNKING; sending with DATA")
+			logger.LogWarn(slogLogger, "CHUNKING not advertised by server")
+		}
+	}
+	if config.BinaryMIME && caps.SupportsChunking() && !caps.SupportsBinaryMIME() {
+		fmt.Println("⚠ -binarymime requested but server does not advertise BINARYMIME; omitting BODY=BINARYMIME")
+		logger.LogWarn(slogLogger, "BINARYMIME not advertised by server")
+	}
+
+	// Bulk messages are composed and sent one recipient at a time. A failed
+	// batch is not retried: its other messages were already delivered.
+	if batch != nil {
+		return retry.Permanent(sendBulk(client, caps, config, batch, csvLogger, slogLogger))
+	}
+
+	// Use the -eml message as written, or build the test message
+	var messageID string
+	var messageData []byte
+	if eml != nil {
+		messageID, messageData = eml.MessageID, eml.Data
+	} else {
+		messageID, messageData, err = composeMessage(config, caps, config.To, config.Subject, config.Body, config.BodyHTML)
+		if err != nil {
+			logger.LogError(slogLogger, "Failed to build message", "error", err)
+			if logErr := csvLogger.WriteRow(sendMailRow(config, "FAILURE", "", messageID, "", err.Error())); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return err
+		}
+		if !protocol.IsASCII(config.From + strings.Join(config.To, "")) {
+			if caps.SupportsSMTPUTF8() {
+				fmt.Println("SMTPUTF8: internationalized addresses and headers will be sent as UTF-8")
+			} else {
+				fmt.Println("⚠ Server does not advertise SMTPUTF8; internationalized domains will be sent as IDNA (punycode)")
+				logger.LogWarn(slogLogger, "SMTPUTF8 not advertised by server; using IDNA domains")
+			}
+		}
+	}
+
+	// Send email
+	fmt.Println("\nSending message...")
+	logger.LogDebug(slogLogger, "Sending email", "from", config.From, "to", config.To)
+
+	err = client.SendMail(config.From, config.To, messageData)
+	if config.Pipelining || config.Chunking || config.VerboseMode {
+		displayTransactionLog(client.GetTransactionLog())
+	}
+
+	// Greylisting defers the first attempt; retry the same message until the
+	// server accepts it and report how long that took
+	var greylistDelay string
+	var replyErr *protocol.ReplyError
+	if errors.As(err, &replyErr) && replyErr.Greylisted() {
+		if config.GreylistMax > 0 {
+			remote := client.RemoteIP()
+			client.Close()
+			var accepted time.Duration
+			accepted, err = deliverAfterGreylisting(ctx, config, remote, messageID, messageData, replyErr, csvLogger, slogLogger)
+			if err == nil {
+				greylistDelay = formatSeconds(accepted)
+			}
+		} else {
+			fmt.Println("⚠ The message was greylisted; set -greylistmax to retry until the server accepts it")
+		}
+	}
+	if err != nil {
+		logger.LogError(slogLogger, "Failed to send email", "error", err)
+		if logErr := csvLogger.WriteRow(sendMailRow(config, "FAILURE", "", "", "", err.Error())); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	fmt.Println("✓ Message sent successfully")
+	fmt.Printf("  Message-ID: <%s>\n", messageID)
+
+	// Log to CSV
+	if logErr := csvLogger.WriteRow(sendMailRow(config, "SUCCESS", "250", messageID, greylistDelay, "")); logErr != nil {
+		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+	}
 
-	// Create and connect client
+	fmt.Println("\n✓ Email sending test completed successfully")
+	logger.LogInfo(slogLogger, "sendmail completed successfully", "messageID", messageID)
+
+	return nil
+}
+
+// sendMailRow returns a sendmail CSV row for the -to recipients.
+// greylistDelay is in seconds, empty when the message was not greylisted.
+func sendMailRow(config *Config, status, code, messageID, greylistDelay, errMsg string) []string {
+	return []string{
+		config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+		config.From, strings.Join(config.To, ", "), config.Subject,
+		code, messageID, greylistDelay, errMsg,
+	}
+}
+
+// openMailSession connects, sends EHLO, upgrades to TLS and authenticates the
+// way sendmail does, printing each step. Greylisting retries open their
+// sessions with it, so every attempt looks the same to the server.
+func openMailSession(ctx context.Context, config *Config, slogLogger *slog.Logger) (*SMTPClient, protocol.Capabilities, error) {
 	client := NewSMTPClient(config.Host, config.Port, config)
 	logger.LogDebug(slogLogger, "Connecting to SMTP server")
 
 	if err := client.Connect(ctx); err != nil {
 		logger.LogError(slogLogger, "Connection failed", "error", err)
-		if logErr := csvLogger.WriteRow([]string{
-			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			config.From, strings.Join(config.To, ", "), config.Subject, "", "", err.Error(),
-		}); logErr != nil {
-			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
-		}
-		return err
+		return nil, nil, err
 	}
-	defer client.Close()
 
 	if config.SMTPS {
 		fmt.Printf("✓ Connected with SMTPS (implicit TLS)\n")
@@ -62,13 +233,8 @@ func sendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 	caps, err := client.EHLO("smtptool.local")
 	if err != nil {
 		logger.LogError(slogLogger, "EHLO failed", "error", err)
-		if logErr := csvLogger.WriteRow([]string{
-			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			config.From, strings.Join(config.To, ", "), config.Subject, "", "", err.Error(),
-		}); logErr != nil {
-			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
-		}
-		return err
+		client.Close()
+		return nil, nil, err
 	}
 
 	// Handle TLS: either already established via SMTPS, or upgrade via STARTTLS
@@ -83,24 +249,14 @@ func sendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 		// STARTTLS if on common SMTP submission ports and available
 		// Ports: 25 (SMTP), 587 (Submission), 2525/2526 (Alternative submission), 1025 (Testing/Alt)
 		fmt.Println("Upgrading to TLS...")
-		tlsVersion := smtptls.ParseTLSVersion(config.TLSVersion)
-		tlsConfig := &tls.Config{
-			ServerName:         config.Host,
-			InsecureSkipVerify: config.SkipVerify,
-			MinVersion:         tlsVersion,
-			MaxVersion:         tlsVersion, // Force exact TLS version
+		tlsConfig, err := newTLSConfig(config, config.Host)
+		if err == nil {
+			tlsState, err = client.StartTLS(tlsConfig)
 		}
-
-		tlsState, err = client.StartTLS(tlsConfig)
 		if err != nil {
 			logger.LogError(slogLogger, "STARTTLS failed", "error", err)
-			if logErr := csvLogger.WriteRow([]string{
-				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-				config.From, strings.Join(config.To, ", "), config.Subject, "", "", fmt.Sprintf("STARTTLS failed: %v", err),
-			}); logErr != nil {
-				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
-			}
-			return fmt.Errorf("STARTTLS failed: %w", err)
+			client.Close()
+			return nil, nil, fmt.Errorf("STARTTLS failed: %w", err)
 		}
 
 		fmt.Println("✓ TLS upgrade successful")
@@ -113,7 +269,8 @@ func sendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 		// Re-run EHLO on encrypted connection
 		caps, err = client.EHLO("smtptool.local")
 		if err != nil {
-			return fmt.Errorf("EHLO on encrypted connection failed: %w", err)
+			client.Close()
+			return nil, nil, fmt.Errorf("EHLO on encrypted connection failed: %w", err)
 		}
 	}
 
@@ -124,14 +281,8 @@ func sendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 		methodToUse := selectAuthMechanism([]string{config.AuthMethod}, authMechanisms, config.AccessToken != "")
 
 		if methodToUse == "" {
-			msg := "No compatible authentication mechanism found"
-			if logErr := csvLogger.WriteRow([]string{
-				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-				config.From, strings.Join(config.To, ", "), config.Subject, "", "", msg,
-			}); logErr != nil {
-				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
-			}
-			return errors.New(msg)
+			client.Close()
+			return nil, nil, errors.New("No compatible authentication mechanism found")
 		}
 
 		if err := client.Auth(config.Username, config.Password, config.AccessToken, []string{methodToUse}); err != nil {
@@ -148,84 +299,119 @@ func sendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 				displayTLSCipherInfo(tlsState)
 			}
 
-			if logErr := csvLogger.WriteRow([]string{
-				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-				config.From, strings.Join(config.To, ", "), config.Subject, "", "", fmt.Sprintf("Auth failed: %v", err),
-			}); logErr != nil {
-				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
-			}
-			return fmt.Errorf("authentication failed: %w", err)
+			client.Close()
+			return nil, nil, fmt.Errorf("authentication failed: %w", err)
 		}
 
 		fmt.Println("✓ Authentication successful")
 	}
 
-	// Build email message
-	messageData := buildEmailMessage(config.From, config.To, config.Subject, config.Body)
-	messageID := generateMessageID(config.Host)
+	return client, caps, nil
+}
 
-	// Send email
-	fmt.Println("\nSending message...")
-	logger.LogDebug(slogLogger, "Sending email", "from", config.From, "to", config.To)
+// displayTransactionLog prints the reply and timing of every command in the
+// mail transaction, so pipelined batches and BDAT chunks can be matched to
+// the server's replies.
+func displayTransactionLog(results []CommandResult) {
+	if len(results) == 0 {
+		return
+	}
 
-	err = client.SendMail(config.From, config.To, messageData)
-	if err != nil {
-		logger.LogError(slogLogger, "Failed to send email", "error", err)
-		if logErr := csvLogger.WriteRow([]string{
-			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			config.From, strings.Join(config.To, ", "), config.Subject, "", "", err.Error(),
-		}); logErr != nil {
-			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+	fmt.Println("\nSMTP Transaction:")
+	for _, r := range results {
+		mode := ""
+		if r.Pipelined {
+			mode = " [pipelined]"
 		}
-		return fmt.Errorf("failed to send email: %w", err)
+		reply := "(no reply)"
+		if r.Code != 0 {
+			reply = strings.TrimSpace(fmt.Sprintf("%d %s", r.Code, strings.ReplaceAll(r.Message, "\n", " ")))
+		}
+		fmt.Printf("  %-45s → %-30s %8s%s\n", r.Command, reply, r.Duration.Round(time.Microsecond), mode)
 	}
+}
 
-	fmt.Println("✓ Message sent successfully")
-	fmt.Printf("  Message-ID: <%s>\n", messageID)
-
-	// Log to CSV
-	if logErr := csvLogger.WriteRow([]string{
-		config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port),
-		config.From, strings.Join(config.To, ", "), config.Subject,
-		"250", messageID, "",
-	}); logErr != nil {
-		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+// composeMessage builds the test message for the given recipients: multipart
+// when HTML, attachments or inline images are requested. Internationalized
+// addresses need SMTPUTF8; without it, headers fall back to IDNA domains and
+// the subject to RFC 2047 encoded words.
+func composeMessage(config *Config, caps protocol.Capabilities, to []string, subject, textBody, htmlBody string) (string, []byte, error) {
+	headerFrom, headerTo := config.From, to
+	utf8Headers := false
+	if !protocol.IsASCII(config.From + strings.Join(to, "")) {
+		if caps.SupportsSMTPUTF8() {
+			utf8Headers = true
+		} else {
+			var err error
+			if headerFrom, headerTo, err = addressesToASCII(config.From, to); err != nil {
+				return "", nil, fmt.Errorf("server does not advertise SMTPUTF8 (RFC 6531): %w", err)
+			}
+		}
 	}
 
-	fmt.Println("\n✓ Email sending test completed successfully")
-	logger.LogInfo(slogLogger, "sendmail completed successfully", "messageID", messageID)
-
-	return nil
+	messageID := generateMessageID(config.Host)
+	data, err := buildMIMEMessage(&messageContent{
+		MessageID:    messageID,
+		From:         headerFrom,
+		To:           headerTo,
+		Subject:      subject,
+		UTF8Headers:  utf8Headers,
+		TextBody:     textBody,
+		HTMLBody:     htmlBody,
+		Attachments:  config.Attachments,
+		InlineImages: config.InlineImages,
+	})
+	if err != nil {
+		return messageID, nil, fmt.Errorf("failed to build message: %w", err)
+	}
+	return messageID, data, nil
 }
 
-// buildEmailMessage constructs an RFC 5322 email message.
+// buildEmailMessage constructs a plain-text RFC 5322 email message.
 // Defense-in-Depth: Email headers (From, To, Subject) are sanitized to remove
 // CRLF sequences that could be used for header injection attacks. The message
 // body is not sanitized as it legitimately may contain newlines.
 func buildEmailMessage(from string, to []string, subject, body string) []byte {
-	messageID := generateMessageID("")
-	date := time.Now().Format(time.RFC1123Z)
+	// A text-only message never touches the filesystem, so composing cannot fail
+	message, _ := buildMIMEMessage(&messageContent{
+		From:     from,
+		To:       to,
+		Subject:  subject,
+		TextBody: body,
+	})
+	return message
+}
 
-	// Sanitize header fields to prevent header injection
-	from = sanitizeEmailHeader(from)
-	subject = sanitizeEmailHeader(subject)
-	sanitizedTo := make([]string, len(to))
-	for i, addr := range to {
-		sanitizedTo[i] = sanitizeEmailHeader(addr)
+// buildDSNOptions converts the -dsn-notify, -dsn-ret and -envid flags into
+// DSN envelope parameters. Values are validated in validateConfiguration, so
+// parse errors cannot occur here. Returns nil when no DSN option is set.
+func buildDSNOptions(config *Config) *protocol.DSNOptions {
+	if config == nil {
+		return nil
 	}
+	notify, _ := protocol.ParseDSNNotify(config.DSNNotify)
+	ret, _ := protocol.ParseDSNRet(config.DSNRet)
+	opts := &protocol.DSNOptions{Notify: notify, Ret: ret, EnvID: config.EnvID}
+	if opts.IsEmpty() {
+		return nil
+	}
+	return opts
+}
 
-	message := fmt.Sprintf("Message-ID: <%s>\r\n", messageID)
-	message += fmt.Sprintf("Date: %s\r\n", date)
-	message += fmt.Sprintf("From: %s\r\n", from)
-	message += fmt.Sprintf("To: %s\r\n", strings.Join(sanitizedTo, ", "))
-	message += fmt.Sprintf("Subject: %s\r\n", subject)
-	message += "MIME-Version: 1.0\r\n"
-	message += "Content-Type: text/plain; charset=UTF-8\r\n"
-	message += "\r\n"
-	message += body
-	message += "\r\n"
-
-	return []byte(message)
+// addressesToASCII converts the sender and recipients to their IDNA A-label
+// form for servers without SMTPUTF8. Fails for UTF-8 local parts.
+func addressesToASCII(from string, to []string) (string, []string, error) {
+	asciiFrom, err := protocol.AddressToASCII(strings.TrimSpace(from))
+	if err != nil {
+		return "", nil, err
+	}
+	asciiTo := make([]string, len(to))
+	for i, addr := range to {
+		if asciiTo[i], err = protocol.AddressToASCII(strings.TrimSpace(addr)); err != nil {
+			return "", nil, err
+		}
+	}
+	return asciiFrom, asciiTo, nil
 }
 
 // sanitizeEmailHeader removes CRLF sequences from email header values to prevent
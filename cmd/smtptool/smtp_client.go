@@ -4,15 +4,25 @@ import (
 	"bufio"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
 	"net/smtp"
 	"net/textproto"
+	"os"
 	"strings"
+	"time"
 
+	"msgraphtool/internal/common/dns"
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/proxy"
 	"msgraphtool/internal/common/ratelimit"
+	"msgraphtool/internal/common/retry"
+	"msgraphtool/internal/common/sasl"
+	"msgraphtool/internal/common/timing"
+	commontls "msgraphtool/internal/common/tls"
 	"msgraphtool/internal/smtp/protocol"
-	smtptls "msgraphtool/internal/smtp/tls"
 )
 
 // SMTPClient wraps SMTP connection with enhanced diagnostics.
@@ -28,6 +38,16 @@ type SMTPClient struct {
 	smtpClient   *smtp.Client           // Reusable stdlib client after STARTTLS or SMTPS
 	tlsState     *tls.ConnectionState   // Stored TLS state for SMTPS connections
 	ctx          context.Context        // Context for cancellation propagation
+	transaction  []CommandResult        // Per-command replies of the last SendMail call
+}
+
+// CommandResult maps one command of a mail transaction to the reply it received.
+type CommandResult struct {
+	Command   string        // Command line as sent (BDAT chunks show the header only)
+	Code      int           // Reply code (0 when no reply was read)
+	Message   string        // Reply text
+	Duration  time.Duration // Time from writing the command (or its batch) to reading the reply
+	Pipelined bool          // Sent as part of a PIPELINING batch
 }
 
 // debugLogCommand logs an SMTP command being sent to the server.
@@ -74,22 +94,76 @@ func NewSMTPClient(host string, port int, config *Config) *SMTPClient {
 	}
 }
 
+// newTLSConfig returns the client TLS configuration for serverName: the
+// exact -tlsversion, -skipverify, and the CA bundle, client certificate and
+// pins from the config.
+func newTLSConfig(config *Config, serverName string) (*tls.Config, error) {
+	tlsVersion := commontls.ParseTLSVersion(config.TLSVersion)
+	tlsConfig := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: config.SkipVerify,
+		MinVersion:         tlsVersion,
+		MaxVersion:         tlsVersion, // Force exact TLS version
+	}
+	if err := config.TrustOptions().Apply(tlsConfig); err != nil {
+		return nil, err
+	}
+	return tlsConfig, nil
+}
+
+// tlsHandshake performs the client TLS handshake on conn. With -savechain,
+// the presented chain is saved whether or not it verifies.
+func (c *SMTPClient) tlsHandshake(ctx context.Context, conn net.Conn, tlsConfig *tls.Config) (*tls.Conn, error) {
+	var capture *commontls.ChainCapture
+	if c.config.SaveChain != "" {
+		capture = &commontls.ChainCapture{}
+		tlsConfig = tlsConfig.Clone()
+		capture.Apply(tlsConfig)
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	err := tlsConn.HandshakeContext(ctx)
+	if capture != nil {
+		capture.SaveAndPrint(os.Stdout, c.config.SaveChain, fmt.Sprintf("%s_%d", c.host, c.port))
+	}
+	return tlsConn, err
+}
+
+// recorder returns the timing.Recorder of the connection's context, if any.
+func (c *SMTPClient) recorder() *timing.Recorder {
+	if c.ctx == nil {
+		return nil
+	}
+	return timing.FromContext(c.ctx)
+}
+
 // Connect establishes a TCP connection and reads the banner.
 // For SMTPS mode, performs immediate TLS handshake before reading banner.
+// The phases of the session are recorded in the context's timing.Recorder,
+// which is reset first so that it describes the latest connection.
 func (c *SMTPClient) Connect(ctx context.Context) error {
 	// Apply rate limiting
 	if err := c.limiter.Wait(ctx); err != nil {
 		return fmt.Errorf("rate limit wait failed: %w", err)
 	}
 
+	rec := timing.FromContext(ctx)
+	rec.Reset()
+
 	addr := fmt.Sprintf("%s:%d", c.host, c.port)
 
-	// Use context-aware dialer
-	dialer := &net.Dialer{
-		Timeout: c.config.Timeout,
+	// Use context-aware dialer, through the -proxy when one is configured
+	dialer, err := proxy.NewDialer(c.config.ProxyURL, &net.Dialer{
+		Timeout:   c.config.Timeout,
+		Resolver:  dns.NewResolver(c.config.DNSServer),
+		LocalAddr: dualstack.LocalAddr(c.config.SourceIP),
+	})
+	if err != nil {
+		return err
 	}
+	dialer = dualstack.Wrap(dialer, c.config.IPVersion)
 
-	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	conn, err := timing.Dial(ctx, dialer, "tcp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -98,16 +172,16 @@ func (c *SMTPClient) Connect(ctx context.Context) error {
 	if c.config.SMTPS {
 		c.debugLogMessage("SMTPS mode: Performing immediate TLS handshake...")
 
-		tlsVersion := smtptls.ParseTLSVersion(c.config.TLSVersion)
-		tlsConfig := &tls.Config{
-			ServerName:         c.host,
-			InsecureSkipVerify: c.config.SkipVerify,
-			MinVersion:         tlsVersion,
-			MaxVersion:         tlsVersion, // Force exact TLS version
+		tlsConfig, err := newTLSConfig(c.config, c.host)
+		if err != nil {
+			conn.Close()
+			return err
 		}
 
-		tlsConn := tls.Client(conn, tlsConfig)
-		if err := tlsConn.HandshakeContext(ctx); err != nil {
+		start := time.Now()
+		tlsConn, err := c.tlsHandshake(ctx, conn, tlsConfig)
+		rec.Since(timing.TLS, start)
+		if err != nil {
 			// Close the underlying connection; log any close error in verbose mode
 			// but return the TLS error as it's more relevant for diagnostics
 			if closeErr := conn.Close(); closeErr != nil {
@@ -131,7 +205,9 @@ func (c *SMTPClient) Connect(ctx context.Context) error {
 	c.reader = bufio.NewReader(conn)
 
 	// Read banner (220 response) with timeout
+	start := time.Now()
 	resp, err := protocol.ReadResponseWithTimeout(c.reader, protocol.DefaultResponseTimeout)
+	rec.Since(timing.Greeting, start)
 	if err != nil {
 		c.conn.Close()
 		return fmt.Errorf("failed to read banner: %w", err)
@@ -142,7 +218,7 @@ func (c *SMTPClient) Connect(ctx context.Context) error {
 
 	if !resp.IsSuccess() {
 		c.conn.Close()
-		return fmt.Errorf("unexpected banner response: %d %s", resp.Code, resp.Message)
+		return fmt.Errorf("unexpected banner response: %w", protocol.NewReplyError(resp))
 	}
 
 	c.banner = resp.Message
@@ -167,6 +243,7 @@ func (c *SMTPClient) EHLO(hostname string) (protocol.Capabilities, error) {
 	// Send EHLO command
 	cmd := protocol.EHLO(hostname)
 	c.debugLogCommand(cmd)
+	defer c.recorder().Since(timing.Capabilities, time.Now())
 	if _, err := c.conn.Write([]byte(cmd)); err != nil {
 		return nil, fmt.Errorf("failed to send EHLO: %w", err)
 	}
@@ -180,7 +257,8 @@ func (c *SMTPClient) EHLO(hostname string) (protocol.Capabilities, error) {
 	c.debugLogResponse(resp)
 
 	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("EHLO failed: %d %s", resp.Code, resp.Message)
+		c.annotateReply(resp)
+		return nil, fmt.Errorf("EHLO failed: %w", protocol.NewReplyError(resp))
 	}
 
 	// Parse capabilities
@@ -189,6 +267,34 @@ func (c *SMTPClient) EHLO(hostname string) (protocol.Capabilities, error) {
 	return c.capabilities, nil
 }
 
+// Command sends a single command and returns the server's reply.
+// Rejections (4xx/5xx) are returned as a response, not as an error; the error
+// reports I/O failures such as a dropped connection.
+func (c *SMTPClient) Command(cmd string) (*protocol.SMTPResponse, error) {
+	// Apply rate limiting using stored context
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limit wait failed: %w", err)
+	}
+
+	c.debugLogCommand(cmd)
+	verb, _, _ := strings.Cut(strings.TrimRight(cmd, "\r\n"), " ")
+	if _, err := c.conn.Write([]byte(cmd)); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", verb, err)
+	}
+
+	resp, err := protocol.ReadResponseWithTimeout(c.reader, protocol.DefaultResponseTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", verb, err)
+	}
+	c.debugLogResponse(resp)
+	c.annotateReply(resp)
+	return resp, nil
+}
+
 // StartTLS upgrades the connection to TLS.
 func (c *SMTPClient) StartTLS(tlsConfig *tls.Config) (*tls.ConnectionState, error) {
 	// Apply rate limiting using stored context
@@ -203,6 +309,7 @@ func (c *SMTPClient) StartTLS(tlsConfig *tls.Config) (*tls.ConnectionState, erro
 	// Send STARTTLS command
 	cmd := protocol.STARTTLS()
 	c.debugLogCommand(cmd)
+	defer c.recorder().Since(timing.TLS, time.Now())
 	if _, err := c.conn.Write([]byte(cmd)); err != nil {
 		return nil, fmt.Errorf("failed to send STARTTLS: %w", err)
 	}
@@ -216,14 +323,15 @@ func (c *SMTPClient) StartTLS(tlsConfig *tls.Config) (*tls.ConnectionState, erro
 	c.debugLogResponse(resp)
 
 	if resp.Code != 220 {
-		return nil, fmt.Errorf("STARTTLS failed: %d %s", resp.Code, resp.Message)
+		c.annotateReply(resp)
+		return nil, fmt.Errorf("STARTTLS failed: %w", protocol.NewReplyError(resp))
 	}
 
 	// Perform TLS handshake
 	c.debugLogMessage("Performing TLS handshake...")
-	tlsConn := tls.Client(c.conn, tlsConfig)
-	if err := tlsConn.HandshakeContext(ctx); err != nil {
-		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	tlsConn, err := c.tlsHandshake(ctx, c.conn, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", &commontls.HandshakeError{Err: err})
 	}
 
 	c.debugLogMessage("TLS handshake completed successfully")
@@ -278,8 +386,10 @@ func (c *SMTPClient) Auth(username, password, accessToken string, mechanisms []s
 		return fmt.Errorf("rate limit wait failed: %w", err)
 	}
 
+	defer c.recorder().Since(timing.Auth, time.Now())
+
 	// Determine which mechanism to use
-	mechanism := selectAuthMechanism(mechanisms, c.capabilities.GetAuthMechanisms(), accessToken != "")
+	mechanism := selectAuthMechanism(mechanisms, c.UsableAuthMechanisms(), accessToken != "")
 	if mechanism == "" {
 		return fmt.Errorf("no compatible authentication mechanism found")
 	}
@@ -301,7 +411,15 @@ func (c *SMTPClient) Auth(username, password, accessToken string, mechanisms []s
 	case "XOAUTH2":
 		auth = &xoauth2Auth{username, accessToken}
 	default:
-		return fmt.Errorf("unsupported authentication mechanism: %s", mechanism)
+		// SCRAM (with channel binding for -PLUS) and NTLM
+		if !sasl.IsSupported(mechanism) {
+			return fmt.Errorf("unsupported authentication mechanism: %s", mechanism)
+		}
+		saslClient, err := sasl.NewClient(mechanism, username, password, c.tlsState, c.capabilities.GetAuthMechanisms())
+		if err != nil {
+			return err
+		}
+		auth = &saslAuth{saslClient}
 	}
 
 	// Use the reusable smtp.Client created after STARTTLS
@@ -320,12 +438,12 @@ func (c *SMTPClient) Auth(username, password, accessToken string, mechanisms []s
 	// This sends EHLO again, which is required by smtp.Client.Auth()
 	if err := c.smtpClient.Hello(c.host); err != nil {
 		c.debugLogMessage("<<< EHLO for auth failed")
-		return fmt.Errorf("EHLO for auth failed: %w", err)
+		return fmt.Errorf("EHLO for auth failed: %w", c.replyError(err))
 	}
 
 	if err := c.smtpClient.Auth(auth); err != nil {
 		c.debugLogMessage("<<< Authentication failed")
-		return fmt.Errorf("authentication failed: %w", err)
+		return fmt.Errorf("authentication failed: %w", c.replyError(err))
 	}
 
 	c.debugLogMessage("<<< 235 Authentication successful")
@@ -355,31 +473,99 @@ func (c *SMTPClient) SendMail(from string, to []string, data []byte) error {
 	}
 	smtpClient := c.smtpClient
 
-	// MAIL FROM
-	c.debugLogMessage(fmt.Sprintf(">>> MAIL FROM:<%s>", from))
-	if err := smtpClient.Mail(from); err != nil {
-		c.debugLogMessage(fmt.Sprintf("<<< MAIL FROM failed: %v", err))
-		return fmt.Errorf("MAIL FROM failed: %w", err)
+	// DSN parameters are only sent when the server advertises the extension
+	dsn := buildDSNOptions(c.config)
+	if !dsn.IsEmpty() && !c.capabilities.SupportsDSN() {
+		c.debugLogMessage("Server does not advertise DSN; sending without NOTIFY/ORCPT/RET/ENVID parameters")
+		dsn = nil
 	}
-	c.debugLogMessage("<<< 250 Sender OK")
 
-	// RCPT TO
+	c.transaction = nil
+	defer c.recordTransaction(time.Now())
+
+	// CHUNKING and BINARYMIME are only used when advertised; otherwise fall back to DATA
+	useChunking := c.config.Chunking && c.capabilities.SupportsChunking()
+	if c.config.Chunking && !useChunking {
+		c.debugLogMessage("Server does not advertise CHUNKING; sending message with DATA")
+	}
+	mailParams := dsn.MailParams()
+	binaryMIME := false
+	if c.config.BinaryMIME {
+		if useChunking && c.capabilities.SupportsBinaryMIME() {
+			binaryMIME = true
+			mailParams = append(mailParams, "BODY=BINARYMIME")
+		} else {
+			c.debugLogMessage("Server does not advertise BINARYMIME with CHUNKING; omitting BODY=BINARYMIME")
+		}
+	}
+	// Declare 8-bit content when the server accepts it, as net/smtp's Mail
+	// does, so that -eml replays and raw UTF-8 headers are not rejected
+	if !binaryMIME && c.capabilities.Supports8BITMIME() {
+		mailParams = append(mailParams, "BODY=8BITMIME")
+	}
+
+	// Internationalized addresses: declare SMTPUTF8 when advertised, otherwise
+	// fall back to IDNA domains (impossible for UTF-8 local parts)
+	internationalized := false
+	if !protocol.IsASCII(from + strings.Join(to, "")) {
+		if c.capabilities.SupportsSMTPUTF8() {
+			internationalized = true
+			mailParams = append(mailParams, protocol.SMTPUTF8Param)
+		} else {
+			c.debugLogMessage("Server does not advertise SMTPUTF8; converting domains to IDNA A-labels")
+			var err error
+			if from, to, err = addressesToASCII(from, to); err != nil {
+				return fmt.Errorf("server does not advertise SMTPUTF8 (RFC 6531): %w", err)
+			}
+		}
+	}
+
+	// MAIL FROM and RCPT TO, pipelined as one batch when PIPELINING is advertised
+	commands := []string{protocol.MAILFROMWithParams(from, mailParams)}
 	for _, recipient := range to {
-		c.debugLogMessage(fmt.Sprintf(">>> RCPT TO:<%s>", recipient))
-		if err := smtpClient.Rcpt(recipient); err != nil {
-			c.debugLogMessage(fmt.Sprintf("<<< RCPT TO failed: %v", err))
-			return fmt.Errorf("RCPT TO failed for %s: %w", recipient, err)
+		commands = append(commands, protocol.RCPTTOWithParams(recipient, dsn.RcptParams(recipient)))
+	}
+	var errs []error
+	if c.config.Pipelining && c.capabilities.SupportsPipelining() {
+		var err error
+		if errs, err = c.pipelineCommands(commands); err != nil {
+			return fmt.Errorf("pipelined envelope failed: %w", err)
+		}
+	} else {
+		if c.config.Pipelining {
+			c.debugLogMessage("Server does not advertise PIPELINING; sending commands one at a time")
+		}
+		for _, cmd := range commands {
+			_, err := c.envelopeCommand(cmd)
+			errs = append(errs, err)
+			if err != nil {
+				break
+			}
 		}
-		c.debugLogMessage("<<< 250 Recipient OK")
+	}
+	if errs[0] != nil {
+		return fmt.Errorf("MAIL FROM failed: %w", explainEAIError(errs[0], internationalized))
+	}
+	for i, err := range errs[1:] {
+		if err != nil {
+			return fmt.Errorf("RCPT TO failed for %s: %w", to[i], explainEAIError(err, internationalized))
+		}
+	}
+
+	if useChunking {
+		return explainEAIError(c.sendChunks(data), internationalized)
 	}
 
 	// DATA
 	c.debugLogMessage(">>> DATA")
+	start := time.Now()
 	w, err := smtpClient.Data()
 	if err != nil {
 		c.debugLogMessage(fmt.Sprintf("<<< DATA failed: %v", err))
-		return fmt.Errorf("DATA command failed: %w", err)
+		c.recordResult(protocol.DATA(), 354, err, time.Since(start))
+		return fmt.Errorf("DATA command failed: %w", c.replyError(err))
 	}
+	c.recordResult(protocol.DATA(), 354, nil, time.Since(start))
 	c.debugLogMessage("<<< 354 Start mail input; end with <CRLF>.<CRLF>")
 
 	// Send message body
@@ -400,16 +586,214 @@ func (c *SMTPClient) SendMail(from string, to []string, data []byte) error {
 	}
 
 	c.debugLogMessage(">>> . (end of message)")
+	start = time.Now()
 	if err := w.Close(); err != nil {
 		c.debugLogMessage(fmt.Sprintf("<<< Message send failed: %v", err))
-		return fmt.Errorf("failed to close DATA: %w", err)
+		c.recordResult(".", 250, err, time.Since(start))
+		return fmt.Errorf("failed to close DATA: %w", deliveryUnknown(explainEAIError(c.replyError(err), internationalized)))
 	}
+	c.recordResult(".", 250, nil, time.Since(start))
 	c.debugLogMessage("<<< 250 Message accepted for delivery")
 
 	return nil
 }
 
-// Close closes the connection.
+// recordTransaction records the time since start as the Transaction phase,
+// with each command of the transaction log as a step.
+func (c *SMTPClient) recordTransaction(start time.Time) {
+	rec := c.recorder()
+	rec.Since(timing.Transaction, start)
+	for _, result := range c.transaction {
+		rec.Step(result.Command, result.Duration)
+	}
+}
+
+// envelopeCommand sends a MAIL FROM or RCPT TO command through the shared
+// textproto connection and expects a 25x reply. Commands are written raw
+// (instead of via smtp.Client.Mail/Rcpt) so ESMTP parameters can be attached.
+func (c *SMTPClient) envelopeCommand(cmd string) (*protocol.SMTPResponse, error) {
+	c.debugLogCommand(cmd)
+
+	text := c.smtpClient.Text
+	start := time.Now()
+	id, err := text.Cmd("%s", strings.TrimRight(cmd, "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	resp, err := c.readReply(cmd, 25, start, false)
+	if err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// pipelineCommands writes all commands in a single batch (RFC 2920) and then
+// reads one reply per command, in order. The returned slice holds the reply
+// error for each command (nil on 25x). A non-nil error means the connection
+// failed and the remaining replies could not be read.
+func (c *SMTPClient) pipelineCommands(commands []string) ([]error, error) {
+	text := c.smtpClient.Text
+	c.debugLogMessage(fmt.Sprintf("Pipelining %d commands", len(commands)))
+	for _, cmd := range commands {
+		c.debugLogCommand(cmd)
+		if _, err := text.W.WriteString(cmd); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	if err := text.W.Flush(); err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(commands))
+	for i, cmd := range commands {
+		if _, err := c.readReply(cmd, 25, start, true); err != nil {
+			var protoErr *textproto.Error
+			if !errors.As(err, &protoErr) {
+				return errs, err
+			}
+			errs[i] = err
+		}
+	}
+	return errs, nil
+}
+
+// sendChunks transfers the message with BDAT (RFC 3030). The data is sent
+// as-is in ChunkSize pieces, without dot-stuffing; the final chunk is marked LAST.
+func (c *SMTPClient) sendChunks(data []byte) error {
+	text := c.smtpClient.Text
+	chunkSize := c.config.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	for offset := 0; ; offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		last := end == len(data)
+
+		cmd := protocol.BDAT(end-offset, last)
+		c.debugLogCommand(cmd)
+		start := time.Now()
+		if _, err := text.W.WriteString(cmd); err != nil {
+			return fmt.Errorf("BDAT failed: %w", err)
+		}
+		if _, err := text.W.Write(data[offset:end]); err != nil {
+			return fmt.Errorf("BDAT failed: %w", err)
+		}
+		if err := text.W.Flush(); err != nil {
+			return fmt.Errorf("BDAT failed: %w", err)
+		}
+		if _, err := c.readReply(cmd, 250, start, false); err != nil {
+			if last {
+				err = deliveryUnknown(err)
+			}
+			return fmt.Errorf("BDAT failed: %w", err)
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// deliveryUnknown marks a failure to read the reply to the end of the message
+// data as permanent: the server may have accepted the message before the
+// connection failed, and sending it again could deliver it twice.
+func deliveryUnknown(err error) error {
+	var reply *protocol.ReplyError
+	if errors.As(err, &reply) {
+		return err
+	}
+	return retry.Permanent(fmt.Errorf("no reply to the end of the message, it may have been delivered: %w", err))
+}
+
+// readReply reads one reply for cmd, logs it and records it in the transaction log.
+func (c *SMTPClient) readReply(cmd string, expectCode int, start time.Time, pipelined bool) (*protocol.SMTPResponse, error) {
+	code, message, err := c.smtpClient.Text.ReadResponse(expectCode)
+	resp := &protocol.SMTPResponse{Code: code, Message: message, Lines: strings.Split(message, "\n")}
+	if code != 0 {
+		c.debugLogResponse(resp)
+		c.annotateReply(resp)
+	}
+	c.transaction = append(c.transaction, CommandResult{
+		Command:   strings.TrimRight(cmd, "\r\n"),
+		Code:      code,
+		Message:   message,
+		Duration:  time.Since(start),
+		Pipelined: pipelined,
+	})
+	return resp, c.replyError(err)
+}
+
+// annotateReply sets the enhanced status code of a reply when the server
+// advertises ENHANCEDSTATUSCODES; without it, text that looks like a code is
+// not trusted.
+func (c *SMTPClient) annotateReply(resp *protocol.SMTPResponse) {
+	if c.capabilities.SupportsEnhancedStatusCodes() {
+		resp.Enhanced = resp.EnhancedCode()
+	}
+}
+
+// replyError converts a reply error of the stdlib client (*textproto.Error)
+// into a *protocol.ReplyError, whose message explains the reply. The
+// original error stays reachable through errors.As. Other errors are
+// returned unchanged.
+func (c *SMTPClient) replyError(err error) error {
+	var replyErr *protocol.ReplyError
+	var protoErr *textproto.Error
+	if err == nil || errors.As(err, &replyErr) || !errors.As(err, &protoErr) {
+		return err
+	}
+	resp := &protocol.SMTPResponse{Code: protoErr.Code, Message: protoErr.Msg, Lines: strings.Split(protoErr.Msg, "\n")}
+	c.annotateReply(resp)
+	replyErr = protocol.NewReplyError(resp)
+	replyErr.Err = err
+	return replyErr
+}
+
+// recordResult records a command whose reply was consumed by smtp.Client.
+// The stdlib client only exposes failed replies (through the error), so a
+// successful reply is recorded with the code the client required.
+func (c *SMTPClient) recordResult(cmd string, successCode int, err error, elapsed time.Duration) {
+	result := CommandResult{
+		Command:  strings.TrimRight(cmd, "\r\n"),
+		Duration: elapsed,
+	}
+	var protoErr *textproto.Error
+	if err == nil {
+		result.Code = successCode
+	} else if errors.As(err, &protoErr) {
+		result.Code = protoErr.Code
+		result.Message = protoErr.Msg
+	}
+	c.transaction = append(c.transaction, result)
+}
+
+// explainEAIError annotates a reply error from an SMTPUTF8 transaction with
+// the reason the server refused the internationalized message, if recognized.
+func explainEAIError(err error, internationalized bool) error {
+	var protoErr *textproto.Error
+	if err == nil || !internationalized || !errors.As(err, &protoErr) {
+		return err
+	}
+	if reason := protocol.EAIRejectionReason(protoErr.Code, protoErr.Msg); reason != "" {
+		return fmt.Errorf("%w (EAI rejected: %s)", err, reason)
+	}
+	return err
+}
+
+// GetTransactionLog returns the per-command replies and timings of the last SendMail call.
+func (c *SMTPClient) GetTransactionLog() []CommandResult {
+	return c.transaction
+}
+
+// Close closes the connection. Closing a closed client does nothing.
 func (c *SMTPClient) Close() error {
 	if c.conn != nil {
 		// Send QUIT
@@ -418,7 +802,9 @@ func (c *SMTPClient) Close() error {
 		_, _ = c.conn.Write([]byte(cmd))
 		// Note: We don't wait for the response as the connection is being closed
 		c.debugLogMessage("<<< 221 Closing connection")
-		return c.conn.Close()
+		conn := c.conn
+		c.conn = nil
+		return conn.Close()
 	}
 	return nil
 }
@@ -443,6 +829,26 @@ func (c *SMTPClient) GetTLSState() *tls.ConnectionState {
 	return c.tlsState
 }
 
+// RemoteIP returns the IP address of the server the client is connected to,
+// or "" when it is not connected or connects through a -proxy, which chooses
+// the server address itself.
+func (c *SMTPClient) RemoteIP() string {
+	if c.conn == nil || c.config.ProxyURL != "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// UsableAuthMechanisms returns the advertised AUTH mechanisms that can be used
+// on this connection. Channel-binding (-PLUS) mechanisms require TLS.
+func (c *SMTPClient) UsableAuthMechanisms() []string {
+	return sasl.Usable(c.capabilities.GetAuthMechanisms(), c.IsEncrypted())
+}
+
 // selectAuthMechanism selects the best authentication mechanism.
 // If hasAccessToken is true, XOAUTH2 is preferred when available.
 func selectAuthMechanism(requested []string, available []string, hasAccessToken bool) string {
@@ -459,11 +865,9 @@ func selectAuthMechanism(requested []string, available []string, hasAccessToken
 	}
 
 	// Auto-select: prefer XOAUTH2 if access token provided, otherwise prefer stronger mechanisms
-	var preferenceOrder []string
+	preferenceOrder := sasl.PasswordPreference
 	if hasAccessToken {
-		preferenceOrder = []string{"XOAUTH2", "CRAM-MD5", "PLAIN", "LOGIN"}
-	} else {
-		preferenceOrder = []string{"CRAM-MD5", "PLAIN", "LOGIN"}
+		preferenceOrder = append([]string{"XOAUTH2"}, sasl.PasswordPreference...)
 	}
 
 	for _, preferred := range preferenceOrder {
@@ -536,3 +940,36 @@ func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
 	// Return empty to signal we have nothing more to send
 	return nil, nil
 }
+
+// saslAuth adapts a SASL client mechanism (SCRAM, NTLM) to smtp.Auth.
+type saslAuth struct {
+	client sasl.Client
+}
+
+func (a *saslAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return a.client.Start()
+}
+
+func (a *saslAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		// fromServer is the text of the 235 reply; SCRAM must have seen the
+		// server signature, in a 334 challenge or as base64 after the status code
+		if completer, ok := a.client.(sasl.Completer); ok {
+			return nil, completer.Complete(successData(fromServer))
+		}
+		return nil, nil
+	}
+	return a.client.Next(fromServer)
+}
+
+// successData decodes SASL data sent with a 235 reply, e.g.
+// "2.7.0 dj1ybWY5cHFW..." (nil if the text is not base64).
+func successData(text []byte) []byte {
+	resp := &protocol.SMTPResponse{Code: 235, Message: string(text)}
+	data := strings.TrimSpace(strings.TrimPrefix(resp.Message, resp.EnhancedCode()))
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
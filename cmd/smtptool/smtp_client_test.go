@@ -4,14 +4,23 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"msgraphtool/internal/common/retry"
+	"msgraphtool/internal/common/timing"
 	"msgraphtool/internal/smtp/protocol"
 )
 
@@ -422,6 +431,27 @@ func TestSelectAuthMechanism(t *testing.T) {
 			hasAccessToken: false,
 			expected:       "LOGIN",
 		},
+		{
+			name:           "Auto-select SCRAM-SHA-256 over CRAM-MD5 and NTLM",
+			requested:      []string{"auto"},
+			available:      []string{"PLAIN", "NTLM", "CRAM-MD5", "SCRAM-SHA-1", "SCRAM-SHA-256"},
+			hasAccessToken: false,
+			expected:       "SCRAM-SHA-256",
+		},
+		{
+			name:           "Auto-select channel-bound SCRAM when advertised",
+			requested:      []string{"auto"},
+			available:      []string{"SCRAM-SHA-256", "SCRAM-SHA-256-PLUS", "PLAIN"},
+			hasAccessToken: false,
+			expected:       "SCRAM-SHA-256-PLUS",
+		},
+		{
+			name:           "Auto-select NTLM over PLAIN (on-prem Exchange)",
+			requested:      []string{"auto"},
+			available:      []string{"NTLM", "GSSAPI", "LOGIN", "PLAIN"},
+			hasAccessToken: false,
+			expected:       "NTLM",
+		},
 
 		// Auto-selection WITH access token (prefer XOAUTH2)
 		{
@@ -870,3 +900,642 @@ func TestSMTPSConfig(t *testing.T) {
 		}
 	})
 }
+
+// fakeSMTPServer is a scripted SMTP server for client tests that serves one
+// connection at a time. Commands of all connections are recorded; replies come from respond (or sensible defaults when
+// respond returns ""). Message content received after DATA is stored in data.
+type fakeSMTPServer struct {
+	listener   net.Listener
+	extensions []string
+	respond    func(cmd string) string
+
+	mu       sync.Mutex
+	commands []string
+	batched  []bool // Whether more client input was already buffered when each command was read
+	data     string
+}
+
+// newFakeSMTPServer starts a fake server advertising the given EHLO extensions.
+func newFakeSMTPServer(t *testing.T, extensions []string, respond func(cmd string) string) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake SMTP server: %v", err)
+	}
+	s := &fakeSMTPServer{listener: listener, extensions: extensions, respond: respond}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+// port returns the TCP port the fake server listens on.
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// recorded returns a copy of the commands received so far.
+func (s *fakeSMTPServer) recorded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.handle(conn)
+	}
+}
+
+// handle runs one SMTP session.
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 fake.example.com ESMTP ready\r\n")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		s.batched = append(s.batched, reader.Buffered() > 0)
+		s.mu.Unlock()
+
+		// BDAT is followed by exactly <size> octets of message data
+		upper := strings.ToUpper(cmd)
+		if strings.HasPrefix(upper, "BDAT ") {
+			var size int
+			fmt.Sscanf(cmd[5:], "%d", &size)
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(reader, chunk); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data += string(chunk)
+			s.mu.Unlock()
+		}
+
+		reply := ""
+		if s.respond != nil {
+			reply = s.respond(cmd)
+		}
+		if reply == "" {
+			switch {
+			case strings.HasPrefix(upper, "EHLO"):
+				var b strings.Builder
+				b.WriteString("250-fake.example.com\r\n")
+				for _, ext := range s.extensions {
+					b.WriteString("250-" + ext + "\r\n")
+				}
+				b.WriteString("250 HELP\r\n")
+				reply = b.String()
+			case upper == "DATA":
+				reply = "354 Start mail input\r\n"
+			case strings.HasPrefix(upper, "BDAT "):
+				reply = "250 2.0.0 Chunk accepted\r\n"
+			case upper == "QUIT":
+				fmt.Fprint(conn, "221 Bye\r\n")
+				return
+			default:
+				reply = "250 OK\r\n"
+			}
+		}
+		fmt.Fprint(conn, reply)
+
+		if upper == "DATA" && strings.HasPrefix(reply, "354") {
+			var body strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				body.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.data = body.String()
+			s.mu.Unlock()
+			fmt.Fprint(conn, "250 2.0.0 Queued\r\n")
+		}
+	}
+}
+
+// connectFakeServer connects an SMTPClient to the fake server and runs EHLO.
+func connectFakeServer(t *testing.T, server *fakeSMTPServer, config *Config) *SMTPClient {
+	t.Helper()
+	config.Timeout = 5 * time.Second
+	client := NewSMTPClient("127.0.0.1", server.port(), config)
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.EHLO("smtptool.local"); err != nil {
+		t.Fatalf("EHLO() error = %v", err)
+	}
+	return client
+}
+
+// TestSendMail_DSNParameters tests that DSN parameters are sent only when advertised
+func TestSendMail_DSNParameters(t *testing.T) {
+	newDSNConfig := func() *Config {
+		config := NewConfig()
+		config.DSNNotify = "failure,delay"
+		config.DSNRet = "HDRS"
+		config.EnvID = "run 42"
+		return config
+	}
+
+	t.Run("DSN advertised", func(t *testing.T) {
+		server := newFakeSMTPServer(t, []string{"DSN", "8BITMIME"}, nil)
+		client := connectFakeServer(t, server, newDSNConfig())
+
+		if err := client.SendMail("sender@example.com", []string{"rcpt@example.com"}, []byte("Subject: x\r\n\r\nbody\r\n")); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+
+		commands := server.recorded()
+		wantMail := "MAIL FROM:<sender@example.com> RET=HDRS ENVID=run+2042 BODY=8BITMIME"
+		wantRcpt := "RCPT TO:<rcpt@example.com> NOTIFY=FAILURE,DELAY ORCPT=rfc822;rcpt@example.com"
+		if !containsString(commands, wantMail) {
+			t.Errorf("commands %q missing %q", commands, wantMail)
+		}
+		if !containsString(commands, wantRcpt) {
+			t.Errorf("commands %q missing %q", commands, wantRcpt)
+		}
+	})
+
+	t.Run("DSN not advertised", func(t *testing.T) {
+		server := newFakeSMTPServer(t, []string{"8BITMIME"}, nil)
+		client := connectFakeServer(t, server, newDSNConfig())
+
+		if err := client.SendMail("sender@example.com", []string{"rcpt@example.com"}, []byte("Subject: x\r\n\r\nbody\r\n")); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+
+		commands := server.recorded()
+		if !containsString(commands, "MAIL FROM:<sender@example.com> BODY=8BITMIME") || !containsString(commands, "RCPT TO:<rcpt@example.com>") {
+			t.Errorf("expected MAIL FROM/RCPT TO without DSN parameters, got %q", commands)
+		}
+	})
+
+	t.Run("Recipient rejected", func(t *testing.T) {
+		server := newFakeSMTPServer(t, nil, func(cmd string) string {
+			if strings.HasPrefix(cmd, "RCPT TO") {
+				return "550 5.1.1 User unknown\r\n"
+			}
+			return ""
+		})
+		client := connectFakeServer(t, server, NewConfig())
+
+		err := client.SendMail("sender@example.com", []string{"missing@example.com"}, []byte("body\r\n"))
+		if err == nil || !strings.Contains(err.Error(), "550") {
+			t.Errorf("SendMail() error = %v, want 550 rejection", err)
+		}
+	})
+}
+
+// TestSendMail_Pipelining tests that the envelope is sent as one batch and replies are mapped in order
+func TestSendMail_Pipelining(t *testing.T) {
+	server := newFakeSMTPServer(t, []string{"PIPELINING"}, func(cmd string) string {
+		if cmd == "RCPT TO:<missing@example.com>" {
+			return "550 5.1.1 User unknown\r\n"
+		}
+		return ""
+	})
+	config := NewConfig()
+	config.Pipelining = true
+	client := connectFakeServer(t, server, config)
+
+	err := client.SendMail("sender@example.com", []string{"rcpt@example.com", "missing@example.com"}, []byte("body\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "RCPT TO failed for missing@example.com") {
+		t.Fatalf("SendMail() error = %v, want RCPT TO failure for missing@example.com", err)
+	}
+
+	server.mu.Lock()
+	commands, batched := server.commands, server.batched
+	server.mu.Unlock()
+	for i, cmd := range commands {
+		if strings.HasPrefix(cmd, "MAIL FROM") && (i+1 >= len(batched) || !batched[i]) {
+			t.Errorf("MAIL FROM was not followed by pipelined RCPT TO commands: %q", commands)
+		}
+	}
+
+	log := client.GetTransactionLog()
+	if len(log) != 3 {
+		t.Fatalf("GetTransactionLog() has %d entries, want 3: %+v", len(log), log)
+	}
+	wantCodes := []int{250, 250, 550}
+	for i, want := range wantCodes {
+		if log[i].Code != want || !log[i].Pipelined {
+			t.Errorf("entry %d = %+v, want pipelined code %d", i, log[i], want)
+		}
+	}
+	if log[2].Command != "RCPT TO:<missing@example.com>" {
+		t.Errorf("reply 550 mapped to %q, want RCPT TO:<missing@example.com>", log[2].Command)
+	}
+}
+
+// TestSendMail_Chunking tests BDAT transfer, chunk splitting and BINARYMIME declaration
+func TestSendMail_Chunking(t *testing.T) {
+	message := "Subject: chunked\r\n\r\nline one\r\n.leading dot\r\n"
+
+	t.Run("CHUNKING advertised", func(t *testing.T) {
+		server := newFakeSMTPServer(t, []string{"CHUNKING", "BINARYMIME"}, nil)
+		config := NewConfig()
+		config.Chunking = true
+		config.ChunkSize = 16
+		config.BinaryMIME = true
+		client := connectFakeServer(t, server, config)
+
+		if err := client.SendMail("sender@example.com", []string{"rcpt@example.com"}, []byte(message)); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+
+		commands := server.recorded()
+		if !containsString(commands, "MAIL FROM:<sender@example.com> BODY=BINARYMIME") {
+			t.Errorf("commands %q missing BODY=BINARYMIME", commands)
+		}
+		if containsString(commands, "DATA") {
+			t.Errorf("DATA sent despite CHUNKING: %q", commands)
+		}
+		if !containsString(commands, "BDAT 16") || !containsString(commands, fmt.Sprintf("BDAT %d LAST", len(message)%16)) {
+			t.Errorf("unexpected BDAT sequence: %q", commands)
+		}
+
+		server.mu.Lock()
+		data := server.data
+		server.mu.Unlock()
+		if data != message {
+			t.Errorf("server received %q, want %q (no dot-stuffing)", data, message)
+		}
+	})
+
+	t.Run("CHUNKING not advertised", func(t *testing.T) {
+		server := newFakeSMTPServer(t, nil, nil)
+		config := NewConfig()
+		config.Chunking = true
+		config.BinaryMIME = true
+		client := connectFakeServer(t, server, config)
+
+		if err := client.SendMail("sender@example.com", []string{"rcpt@example.com"}, []byte(message)); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+
+		commands := server.recorded()
+		if !containsString(commands, "DATA") || !containsString(commands, "MAIL FROM:<sender@example.com>") {
+			t.Errorf("expected DATA fallback without BODY parameter, got %q", commands)
+		}
+	})
+}
+
+// TestSendMail_8BITMIME tests that BODY=8BITMIME is declared when advertised
+func TestSendMail_8BITMIME(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []string
+		binaryMIME bool
+		from       string
+		want       string
+	}{
+		{"Advertised", []string{"8BITMIME"}, false, "sender@example.com", "MAIL FROM:<sender@example.com> BODY=8BITMIME"},
+		{"Not advertised", nil, false, "sender@example.com", "MAIL FROM:<sender@example.com>"},
+		{"With SMTPUTF8", []string{"8BITMIME", "SMTPUTF8"}, false, "用户@example.com", "MAIL FROM:<用户@example.com> BODY=8BITMIME SMTPUTF8"},
+		{"BINARYMIME instead", []string{"8BITMIME", "CHUNKING", "BINARYMIME"}, true, "sender@example.com", "MAIL FROM:<sender@example.com> BODY=BINARYMIME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, tt.extensions, nil)
+			config := NewConfig()
+			config.Chunking = tt.binaryMIME
+			config.BinaryMIME = tt.binaryMIME
+			client := connectFakeServer(t, server, config)
+
+			if err := client.SendMail(tt.from, []string{"rcpt@example.com"}, []byte("Subject: caf\xc3\xa9\r\n\r\nbody\r\n")); err != nil {
+				t.Fatalf("SendMail() error = %v", err)
+			}
+			if commands := server.recorded(); !containsString(commands, tt.want) {
+				t.Errorf("commands %q, want %q", commands, tt.want)
+			}
+		})
+	}
+}
+
+// TestSendMail_SMTPUTF8 tests internationalized envelopes with and without SMTPUTF8
+func TestSendMail_SMTPUTF8(t *testing.T) {
+	t.Run("SMTPUTF8 advertised", func(t *testing.T) {
+		server := newFakeSMTPServer(t, []string{"SMTPUTF8"}, nil)
+		client := connectFakeServer(t, server, NewConfig())
+
+		if err := client.SendMail("用户@example.com", []string{"josé@bücher.example"}, []byte("body\r\n")); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+
+		commands := server.recorded()
+		if !containsString(commands, "MAIL FROM:<用户@example.com> SMTPUTF8") {
+			t.Errorf("commands %q missing MAIL FROM with SMTPUTF8", commands)
+		}
+		if !containsString(commands, "RCPT TO:<josé@bücher.example>") {
+			t.Errorf("commands %q missing UTF-8 RCPT TO", commands)
+		}
+	})
+
+	t.Run("IDN domain without SMTPUTF8", func(t *testing.T) {
+		server := newFakeSMTPServer(t, nil, nil)
+		client := connectFakeServer(t, server, NewConfig())
+
+		if err := client.SendMail("sender@example.com", []string{"user@bücher.example"}, []byte("body\r\n")); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+
+		commands := server.recorded()
+		if !containsString(commands, "MAIL FROM:<sender@example.com>") || !containsString(commands, "RCPT TO:<user@xn--bcher-kva.example>") {
+			t.Errorf("expected IDNA recipient without SMTPUTF8, got %q", commands)
+		}
+	})
+
+	t.Run("UTF-8 local part without SMTPUTF8", func(t *testing.T) {
+		server := newFakeSMTPServer(t, nil, nil)
+		client := connectFakeServer(t, server, NewConfig())
+
+		err := client.SendMail("sender@example.com", []string{"用户@example.com"}, []byte("body\r\n"))
+		if err == nil || !strings.Contains(err.Error(), "does not advertise SMTPUTF8") {
+			t.Errorf("SendMail() error = %v, want SMTPUTF8 diagnostic", err)
+		}
+		for _, cmd := range server.recorded() {
+			if strings.HasPrefix(cmd, "MAIL FROM") {
+				t.Errorf("MAIL FROM sent although the recipient cannot be expressed: %q", cmd)
+			}
+		}
+	})
+
+	t.Run("EAI rejected", func(t *testing.T) {
+		server := newFakeSMTPServer(t, []string{"SMTPUTF8"}, func(cmd string) string {
+			if strings.HasPrefix(cmd, "RCPT TO") {
+				return "553 5.6.7 Non-ASCII addresses not permitted for that recipient\r\n"
+			}
+			return ""
+		})
+		client := connectFakeServer(t, server, NewConfig())
+
+		err := client.SendMail("sender@example.com", []string{"用户@example.com"}, []byte("body\r\n"))
+		if err == nil || !strings.Contains(err.Error(), "EAI rejected") {
+			t.Errorf("SendMail() error = %v, want EAI rejection diagnostic", err)
+		}
+	})
+}
+
+// TestSendMail_EnhancedStatusCodes tests that rejections are explained and
+// enhanced status codes are only trusted when ENHANCEDSTATUSCODES is advertised
+func TestSendMail_EnhancedStatusCodes(t *testing.T) {
+	reject := func(cmd string) string {
+		if strings.HasPrefix(cmd, "RCPT TO") {
+			return "550 5.7.54 Unable to relay recipient in non-accepted domain\r\n"
+		}
+		return ""
+	}
+
+	t.Run("advertised", func(t *testing.T) {
+		server := newFakeSMTPServer(t, []string{"ENHANCEDSTATUSCODES"}, reject)
+		client := connectFakeServer(t, server, NewConfig())
+
+		err := client.SendMail("sender@example.com", []string{"rcpt@other.example"}, []byte("body\r\n"))
+		var replyErr *protocol.ReplyError
+		if !errors.As(err, &replyErr) {
+			t.Fatalf("SendMail() error = %v, want *protocol.ReplyError", err)
+		}
+		if replyErr.Enhanced != "5.7.54" || replyErr.Temporary() {
+			t.Errorf("reply error = %+v, want permanent 5.7.54", replyErr)
+		}
+		if !strings.Contains(err.Error(), "Unable to relay to a non-accepted domain") {
+			t.Errorf("SendMail() error = %v, want Microsoft 365 explanation", err)
+		}
+	})
+
+	t.Run("not advertised", func(t *testing.T) {
+		server := newFakeSMTPServer(t, nil, reject)
+		client := connectFakeServer(t, server, NewConfig())
+
+		err := client.SendMail("sender@example.com", []string{"rcpt@other.example"}, []byte("body\r\n"))
+		var replyErr *protocol.ReplyError
+		if !errors.As(err, &replyErr) {
+			t.Fatalf("SendMail() error = %v, want *protocol.ReplyError", err)
+		}
+		if replyErr.Enhanced != "" {
+			t.Errorf("Enhanced = %q, want none without ENHANCEDSTATUSCODES", replyErr.Enhanced)
+		}
+		if !strings.Contains(err.Error(), "550 Mailbox unavailable or policy rejection") {
+			t.Errorf("SendMail() error = %v, want reply code explanation", err)
+		}
+	})
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAuth_NTLM tests the NTLM exchange through AUTH NTLM with an initial response
+func TestAuth_NTLM(t *testing.T) {
+	// Minimal CHALLENGE_MESSAGE without target information
+	challenge := make([]byte, 32)
+	copy(challenge, "NTLMSSP\x00")
+	challenge[8] = 2
+	challenge[20] = 0x01 // NTLMSSP_NEGOTIATE_UNICODE
+	copy(challenge[24:], "8bytes!!")
+
+	server := newFakeSMTPServer(t, []string{"AUTH NTLM LOGIN"}, func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "AUTH NTLM "):
+			return "334 " + base64.StdEncoding.EncodeToString(challenge) + "\r\n"
+		case strings.HasPrefix(cmd, "TlRMTVNTUAAD"): // base64 of "NTLMSSP\x00" + type 3
+			return "235 2.7.0 Authentication successful\r\n"
+		}
+		return ""
+	})
+	client := connectFakeServer(t, server, NewConfig())
+
+	if err := client.Auth(`EXAMPLE\user`, "secret", "", []string{"auto"}); err != nil {
+		t.Fatalf("Auth() error = %v", err)
+	}
+
+	var authCmd string
+	for _, cmd := range server.recorded() {
+		if strings.HasPrefix(cmd, "AUTH ") {
+			authCmd = cmd
+		}
+	}
+	if !strings.HasPrefix(authCmd, "AUTH NTLM TlRMTVNTUAAB") {
+		t.Errorf("AUTH command = %q, want NTLM negotiate message as initial response", authCmd)
+	}
+}
+
+// TestConnect_Proxy tests that the session is tunneled through an HTTP CONNECT proxy
+func TestConnect_Proxy(t *testing.T) {
+	server := newFakeSMTPServer(t, []string{"8BITMIME"}, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	targets := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Request line, then headers up to the empty line
+		r := bufio.NewReader(conn)
+		line, _ := r.ReadString('\n')
+		for header, _ := r.ReadString('\n'); strings.TrimSpace(header) != ""; header, _ = r.ReadString('\n') {
+		}
+		targets <- strings.Fields(line)[1]
+
+		upstream, err := net.Dial("tcp", server.listener.Addr().String())
+		if err != nil {
+			return
+		}
+		defer upstream.Close()
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go io.Copy(upstream, r)
+		io.Copy(conn, upstream)
+	}()
+
+	config := NewConfig()
+	config.ProxyURL = "http://" + listener.Addr().String()
+	config.Timeout = 5 * time.Second
+	// The proxy resolves the name; it does not need to resolve locally
+	client := NewSMTPClient("mail.example.invalid", 25, config)
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+	if _, err := client.EHLO("smtptool.local"); err != nil {
+		t.Fatalf("EHLO() error = %v", err)
+	}
+
+	if target := <-targets; target != "mail.example.invalid:25" {
+		t.Errorf("CONNECT target = %q, want mail.example.invalid:25", target)
+	}
+	if !containsString(server.recorded(), "EHLO smtptool.local") {
+		t.Errorf("EHLO not relayed to the server: %q", server.recorded())
+	}
+}
+
+// TestDeliveryUnknown tests that a lost end-of-data reply is never retried
+func TestDeliveryUnknown(t *testing.T) {
+	reply := &protocol.ReplyError{Code: 451, Message: "Try again later"}
+	if err := deliveryUnknown(reply); err != error(reply) {
+		t.Errorf("deliveryUnknown(reply) = %v, want the reply unchanged", err)
+	}
+
+	err := deliveryUnknown(io.EOF)
+	if retry.IsTransientError(err) {
+		t.Errorf("deliveryUnknown(io.EOF) is retryable: %v", err)
+	}
+	if !errors.Is(err, io.EOF) || !strings.Contains(err.Error(), "may have been delivered") {
+		t.Errorf("deliveryUnknown(io.EOF) = %v", err)
+	}
+}
+
+// TestSMTPClient_Timing tests that a session records its phases and the transaction steps
+func TestSMTPClient_Timing(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	config := NewConfig()
+	config.Timeout = 5 * time.Second
+	rec := &timing.Recorder{}
+	client := NewSMTPClient("127.0.0.1", server.port(), config)
+	if err := client.Connect(timing.WithRecorder(context.Background(), rec)); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+	if _, err := client.EHLO("smtptool.local"); err != nil {
+		t.Fatalf("EHLO() error = %v", err)
+	}
+	if err := client.SendMail("sender@example.com", []string{"rcpt@example.com"}, []byte("body\r\n")); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	for _, phase := range []timing.Phase{timing.TCP, timing.Greeting, timing.Capabilities, timing.Transaction} {
+		if _, ok := rec.Duration(phase); !ok {
+			t.Errorf("%v was not recorded", phase)
+		}
+	}
+	for _, phase := range []timing.Phase{timing.TLS, timing.Auth} {
+		if _, ok := rec.Duration(phase); ok {
+			t.Errorf("%v was recorded on a plain session without AUTH", phase)
+		}
+	}
+	var steps []string
+	for _, step := range rec.Steps() {
+		steps = append(steps, step.Name)
+	}
+	want := []string{"MAIL FROM:<sender@example.com>", "RCPT TO:<rcpt@example.com>", "DATA", "."}
+	if strings.Join(steps, "|") != strings.Join(want, "|") {
+		t.Errorf("steps = %q, want %q", steps, want)
+	}
+}
+
+// TestSuccessData tests decoding SASL data sent with the 235 reply
+func TestSuccessData(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"2.7.0 dj1ybUY5cHFWOFM3c3VBb1pXamE0ZEpSa0ZzS1E9", "v=rmF9pqV8S7suAoZWja4dJRkFsKQ="},
+		{"dj1ybUY5cHFWOFM3c3VBb1pXamE0ZEpSa0ZzS1E9", "v=rmF9pqV8S7suAoZWja4dJRkFsKQ="},
+		{"2.7.0 Authentication successful", ""},
+		{"2.7.0", ""},
+	}
+	for _, tt := range tests {
+		if got := string(successData([]byte(tt.text))); got != tt.want {
+			t.Errorf("successData(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestExecuteAction_IPAddressColumn tests that rows have the IP_Address column with and without -ipversion both
+func TestExecuteAction_IPAddressColumn(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	config := NewConfig()
+	config.Action = ActionTestConnect
+	config.Host = "127.0.0.1"
+	config.Port = server.port()
+	config.Timeout = 5 * time.Second
+
+	single := &recordingLogger{}
+	if err := executeAction(context.Background(), config, single, nil); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	config.IPVersion = "both"
+	both := &recordingLogger{}
+	if err := executeAction(context.Background(), config, both, nil); err != nil {
+		t.Fatalf("executeAction() with -ipversion both error = %v", err)
+	}
+
+	if len(single.rows) != 1 || len(both.rows) != 1 {
+		t.Fatalf("got %d and %d rows, want 1 each", len(single.rows), len(both.rows))
+	}
+	if len(single.rows[0]) != len(both.rows[0]) {
+		t.Fatalf("rows have %d and %d columns, want the same: %q, %q", len(single.rows[0]), len(both.rows[0]), single.rows[0], both.rows[0])
+	}
+	address := len(both.rows[0]) - 2
+	if single.rows[0][address] != "" || both.rows[0][address] != "127.0.0.1" {
+		t.Errorf("IP_Address = %q and %q, want empty and 127.0.0.1", single.rows[0][address], both.rows[0][address])
+	}
+}
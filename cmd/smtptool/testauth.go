@@ -9,7 +9,6 @@ import (
 	"strings"
 
 	"msgraphtool/internal/common/logger"
-	smtptls "msgraphtool/internal/smtp/tls"
 )
 
 // testAuth performs SMTP authentication testing.
@@ -94,15 +93,10 @@ func testAuth(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 	} else if (config.Port == 25 || config.Port == 587) && caps.SupportsSTARTTLS() {
 		// STARTTLS if on port 25/587 and available
 		fmt.Println("Upgrading to TLS before authentication...")
-		tlsVersion := smtptls.ParseTLSVersion(config.TLSVersion)
-		tlsConfig := &tls.Config{
-			ServerName:         config.Host,
-			InsecureSkipVerify: config.SkipVerify,
-			MinVersion:         tlsVersion,
-			MaxVersion:         tlsVersion, // Force exact TLS version
+		tlsConfig, err := newTLSConfig(config, config.Host)
+		if err == nil {
+			tlsState, err = client.StartTLS(tlsConfig)
 		}
-
-		tlsState, err = client.StartTLS(tlsConfig)
 		if err != nil {
 			logger.LogError(slogLogger, "STARTTLS failed", "error", err)
 			if logErr := csvLogger.WriteRow([]string{
@@ -137,7 +131,7 @@ func testAuth(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 		methodsToTry = []string{config.AuthMethod}
 	}
 
-	methodUsed := selectAuthMechanism(methodsToTry, authMechanisms, config.AccessToken != "")
+	methodUsed := selectAuthMechanism(methodsToTry, client.UsableAuthMechanisms(), config.AccessToken != "")
 	if methodUsed == "" {
 		msg := fmt.Sprintf("No compatible authentication mechanism found (requested: %s, available: %s)",
 			config.AuthMethod, strings.Join(authMechanisms, ", "))
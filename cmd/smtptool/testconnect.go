@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
 	"strings"
 
+	"msgraphtool/internal/common/fingerprint"
 	"msgraphtool/internal/common/logger"
 	"msgraphtool/internal/smtp/exchange"
+	"msgraphtool/internal/smtp/protocol"
 )
 
 // testConnect performs basic SMTP connectivity and capability testing.
@@ -18,9 +21,14 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 		fmt.Printf("Testing SMTP connectivity to %s:%d...\n\n", config.Host, config.Port)
 	}
 
+	registry, err := fingerprint.LoadRegistry(config.Fingerprints)
+	if err != nil {
+		return err
+	}
+
 	// Write CSV header
 	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
-		if err := csvLogger.WriteHeader([]string{"Action", "Status", "Server", "Port", "Connected", "Banner", "Capabilities", "Exchange_Detected", "Error"}); err != nil {
+		if err := csvLogger.WriteHeader([]string{"Action", "Status", "Server", "Port", "Connected", "Banner", "Capabilities", "Exchange_Detected", "Fingerprint", "Error"}); err != nil {
 			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
 		}
 	}
@@ -34,7 +42,7 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 		logger.LogError(slogLogger, "Connection failed", "error", err)
 		if logErr := csvLogger.WriteRow([]string{
 			config.Action, "FAILURE", config.Host,
-			fmt.Sprintf("%d", config.Port), "false", "", "", "false", err.Error(),
+			fmt.Sprintf("%d", config.Port), "false", "", "", "false", "", err.Error(),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
@@ -56,7 +64,7 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 		logger.LogError(slogLogger, "EHLO failed", "error", err)
 		if logErr := csvLogger.WriteRow([]string{
 			config.Action, "FAILURE", config.Host,
-			fmt.Sprintf("%d", config.Port), "true", client.GetBanner(), "", "false", err.Error(),
+			fmt.Sprintf("%d", config.Port), "true", client.GetBanner(), "", "false", "", err.Error(),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
@@ -80,12 +88,17 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 		fmt.Print(exchange.FormatExchangeInfo(exchangeInfo, caps))
 	}
 
+	// Identify the server software
+	matches := registry.Identify(smtpEvidence(client.GetBanner(), caps))
+	fingerprint.Print(os.Stdout, matches)
+	fmt.Println()
+
 	// Log to CSV
 	capsStr := caps.String()
 	if logErr := csvLogger.WriteRow([]string{
 		config.Action, "SUCCESS", config.Host,
 		fmt.Sprintf("%d", config.Port), "true", client.GetBanner(),
-		capsStr, fmt.Sprintf("%t", exchangeInfo.IsExchange), "",
+		capsStr, fmt.Sprintf("%t", exchangeInfo.IsExchange), fingerprint.Best(matches), "",
 	}); logErr != nil {
 		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 	}
@@ -99,3 +112,12 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 
 	return nil
 }
+
+// smtpEvidence collects the banner and EHLO keywords for fingerprinting.
+func smtpEvidence(banner string, caps protocol.Capabilities) fingerprint.Evidence {
+	ev := fingerprint.Evidence{Protocol: fingerprint.ProtocolSMTP, Banner: banner}
+	for keyword, params := range caps {
+		ev.Capabilities = append(ev.Capabilities, strings.TrimSpace(keyword+" "+strings.Join(params, " ")))
+	}
+	return ev
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"msgraphtool/internal/common/dns"
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/logger"
+	commontls "msgraphtool/internal/common/tls"
+	"msgraphtool/internal/smtp/protocol"
+)
+
+// mxProbeResult holds the connect/EHLO/STARTTLS diagnostics for one MX host.
+type mxProbeResult struct {
+	MX           dns.MXHost
+	Addresses    []string
+	Banner       string
+	Capabilities protocol.Capabilities
+	STARTTLS     bool                       // STARTTLS advertised and handshake completed
+	TLSInfo      *commontls.TLSInfo         // Negotiated TLS parameters (nil without STARTTLS)
+	CertInfo     *commontls.CertificateInfo // Presented leaf certificate (nil without STARTTLS)
+	CertError    error                      // Chain verification failure (nil when valid or -skipverify)
+	Warnings     []string
+	Err          error // Connection or protocol failure that stopped the probe
+}
+
+// Problem returns the reason the host is considered misconfigured, or "" when healthy.
+func (r *mxProbeResult) Problem() string {
+	switch {
+	case r.Err != nil:
+		return r.Err.Error()
+	case !r.STARTTLS:
+		return "STARTTLS not available"
+	case r.CertError != nil:
+		return fmt.Sprintf("certificate verification failed: %v", r.CertError)
+	case r.CertInfo != nil && r.CertInfo.IsRevoked():
+		return "certificate revoked"
+	}
+	return ""
+}
+
+// testMX resolves the MX hosts of a domain and runs the connect, EHLO and
+// STARTTLS diagnostics against each of them in preference order.
+func testMX(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Testing MX hosts of %s (port %d)...\n", config.Domain, config.Port)
+	if config.DNSServer != "" {
+		fmt.Printf("Resolver: %s\n", config.DNSServer)
+	}
+	fmt.Println()
+
+	// Write CSV header
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader([]string{
+			"Action", "Status", "Domain", "MX_Host", "Preference", "IP_Addresses", "Port",
+			"Banner", "STARTTLS_Available", "TLS_Version", "Cipher_Suite", "Cert_Subject",
+			"Cert_Valid_To", "Verification_Status", "Warnings", "Error",
+		}); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	resolver := dns.NewResolver(config.DNSServer)
+	lookupCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	hosts, err := dns.LookupMX(lookupCtx, resolver, config.Domain)
+	cancel()
+	if err != nil {
+		logger.LogError(slogLogger, "MX lookup failed", "domain", config.Domain, "error", err)
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, "FAILURE", config.Domain, "", "", "", fmt.Sprintf("%d", config.Port),
+			"", "", "", "", "", "", "", "", err.Error(),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+		return err
+	}
+
+	fmt.Println("MX Records:")
+	for _, mx := range hosts {
+		if mx.Implicit {
+			fmt.Printf("  • %s (implicit MX, no MX records published)\n", mx.Host)
+		} else {
+			fmt.Printf("  • %d %s\n", mx.Pref, mx.Host)
+		}
+	}
+
+	// With -ipversion both, every address of every MX host is a probe
+	probed, failed := 0, 0
+	unit := "MX host(s)"
+	if config.IPVersion == dualstack.Both {
+		unit = "MX address(es)"
+	}
+	for _, mx := range hosts {
+		for _, result := range probeMX(ctx, config, mx) {
+			printMXProbeResult(result)
+			probed++
+
+			status := "SUCCESS"
+			if result.Problem() != "" {
+				status = "FAILURE"
+				failed++
+				logger.LogWarn(slogLogger, "MX host failed", "host", mx.Host, "addresses", strings.Join(result.Addresses, ", "), "problem", result.Problem())
+			}
+
+			if logErr := csvLogger.WriteRow(mxProbeRow(config, status, result)); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		fmt.Printf("✗ %d of %d %s failed\n", failed, probed, unit)
+		return fmt.Errorf("%d of %d %s for %s failed", failed, probed, unit, config.Domain)
+	}
+
+	fmt.Printf("✓ All %d %s passed\n", probed, unit)
+	logger.LogInfo(slogLogger, "testmx completed successfully", "domain", config.Domain, "probes", probed)
+	return nil
+}
+
+// probeMX probes an MX host, or with -ipversion both each of its addresses
+// separately.
+func probeMX(ctx context.Context, config *Config, mx dns.MXHost) []*mxProbeResult {
+	if config.IPVersion != dualstack.Both {
+		return []*mxProbeResult{probeMXHost(ctx, config, mx)}
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	addresses, err := dualstack.Lookup(lookupCtx, dns.NewResolver(config.DNSServer), mx.Host, dualstack.Both)
+	cancel()
+	if err != nil {
+		return []*mxProbeResult{{MX: mx, Err: fmt.Errorf("failed to resolve %s: %w", mx.Host, err)}}
+	}
+	var results []*mxProbeResult
+	for _, address := range addresses {
+		results = append(results, probeMXSession(dualstack.WithAddress(ctx, mx.Host, address), config, mx, []string{address}))
+	}
+	return results
+}
+
+// probeMXHost connects to one MX host and collects banner, capabilities and
+// STARTTLS diagnostics. Like a sending MTA, the handshake itself does not
+// verify the certificate; the chain is verified separately so the report can
+// show both the negotiated session and the validation result.
+func probeMXHost(ctx context.Context, config *Config, mx dns.MXHost) *mxProbeResult {
+	lookupCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	addresses, _ := dualstack.Lookup(lookupCtx, dns.NewResolver(config.DNSServer), mx.Host, config.IPVersion)
+	cancel()
+	return probeMXSession(ctx, config, mx, addresses)
+}
+
+// probeMXSession runs the probe of probeMXHost, reporting addresses as the
+// addresses of the host.
+func probeMXSession(ctx context.Context, config *Config, mx dns.MXHost, addresses []string) *mxProbeResult {
+	result := &mxProbeResult{MX: mx, Addresses: addresses}
+
+	hostConfig := *config
+	hostConfig.Host = mx.Host
+	client := NewSMTPClient(mx.Host, config.Port, &hostConfig)
+	if err := client.Connect(ctx); err != nil {
+		result.Err = err
+		return result
+	}
+	defer client.Close()
+	result.Banner = client.GetBanner()
+
+	caps, err := client.EHLO("smtptool.local")
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Capabilities = caps
+	if !caps.SupportsSTARTTLS() {
+		return result
+	}
+
+	tlsConfig, err := newTLSConfig(config, mx.Host)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	tlsConfig.InsecureSkipVerify = true // Verified below against the system roots or -cacert
+	state, err := client.StartTLS(tlsConfig)
+	if err != nil {
+		result.Err = fmt.Errorf("STARTTLS failed: %w", err)
+		return result
+	}
+	result.STARTTLS = true
+
+	result.TLSInfo = commontls.AnalyzeTLSConnection(state)
+	result.CertInfo = commontls.AnalyzeCertificateChain(state, mx.Host)
+	if config.Revocation {
+		result.CertInfo.Revocation = commontls.CheckRevocation(ctx, state.PeerCertificates, config.RevocationOptions())
+	}
+	if !config.SkipVerify {
+		result.CertError = commontls.VerifyCertificateChain(state.PeerCertificates, mx.Host, tlsConfig.RootCAs)
+	}
+	result.Warnings = commontls.CheckTLSWarnings(result.TLSInfo, result.CertInfo, config.SkipVerify)
+
+	if _, err := client.EHLO("smtptool.local"); err != nil {
+		result.Err = fmt.Errorf("EHLO on encrypted connection failed: %w", err)
+	}
+
+	return result
+}
+
+// VerificationStatus returns the certificate verification result for
+// reports: the hostname check, overridden by chain verification failures.
+func (r *mxProbeResult) VerificationStatus() string {
+	if r.CertInfo == nil {
+		return ""
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	switch {
+	case errors.As(r.CertError, &unknownAuthority):
+		return "untrusted"
+	case r.CertError != nil:
+		return "invalid"
+	}
+	return r.CertInfo.VerificationStatus
+}
+
+// printMXProbeResult displays the diagnostics for one MX host.
+func printMXProbeResult(r *mxProbeResult) {
+	fmt.Println()
+	fmt.Println(strings.Repeat("═", 60))
+	if r.MX.Implicit {
+		fmt.Printf("MX %s (implicit)\n", r.MX.Host)
+	} else {
+		fmt.Printf("MX %d %s\n", r.MX.Pref, r.MX.Host)
+	}
+	fmt.Println(strings.Repeat("═", 60))
+	if len(r.Addresses) > 0 {
+		fmt.Printf("  Addresses:   %s\n", strings.Join(r.Addresses, ", "))
+	}
+
+	if r.Banner == "" {
+		fmt.Printf("  ✗ %s\n", r.Err)
+		return
+	}
+	fmt.Printf("  ✓ Connected: %s\n", r.Banner)
+
+	if r.Capabilities != nil {
+		fmt.Printf("  ✓ EHLO:      %s\n", r.Capabilities.String())
+	}
+
+	switch {
+	case r.STARTTLS:
+		fmt.Printf("  ✓ STARTTLS:  %s, %s\n", r.TLSInfo.Version, r.TLSInfo.CipherSuite)
+		fmt.Printf("  Certificate: %s (valid to %s)\n", r.CertInfo.Subject, r.CertInfo.ValidTo.Format("2006-01-02"))
+		if r.CertError != nil {
+			fmt.Printf("  ✗ Verification failed: %v\n", r.CertError)
+		} else {
+			fmt.Printf("  Verification: %s\n", strings.ToUpper(r.CertInfo.VerificationStatus))
+		}
+	case r.Err == nil:
+		fmt.Println("  ✗ STARTTLS not advertised")
+	}
+
+	if r.Err != nil {
+		fmt.Printf("  ✗ %s\n", r.Err)
+	}
+	for _, w := range r.Warnings {
+		fmt.Printf("  ⚠ %s\n", w)
+	}
+}
+
+// mxProbeRow converts a probe result into a testmx CSV row.
+func mxProbeRow(config *Config, status string, r *mxProbeResult) []string {
+	pref := fmt.Sprintf("%d", r.MX.Pref)
+	if r.MX.Implicit {
+		pref = "implicit"
+	}
+
+	tlsVersion, cipherSuite, certSubject, certValidTo, verification := "", "", "", "", ""
+	if r.TLSInfo != nil {
+		tlsVersion, cipherSuite = r.TLSInfo.Version, r.TLSInfo.CipherSuite
+	}
+	if r.CertInfo != nil {
+		certSubject = r.CertInfo.Subject
+		certValidTo = r.CertInfo.ValidTo.Format(time.RFC3339)
+		verification = r.VerificationStatus()
+	}
+
+	return []string{
+		config.Action, status, config.Domain, r.MX.Host, pref, strings.Join(r.Addresses, "; "),
+		fmt.Sprintf("%d", config.Port), r.Banner, fmt.Sprintf("%t", r.STARTTLS),
+		tlsVersion, cipherSuite, certSubject, certValidTo, verification,
+		strings.Join(r.Warnings, "; "), r.Problem(),
+	}
+}
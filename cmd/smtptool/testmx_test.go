@@ -0,0 +1,103 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"msgraphtool/internal/common/dns"
+)
+
+// TestProbeMXHost_NoSTARTTLS tests that an MX without STARTTLS is reported as misconfigured
+func TestProbeMXHost_NoSTARTTLS(t *testing.T) {
+	server := newFakeSMTPServer(t, []string{"8BITMIME"}, nil)
+	config := NewConfig()
+	config.Action = ActionTestMX
+	config.Domain = "example.test"
+	config.Port = server.port()
+	config.Timeout = 5 * time.Second
+
+	result := probeMXHost(context.Background(), config, dns.MXHost{Host: "127.0.0.1", Pref: 10})
+	if result.Err != nil {
+		t.Fatalf("probeMXHost() Err = %v", result.Err)
+	}
+	if !strings.Contains(result.Banner, "fake.example.com") {
+		t.Errorf("Banner = %q, want fake server banner", result.Banner)
+	}
+	if !result.Capabilities.Has("8BITMIME") {
+		t.Errorf("Capabilities = %v, want 8BITMIME", result.Capabilities)
+	}
+	if got := result.Problem(); got != "STARTTLS not available" {
+		t.Errorf("Problem() = %q, want STARTTLS not available", got)
+	}
+
+	row := mxProbeRow(config, "FAILURE", result)
+	if row[3] != "127.0.0.1" || row[4] != "10" || row[8] != "false" {
+		t.Errorf("mxProbeRow() = %q", row)
+	}
+}
+
+// TestProbeMXHost_ConnectFailure tests that unreachable MX hosts are reported
+func TestProbeMXHost_ConnectFailure(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, nil)
+	port := server.port()
+	server.listener.Close()
+
+	config := NewConfig()
+	config.Port = port
+	config.Timeout = 2 * time.Second
+
+	result := probeMXHost(context.Background(), config, dns.MXHost{Host: "127.0.0.1", Implicit: true})
+	if result.Err == nil || result.Problem() == "" {
+		t.Errorf("probeMXHost() expected connection failure, got %+v", result)
+	}
+	if row := mxProbeRow(config, "FAILURE", result); row[4] != "implicit" {
+		t.Errorf("Preference column = %q, want implicit", row[4])
+	}
+}
+
+// TestValidateConfiguration_TestMX tests testmx-specific validation
+func TestValidateConfiguration_TestMX(t *testing.T) {
+	tests := []struct {
+		name      string
+		domain    string
+		dnsServer string
+		smtps     bool
+		wantDNS   string
+		errorMsg  string
+	}{
+		{name: "Domain without host", domain: "example.com"},
+		{name: "Resolver gets default port", domain: "example.com", dnsServer: "127.0.0.1", wantDNS: "127.0.0.1:53"},
+		{name: "Missing domain", errorMsg: "testmx requires -domain"},
+		{name: "Invalid resolver", domain: "example.com", dnsServer: "127.0.0.1:abc", errorMsg: "invalid -dnsserver"},
+		{name: "SMTPS rejected", domain: "example.com", smtps: true, errorMsg: "cannot be combined with -smtps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestMX
+			config.Domain = tt.domain
+			config.DNSServer = tt.dnsServer
+			config.SMTPS = tt.smtps
+
+			err := validateConfiguration(config)
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateConfiguration() unexpected error: %v", err)
+			}
+			if config.DNSServer != tt.wantDNS {
+				t.Errorf("DNSServer = %q, want %q", config.DNSServer, tt.wantDNS)
+			}
+		})
+	}
+}
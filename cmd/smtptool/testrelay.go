@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/smtp/protocol"
+)
+
+// Relay probe results.
+const (
+	relayAccepted     = "accepted"      // RCPT TO accepted (2xx)
+	relayRejectedMail = "rejected_mail" // MAIL FROM refused
+	relayRejectedRcpt = "rejected_rcpt" // RCPT TO refused permanently (5xx)
+	relayDeferred     = "deferred"      // MAIL FROM or RCPT TO refused temporarily (4xx)
+	relayError        = "error"         // Connection failed before a reply was read
+)
+
+// relayCase is one MAIL FROM/RCPT TO combination of the relay audit.
+type relayCase struct {
+	Name    string
+	From    string // Envelope sender ("" for the null reverse-path)
+	To      string // Envelope recipient
+	Relay   bool   // Delivery would leave the server's domain: acceptance means relaying
+	Spoofed bool   // Sender claims a local address
+}
+
+// relayProbe is the outcome of one relayCase.
+type relayProbe struct {
+	Case          relayCase
+	Authenticated bool
+	MailReply     *protocol.SMTPResponse
+	RcptReply     *protocol.SMTPResponse
+	Result        string
+	Err           error
+}
+
+// Finding explains why an accepted combination is a problem, or returns ""
+// when the server behaved as expected.
+func (p *relayProbe) Finding() string {
+	if p.Result != relayAccepted {
+		return ""
+	}
+	switch {
+	case !p.Authenticated && p.Case.Relay:
+		return "open relay: unauthenticated client may relay to external recipients"
+	case !p.Authenticated && p.Case.Spoofed:
+		return "unauthenticated client may use a local sender address"
+	case p.Authenticated && p.Case.Spoofed:
+		return "authenticated client may send as another local address"
+	}
+	return ""
+}
+
+// relayCases returns the audit matrix for a server that accepts mail for
+// localDomain. Recipients that are nominally local but encode an external
+// destination (percent hack, source route, UUCP bang path, quoted local part)
+// count as relay attempts.
+func relayCases(localDomain, externalDomain string) []relayCase {
+	local := "postmaster@" + localDomain
+	extSender := "relay-sender@" + externalDomain
+	extRcpt := "relay-rcpt@" + externalDomain
+
+	return []relayCase{
+		{Name: "external-to-local", From: extSender, To: local},
+		{Name: "external-to-external", From: extSender, To: extRcpt, Relay: true},
+		{Name: "null-sender-to-external", From: "", To: extRcpt, Relay: true},
+		{Name: "spoofed-local-to-local", From: local, To: local, Spoofed: true},
+		{Name: "spoofed-local-to-external", From: local, To: extRcpt, Relay: true, Spoofed: true},
+		{Name: "percent-hack", From: extSender, To: "relay-rcpt%" + externalDomain + "@" + localDomain, Relay: true},
+		{Name: "source-route", From: extSender, To: "@" + localDomain + ":" + extRcpt, Relay: true},
+		{Name: "uucp-bang-path", From: extSender, To: externalDomain + "!relay-rcpt@" + localDomain, Relay: true},
+		{Name: "quoted-local-part", From: extSender, To: `"` + extRcpt + `"@` + localDomain, Relay: true},
+	}
+}
+
+// testRelay audits relay restrictions. Each combination runs MAIL FROM and
+// RCPT TO followed by RSET; DATA is never sent, so no message is delivered.
+// The matrix runs unauthenticated and, when -username is set, again after AUTH.
+func testRelay(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Testing relay restrictions on %s:%d...\n", config.Host, config.Port)
+	fmt.Printf("  Local domain:    %s\n", config.Domain)
+	fmt.Printf("  External domain: %s\n", config.ExternalDomain)
+	fmt.Println("  No message is sent: every probe stops before DATA")
+
+	// Write CSV header
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader([]string{
+			"Action", "Status", "Server", "Port", "Authenticated", "Test_Case", "Mail_From", "Rcpt_To",
+			"Mail_Response", "Rcpt_Response", "Result", "Finding", "Error",
+		}); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	cases := relayCases(config.Domain, config.ExternalDomain)
+	modes := []bool{false}
+	if config.Username != "" {
+		modes = append(modes, true)
+	}
+
+	findings := 0
+	for _, authenticated := range modes {
+		if authenticated {
+			fmt.Printf("\nAuthenticated as %s:\n", config.Username)
+		} else {
+			fmt.Println("\nUnauthenticated:")
+		}
+		fmt.Println(strings.Repeat("─", 60))
+
+		probes, err := runRelayProbes(ctx, config, cases, authenticated)
+		if err != nil {
+			fmt.Printf("  ✗ %v\n", err)
+			logger.LogError(slogLogger, "Relay test session failed", "authenticated", authenticated, "error", err)
+			if logErr := csvLogger.WriteRow([]string{
+				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+				fmt.Sprintf("%t", authenticated), "", "", "", "", "", "", "", err.Error(),
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return err
+		}
+
+		for _, p := range probes {
+			printRelayProbe(p)
+			status := "SUCCESS"
+			if p.Finding() != "" || p.Result == relayError {
+				status = "FAILURE"
+			}
+			if p.Finding() != "" {
+				findings++
+				logger.LogWarn(slogLogger, "Relay finding", "case", p.Case.Name, "authenticated", authenticated, "finding", p.Finding())
+			}
+			errMsg := ""
+			if p.Err != nil {
+				errMsg = p.Err.Error()
+			}
+			if logErr := csvLogger.WriteRow([]string{
+				config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+				fmt.Sprintf("%t", authenticated), p.Case.Name, p.Case.From, p.Case.To,
+				relayReplyString(p.MailReply), relayReplyString(p.RcptReply), p.Result, p.Finding(), errMsg,
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+		}
+		fmt.Println(strings.Repeat("─", 60))
+	}
+
+	fmt.Println()
+	if findings > 0 {
+		fmt.Printf("✗ %d relay finding(s)\n", findings)
+		return fmt.Errorf("server accepted %d risky relay combination(s)", findings)
+	}
+
+	fmt.Println("✓ No relay findings: all risky combinations were refused")
+	logger.LogInfo(slogLogger, "testrelay completed successfully", "cases", len(cases), "authenticated", config.Username != "")
+	return nil
+}
+
+// openProbeSession connects and prepares a session for envelope probes:
+// EHLO, STARTTLS when advertised, and AUTH when authenticated is set.
+// Server policy often differs between plain and encrypted sessions, so the
+// session is upgraded whenever a real client would upgrade it.
+func openProbeSession(ctx context.Context, config *Config, authenticated bool) (*SMTPClient, error) {
+	client := NewSMTPClient(config.Host, config.Port, config)
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	caps, err := client.EHLO("smtptool.local")
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	if !config.SMTPS && caps.SupportsSTARTTLS() {
+		tlsConfig, err := newTLSConfig(config, config.Host)
+		if err == nil {
+			_, err = client.StartTLS(tlsConfig)
+		}
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+		if _, err := client.EHLO("smtptool.local"); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("EHLO on encrypted connection failed: %w", err)
+		}
+	}
+
+	if authenticated {
+		if err := client.Auth(config.Username, config.Password, config.AccessToken, []string{config.AuthMethod}); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// runRelayProbes opens one session and runs every case in it. An error means
+// the session could not be established; failures during the probes are
+// recorded per case.
+func runRelayProbes(ctx context.Context, config *Config, cases []relayCase, authenticated bool) ([]*relayProbe, error) {
+	client, err := openProbeSession(ctx, config, authenticated)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	probes := make([]*relayProbe, 0, len(cases))
+	var connErr error
+	for _, rc := range cases {
+		p := &relayProbe{Case: rc, Authenticated: authenticated}
+		probes = append(probes, p)
+		if connErr != nil {
+			// The server dropped the session (e.g. after too many rejections)
+			p.Result, p.Err = relayError, connErr
+			continue
+		}
+
+		p.MailReply, p.Err = client.Command(protocol.MAILFROM(rc.From))
+		if p.Err == nil && p.MailReply.IsSuccess() {
+			p.RcptReply, p.Err = client.Command(protocol.RCPTTO(rc.To))
+		}
+		p.Result = classifyRelayProbe(p)
+
+		// Reset the transaction so the next case starts clean
+		if p.Err == nil {
+			_, p.Err = client.Command(protocol.RSET())
+		}
+		if p.Err != nil {
+			connErr = fmt.Errorf("connection lost: %w", p.Err)
+		}
+	}
+	return probes, nil
+}
+
+// classifyRelayProbe maps the MAIL FROM and RCPT TO replies of a probe to a result.
+func classifyRelayProbe(p *relayProbe) string {
+	switch {
+	case p.MailReply == nil:
+		return relayError
+	case p.MailReply.IsTemporaryError():
+		return relayDeferred
+	case !p.MailReply.IsSuccess():
+		return relayRejectedMail
+	case p.RcptReply == nil:
+		return relayError
+	case p.RcptReply.IsSuccess():
+		return relayAccepted
+	case p.RcptReply.IsTemporaryError():
+		return relayDeferred
+	}
+	return relayRejectedRcpt
+}
+
+// printRelayProbe displays one line per probe.
+func printRelayProbe(p *relayProbe) {
+	marker := "✓"
+	if p.Finding() != "" || p.Result == relayError {
+		marker = "✗"
+	}
+	fmt.Printf("  %s %-26s MAIL %-4s RCPT %-4s %s\n", marker, p.Case.Name,
+		relayReplyCode(p.MailReply), relayReplyCode(p.RcptReply), p.Result)
+	if finding := p.Finding(); finding != "" {
+		fmt.Printf("      %s\n", finding)
+	}
+	for _, r := range []*protocol.SMTPResponse{p.MailReply, p.RcptReply} {
+		if r != nil && !r.IsSuccess() {
+			if explanation := r.Explain(); explanation != "" {
+				fmt.Printf("      %s\n", explanation)
+			}
+		}
+	}
+	if p.Err != nil {
+		fmt.Printf("      %v\n", p.Err)
+	}
+}
+
+// relayReplyCode returns the reply code of r, or "-" when no reply was read.
+func relayReplyCode(r *protocol.SMTPResponse) string {
+	if r == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", r.Code)
+}
+
+// relayReplyString returns "code message" for CSV output, or "" when no reply was read.
+func relayReplyString(r *protocol.SMTPResponse) string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d %s", r.Code, r.Message)
+}
@@ -0,0 +1,159 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunRelayProbes tests the relay matrix against a server that relays the percent hack
+func TestRunRelayProbes(t *testing.T) {
+	server := newFakeSMTPServer(t, []string{"PIPELINING"}, func(cmd string) string {
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "MAIL FROM:<POSTMASTER@"):
+			return "550 5.7.1 Client does not have permissions to send as this sender\r\n"
+		case strings.HasPrefix(upper, "RCPT TO:") && strings.Contains(cmd, "%"):
+			return "250 2.1.5 Recipient OK\r\n"
+		case strings.HasPrefix(upper, "RCPT TO:<POSTMASTER@EXAMPLE.COM>"):
+			return "250 2.1.5 Recipient OK\r\n"
+		case strings.HasPrefix(upper, "RCPT TO:") && strings.Contains(cmd, "@example.com>"):
+			return "550 5.1.1 User unknown\r\n"
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			return "550 5.7.54 Unable to relay recipient in non-accepted domain\r\n"
+		}
+		return ""
+	})
+
+	config := NewConfig()
+	config.Action = ActionTestRelay
+	config.Host = "127.0.0.1"
+	config.Port = server.port()
+	config.Timeout = 5 * time.Second
+	config.Domain = "example.com"
+	config.ExternalDomain = DefaultExternalDomain
+
+	cases := relayCases(config.Domain, config.ExternalDomain)
+	probes, err := runRelayProbes(context.Background(), config, cases, false)
+	if err != nil {
+		t.Fatalf("runRelayProbes() error = %v", err)
+	}
+	if len(probes) != len(cases) {
+		t.Fatalf("runRelayProbes() returned %d probes, want %d", len(probes), len(cases))
+	}
+
+	want := map[string]string{
+		"external-to-local":         relayAccepted,
+		"external-to-external":      relayRejectedRcpt,
+		"null-sender-to-external":   relayRejectedRcpt,
+		"spoofed-local-to-local":    relayRejectedMail,
+		"spoofed-local-to-external": relayRejectedMail,
+		"percent-hack":              relayAccepted,
+		"source-route":              relayRejectedRcpt,
+		"uucp-bang-path":            relayRejectedRcpt,
+		"quoted-local-part":         relayRejectedRcpt,
+	}
+	for _, p := range probes {
+		if p.Result != want[p.Case.Name] {
+			t.Errorf("%s: Result = %q, want %q", p.Case.Name, p.Result, want[p.Case.Name])
+		}
+		wantFinding := p.Case.Name == "percent-hack"
+		if (p.Finding() != "") != wantFinding {
+			t.Errorf("%s: Finding() = %q, want finding %v", p.Case.Name, p.Finding(), wantFinding)
+		}
+	}
+
+	// Every transaction is reset and DATA is never sent
+	rsets := 0
+	for _, cmd := range server.recorded() {
+		switch strings.ToUpper(cmd) {
+		case "DATA":
+			t.Fatal("testrelay must not send DATA")
+		case "RSET":
+			rsets++
+		}
+	}
+	if rsets != len(cases) {
+		t.Errorf("RSET sent %d times, want %d", rsets, len(cases))
+	}
+	if !containsString(server.recorded(), "RCPT TO:<@example.com:relay-rcpt@example.org>") {
+		t.Errorf("source-route probe not sent: %v", server.recorded())
+	}
+}
+
+// TestRelayProbeFinding tests which accepted combinations are reported
+func TestRelayProbeFinding(t *testing.T) {
+	cases := relayCases("example.com", "example.org")
+	byName := make(map[string]relayCase)
+	for _, c := range cases {
+		byName[c.Name] = c
+	}
+
+	tests := []struct {
+		name          string
+		authenticated bool
+		wantFinding   bool
+	}{
+		{"external-to-local", false, false},
+		{"external-to-external", false, true},
+		{"external-to-external", true, false},
+		{"spoofed-local-to-local", false, true},
+		{"spoofed-local-to-local", true, true},
+		{"source-route", false, true},
+	}
+	for _, tt := range tests {
+		p := &relayProbe{Case: byName[tt.name], Authenticated: tt.authenticated, Result: relayAccepted}
+		if got := p.Finding() != ""; got != tt.wantFinding {
+			t.Errorf("%s (authenticated=%v): finding = %v, want %v", tt.name, tt.authenticated, got, tt.wantFinding)
+		}
+		p.Result = relayRejectedRcpt
+		if p.Finding() != "" {
+			t.Errorf("%s: rejected probe reported a finding", tt.name)
+		}
+	}
+}
+
+// TestValidateConfiguration_TestRelay tests testrelay-specific validation
+func TestValidateConfiguration_TestRelay(t *testing.T) {
+	tests := []struct {
+		name           string
+		domain         string
+		externalDomain string
+		username       string
+		errorMsg       string
+	}{
+		{name: "Default external domain", domain: "example.com"},
+		{name: "Missing domain", errorMsg: "testrelay requires -domain"},
+		{name: "Same domains", domain: "example.org", externalDomain: "EXAMPLE.ORG", errorMsg: "must differ"},
+		{name: "Username without password", domain: "example.com", username: "user@example.com", errorMsg: "require -password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestRelay
+			config.Host = "mail.example.com"
+			config.Domain = tt.domain
+			config.ExternalDomain = tt.externalDomain
+			config.Username = tt.username
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Fatalf("validateConfiguration() unexpected error: %v", err)
+				}
+				if config.ExternalDomain != DefaultExternalDomain {
+					t.Errorf("ExternalDomain = %q, want default %q", config.ExternalDomain, DefaultExternalDomain)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
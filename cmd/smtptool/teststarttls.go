@@ -3,19 +3,28 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"strings"
 	"time"
 
+	"msgraphtool/internal/common/dns"
 	"msgraphtool/internal/common/logger"
+	commontls "msgraphtool/internal/common/tls"
 	smtptls "msgraphtool/internal/smtp/tls"
 )
 
 // testStartTLS performs comprehensive TLS/SSL testing with detailed diagnostics.
 // For SMTPS mode, tests implicit TLS (TLS handshake happens immediately after TCP connect).
+// With -tlssweep, it runs the protocol/cipher sweep instead.
 func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	if config.TLSSweep {
+		return sweepTLS(ctx, config, csvLogger, slogLogger)
+	}
+
 	if config.SMTPS {
 		fmt.Printf("Testing SMTPS (implicit TLS) on %s:%d...\n\n", config.Host, config.Port)
 	} else {
@@ -28,21 +37,55 @@ func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger,
 			"Action", "Status", "Server", "Port", "STARTTLS_Available",
 			"TLS_Version", "Cipher_Suite", "Cert_Subject", "Cert_Issuer",
 			"Cert_Valid_From", "Cert_Valid_To", "Cert_SANs",
-			"Verification_Status", "Warnings", "Error",
+			"Verification_Status", "DANE_Status", "Warnings", "Error",
 		}); err != nil {
 			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
 		}
 	}
 
+	// Look up TLSA records before connecting so a lookup failure is reported
+	// without a half-finished handshake (RFC 7672 section 2.2)
+	var tlsaName string
+	var tlsaRecords []dns.TLSARecord
+	var tlsaAuthenticated bool
+	if config.DANE {
+		tlsaName = dns.TLSAName(config.Host, config.Port)
+		fmt.Printf("Looking up TLSA records for %s...\n", tlsaName)
+		lookupCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+		records, authenticated, err := dns.LookupTLSA(lookupCtx, config.DNSServer, tlsaName)
+		cancel()
+		if err != nil {
+			logger.LogError(slogLogger, "TLSA lookup failed", "name", tlsaName, "error", err)
+			if logErr := csvLogger.WriteRow([]string{
+				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+				"unknown", "", "", "", "", "", "", "", "", "", "", err.Error(),
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return err
+		}
+		tlsaRecords, tlsaAuthenticated = records, authenticated
+		fmt.Printf("✓ Found %d TLSA record(s)\n\n", len(tlsaRecords))
+	}
+
+	// With DANE the handshake accepts any certificate; the chain is checked
+	// against the TLSA records, or the system roots when DANE does not apply
+	clientConfig := config
+	if config.DANE {
+		daneConfig := *config
+		daneConfig.SkipVerify = true
+		clientConfig = &daneConfig
+	}
+
 	// Create and connect client
-	client := NewSMTPClient(config.Host, config.Port, config)
+	client := NewSMTPClient(config.Host, config.Port, clientConfig)
 	logger.LogDebug(slogLogger, "Connecting to SMTP server")
 
 	if err := client.Connect(ctx); err != nil {
 		logger.LogError(slogLogger, "Connection failed", "error", err)
 		if logErr := csvLogger.WriteRow([]string{
 			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			"unknown", "", "", "", "", "", "", "", "", "", err.Error(),
+			"unknown", "", "", "", "", "", "", "", "", "", "", err.Error(),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
@@ -63,7 +106,7 @@ func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger,
 		logger.LogError(slogLogger, "EHLO failed", "error", err)
 		if logErr := csvLogger.WriteRow([]string{
 			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			"unknown", "", "", "", "", "", "", "", "", "", err.Error(),
+			"unknown", "", "", "", "", "", "", "", "", "", "", err.Error(),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
@@ -80,7 +123,7 @@ func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger,
 			logger.LogError(slogLogger, msg)
 			if logErr := csvLogger.WriteRow([]string{
 				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-				"N/A (SMTPS)", "", "", "", "", "", "", "", "", "", msg,
+				"N/A (SMTPS)", "", "", "", "", "", "", "", "", "", "", msg,
 			}); logErr != nil {
 				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 			}
@@ -95,7 +138,7 @@ func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger,
 			logger.LogWarn(slogLogger, msg)
 			if logErr := csvLogger.WriteRow([]string{
 				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-				"false", "", "", "", "", "", "", "", "", "", msg,
+				"false", "", "", "", "", "", "", "", "", "", "", msg,
 			}); logErr != nil {
 				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 			}
@@ -106,25 +149,20 @@ func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger,
 
 		// Perform STARTTLS handshake
 		fmt.Println("Performing TLS handshake...")
-		tlsVersion := smtptls.ParseTLSVersion(config.TLSVersion)
-		tlsConfig := &tls.Config{
-			ServerName:         config.Host,
-			InsecureSkipVerify: config.SkipVerify,
-			MinVersion:         tlsVersion,
-			MaxVersion:         tlsVersion, // Force exact TLS version
+		tlsConfig, err := newTLSConfig(clientConfig, config.Host)
+		if err == nil {
+			logger.LogDebug(slogLogger, "Starting TLS handshake",
+				"skipVerify", config.SkipVerify,
+				"tlsVersion", config.TLSVersion,
+				"clientCert", config.ClientCert != "",
+				"pins", len(config.PinSHA256))
+			connState, err = client.StartTLS(tlsConfig)
 		}
-
-		logger.LogDebug(slogLogger, "Starting TLS handshake",
-			"skipVerify", config.SkipVerify,
-			"tlsVersion", config.TLSVersion,
-			"minVersion", tlsVersion,
-			"maxVersion", tlsVersion)
-		connState, err = client.StartTLS(tlsConfig)
 		if err != nil {
 			logger.LogError(slogLogger, "STARTTLS handshake failed", "error", err)
 			if logErr := csvLogger.WriteRow([]string{
 				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-				"true", "", "", "", "", "", "", "", "", "", err.Error(),
+				"true", "", "", "", "", "", "", "", "", "", "", err.Error(),
 			}); logErr != nil {
 				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 			}
@@ -135,33 +173,86 @@ func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger,
 	}
 
 	// Analyze TLS connection
-	tlsInfo := smtptls.AnalyzeTLSConnection(connState)
-	printTLSInfo(tlsInfo)
+	tlsInfo := commontls.AnalyzeTLSConnection(connState)
+	commontls.PrintTLSInfo(os.Stdout, tlsInfo)
 
 	// Analyze certificate chain
-	certInfo := smtptls.AnalyzeCertificateChain(connState.PeerCertificates, config.Host)
-	printCertificateInfo(certInfo)
+	certInfo := commontls.AnalyzeCertificateChain(connState, config.Host)
+	if config.Revocation {
+		fmt.Println("\nChecking certificate revocation (OCSP/CRL)...")
+		certInfo.Revocation = commontls.CheckRevocation(ctx, connState.PeerCertificates, config.RevocationOptions())
+	}
+	commontls.PrintCertificateInfo(os.Stdout, certInfo)
 
 	// Check for warnings
-	warnings := smtptls.CheckTLSWarnings(tlsInfo, certInfo, config.SkipVerify)
-	if len(warnings) > 0 {
-		fmt.Println("\n⚠ TLS Warnings:")
-		fmt.Println(strings.Repeat("─", 60))
-		for _, w := range warnings {
-			fmt.Printf("  • %s\n", w)
+	warnings := commontls.CheckTLSWarnings(tlsInfo, certInfo, config.SkipVerify)
+
+	// Verify the chain against the TLSA records
+	daneStatus := ""
+	if config.DANE {
+		daneResult := smtptls.VerifyDANE(connState.PeerCertificates, config.Host, tlsaRecords)
+		daneStatus = daneResult.Status
+		printDANEResult(tlsaName, daneResult, tlsaAuthenticated)
+
+		var daneErr error
+		switch {
+		case daneResult.Verified() && !tlsaAuthenticated:
+			warnings = append(warnings, "TLSA records are not DNSSEC-authenticated (resolver did not set the AD bit); DANE does not apply")
+		case daneResult.Status == smtptls.DANEStatusMismatch && tlsaAuthenticated:
+			// Authenticated, usable records that do not match must fail delivery (RFC 7672 section 2.2)
+			daneErr = fmt.Errorf("DANE verification failed: no usable TLSA record matches the certificate chain")
+		case daneResult.Status == smtptls.DANEStatusMismatch:
+			warnings = append(warnings, "TLSA records do not match, but are not DNSSEC-authenticated; DANE does not apply")
+		}
+
+		// Without a DANE match the certificate must pass regular PKIX validation
+		if daneErr == nil && !(daneResult.Verified() && tlsaAuthenticated) && !config.SkipVerify {
+			var roots *x509.CertPool // System roots unless -cacert is set
+			if config.CACert != "" {
+				roots, daneErr = commontls.LoadCAFile(config.CACert)
+			}
+			if daneErr == nil {
+				if err := commontls.VerifyCertificateChain(connState.PeerCertificates, config.Host, roots); err != nil {
+					daneErr = fmt.Errorf("certificate verification failed: %w", err)
+				}
+			}
+		}
+
+		if daneErr != nil {
+			fmt.Printf("\n✗ %v\n", daneErr)
+			logger.LogError(slogLogger, "DANE verification failed", "name", tlsaName, "status", daneStatus, "error", daneErr)
+			if logErr := csvLogger.WriteRow([]string{
+				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+				"true", tlsInfo.Version, tlsInfo.CipherSuite, certInfo.Subject, certInfo.Issuer,
+				certInfo.ValidFrom.Format(time.RFC3339), certInfo.ValidTo.Format(time.RFC3339),
+				strings.Join(certInfo.SANs, "; "), certInfo.VerificationStatus, daneStatus,
+				strings.Join(warnings, "; "), daneErr.Error(),
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return daneErr
 		}
-		fmt.Println(strings.Repeat("─", 60))
 	}
+	commontls.PrintWarnings(os.Stdout, warnings)
 
 	// Get recommendations
-	recommendations := smtptls.GetTLSRecommendations(tlsInfo)
-	if len(recommendations) > 0 {
-		fmt.Println("\n💡 Recommendations:")
-		fmt.Println(strings.Repeat("─", 60))
-		for _, r := range recommendations {
-			fmt.Printf("  • %s\n", r)
+	commontls.PrintRecommendations(os.Stdout, commontls.GetTLSRecommendations(tlsInfo))
+
+	// A revoked certificate fails the test even when the handshake succeeded
+	if certInfo.IsRevoked() {
+		revokedErr := errors.New("certificate has been revoked")
+		fmt.Printf("\n✗ %v\n", revokedErr)
+		logger.LogError(slogLogger, "Certificate revoked", "subject", certInfo.Subject, "serial", certInfo.SerialNumber)
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+			"true", tlsInfo.Version, tlsInfo.CipherSuite, certInfo.Subject, certInfo.Issuer,
+			certInfo.ValidFrom.Format(time.RFC3339), certInfo.ValidTo.Format(time.RFC3339),
+			strings.Join(certInfo.SANs, "; "), certInfo.VerificationStatus, daneStatus,
+			strings.Join(warnings, "; "), revokedErr.Error(),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
-		fmt.Println(strings.Repeat("─", 60))
+		return revokedErr
 	}
 
 	// Test encrypted connection
@@ -192,6 +283,7 @@ func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger,
 		certInfo.ValidTo.Format(time.RFC3339),
 		strings.Join(certInfo.SANs, "; "),
 		certInfo.VerificationStatus,
+		daneStatus,
 		strings.Join(warnings, "; "),
 		"",
 	}); logErr != nil {
@@ -210,61 +302,28 @@ func testStartTLS(ctx context.Context, config *Config, csvLogger logger.Logger,
 	return nil
 }
 
-// printTLSInfo displays TLS connection details.
-func printTLSInfo(info *smtptls.TLSInfo) {
-	fmt.Println("TLS Connection Details:")
-	fmt.Println(strings.Repeat("═", 60))
-	fmt.Printf("  Protocol Version:    %s\n", info.Version)
-	fmt.Printf("  Cipher Suite:        %s\n", info.CipherSuite)
-	fmt.Printf("  Cipher Strength:     %s\n", strings.ToUpper(info.CipherSuiteStrength))
-	if info.ServerName != "" {
-		fmt.Printf("  Server Name (SNI):   %s\n", info.ServerName)
-	}
-	if info.NegotiatedProtocol != "" {
-		fmt.Printf("  Negotiated Protocol: %s\n", info.NegotiatedProtocol)
-	}
+// printDANEResult displays the outcome of TLSA matching.
+func printDANEResult(name string, result *smtptls.DANEResult, authenticated bool) {
+	fmt.Println("\nDANE Verification (RFC 7672):")
 	fmt.Println(strings.Repeat("═", 60))
-}
-
-// printCertificateInfo displays certificate details.
-func printCertificateInfo(info *smtptls.CertificateInfo) {
-	fmt.Println("\nCertificate Information:")
-	fmt.Println(strings.Repeat("═", 60))
-	fmt.Printf("  Subject:             %s\n", info.Subject)
-	fmt.Printf("  Issuer:              %s\n", info.Issuer)
-	fmt.Printf("  Serial Number:       %s\n", info.SerialNumber)
-	fmt.Printf("  Valid From:          %s\n", info.ValidFrom.Format("2006-01-02 15:04:05 MST"))
-	fmt.Printf("  Valid To:            %s\n", info.ValidTo.Format("2006-01-02 15:04:05 MST"))
-
-	if info.IsExpired {
-		fmt.Printf("  Status:              ⚠ EXPIRED\n")
+	fmt.Printf("  TLSA Name:           %s\n", name)
+	if authenticated {
+		fmt.Printf("  DNSSEC:              ✓ Authenticated\n")
 	} else {
-		fmt.Printf("  Days Until Expiry:   %d\n", info.DaysUntilExpiry)
+		fmt.Printf("  DNSSEC:              ⚠ Not authenticated\n")
 	}
-
-	if len(info.SANs) > 0 {
-		fmt.Println("  Subject Alternative Names:")
-		for _, san := range info.SANs {
-			fmt.Printf("    • %s\n", san)
-		}
+	fmt.Printf("  TLSA Records:        %d (%d usable)\n", result.Records, result.UsableRecords)
+
+	switch {
+	case result.Verified():
+		fmt.Printf("  Result:              ✓ %s match (%s)\n", strings.ToUpper(result.Status), result.Matched)
+	case result.Status == smtptls.DANEStatusNone:
+		fmt.Printf("  Result:              No TLSA records published\n")
+	default:
+		fmt.Printf("  Result:              ✗ %s\n", strings.ToUpper(result.Status))
 	}
-
-	fmt.Printf("  Signature Algorithm: %s\n", info.SignatureAlgorithm)
-	fmt.Printf("  Public Key:          %s (%d bits)\n", info.PublicKeyAlgorithm, info.PublicKeySize)
-
-	if len(info.KeyUsage) > 0 {
-		fmt.Printf("  Key Usage:           %s\n", strings.Join(info.KeyUsage, ", "))
+	for _, d := range result.Details {
+		fmt.Printf("    • %s\n", d)
 	}
-	if len(info.ExtKeyUsage) > 0 {
-		fmt.Printf("  Extended Key Usage:  %s\n", strings.Join(info.ExtKeyUsage, ", "))
-	}
-
-	fmt.Printf("  Verification:        %s\n", strings.ToUpper(info.VerificationStatus))
-	fmt.Printf("  Chain Length:        %d certificate(s)\n", info.ChainLength)
-
-	if info.IsSelfSigned {
-		fmt.Println("  ⚠ Self-signed certificate")
-	}
-
 	fmt.Println(strings.Repeat("═", 60))
 }
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+
+	"msgraphtool/internal/common/dns"
+	"msgraphtool/internal/common/dualstack"
+	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/common/proxy"
+	commontls "msgraphtool/internal/common/tls"
+)
+
+// sweepTLS performs one handshake per protocol version and cipher suite and
+// prints the resulting support matrix. Accepted legacy protocols (TLS 1.0/1.1)
+// and deprecated cipher suites are findings that fail the action.
+func sweepTLS(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	mode := "STARTTLS"
+	if config.SMTPS {
+		mode = "SMTPS"
+	}
+	fmt.Printf("Sweeping TLS versions and cipher suites on %s:%d (%s)...\n", config.Host, config.Port, mode)
+	fmt.Println("  One connection per handshake; certificates are not verified during the sweep")
+
+	// Write CSV header
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader([]string{
+			"Action", "Status", "Server", "Port", "Mode", "TLS_Version", "Cipher_Suite", "Cipher_ID",
+			"Supported", "Strength", "Finding", "Error",
+		}); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	// Present -clientcert to servers that require mutual TLS
+	var results []commontls.SweepResult
+	trustConfig, err := newTLSConfig(config, config.Host)
+	if err == nil {
+		results, err = commontls.Sweep(ctx, config.Host, sweepHandshake(config, trustConfig.Certificates))
+	}
+	if err != nil {
+		fmt.Printf("✗ Sweep aborted: %v\n", err)
+		logger.LogError(slogLogger, "TLS sweep aborted", "error", err)
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port), mode,
+			"", "", "", "", "", "", err.Error(),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+		return err
+	}
+
+	printSweepMatrix(mode, results)
+
+	findings, weak := 0, 0
+	for _, r := range results {
+		status := "SUCCESS"
+		if finding := r.Finding(); finding != "" {
+			status = "FAILURE"
+			findings++
+			logger.LogWarn(slogLogger, "TLS sweep finding", "finding", finding)
+		} else if r.Supported && r.Strength == "weak" {
+			weak++
+		}
+
+		suite, suiteID, errMsg := "", "", ""
+		if r.CipherSuite != 0 {
+			suite = tls.CipherSuiteName(r.CipherSuite)
+			suiteID = fmt.Sprintf("0x%04X", r.CipherSuite)
+		}
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port), mode,
+			commontls.TLSVersionString(r.Version), suite, suiteID,
+			fmt.Sprintf("%t", r.Supported), r.Strength, r.Finding(), errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	fmt.Println()
+	if weak > 0 {
+		fmt.Printf("⚠ %d weak cipher suite(s) accepted\n", weak)
+	}
+	if findings > 0 {
+		fmt.Printf("✗ %d finding(s): legacy protocols or deprecated cipher suites are enabled\n", findings)
+		return fmt.Errorf("server accepts %d legacy protocol/deprecated cipher combination(s)", findings)
+	}
+
+	fmt.Println("✓ Legacy protocols (TLS 1.0/1.1) and deprecated cipher suites are disabled")
+	logger.LogInfo(slogLogger, "TLS sweep completed successfully", "handshakes", len(results), "weak", weak)
+	return nil
+}
+
+// sweepHandshake returns the handshake used for each sweep probe: a new
+// connection, followed by STARTTLS unless -smtps is set. clientCerts are
+// offered when the server requests a client certificate.
+func sweepHandshake(config *Config, clientCerts []tls.Certificate) commontls.HandshakeFunc {
+	return func(ctx context.Context, tlsConfig *tls.Config) (*tls.ConnectionState, error) {
+		tlsConfig.Certificates = clientCerts
+		if config.SMTPS {
+			dialer, err := proxy.NewDialer(config.ProxyURL, &net.Dialer{
+				Timeout:   config.Timeout,
+				Resolver:  dns.NewResolver(config.DNSServer),
+				LocalAddr: dualstack.LocalAddr(config.SourceIP),
+			})
+			if err != nil {
+				return nil, err
+			}
+			conn, err := dualstack.Wrap(dialer, config.IPVersion).DialContext(ctx, "tcp", net.JoinHostPort(config.Host, strconv.Itoa(config.Port)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect: %w", err)
+			}
+			defer conn.Close()
+
+			tlsConn := tls.Client(conn, tlsConfig)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				return nil, &commontls.HandshakeError{Err: err}
+			}
+			state := tlsConn.ConnectionState()
+			return &state, nil
+		}
+
+		client := NewSMTPClient(config.Host, config.Port, config)
+		if err := client.Connect(ctx); err != nil {
+			return nil, err
+		}
+		defer client.Close()
+
+		caps, err := client.EHLO("smtptool.local")
+		if err != nil {
+			return nil, err
+		}
+		if !caps.SupportsSTARTTLS() {
+			return nil, errors.New("STARTTLS not advertised by server")
+		}
+		return client.StartTLS(tlsConfig)
+	}
+}
+
+// printSweepMatrix displays the support matrix, one block per protocol version.
+func printSweepMatrix(mode string, results []commontls.SweepResult) {
+	fmt.Printf("\nTLS Support Matrix (%s):\n", mode)
+	fmt.Println(strings.Repeat("═", 60))
+	for _, version := range commontls.SweepVersions {
+		name := commontls.TLSVersionString(version)
+		if !commontls.VersionSupported(results, version) {
+			fmt.Printf("  %-9s disabled\n", name)
+			continue
+		}
+
+		marker := "✓"
+		if version < tls.VersionTLS12 {
+			marker = "✗"
+		}
+		fmt.Printf("  %-9s %s enabled\n", name, marker)
+		for _, r := range results {
+			if r.Version != version || !r.Supported {
+				continue
+			}
+			suiteMarker := "✓"
+			switch {
+			case r.Finding() != "":
+				suiteMarker = "✗"
+			case r.Strength != "strong":
+				suiteMarker = "⚠"
+			}
+			fmt.Printf("      %s %-10s %s (0x%04X)\n", suiteMarker, strings.ToUpper(r.Strength), tls.CipherSuiteName(r.CipherSuite), r.CipherSuite)
+		}
+		if version == tls.VersionTLS13 {
+			fmt.Println("      (TLS 1.3 suites cannot be restricted by the client; negotiated suite shown)")
+		}
+	}
+	fmt.Println(strings.Repeat("═", 60))
+}
@@ -0,0 +1,78 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	commontls "msgraphtool/internal/common/tls"
+)
+
+// TestSweepHandshake_SMTPS tests that refused handshakes are reported as
+// *HandshakeError and unreachable servers are not
+func TestSweepHandshake_SMTPS(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	config := NewConfig()
+	config.Host = "127.0.0.1"
+	config.Port = addr.Port
+	config.SMTPS = true
+	config.Timeout = 5 * time.Second
+	handshake := sweepHandshake(config, nil)
+
+	state, err := handshake(context.Background(), &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+		MaxVersion:         tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatalf("TLS 1.2 handshake failed: %v", err)
+	}
+	if state.Version != tls.VersionTLS12 {
+		t.Errorf("Version = %s, want TLS 1.2", commontls.TLSVersionString(state.Version))
+	}
+
+	var handshakeErr *commontls.HandshakeError
+	_, err = handshake(context.Background(), &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS13,
+		MaxVersion:         tls.VersionTLS13,
+	})
+	if !errors.As(err, &handshakeErr) {
+		t.Errorf("TLS 1.3 handshake error = %v, want *HandshakeError", err)
+	}
+
+	server.Close()
+	_, err = handshake(context.Background(), &tls.Config{InsecureSkipVerify: true})
+	if err == nil || errors.As(err, &handshakeErr) {
+		t.Errorf("closed server error = %v, want connection error", err)
+	}
+}
+
+func TestValidateConfiguration_TLSSweep(t *testing.T) {
+	config := NewConfig()
+	config.Action = ActionTestStartTLS
+	config.Host = "mail.example.com"
+	config.TLSSweep = true
+	if err := validateConfiguration(config); err != nil {
+		t.Fatalf("validateConfiguration() unexpected error: %v", err)
+	}
+
+	config.DANE = true
+	err := validateConfiguration(config)
+	if err == nil || !strings.Contains(err.Error(), "-tlssweep cannot be combined with -dane") {
+		t.Errorf("validateConfiguration() error = %v, want -tlssweep/-dane conflict", err)
+	}
+}
@@ -48,3 +48,11 @@ func maskAccessToken(token string) string {
 	}
 	return token[:8] + "..." + token[len(token)-4:]
 }
+
+// ifEmpty returns the fallback string when value is empty.
+func ifEmpty(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"msgraphtool/internal/common/logger"
+	"msgraphtool/internal/smtp/protocol"
+)
+
+// Recipient verification results.
+const (
+	rcptExists    = "exists"    // Server confirmed the mailbox
+	rcptRejected  = "rejected"  // Server refused the mailbox permanently
+	rcptCatchAll  = "catch-all" // Accepted, but the domain accepts any local part
+	rcptTarpitted = "tarpitted" // Replies were delayed or temporarily refused
+	rcptUnknown   = "unknown"   // No conclusive reply (e.g. VRFY 252 and RCPT not possible)
+)
+
+// tarpitThreshold is the RCPT TO reply delay above which a server is considered to tarpit.
+const tarpitThreshold = 5 * time.Second
+
+// rcptVerification holds the probe replies and classification for one address.
+type rcptVerification struct {
+	Address     string
+	VRFY        *protocol.SMTPResponse
+	EXPN        *protocol.SMTPResponse
+	RCPT        *protocol.SMTPResponse
+	RCPTLatency time.Duration
+	CatchAll    bool     // The address's domain accepted a random nonexistent local part
+	Members     []string // EXPN list members
+	Result      string
+	Err         error
+}
+
+// EnhancedCode returns the enhanced status code of the most conclusive reply,
+// or "" when the server does not advertise ENHANCEDSTATUSCODES.
+func (v *rcptVerification) EnhancedCode() string {
+	for _, r := range []*protocol.SMTPResponse{v.RCPT, v.VRFY} {
+		if r != nil && r.Enhanced != "" {
+			return r.Enhanced
+		}
+	}
+	return ""
+}
+
+// verifyRecipients checks each -to address with VRFY, EXPN and a
+// RCPT TO probe (reset before DATA) and classifies the result.
+func verifyRecipients(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Verifying %d recipient(s) on %s:%d...\n", len(config.To), config.Host, config.Port)
+	fmt.Println("  No message is sent: RCPT TO probes are reset before DATA")
+
+	// Write CSV header
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader([]string{
+			"Action", "Status", "Server", "Port", "Address", "Result", "Enhanced_Status",
+			"VRFY_Response", "EXPN_Response", "RCPT_Response", "RCPT_Latency_ms", "Catch_All", "List_Members", "Error",
+		}); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	client, err := openProbeSession(ctx, config, config.Username != "")
+	if err != nil {
+		logger.LogError(slogLogger, "Connection failed", "error", err)
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+			"", "", "", "", "", "", "", "", "", err.Error(),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+		return err
+	}
+	defer client.Close()
+	fmt.Printf("✓ Connected: %s\n\n", client.GetBanner())
+
+	catchAll := make(map[string]bool) // Per-domain result of the catch-all probe
+	counts := make(map[string]int)
+	for _, address := range config.To {
+		address = strings.TrimSpace(address)
+		v := verifyRecipient(client, config.From, address, catchAll)
+		counts[v.Result]++
+		printRcptVerification(v)
+
+		status := "SUCCESS"
+		errMsg := ""
+		if v.Err != nil {
+			status = "FAILURE"
+			errMsg = v.Err.Error()
+			logger.LogError(slogLogger, "Recipient verification failed", "address", address, "error", v.Err)
+		}
+		latency := ""
+		if v.RCPT != nil {
+			latency = fmt.Sprintf("%d", v.RCPTLatency.Milliseconds())
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			address, v.Result, v.EnhancedCode(),
+			relayReplyString(v.VRFY), relayReplyString(v.EXPN), relayReplyString(v.RCPT), latency,
+			fmt.Sprintf("%t", v.CatchAll), strings.Join(v.Members, "; "), errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+
+		if v.Err != nil {
+			return fmt.Errorf("verification of %s failed: %w", address, v.Err)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Summary: %d exists, %d rejected, %d catch-all, %d tarpitted, %d unknown\n",
+		counts[rcptExists], counts[rcptRejected], counts[rcptCatchAll], counts[rcptTarpitted], counts[rcptUnknown])
+	logger.LogInfo(slogLogger, "verifyrcpt completed successfully",
+		"exists", counts[rcptExists], "rejected", counts[rcptRejected], "catchAll", counts[rcptCatchAll])
+	return nil
+}
+
+// verifyRecipient runs the VRFY, EXPN and RCPT TO probes for one address.
+// catchAll caches the catch-all probe per domain across calls.
+func verifyRecipient(client *SMTPClient, from, address string, catchAll map[string]bool) *rcptVerification {
+	v := &rcptVerification{Address: address}
+
+	if v.VRFY, v.Err = client.Command(protocol.VRFY(address)); v.Err != nil {
+		return v
+	}
+	if v.EXPN, v.Err = client.Command(protocol.EXPN(address)); v.Err != nil {
+		return v
+	}
+	if v.EXPN.IsSuccess() {
+		v.Members = v.EXPN.Lines
+	}
+
+	v.RCPT, v.RCPTLatency, v.Err = probeRCPT(client, from, address)
+	if v.Err != nil {
+		return v
+	}
+
+	// A domain that accepts a random local part accepts everything, so an
+	// accepted RCPT proves nothing about the mailbox
+	if v.RCPT != nil && v.RCPT.IsSuccess() {
+		domain := strings.ToLower(address[strings.LastIndex(address, "@")+1:])
+		accepted, cached := catchAll[domain]
+		if !cached {
+			var resp *protocol.SMTPResponse
+			resp, _, v.Err = probeRCPT(client, from, randomLocalPart()+"@"+domain)
+			if v.Err != nil {
+				return v
+			}
+			accepted = resp != nil && resp.IsSuccess()
+			catchAll[domain] = accepted
+		}
+		v.CatchAll = accepted
+	}
+
+	v.Result = classifyRecipient(v)
+	return v
+}
+
+// probeRCPT runs MAIL FROM, RCPT TO and RSET and returns the RCPT TO reply
+// and its latency. The reply is nil when MAIL FROM was refused.
+func probeRCPT(client *SMTPClient, from, address string) (*protocol.SMTPResponse, time.Duration, error) {
+	mail, err := client.Command(protocol.MAILFROM(from))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var rcpt *protocol.SMTPResponse
+	var latency time.Duration
+	if mail.IsSuccess() {
+		start := time.Now()
+		if rcpt, err = client.Command(protocol.RCPTTO(address)); err != nil {
+			return nil, 0, err
+		}
+		latency = time.Since(start)
+	}
+
+	if _, err := client.Command(protocol.RSET()); err != nil {
+		return nil, 0, err
+	}
+	return rcpt, latency, nil
+}
+
+// classifyRecipient derives the result from the probe replies. RCPT TO is
+// authoritative; VRFY is used when no RCPT TO reply is available.
+func classifyRecipient(v *rcptVerification) string {
+	if v.RCPT != nil {
+		switch {
+		case v.RCPTLatency >= tarpitThreshold || v.RCPT.IsTemporaryError():
+			return rcptTarpitted
+		case v.RCPT.IsSuccess() && v.CatchAll:
+			return rcptCatchAll
+		case v.RCPT.IsSuccess():
+			return rcptExists
+		case v.RCPT.IsPermanentError():
+			return rcptRejected
+		}
+	}
+
+	if v.VRFY != nil {
+		switch v.VRFY.Code {
+		case 250, 251:
+			return rcptExists
+		case 550, 551, 553:
+			return rcptRejected
+		}
+		if v.VRFY.IsTemporaryError() {
+			return rcptTarpitted
+		}
+	}
+	return rcptUnknown
+}
+
+// randomLocalPart returns a local part that is practically guaranteed not to exist.
+func randomLocalPart() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "smtptool-probe-" + hex.EncodeToString(b)
+}
+
+// printRcptVerification displays the replies and result for one address.
+func printRcptVerification(v *rcptVerification) {
+	marker := "✓"
+	switch v.Result {
+	case rcptRejected:
+		marker = "✗"
+	case rcptCatchAll, rcptTarpitted, rcptUnknown:
+		marker = "⚠"
+	}
+	if v.Err != nil {
+		fmt.Printf("✗ %s: %v\n", v.Address, v.Err)
+		return
+	}
+
+	fmt.Printf("%s %s: %s", marker, v.Address, strings.ToUpper(v.Result))
+	if code := v.EnhancedCode(); code != "" {
+		fmt.Printf(" (%s)", code)
+	}
+	fmt.Println()
+	fmt.Printf("    VRFY: %s\n", relayReplyString(v.VRFY))
+	fmt.Printf("    EXPN: %s\n", relayReplyString(v.EXPN))
+	if v.RCPT != nil {
+		fmt.Printf("    RCPT: %s (%dms)\n", relayReplyString(v.RCPT), v.RCPTLatency.Milliseconds())
+		if explanation := v.RCPT.Explain(); explanation != "" && !v.RCPT.IsSuccess() {
+			fmt.Printf("          %s\n", explanation)
+		}
+	} else {
+		fmt.Println("    RCPT: not tested (MAIL FROM refused)")
+	}
+	if len(v.Members) > 1 {
+		fmt.Printf("    List members: %s\n", strings.Join(v.Members, ", "))
+	}
+	if v.CatchAll {
+		fmt.Println("    Domain accepts any recipient (catch-all); RCPT TO cannot confirm the mailbox")
+	}
+}
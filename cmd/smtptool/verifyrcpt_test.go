@@ -0,0 +1,160 @@
+//go:build !integration
+// +build !integration
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"msgraphtool/internal/smtp/protocol"
+)
+
+// TestVerifyRecipient tests VRFY/EXPN/RCPT probing and classification against a fake server
+func TestVerifyRecipient(t *testing.T) {
+	server := newFakeSMTPServer(t, []string{"ENHANCEDSTATUSCODES"}, func(cmd string) string {
+		upper := strings.ToUpper(cmd)
+		switch {
+		case upper == "VRFY ALICE@EXAMPLE.COM":
+			return "250 2.1.5 Alice <alice@example.com>\r\n"
+		case strings.HasPrefix(upper, "VRFY "):
+			return "252 2.5.2 Cannot VRFY user, but will accept message\r\n"
+		case upper == "EXPN SALES@EXAMPLE.COM":
+			return "250-2.1.5 Alice <alice@example.com>\r\n250 2.1.5 Bob <bob@example.com>\r\n"
+		case strings.HasPrefix(upper, "EXPN "):
+			return "502 5.5.1 EXPN disabled\r\n"
+		case strings.HasPrefix(upper, "RCPT TO:") && strings.Contains(upper, "@CATCHALL.EXAMPLE>"):
+			return "250 2.1.5 OK\r\n"
+		case strings.HasPrefix(upper, "RCPT TO:<ALICE@") || strings.HasPrefix(upper, "RCPT TO:<SALES@"):
+			return "250 2.1.5 Recipient OK\r\n"
+		case strings.HasPrefix(upper, "RCPT TO:<GREY@"):
+			return "451 4.7.1 Greylisted, try again later\r\n"
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			return "550 5.1.1 User unknown\r\n"
+		}
+		return ""
+	})
+	client := connectFakeServer(t, server, NewConfig())
+
+	catchAll := make(map[string]bool)
+	tests := []struct {
+		address      string
+		wantResult   string
+		wantEnhanced string
+	}{
+		{"alice@example.com", rcptExists, "2.1.5"},
+		{"sales@example.com", rcptExists, "2.1.5"},
+		{"nobody@example.com", rcptRejected, "5.1.1"},
+		{"grey@example.com", rcptTarpitted, "4.7.1"},
+		{"anyone@catchall.example", rcptCatchAll, "2.1.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			v := verifyRecipient(client, "", tt.address, catchAll)
+			if v.Err != nil {
+				t.Fatalf("verifyRecipient() error = %v", v.Err)
+			}
+			if v.Result != tt.wantResult {
+				t.Errorf("Result = %q, want %q", v.Result, tt.wantResult)
+			}
+			if got := v.EnhancedCode(); got != tt.wantEnhanced {
+				t.Errorf("EnhancedCode() = %q, want %q", got, tt.wantEnhanced)
+			}
+		})
+	}
+
+	if v := verifyRecipient(client, "", "sales@example.com", catchAll); len(v.Members) != 2 {
+		t.Errorf("EXPN members = %v, want 2 entries", v.Members)
+	}
+	if catchAll["example.com"] || !catchAll["catchall.example"] {
+		t.Errorf("catch-all cache = %v", catchAll)
+	}
+	for _, cmd := range server.recorded() {
+		if strings.EqualFold(cmd, "DATA") {
+			t.Fatal("verifyrcpt must not send DATA")
+		}
+	}
+}
+
+// TestVerifyRecipient_NoEnhancedStatusCodes tests that code-like reply text is not reported without ENHANCEDSTATUSCODES
+func TestVerifyRecipient_NoEnhancedStatusCodes(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, func(cmd string) string {
+		if strings.HasPrefix(strings.ToUpper(cmd), "RCPT TO:") {
+			return "550 5.1.1 User unknown\r\n"
+		}
+		return ""
+	})
+	client := connectFakeServer(t, server, NewConfig())
+
+	v := verifyRecipient(client, "", "nobody@example.com", make(map[string]bool))
+	if v.Result != rcptRejected {
+		t.Errorf("Result = %q, want %q", v.Result, rcptRejected)
+	}
+	if got := v.EnhancedCode(); got != "" {
+		t.Errorf("EnhancedCode() = %q, want empty without ENHANCEDSTATUSCODES", got)
+	}
+}
+
+// TestClassifyRecipient tests classification fallbacks
+func TestClassifyRecipient(t *testing.T) {
+	reply := func(code int) *protocol.SMTPResponse {
+		return &protocol.SMTPResponse{Code: code, Message: "x", Lines: []string{"x"}}
+	}
+	tests := []struct {
+		name string
+		v    *rcptVerification
+		want string
+	}{
+		{"Slow RCPT", &rcptVerification{RCPT: reply(250), RCPTLatency: tarpitThreshold + time.Second}, rcptTarpitted},
+		{"VRFY only, confirmed", &rcptVerification{VRFY: reply(250)}, rcptExists},
+		{"VRFY only, unknown user", &rcptVerification{VRFY: reply(550)}, rcptRejected},
+		{"VRFY 252 only", &rcptVerification{VRFY: reply(252)}, rcptUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyRecipient(tt.v); got != tt.want {
+				t.Errorf("classifyRecipient() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_VerifyRcpt tests verifyrcpt validation
+func TestValidateConfiguration_VerifyRcpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		to       []string
+		from     string
+		username string
+		errorMsg string
+	}{
+		{name: "Valid", to: []string{"alice@example.com", " bob@example.com"}},
+		{name: "Missing to", errorMsg: "verifyrcpt requires -to"},
+		{name: "Invalid address", to: []string{"not-an-address"}, errorMsg: "invalid address"},
+		{name: "Invalid sender", to: []string{"alice@example.com"}, from: "bad", errorMsg: "invalid sender"},
+		{name: "Username without password", to: []string{"alice@example.com"}, username: "user@example.com", errorMsg: "requires -password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionVerifyRcpt
+			config.Host = "mail.example.com"
+			config.To = tt.to
+			config.From = tt.from
+			config.Username = tt.username
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Fatalf("validateConfiguration() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package certwatch
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"msgraphtool/internal/common/proxy"
+	commontls "msgraphtool/internal/common/tls"
+	pop3protocol "msgraphtool/internal/pop3/protocol"
+	smtpprotocol "msgraphtool/internal/smtp/protocol"
+)
+
+// Status is a Nagios plugin state; its value is the plugin exit code.
+type Status int
+
+// Nagios plugin states.
+const (
+	StatusOK       Status = 0
+	StatusWarning  Status = 1
+	StatusCritical Status = 2
+	StatusUnknown  Status = 3
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "OK"
+	case StatusWarning:
+		return "WARNING"
+	case StatusCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// severity orders states for Worst: an unreachable endpoint does not hide
+// certificates that are about to expire.
+var severity = map[Status]int{StatusOK: 0, StatusUnknown: 1, StatusWarning: 2, StatusCritical: 3}
+
+// Worst returns the more severe of two states (CRITICAL, WARNING, UNKNOWN, OK).
+func Worst(a, b Status) Status {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+// Options configures Run.
+type Options struct {
+	WarningDays  int           // Days before expiry that raise WARNING
+	CriticalDays int           // Days before expiry that raise CRITICAL
+	Concurrency  int           // Maximum simultaneous connections (default 10)
+	Timeout      time.Duration // Connect, STARTTLS and handshake timeout per endpoint (default 30s)
+	Resolver     *net.Resolver // Resolver for endpoint hostnames (system resolver when nil)
+	Dialer       proxy.Dialer  // Dialer for endpoint connections, e.g. through a proxy (direct with Resolver when nil)
+	SaveChain    string        // Directory the presented chains are saved to (see tls.SaveChain)
+
+	// TLSConfig is cloned for each endpoint; it may carry a client
+	// certificate and the roots used to verify the chain (system roots when nil).
+	TLSConfig *tls.Config
+}
+
+// Result is the certificate check of one endpoint.
+type Result struct {
+	Endpoint     Endpoint
+	Certificate  *commontls.CertificateInfo // Leaf certificate (nil if the check failed)
+	Verification string                     // Leaf status, or untrusted/invalid if the chain does not verify
+
+	// The presented certificate that expires first; usually the leaf, but an
+	// expiring intermediate breaks the chain just the same.
+	Expiry        time.Time
+	ExpirySubject string
+	DaysLeft      int
+
+	Status    Status
+	Err       error // Connection, STARTTLS or handshake failure
+	SaveError error // Why the chain could not be saved to Options.SaveChain
+}
+
+// Run checks all endpoints with at most opts.Concurrency connections at a
+// time and returns the results sorted by expiry (see SortByExpiry).
+func Run(ctx context.Context, endpoints []Endpoint, opts Options) []*Result {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+
+	results := make([]*Result, len(endpoints))
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				results[i] = Check(ctx, ep, opts)
+			case <-ctx.Done():
+				results[i] = &Result{Endpoint: ep, Status: StatusUnknown, Err: ctx.Err()}
+			}
+		}()
+	}
+	wg.Wait()
+
+	SortByExpiry(results)
+	return results
+}
+
+// SortByExpiry orders results by the earliest expiring certificate, with
+// failed checks last.
+func SortByExpiry(results []*Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err == nil
+		}
+		if !a.Expiry.Equal(b.Expiry) {
+			return a.Expiry.Before(b.Expiry)
+		}
+		return a.Endpoint.String() < b.Endpoint.String()
+	})
+}
+
+// Check connects to one endpoint, analyzes the presented chain and grades
+// its expiry. With opts.SaveChain, the chain is also saved to files.
+func Check(ctx context.Context, ep Endpoint, opts Options) *Result {
+	result := &Result{Endpoint: ep}
+
+	var capture *commontls.ChainCapture
+	if opts.SaveChain != "" {
+		capture = &commontls.ChainCapture{}
+		defer func() {
+			if capture.State != nil {
+				name := fmt.Sprintf("%s_%s_%d", ep.Protocol, ep.Host, ep.Port)
+				_, result.SaveError = capture.Save(opts.SaveChain, name, result.Certificate)
+			}
+		}()
+	}
+
+	state, err := handshake(ctx, ep, opts, capture)
+	if err != nil {
+		result.Err = err
+		result.Status = StatusUnknown
+		return result
+	}
+
+	result.Certificate = commontls.AnalyzeCertificateChain(state, ep.Host)
+	result.Verification = result.Certificate.VerificationStatus
+	var roots *x509.CertPool
+	if opts.TLSConfig != nil {
+		roots = opts.TLSConfig.RootCAs
+	}
+	if result.Verification == "valid" {
+		if err := commontls.VerifyCertificateChain(state.PeerCertificates, ep.Host, roots); err != nil {
+			var unknownAuthority x509.UnknownAuthorityError
+			if errors.As(err, &unknownAuthority) {
+				result.Verification = "untrusted"
+			} else {
+				result.Verification = "invalid"
+			}
+		}
+	}
+
+	for _, cert := range state.PeerCertificates {
+		if result.Expiry.IsZero() || cert.NotAfter.Before(result.Expiry) {
+			result.Expiry = cert.NotAfter
+			result.ExpirySubject = cert.Subject.String()
+		}
+	}
+	result.DaysLeft = int(time.Until(result.Expiry).Hours() / 24)
+	if time.Now().After(result.Expiry) && result.DaysLeft == 0 {
+		result.DaysLeft = -1 // Expired less than a day ago
+	}
+	result.Status = Grade(result.DaysLeft, opts.WarningDays, opts.CriticalDays)
+	return result
+}
+
+// Grade maps the days until expiry to a state. Expired certificates are
+// always CRITICAL.
+func Grade(daysLeft, warningDays, criticalDays int) Status {
+	switch {
+	case daysLeft < 0 || daysLeft <= criticalDays:
+		return StatusCritical
+	case daysLeft <= warningDays:
+		return StatusWarning
+	}
+	return StatusOK
+}
+
+// handshake connects to the endpoint, negotiates STARTTLS if required and
+// completes a TLS handshake without verifying the chain, so that invalid
+// and expired certificates can still be reported.
+func handshake(ctx context.Context, ep Endpoint, opts Options, capture *commontls.ChainCapture) (*tls.ConnectionState, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer proxy.Dialer = &net.Dialer{Resolver: opts.Resolver}
+	if opts.Dialer != nil {
+		dialer = opts.Dialer
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if ep.TLSMode == TLSModeSTARTTLS {
+		if err := startTLS(conn, ep.Protocol); err != nil {
+			return nil, err
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if opts.TLSConfig != nil {
+		tlsConfig = opts.TLSConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig.ServerName = ep.Host
+	tlsConfig.InsecureSkipVerify = true // Verified by Check against the configured roots
+	if capture != nil {
+		capture.Apply(tlsConfig)
+	}
+	if ep.Protocol == ProtocolJMAP {
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	state := tlsConn.ConnectionState()
+	tlsConn.Close()
+	return &state, nil
+}
+
+// startTLS reads the greeting and requests the TLS upgrade in the
+// endpoint's protocol.
+func startTLS(conn net.Conn, protocol string) error {
+	reader := bufio.NewReader(conn)
+	switch protocol {
+	case ProtocolSMTP:
+		resp, err := smtpprotocol.ReadResponse(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read greeting: %w", err)
+		}
+		if resp.Code != 220 {
+			return fmt.Errorf("server rejected connection: %s", resp.String())
+		}
+		for _, cmd := range []string{smtpprotocol.EHLO("certwatch.local"), smtpprotocol.STARTTLS()} {
+			if _, err := conn.Write([]byte(cmd)); err != nil {
+				return fmt.Errorf("failed to send %s: %w", strings.Fields(cmd)[0], err)
+			}
+			resp, err := smtpprotocol.ReadResponse(reader)
+			if err != nil {
+				return fmt.Errorf("failed to read %s response: %w", strings.Fields(cmd)[0], err)
+			}
+			if !resp.IsSuccess() {
+				return fmt.Errorf("%s failed: %s", strings.Fields(cmd)[0], resp.String())
+			}
+		}
+
+	case ProtocolIMAP:
+		greeting, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read greeting: %w", err)
+		}
+		if !strings.HasPrefix(greeting, "* OK") {
+			return fmt.Errorf("server rejected connection: %s", strings.TrimSpace(greeting))
+		}
+		if _, err := conn.Write([]byte("a1 STARTTLS\r\n")); err != nil {
+			return fmt.Errorf("failed to send STARTTLS: %w", err)
+		}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("failed to read STARTTLS response: %w", err)
+			}
+			if strings.HasPrefix(line, "a1 ") {
+				if !strings.HasPrefix(line, "a1 OK") {
+					return fmt.Errorf("STARTTLS failed: %s", strings.TrimSpace(line))
+				}
+				break
+			}
+		}
+
+	case ProtocolPOP3:
+		resp, err := pop3protocol.ReadResponse(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read greeting: %w", err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("server rejected connection: %s", resp.Message)
+		}
+		if _, err := conn.Write([]byte(pop3protocol.STLS())); err != nil {
+			return fmt.Errorf("failed to send STLS: %w", err)
+		}
+		if resp, err = pop3protocol.ReadResponse(reader); err != nil {
+			return fmt.Errorf("failed to read STLS response: %w", err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("STLS failed: %s", resp.Message)
+		}
+
+	default:
+		return fmt.Errorf("STARTTLS is not supported for %s", protocol)
+	}
+	return nil
+}
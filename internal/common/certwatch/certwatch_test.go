@@ -0,0 +1,249 @@
+package certwatch
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a self-signed certificate for 127.0.0.1 that
+// expires after validFor.
+func testCertificate(t *testing.T, validFor time.Duration) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mail.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startServer serves TLS on a local port, running dialog before the
+// handshake for STARTTLS endpoints, and returns the endpoint.
+func startServer(t *testing.T, protocol, mode string, cert tls.Certificate, dialog func(*bufio.ReadWriter) bool) Endpoint {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if dialog != nil {
+					rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+					if !dialog(rw) {
+						return
+					}
+				}
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+				tlsConn.Handshake()
+				tlsConn.Close()
+			}()
+		}
+	}()
+
+	return Endpoint{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, Protocol: protocol, TLSMode: mode}
+}
+
+// exchange writes a line and reports whether the next request starts with expect.
+func exchange(rw *bufio.ReadWriter, line, expect string) bool {
+	rw.WriteString(line)
+	rw.Flush()
+	req, err := rw.ReadString('\n')
+	return err == nil && strings.HasPrefix(strings.ToUpper(req), expect)
+}
+
+func smtpDialog(rw *bufio.ReadWriter) bool {
+	if !exchange(rw, "220 mail.example.com ESMTP\r\n", "EHLO") {
+		return false
+	}
+	if !exchange(rw, "250-mail.example.com\r\n250 STARTTLS\r\n", "STARTTLS") {
+		return false
+	}
+	rw.WriteString("220 Ready to start TLS\r\n")
+	return rw.Flush() == nil
+}
+
+func imapDialog(rw *bufio.ReadWriter) bool {
+	if !exchange(rw, "* OK IMAP4rev1 ready\r\n", "A1 STARTTLS") {
+		return false
+	}
+	rw.WriteString("* CAPABILITY IMAP4rev1\r\na1 OK Begin TLS negotiation now\r\n")
+	return rw.Flush() == nil
+}
+
+func pop3Dialog(rw *bufio.ReadWriter) bool {
+	if !exchange(rw, "+OK POP3 ready\r\n", "STLS") {
+		return false
+	}
+	rw.WriteString("+OK Begin TLS negotiation\r\n")
+	return rw.Flush() == nil
+}
+
+func TestCheck_Protocols(t *testing.T) {
+	cert := testCertificate(t, 60*24*time.Hour)
+	roots := x509.NewCertPool()
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	roots.AddCert(leaf)
+
+	endpoints := map[string]Endpoint{
+		"implicit":      startServer(t, ProtocolJMAP, TLSModeImplicit, cert, nil),
+		"smtp starttls": startServer(t, ProtocolSMTP, TLSModeSTARTTLS, cert, smtpDialog),
+		"imap starttls": startServer(t, ProtocolIMAP, TLSModeSTARTTLS, cert, imapDialog),
+		"pop3 starttls": startServer(t, ProtocolPOP3, TLSModeSTARTTLS, cert, pop3Dialog),
+	}
+	opts := Options{WarningDays: 30, CriticalDays: 7, Timeout: 5 * time.Second, TLSConfig: &tls.Config{RootCAs: roots}}
+	for name, ep := range endpoints {
+		t.Run(name, func(t *testing.T) {
+			result := Check(context.Background(), ep, opts)
+			if result.Err != nil {
+				t.Fatalf("Check() error: %v", result.Err)
+			}
+			if result.Status != StatusOK {
+				t.Errorf("Status = %s, want OK", result.Status)
+			}
+			if result.DaysLeft < 59 || result.DaysLeft > 60 {
+				t.Errorf("DaysLeft = %d, want ~60", result.DaysLeft)
+			}
+			if result.Certificate == nil || !strings.Contains(result.Certificate.Subject, "mail.example.com") {
+				t.Errorf("Certificate = %+v", result.Certificate)
+			}
+		})
+	}
+}
+
+func TestCheck_Failures(t *testing.T) {
+	cert := testCertificate(t, 60*24*time.Hour)
+	rejecting := startServer(t, ProtocolSMTP, TLSModeSTARTTLS, cert, func(rw *bufio.ReadWriter) bool {
+		exchange(rw, "220 mail.example.com ESMTP\r\n", "EHLO")
+		exchange(rw, "250 mail.example.com\r\n", "STARTTLS")
+		rw.WriteString("454 TLS not available\r\n")
+		rw.Flush()
+		return false
+	})
+
+	opts := Options{Timeout: 5 * time.Second}
+	result := Check(context.Background(), rejecting, opts)
+	if result.Status != StatusUnknown || result.Err == nil || !strings.Contains(result.Err.Error(), "454") {
+		t.Errorf("rejected STARTTLS: Status = %s, Err = %v", result.Status, result.Err)
+	}
+
+	// Self-signed certificate without a configured root
+	implicit := startServer(t, ProtocolSMTP, TLSModeImplicit, cert, nil)
+	result = Check(context.Background(), implicit, opts)
+	if result.Err != nil {
+		t.Fatalf("Check() error: %v", result.Err)
+	}
+	if result.Verification != "self_signed" {
+		t.Errorf("Verification = %q, want self_signed", result.Verification)
+	}
+}
+
+func TestCheck_SaveChain(t *testing.T) {
+	ep := startServer(t, ProtocolSMTP, TLSModeSTARTTLS, testCertificate(t, 60*24*time.Hour), smtpDialog)
+	dir := t.TempDir()
+
+	result := Check(context.Background(), ep, Options{Timeout: 5 * time.Second, SaveChain: dir})
+	if result.Err != nil || result.SaveError != nil {
+		t.Fatalf("Check() Err = %v, SaveError = %v", result.Err, result.SaveError)
+	}
+	name := fmt.Sprintf("smtp_127.0.0.1_%d", ep.Port)
+	for _, suffix := range []string{"-peer-0.pem", "-peer-0.der", "-summary.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name+suffix)); err != nil {
+			t.Errorf("missing %s: %v", name+suffix, err)
+		}
+	}
+}
+
+func TestGrade(t *testing.T) {
+	tests := []struct {
+		daysLeft int
+		want     Status
+	}{
+		{90, StatusOK},
+		{31, StatusOK},
+		{30, StatusWarning},
+		{8, StatusWarning},
+		{7, StatusCritical},
+		{0, StatusCritical},
+		{-5, StatusCritical},
+	}
+	for _, tt := range tests {
+		if got := Grade(tt.daysLeft, 30, 7); got != tt.want {
+			t.Errorf("Grade(%d) = %s, want %s", tt.daysLeft, got, tt.want)
+		}
+	}
+
+	if got := Worst(StatusUnknown, StatusWarning); got != StatusWarning {
+		t.Errorf("Worst(UNKNOWN, WARNING) = %s", got)
+	}
+	if got := Worst(StatusOK, StatusUnknown); got != StatusUnknown {
+		t.Errorf("Worst(OK, UNKNOWN) = %s", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	expiring := startServer(t, ProtocolSMTP, TLSModeImplicit, testCertificate(t, 5*24*time.Hour), nil)
+	later := startServer(t, ProtocolSMTP, TLSModeImplicit, testCertificate(t, 20*24*time.Hour), nil)
+	expired := startServer(t, ProtocolSMTP, TLSModeImplicit, testCertificate(t, -24*time.Hour), nil)
+
+	// A closed port
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := Endpoint{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, Protocol: ProtocolSMTP, TLSMode: TLSModeImplicit}
+	ln.Close()
+
+	results := Run(context.Background(), []Endpoint{closed, later, expired, expiring}, Options{
+		WarningDays: 30, CriticalDays: 7, Concurrency: 2, Timeout: 5 * time.Second,
+	})
+
+	want := []struct {
+		ep     Endpoint
+		status Status
+	}{
+		{expired, StatusCritical},
+		{expiring, StatusCritical},
+		{later, StatusWarning},
+		{closed, StatusUnknown},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		if results[i].Endpoint != w.ep || results[i].Status != w.status {
+			t.Errorf("result %d = %s %s (%v), want %s %s", i, results[i].Endpoint, results[i].Status, results[i].Err, w.ep, w.status)
+		}
+	}
+	if results[0].DaysLeft >= 0 {
+		t.Errorf("expired DaysLeft = %d, want negative", results[0].DaysLeft)
+	}
+}
@@ -0,0 +1,132 @@
+// Package certwatch checks the certificates of many mail endpoints
+// concurrently and grades their expiry against warning and critical
+// thresholds, for monitoring with Nagios-compatible exit codes.
+package certwatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"msgraphtool/internal/common/validation"
+)
+
+// Protocols an endpoint can speak.
+const (
+	ProtocolSMTP = "smtp"
+	ProtocolIMAP = "imap"
+	ProtocolPOP3 = "pop3"
+	ProtocolJMAP = "jmap"
+)
+
+// TLS modes.
+const (
+	TLSModeImplicit = "implicit" // TLS from the first byte (SMTPS, IMAPS, POP3S, HTTPS)
+	TLSModeSTARTTLS = "starttls" // Plain connection upgraded with STARTTLS/STLS
+)
+
+// Endpoint is one entry of the inventory.
+type Endpoint struct {
+	Host     string
+	Port     int
+	Protocol string // smtp, imap, pop3 or jmap
+	TLSMode  string // implicit or starttls
+}
+
+// String returns the endpoint as protocol://host:port.
+func (e Endpoint) String() string {
+	return fmt.Sprintf("%s://%s:%d", e.Protocol, e.Host, e.Port)
+}
+
+// implicitTLSPorts are the well-known ports that use TLS from the start.
+var implicitTLSPorts = map[int]bool{443: true, 465: true, 993: true, 995: true}
+
+// LoadInventory reads an inventory file (see ParseInventory).
+func LoadInventory(path string) ([]Endpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory: %w", err)
+	}
+	defer f.Close()
+	return ParseInventory(f)
+}
+
+// ParseInventory parses one endpoint per line as "host port protocol
+// [tlsmode]", separated by whitespace or commas. Blank lines and lines
+// starting with # are ignored. Without a TLS mode, ports 443, 465, 993 and
+// 995 and all JMAP endpoints use implicit TLS; other ports use STARTTLS.
+func ParseInventory(r io.Reader) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, fmt.Errorf("inventory line %d: expected \"host port protocol [tlsmode]\"", lineNo)
+		}
+
+		ep, err := parseEndpoint(fields)
+		if err != nil {
+			return nil, fmt.Errorf("inventory line %d: %w", lineNo, err)
+		}
+		endpoints = append(endpoints, ep)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("inventory contains no endpoints")
+	}
+	return endpoints, nil
+}
+
+func parseEndpoint(fields []string) (Endpoint, error) {
+	ep := Endpoint{Host: fields[0], Protocol: strings.ToLower(fields[2])}
+	if err := validation.ValidateHostname(ep.Host); err != nil {
+		return ep, err
+	}
+
+	port, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ep, fmt.Errorf("invalid port %q", fields[1])
+	}
+	if err := validation.ValidatePort(port); err != nil {
+		return ep, err
+	}
+	ep.Port = port
+
+	switch ep.Protocol {
+	case ProtocolSMTP, ProtocolIMAP, ProtocolPOP3, ProtocolJMAP:
+	default:
+		return ep, fmt.Errorf("unknown protocol %q (valid: smtp, imap, pop3, jmap)", fields[2])
+	}
+
+	if len(fields) == 4 {
+		ep.TLSMode = strings.ToLower(fields[3])
+	} else if implicitTLSPorts[port] || ep.Protocol == ProtocolJMAP {
+		ep.TLSMode = TLSModeImplicit
+	} else {
+		ep.TLSMode = TLSModeSTARTTLS
+	}
+	switch ep.TLSMode {
+	case TLSModeImplicit:
+	case TLSModeSTARTTLS:
+		if ep.Protocol == ProtocolJMAP {
+			return ep, fmt.Errorf("jmap endpoints use implicit TLS (HTTPS)")
+		}
+	default:
+		return ep, fmt.Errorf("unknown TLS mode %q (valid: implicit, starttls)", fields[3])
+	}
+	return ep, nil
+}
@@ -0,0 +1,67 @@
+package certwatch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseInventory(t *testing.T) {
+	input := `# Mail endpoints
+mail.example.com 25 smtp
+mail.example.com,465,SMTP
+imap.example.com 993 imap
+imap.example.com	143	imap	starttls
+pop.example.com 995 pop3 implicit
+
+jmap.example.com 443 jmap
+`
+	endpoints, err := ParseInventory(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseInventory() error: %v", err)
+	}
+
+	want := []Endpoint{
+		{"mail.example.com", 25, ProtocolSMTP, TLSModeSTARTTLS},
+		{"mail.example.com", 465, ProtocolSMTP, TLSModeImplicit},
+		{"imap.example.com", 993, ProtocolIMAP, TLSModeImplicit},
+		{"imap.example.com", 143, ProtocolIMAP, TLSModeSTARTTLS},
+		{"pop.example.com", 995, ProtocolPOP3, TLSModeImplicit},
+		{"jmap.example.com", 443, ProtocolJMAP, TLSModeImplicit},
+	}
+	if len(endpoints) != len(want) {
+		t.Fatalf("got %d endpoints, want %d", len(endpoints), len(want))
+	}
+	for i := range want {
+		if endpoints[i] != want[i] {
+			t.Errorf("endpoint %d = %+v, want %+v", i, endpoints[i], want[i])
+		}
+	}
+	if got := endpoints[0].String(); got != "smtp://mail.example.com:25" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseInventory_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"empty", "# nothing\n\n", "no endpoints"},
+		{"too few fields", "mail.example.com 25", "line 1"},
+		{"bad port", "mail.example.com smtp smtp", "invalid port"},
+		{"port out of range", "mail.example.com 70000 smtp", "line 1"},
+		{"unknown protocol", "mail.example.com 25 lmtp", "unknown protocol"},
+		{"unknown TLS mode", "mail.example.com 25 smtp dane", "unknown TLS mode"},
+		{"jmap starttls", "jmap.example.com 8080 jmap starttls", "implicit TLS"},
+		{"line number", "mail.example.com 25 smtp\nbad host!! 25 smtp", "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseInventory(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseInventory() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
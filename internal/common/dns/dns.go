@@ -0,0 +1,104 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// DefaultPort is the standard DNS port appended to resolver addresses without one.
+const DefaultPort = "53"
+
+// MXHost is a mail exchanger for a domain, in preference order.
+type MXHost struct {
+	Host     string // Host name without the trailing dot
+	Pref     uint16 // MX preference (lower is preferred)
+	Implicit bool   // True when the domain has no MX and its address records are used (RFC 5321 section 5.1)
+}
+
+// ErrNullMX is returned when a domain publishes a null MX (RFC 7505),
+// declaring that it does not accept email.
+var ErrNullMX = errors.New("domain publishes a null MX record and does not accept email")
+
+// NormalizeServer validates a resolver address and appends the default port
+// when none is given. Accepts "host", "host:port", "[ipv6]:port" and bare IPv6.
+// An empty address is returned unchanged (system resolver).
+func NormalizeServer(server string) (string, error) {
+	server = strings.TrimSpace(server)
+	if server == "" {
+		return "", nil
+	}
+
+	if ip := net.ParseIP(server); ip != nil {
+		return net.JoinHostPort(server, DefaultPort), nil
+	}
+
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		// No port given
+		host, port = server, DefaultPort
+	}
+	if host == "" {
+		return "", fmt.Errorf("invalid DNS server address: %s", server)
+	}
+	if _, err := net.LookupPort("udp", port); err != nil {
+		return "", fmt.Errorf("invalid DNS server port: %s", port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// NewResolver returns a resolver that sends every query to server (host:port).
+// When server is empty, the system resolver is returned.
+func NewResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// LookupMX resolves the mail exchangers of domain in preference order.
+// Hosts with equal preference keep the resolver's (randomized) order.
+// A domain without MX records falls back to an implicit MX pointing at the
+// domain itself; a null MX returns ErrNullMX.
+func LookupMX(ctx context.Context, resolver *net.Resolver, domain string) ([]MXHost, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	records, err := resolver.LookupMX(ctx, domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound && len(records) == 0 {
+			// No MX records: the domain's own address records act as an implicit MX
+			if _, addrErr := resolver.LookupHost(ctx, domain); addrErr != nil {
+				return nil, fmt.Errorf("no MX or address records for %s: %w", domain, err)
+			}
+			return []MXHost{{Host: domain, Implicit: true}}, nil
+		}
+		return nil, fmt.Errorf("MX lookup for %s failed: %w", domain, err)
+	}
+
+	var hosts []MXHost
+	for _, mx := range records {
+		host := strings.TrimSuffix(mx.Host, ".")
+		if host == "" {
+			if len(records) == 1 {
+				return nil, ErrNullMX
+			}
+			continue
+		}
+		hosts = append(hosts, MXHost{Host: host, Pref: mx.Pref})
+	}
+	if len(hosts) == 0 {
+		return nil, ErrNullMX
+	}
+
+	sort.SliceStable(hosts, func(i, j int) bool { return hosts[i].Pref < hosts[j].Pref })
+	return hosts, nil
+}
@@ -0,0 +1,215 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubServer is a UDP DNS server answering from a fixed record table.
+type stubServer struct {
+	conn          net.PacketConn
+	records       map[string][]dnsmessage.Resource // key: lower-case FQDN + "/" + type
+	authenticated bool                             // Set the AD bit in responses
+}
+
+// newStubServer starts a stub DNS server on the loopback interface.
+func newStubServer(t *testing.T) *stubServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start stub DNS server: %v", err)
+	}
+	s := &stubServer{conn: conn, records: make(map[string][]dnsmessage.Resource)}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *stubServer) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *stubServer) add(name string, rrType dnsmessage.Type, body dnsmessage.ResourceBody) {
+	fqdn := strings.ToLower(strings.TrimSuffix(name, ".") + ".")
+	key := fqdn + "/" + rrType.String()
+	s.records[key] = append(s.records[key], dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName(fqdn),
+			Type:  rrType,
+			Class: dnsmessage.ClassINET,
+			TTL:   60,
+		},
+		Body: body,
+	})
+}
+
+func (s *stubServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+			continue
+		}
+		q := query.Questions[0]
+		answers := s.records[strings.ToLower(q.Name.String())+"/"+q.Type.String()]
+
+		resp := dnsmessage.Message{
+			Header: dnsmessage.Header{
+				ID:                 query.ID,
+				Response:           true,
+				Authoritative:      true,
+				RecursionAvailable: true,
+				AuthenticData:      s.authenticated,
+			},
+			Questions: query.Questions,
+			Answers:   answers,
+		}
+		if len(answers) == 0 && !s.hasName(q.Name.String()) {
+			resp.RCode = dnsmessage.RCodeNameError
+		}
+		packed, err := resp.Pack()
+		if err != nil {
+			continue
+		}
+		_, _ = s.conn.WriteTo(packed, addr)
+	}
+}
+
+// hasName reports whether any record exists for name (NODATA vs NXDOMAIN).
+func (s *stubServer) hasName(name string) bool {
+	prefix := strings.ToLower(name) + "/"
+	for key := range s.records {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestNormalizeServer tests resolver address normalization
+func TestNormalizeServer(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"127.0.0.1", "127.0.0.1:53", false},
+		{"127.0.0.1:5353", "127.0.0.1:5353", false},
+		{"::1", "[::1]:53", false},
+		{"[::1]:5353", "[::1]:5353", false},
+		{"dns.example.com", "dns.example.com:53", false},
+		{"127.0.0.1:notaport", "", true},
+		{":53", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeServer(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeServer(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeServer(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLookupMX tests MX resolution through a configured resolver address
+func TestLookupMX(t *testing.T) {
+	stub := newStubServer(t)
+	stub.add("example.test", dnsmessage.TypeMX, &dnsmessage.MXResource{Pref: 20, MX: dnsmessage.MustNewName("backup.example.test.")})
+	stub.add("example.test", dnsmessage.TypeMX, &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mx1.example.test.")})
+	stub.add("nomx.example.test", dnsmessage.TypeA, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}})
+	stub.add("nullmx.example.test", dnsmessage.TypeMX, &dnsmessage.MXResource{Pref: 0, MX: dnsmessage.MustNewName(".")})
+
+	resolver := NewResolver(stub.addr())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("Preference order", func(t *testing.T) {
+		hosts, err := LookupMX(ctx, resolver, "example.test")
+		if err != nil {
+			t.Fatalf("LookupMX() error = %v", err)
+		}
+		if len(hosts) != 2 || hosts[0].Host != "mx1.example.test" || hosts[1].Host != "backup.example.test" {
+			t.Errorf("LookupMX() = %+v, want mx1 then backup", hosts)
+		}
+	})
+
+	t.Run("Implicit MX", func(t *testing.T) {
+		hosts, err := LookupMX(ctx, resolver, "nomx.example.test")
+		if err != nil {
+			t.Fatalf("LookupMX() error = %v", err)
+		}
+		if len(hosts) != 1 || !hosts[0].Implicit || hosts[0].Host != "nomx.example.test" {
+			t.Errorf("LookupMX() = %+v, want implicit MX", hosts)
+		}
+	})
+
+	t.Run("Null MX", func(t *testing.T) {
+		_, err := LookupMX(ctx, resolver, "nullmx.example.test")
+		if !errors.Is(err, ErrNullMX) {
+			t.Errorf("LookupMX() error = %v, want ErrNullMX", err)
+		}
+	})
+
+	t.Run("Nonexistent domain", func(t *testing.T) {
+		if _, err := LookupMX(ctx, resolver, "missing.example.test"); err == nil {
+			t.Error("LookupMX() expected error for nonexistent domain")
+		}
+	})
+}
+
+// TestLookupTLSA tests TLSA queries and the DNSSEC authentication flag
+func TestLookupTLSA(t *testing.T) {
+	stub := newStubServer(t)
+	stub.authenticated = true
+	stub.add("_25._tcp.mx.example.test", TypeTLSA, &dnsmessage.UnknownResource{Type: TypeTLSA, Data: []byte{3, 1, 1, 0xab, 0xcd}})
+	stub.add("_25._tcp.mx.example.test", TypeTLSA, &dnsmessage.UnknownResource{Type: TypeTLSA, Data: []byte{2, 0, 1, 0xef}})
+	stub.add("_25._tcp.bad.example.test", TypeTLSA, &dnsmessage.UnknownResource{Type: TypeTLSA, Data: []byte{3}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if got := TLSAName("mx.example.test.", 25); got != "_25._tcp.mx.example.test" {
+		t.Errorf("TLSAName() = %q", got)
+	}
+
+	t.Run("Records", func(t *testing.T) {
+		records, authenticated, err := LookupTLSA(ctx, stub.addr(), TLSAName("mx.example.test", 25))
+		if err != nil {
+			t.Fatalf("LookupTLSA() error = %v", err)
+		}
+		if !authenticated {
+			t.Error("LookupTLSA() authenticated = false, want true")
+		}
+		if len(records) != 2 || records[0].String() != "3 1 1 abcd" || records[1].String() != "2 0 1 ef" {
+			t.Errorf("LookupTLSA() = %v", records)
+		}
+	})
+
+	t.Run("No records", func(t *testing.T) {
+		records, _, err := LookupTLSA(ctx, stub.addr(), "_25._tcp.none.example.test")
+		if err != nil || len(records) != 0 {
+			t.Errorf("LookupTLSA() = %v, %v; want no records and no error", records, err)
+		}
+	})
+
+	t.Run("Malformed record", func(t *testing.T) {
+		if _, _, err := LookupTLSA(ctx, stub.addr(), "_25._tcp.bad.example.test"); err == nil {
+			t.Error("LookupTLSA() expected error for malformed record")
+		}
+	})
+}
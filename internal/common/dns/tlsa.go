@@ -0,0 +1,196 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// TypeTLSA is the TLSA resource record type (RFC 6698), which dnsmessage does not define.
+const TypeTLSA dnsmessage.Type = 52
+
+// maxUDPPayload is the EDNS0 UDP payload size advertised in queries (DNS Flag Day 2020).
+const maxUDPPayload = 1232
+
+// resolvConfPath is the system resolver configuration used when no server is given.
+var resolvConfPath = "/etc/resolv.conf"
+
+// TLSARecord is a DANE certificate association (RFC 6698 section 2.1).
+type TLSARecord struct {
+	Usage        uint8  // Certificate usage: 0 PKIX-TA, 1 PKIX-EE, 2 DANE-TA, 3 DANE-EE
+	Selector     uint8  // 0 full certificate, 1 SubjectPublicKeyInfo
+	MatchingType uint8  // 0 exact match, 1 SHA-256, 2 SHA-512
+	Data         []byte // Certificate association data
+}
+
+// String returns the record in presentation format, e.g. "3 1 1 0a1b...".
+func (r TLSARecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Usage, r.Selector, r.MatchingType, hex.EncodeToString(r.Data))
+}
+
+// TLSAName returns the owner name of the TLSA records for a TCP service,
+// e.g. _25._tcp.mx.example.com (RFC 6698 section 3).
+func TLSAName(host string, port int) string {
+	return fmt.Sprintf("_%d._tcp.%s", port, strings.TrimSuffix(host, "."))
+}
+
+// SystemServer returns the first nameserver from /etc/resolv.conf with the
+// default port. The Go resolver does not expose TLSA records or the DNSSEC
+// AD bit, so raw queries need an explicit server address.
+func SystemServer() (string, error) {
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		return "", fmt.Errorf("no system DNS server found (%v); specify one with -dnsserver", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			// Strip IPv6 zone identifiers, which JoinHostPort would keep
+			host, _, _ := strings.Cut(fields[1], "%")
+			return net.JoinHostPort(host, DefaultPort), nil
+		}
+	}
+	return "", fmt.Errorf("no nameserver in %s; specify one with -dnsserver", resolvConfPath)
+}
+
+// LookupTLSA queries server (host:port, or the system server when empty) for
+// the TLSA records at name. authenticated reports whether the resolver set
+// the AD bit, i.e. validated the answer with DNSSEC. DANE only applies to
+// authenticated records (RFC 7672 section 2.2). A name without TLSA records
+// returns no records and no error.
+func LookupTLSA(ctx context.Context, server, name string) (records []TLSARecord, authenticated bool, err error) {
+	if server == "" {
+		if server, err = SystemServer(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid TLSA name %q: %w", name, err)
+	}
+	query, err := buildQuery(qname, TypeTLSA)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := exchange(ctx, "udp", server, query)
+	if err == nil && resp.Truncated {
+		resp, err = exchange(ctx, "tcp", server, query)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("TLSA lookup for %s failed: %w", name, err)
+	}
+
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, resp.AuthenticData, nil
+	default:
+		// A validating resolver answers SERVFAIL for bogus DNSSEC data
+		return nil, false, fmt.Errorf("TLSA lookup for %s failed: %s", name, resp.RCode)
+	}
+
+	for _, rr := range resp.Answers {
+		if rr.Header.Type != TypeTLSA {
+			continue // CNAMEs followed by the resolver
+		}
+		body, ok := rr.Body.(*dnsmessage.UnknownResource)
+		if !ok || len(body.Data) < 3 {
+			return nil, false, fmt.Errorf("malformed TLSA record at %s", rr.Header.Name)
+		}
+		records = append(records, TLSARecord{
+			Usage:        body.Data[0],
+			Selector:     body.Data[1],
+			MatchingType: body.Data[2],
+			Data:         append([]byte(nil), body.Data[3:]...),
+		})
+	}
+	return records, resp.AuthenticData, nil
+}
+
+// buildQuery packs a recursive query that asks for DNSSEC validation:
+// AD=1 requests the authentication status (RFC 6840 section 5.7) and the
+// EDNS0 DO bit asks the resolver to validate.
+func buildQuery(name dnsmessage.Name, qtype dnsmessage.Type) ([]byte, error) {
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(maxUDPPayload, dnsmessage.RCodeSuccess, true); err != nil {
+		return nil, err
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               uint16(rand.N(1 << 16)),
+			RecursionDesired: true,
+			AuthenticData:    true,
+		},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+		Additionals: []dnsmessage.Resource{
+			{Header: opt, Body: &dnsmessage.OPTResource{}},
+		},
+	}
+	return msg.Pack()
+}
+
+// exchange sends a packed query over network ("udp" or "tcp") and returns the
+// matching response.
+func exchange(ctx context.Context, network, server string, query []byte) (*dnsmessage.Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	var buf []byte
+	if network == "tcp" {
+		// TCP messages carry a two-byte length prefix (RFC 1035 section 4.2.2)
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+		if _, err := conn.Write(append(framed, query...)); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, maxUDPPayload)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[:n]
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, fmt.Errorf("invalid DNS response: %w", err)
+	}
+	if resp.ID != binary.BigEndian.Uint16(query) || !resp.Response {
+		return nil, errors.New("DNS response does not match query")
+	}
+	return &resp, nil
+}
@@ -0,0 +1,223 @@
+// Package dualstack controls the IP version and source address of the
+// protocol tools' connections (-ipversion and -sourceip), and runs a test
+// against every address of a host so that the IPv4 and IPv6 paths of a
+// server are tested separately.
+//
+// In "both" mode the address under test travels in the context: WithAddress
+// pins a host name to one address, and dialers wrapped with Wrap connect to
+// it instead of resolving the name. TLS server names and protocol greetings
+// still use the host name.
+package dualstack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+// IP versions accepted by -ipversion.
+const (
+	Any  = ""     // Whatever the resolver and dialer pick (Happy Eyeballs)
+	IPv4 = "4"    // IPv4 addresses only
+	IPv6 = "6"    // IPv6 addresses only
+	Both = "both" // Every address of the host, IPv4 and IPv6, one run each
+)
+
+// Validate checks the -ipversion and -sourceip values. A source address must
+// match the IP version, and cannot be combined with "both", which needs one
+// source address per IP version.
+func Validate(version, sourceIP string) error {
+	switch version {
+	case Any, IPv4, IPv6, Both:
+	default:
+		return fmt.Errorf("invalid -ipversion %q (use 4, 6 or both)", version)
+	}
+	if sourceIP == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(sourceIP)
+	if ip == nil {
+		return fmt.Errorf("invalid -sourceip %q: not an IP address", sourceIP)
+	}
+	switch {
+	case version == Both:
+		return fmt.Errorf("-sourceip cannot be combined with -ipversion both (a source address has one IP version)")
+	case version == IPv4 && ip.To4() == nil:
+		return fmt.Errorf("-sourceip %s is not an IPv4 address (-ipversion 4)", sourceIP)
+	case version == IPv6 && ip.To4() != nil:
+		return fmt.Errorf("-sourceip %s is not an IPv6 address (-ipversion 6)", sourceIP)
+	}
+	return nil
+}
+
+// LocalAddr returns the local address for a net.Dialer bound to sourceIP,
+// or nil when sourceIP is empty.
+func LocalAddr(sourceIP string) net.Addr {
+	ip := net.ParseIP(sourceIP)
+	if ip == nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: ip}
+}
+
+// Network returns network restricted to version: "tcp" becomes "tcp4" or
+// "tcp6". Other networks and versions are returned unchanged.
+func Network(network, version string) string {
+	if network != "tcp" {
+		return network
+	}
+	switch version {
+	case IPv4:
+		return "tcp4"
+	case IPv6:
+		return "tcp6"
+	}
+	return network
+}
+
+// Dialer is the dialer interface of net.Dialer and proxy.Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+type versionDialer struct {
+	forward Dialer
+	version string
+}
+
+// Wrap returns a Dialer that dials through forward with the network
+// restricted to version, and connects to the address pinned with WithAddress
+// when the context carries one for the host being dialed.
+func Wrap(forward Dialer, version string) Dialer {
+	return &versionDialer{forward: forward, version: version}
+}
+
+func (d *versionDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.forward.DialContext(ctx, Network(network, d.version), Pinned(ctx, address))
+}
+
+type pin struct {
+	host, ip string
+}
+
+type contextKey struct{}
+
+// WithAddress returns a copy of ctx in which host is pinned to ip.
+func WithAddress(ctx context.Context, host, ip string) context.Context {
+	return context.WithValue(ctx, contextKey{}, pin{host: host, ip: ip})
+}
+
+// Pinned returns address (host:port) with the host replaced by the address
+// pinned to it in ctx, or address unchanged. Other hosts, such as a JMAP API
+// host that differs from the discovery host, are not affected.
+func Pinned(ctx context.Context, address string) string {
+	p, ok := ctx.Value(contextKey{}).(pin)
+	if !ok {
+		return address
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || !strings.EqualFold(strings.TrimSuffix(host, "."), strings.TrimSuffix(p.host, ".")) {
+		return address
+	}
+	return net.JoinHostPort(p.ip, port)
+}
+
+// Lookup resolves host to its addresses of version (all of them for Any and
+// Both), IPv4 addresses first. An IP address is returned as is.
+func Lookup(ctx context.Context, resolver *net.Resolver, host, version string) ([]string, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	var addresses []string
+	for _, ip := range ips {
+		isIPv4 := ip.To4() != nil
+		if (version == IPv4 && !isIPv4) || (version == IPv6 && isIPv4) {
+			continue
+		}
+		addresses = append(addresses, ip.String())
+	}
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return strings.Contains(addresses[j], ":") && !strings.Contains(addresses[i], ":")
+	})
+	if len(addresses) == 0 {
+		if version == IPv4 || version == IPv6 {
+			return nil, fmt.Errorf("%s has no IPv%s address", host, version)
+		}
+		return nil, fmt.Errorf("%s has no address", host)
+	}
+	return addresses, nil
+}
+
+// Result is the outcome of a test against one address.
+type Result struct {
+	Address string
+	Err     error
+}
+
+// ForEach runs fn once for every address of host, in order, with the address
+// pinned in the context, and returns the outcome of each run. A heading
+// naming the address is written to w before each run.
+func ForEach(ctx context.Context, w io.Writer, host string, addresses []string, fn func(ctx context.Context, address string) error) []Result {
+	results := make([]Result, 0, len(addresses))
+	for i, address := range addresses {
+		if ctx.Err() != nil {
+			results = append(results, Result{Address: address, Err: ctx.Err()})
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\nAddress %d/%d: %s (%s)\n%s\n", strings.Repeat("═", 60), i+1, len(addresses), address, Family(address), strings.Repeat("═", 60))
+		results = append(results, Result{Address: address, Err: fn(WithAddress(ctx, host, address), address)})
+	}
+	return results
+}
+
+// Family returns "IPv4" or "IPv6" for an IP address.
+func Family(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// Print writes a summary line per address.
+func Print(w io.Writer, host string, results []Result) {
+	fmt.Fprintf(w, "\nResults by address for %s:\n", host)
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "  ✗ %-39s %s  %v\n", r.Address, Family(r.Address), r.Err)
+		} else {
+			fmt.Fprintf(w, "  ✓ %-39s %s\n", r.Address, Family(r.Address))
+		}
+	}
+}
+
+// Err returns an error naming the failed addresses, or nil when every
+// address passed.
+func Err(host string, results []Result) error {
+	var failed []string
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r.Address)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d address(es) of %s failed: %s", len(failed), len(results), host, strings.Join(failed, ", "))
+}
@@ -0,0 +1,156 @@
+package dualstack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		version, sourceIP string
+		errorMsg          string
+	}{
+		{version: "", sourceIP: ""},
+		{version: "4", sourceIP: "192.0.2.25"},
+		{version: "6", sourceIP: "2001:db8::25"},
+		{version: "", sourceIP: "2001:db8::25"},
+		{version: "both", sourceIP: ""},
+		{version: "5", errorMsg: "invalid -ipversion"},
+		{version: "4", sourceIP: "mail.example.com", errorMsg: "not an IP address"},
+		{version: "4", sourceIP: "2001:db8::25", errorMsg: "not an IPv4 address"},
+		{version: "6", sourceIP: "192.0.2.25", errorMsg: "not an IPv6 address"},
+		{version: "both", sourceIP: "192.0.2.25", errorMsg: "cannot be combined"},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.version, tt.sourceIP)
+		if tt.errorMsg == "" {
+			if err != nil {
+				t.Errorf("Validate(%q, %q) unexpected error: %v", tt.version, tt.sourceIP, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+			t.Errorf("Validate(%q, %q) error = %v, want error containing %q", tt.version, tt.sourceIP, err, tt.errorMsg)
+		}
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	tests := []struct{ network, version, want string }{
+		{"tcp", "", "tcp"},
+		{"tcp", "4", "tcp4"},
+		{"tcp", "6", "tcp6"},
+		{"tcp", "both", "tcp"},
+		{"udp", "4", "udp"},
+	}
+	for _, tt := range tests {
+		if got := Network(tt.network, tt.version); got != tt.want {
+			t.Errorf("Network(%q, %q) = %q, want %q", tt.network, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestPinned(t *testing.T) {
+	ctx := WithAddress(context.Background(), "mx1.example.com", "2001:db8::25")
+
+	tests := []struct{ address, want string }{
+		{"mx1.example.com:25", "[2001:db8::25]:25"},
+		{"MX1.example.com.:465", "[2001:db8::25]:465"},
+		{"api.example.com:443", "api.example.com:443"},
+		{"mx1.example.com", "mx1.example.com"},
+	}
+	for _, tt := range tests {
+		if got := Pinned(ctx, tt.address); got != tt.want {
+			t.Errorf("Pinned(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+	if got := Pinned(context.Background(), "mx1.example.com:25"); got != "mx1.example.com:25" {
+		t.Errorf("Pinned() without a pin = %q", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	ctx := context.Background()
+
+	got, err := Lookup(ctx, nil, "2001:db8::25", Both)
+	if err != nil || !reflect.DeepEqual(got, []string{"2001:db8::25"}) {
+		t.Errorf("Lookup(IPv6 literal) = %q, %v", got, err)
+	}
+	if _, err := Lookup(ctx, nil, "2001:db8::25", IPv4); err == nil || !strings.Contains(err.Error(), "no IPv4 address") {
+		t.Errorf("Lookup(IPv6 literal, 4) error = %v, want no IPv4 address", err)
+	}
+	got, err = Lookup(ctx, nil, "127.0.0.1", IPv4)
+	if err != nil || !reflect.DeepEqual(got, []string{"127.0.0.1"}) {
+		t.Errorf("Lookup(IPv4 literal, 4) = %q, %v", got, err)
+	}
+}
+
+// TestWrap tests that a pinned host name is dialed at the pinned address
+func TestWrap(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	d := Wrap(&net.Dialer{}, IPv4)
+	ctx := WithAddress(context.Background(), "mail.invalid", "127.0.0.1")
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort("mail.invalid", port))
+	if err != nil {
+		t.Fatalf("DialContext() to the pinned address error = %v", err)
+	}
+	conn.Close()
+
+	if _, err := Wrap(&net.Dialer{}, IPv6).DialContext(ctx, "tcp", net.JoinHostPort("mail.invalid", port)); err == nil {
+		t.Error("DialContext() of an IPv4 address with -ipversion 6 succeeded")
+	}
+}
+
+func TestForEach(t *testing.T) {
+	refused := errors.New("connection refused")
+	var buf bytes.Buffer
+	results := ForEach(context.Background(), &buf, "mx.example.com", []string{"192.0.2.25", "2001:db8::25"}, func(ctx context.Context, address string) error {
+		if got := Pinned(ctx, "mx.example.com:25"); got != net.JoinHostPort(address, "25") {
+			t.Errorf("run for %s dials %s", address, got)
+		}
+		if Family(address) == "IPv6" {
+			return refused
+		}
+		return nil
+	})
+
+	want := []Result{{Address: "192.0.2.25"}, {Address: "2001:db8::25", Err: refused}}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("ForEach() = %v, want %v", results, want)
+	}
+	if !strings.Contains(buf.String(), "Address 2/2: 2001:db8::25 (IPv6)") {
+		t.Errorf("ForEach() headings:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	Print(&buf, "mx.example.com", results)
+	if out := buf.String(); !strings.Contains(out, "✓ 192.0.2.25") || !strings.Contains(out, "✗ 2001:db8::25") {
+		t.Errorf("Print() output:\n%s", out)
+	}
+	if err := Err("mx.example.com", results); err == nil || !strings.Contains(err.Error(), "1 of 2 address(es) of mx.example.com failed: 2001:db8::25") {
+		t.Errorf("Err() = %v", err)
+	}
+	if err := Err("mx.example.com", results[:1]); err != nil {
+		t.Errorf("Err() with every address passing = %v", err)
+	}
+}
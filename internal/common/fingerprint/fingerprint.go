@@ -0,0 +1,273 @@
+// Package fingerprint identifies mail server software from what a server
+// reveals before authentication: SMTP banners and EHLO keywords, IMAP and
+// POP3 greetings and capabilities, and JMAP session data. Signatures are
+// data, not code: the built-in set is read from signatures.json and more
+// can be loaded from a file at run time.
+package fingerprint
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// Protocols evidence can be collected from.
+const (
+	ProtocolSMTP = "smtp"
+	ProtocolIMAP = "imap"
+	ProtocolPOP3 = "pop3"
+	ProtocolJMAP = "jmap"
+)
+
+// Sources a signature can match.
+const (
+	SourceBanner         = "banner"         // SMTP banner, IMAP/POP3 greeting or HTTP Server header
+	SourceCapability     = "capability"     // EHLO keyword, CAPABILITY, CAPA line or JMAP capability URI
+	SourceImplementation = "implementation" // POP3 IMPLEMENTATION or IMAP ID name and version
+	SourceURL            = "url"            // JMAP session API URL
+)
+
+//go:embed signatures.json
+var builtinSignatures []byte
+
+// Signature recognizes a product from one piece of evidence.
+type Signature struct {
+	Product    string   `json:"product"`
+	Protocols  []string `json:"protocols,omitempty"` // Protocols the signature applies to (all when empty)
+	Source     string   `json:"source"`              // banner, capability, implementation or url
+	Pattern    string   `json:"pattern"`             // Regular expression, matched case-insensitively
+	Version    string   `json:"version,omitempty"`   // Version template using the pattern's groups, e.g. "$1"
+	Confidence int      `json:"confidence"`          // How certain a match is, 1-100
+
+	re *regexp.Regexp
+}
+
+// compile validates the signature and compiles its pattern.
+func (s *Signature) compile() error {
+	if s.Product == "" {
+		return fmt.Errorf("signature has no product")
+	}
+	switch s.Source {
+	case SourceBanner, SourceCapability, SourceImplementation, SourceURL:
+	default:
+		return fmt.Errorf("signature for %s: unknown source %q (valid: banner, capability, implementation, url)", s.Product, s.Source)
+	}
+	for _, p := range s.Protocols {
+		switch p {
+		case ProtocolSMTP, ProtocolIMAP, ProtocolPOP3, ProtocolJMAP:
+		default:
+			return fmt.Errorf("signature for %s: unknown protocol %q (valid: smtp, imap, pop3, jmap)", s.Product, p)
+		}
+	}
+	if s.Confidence < 1 || s.Confidence > 100 {
+		return fmt.Errorf("signature for %s: confidence must be between 1 and 100", s.Product)
+	}
+	re, err := regexp.Compile("(?i)" + s.Pattern)
+	if err != nil {
+		return fmt.Errorf("signature for %s: invalid pattern: %w", s.Product, err)
+	}
+	s.re = re
+	return nil
+}
+
+// appliesTo reports whether the signature is used for protocol.
+func (s *Signature) appliesTo(protocol string) bool {
+	if len(s.Protocols) == 0 {
+		return true
+	}
+	for _, p := range s.Protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// Evidence is what a server revealed on one connection.
+type Evidence struct {
+	Protocol       string
+	Banner         string   // SMTP banner, IMAP/POP3 greeting or HTTP Server header
+	Capabilities   []string // One entry per keyword, with its parameters
+	Implementation string   // POP3 IMPLEMENTATION or IMAP ID name and version
+	URL            string   // JMAP session API URL
+}
+
+// values returns the evidence a source matches against.
+func (e *Evidence) values(source string) []string {
+	switch source {
+	case SourceBanner:
+		return []string{e.Banner}
+	case SourceCapability:
+		return e.Capabilities
+	case SourceImplementation:
+		return []string{e.Implementation}
+	case SourceURL:
+		return []string{e.URL}
+	}
+	return nil
+}
+
+// Match is a product identified from the evidence.
+type Match struct {
+	Product    string
+	Version    string   // Empty if no signature revealed it
+	Confidence int      // Combined confidence of all matching signatures, 1-100
+	Evidence   []string // What matched, e.g. banner "ESMTP Postfix"
+}
+
+// String returns the match as "product version (confidence%)".
+func (m Match) String() string {
+	name := m.Product
+	if m.Version != "" {
+		name += " " + m.Version
+	}
+	return fmt.Sprintf("%s (%d%%)", name, m.Confidence)
+}
+
+// Registry holds the signatures used by Identify.
+type Registry struct {
+	signatures []*Signature
+}
+
+// NewRegistry returns a registry with the built-in signatures.
+func NewRegistry() *Registry {
+	r := &Registry{}
+	if err := r.Load(bytes.NewReader(builtinSignatures)); err != nil {
+		panic(fmt.Sprintf("invalid built-in signatures: %v", err))
+	}
+	return r
+}
+
+// LoadRegistry returns a registry with the built-in signatures and, if
+// path is not empty, the signatures of that file.
+func LoadRegistry(path string) (*Registry, error) {
+	r := NewRegistry()
+	if path != "" {
+		if err := r.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add registers a signature.
+func (r *Registry) Add(sig Signature) error {
+	if err := sig.compile(); err != nil {
+		return err
+	}
+	r.signatures = append(r.signatures, &sig)
+	return nil
+}
+
+// Load registers a JSON array of signatures, in the format of
+// signatures.json. Nothing is registered if any signature is invalid.
+func (r *Registry) Load(rd io.Reader) error {
+	var sigs []Signature
+	if err := json.NewDecoder(rd).Decode(&sigs); err != nil {
+		return fmt.Errorf("failed to parse signatures: %w", err)
+	}
+	for i := range sigs {
+		if err := sigs[i].compile(); err != nil {
+			return fmt.Errorf("signature %d: %w", i+1, err)
+		}
+	}
+	for i := range sigs {
+		r.signatures = append(r.signatures, &sigs[i])
+	}
+	return nil
+}
+
+// LoadFile registers the signatures of a JSON file (see Load).
+func (r *Registry) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open signatures: %w", err)
+	}
+	defer f.Close()
+	return r.Load(f)
+}
+
+// Identify matches the evidence against all signatures and returns the
+// products found, most certain first. Independent matches for the same
+// product raise its confidence; the version comes from the most confident
+// signature that revealed one.
+func (r *Registry) Identify(ev Evidence) []Match {
+	type candidate struct {
+		match             Match
+		doubt             float64 // Probability that every match is wrong
+		versionConfidence int
+	}
+	var candidates []*candidate
+	byProduct := make(map[string]*candidate)
+
+	for _, sig := range r.signatures {
+		if !sig.appliesTo(ev.Protocol) {
+			continue
+		}
+		for _, value := range ev.values(sig.Source) {
+			if value == "" {
+				continue
+			}
+			loc := sig.re.FindStringSubmatchIndex(value)
+			if loc == nil {
+				continue
+			}
+
+			c, ok := byProduct[sig.Product]
+			if !ok {
+				c = &candidate{match: Match{Product: sig.Product}, doubt: 1}
+				byProduct[sig.Product] = c
+				candidates = append(candidates, c)
+			}
+			c.doubt *= 1 - float64(sig.Confidence)/100
+			c.match.Evidence = append(c.match.Evidence, fmt.Sprintf("%s %q", sig.Source, value[loc[0]:loc[1]]))
+			if sig.Version != "" && sig.Confidence > c.versionConfidence {
+				if version := string(sig.re.ExpandString(nil, sig.Version, value, loc)); version != "" {
+					c.match.Version = version
+					c.versionConfidence = sig.Confidence
+				}
+			}
+			break // One match per signature
+		}
+	}
+
+	matches := make([]Match, len(candidates))
+	for i, c := range candidates {
+		c.match.Confidence = int(math.Round(100 * (1 - c.doubt)))
+		matches[i] = c.match
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Confidence > matches[j].Confidence
+	})
+	return matches
+}
+
+// Print writes the matches as an indented report section.
+func Print(w io.Writer, matches []Match) {
+	fmt.Fprintln(w, "Server Fingerprint:")
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "  No known server software recognized")
+		return
+	}
+	for _, m := range matches {
+		fmt.Fprintf(w, "  • %s\n", m)
+		for _, ev := range m.Evidence {
+			fmt.Fprintf(w, "      %s\n", ev)
+		}
+	}
+}
+
+// Best returns the most confident match as a string, or "" if there is
+// none; used for CSV output.
+func Best(matches []Match) string {
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[0].String()
+}
@@ -0,0 +1,179 @@
+package fingerprint
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIdentify_BuiltinSignatures(t *testing.T) {
+	tests := []struct {
+		name        string
+		evidence    Evidence
+		wantProduct string
+		wantVersion string
+	}{
+		{
+			name:        "postfix banner",
+			evidence:    Evidence{Protocol: ProtocolSMTP, Banner: "mail.example.com ESMTP Postfix (Debian/GNU)"},
+			wantProduct: "Postfix",
+		},
+		{
+			name:        "exim banner with version",
+			evidence:    Evidence{Protocol: ProtocolSMTP, Banner: "mx.example.com ESMTP Exim 4.96 Mon, 12 Oct 2026 10:00:00 +0000"},
+			wantProduct: "Exim",
+			wantVersion: "4.96",
+		},
+		{
+			name:        "sendmail banner",
+			evidence:    Evidence{Protocol: ProtocolSMTP, Banner: "mx.example.com ESMTP Sendmail 8.15.2/8.15.2; Mon, 12 Oct 2026"},
+			wantProduct: "Sendmail",
+			wantVersion: "8.15.2",
+		},
+		{
+			name:        "exchange extension only",
+			evidence:    Evidence{Protocol: ProtocolSMTP, Banner: "mail.example.com", Capabilities: []string{"PIPELINING", "X-EXPS GSSAPI NTLM"}},
+			wantProduct: "Microsoft Exchange",
+		},
+		{
+			name:        "exchange online protection",
+			evidence:    Evidence{Protocol: ProtocolSMTP, Banner: "BN1NAM02FT012.mail.protection.outlook.com Microsoft ESMTP MAIL Service ready"},
+			wantProduct: "Exchange Online Protection",
+		},
+		{
+			name:        "gmail",
+			evidence:    Evidence{Protocol: ProtocolSMTP, Banner: "mx.google.com ESMTP a1si123456wrb.1 - gsmtp"},
+			wantProduct: "Gmail",
+		},
+		{
+			name:        "proofpoint",
+			evidence:    Evidence{Protocol: ProtocolSMTP, Banner: "mx0a-001b2d01.pphosted.com ESMTP mfa-m0098409"},
+			wantProduct: "Proofpoint",
+		},
+		{
+			name:        "dovecot imap id",
+			evidence:    Evidence{Protocol: ProtocolIMAP, Banner: "[CAPABILITY IMAP4rev1 ID] Dovecot ready.", Implementation: "Dovecot 2.3.21"},
+			wantProduct: "Dovecot",
+			wantVersion: "2.3.21",
+		},
+		{
+			name:        "cyrus pop3 implementation",
+			evidence:    Evidence{Protocol: ProtocolPOP3, Implementation: "Cyrus POP3 v3.4.5"},
+			wantProduct: "Cyrus IMAP",
+			wantVersion: "3.4.5",
+		},
+		{
+			name:        "gmail imap capability",
+			evidence:    Evidence{Protocol: ProtocolIMAP, Capabilities: []string{"IMAP4rev1", "X-GM-EXT-1"}},
+			wantProduct: "Gmail",
+		},
+		{
+			name:        "fastmail jmap session",
+			evidence:    Evidence{Protocol: ProtocolJMAP, Capabilities: []string{"urn:ietf:params:jmap:core", "https://www.fastmail.com/dev/maskedemail"}, URL: "https://api.fastmail.com/jmap/api/"},
+			wantProduct: "Fastmail",
+		},
+	}
+
+	registry := NewRegistry()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := registry.Identify(tt.evidence)
+			if len(matches) == 0 {
+				t.Fatalf("Identify() found nothing, want %s", tt.wantProduct)
+			}
+			if matches[0].Product != tt.wantProduct {
+				t.Errorf("best match = %s, want %s", matches[0], tt.wantProduct)
+			}
+			if matches[0].Version != tt.wantVersion {
+				t.Errorf("version = %q, want %q", matches[0].Version, tt.wantVersion)
+			}
+			if len(matches[0].Evidence) == 0 {
+				t.Error("match has no evidence")
+			}
+		})
+	}
+}
+
+func TestIdentify_CombinesConfidence(t *testing.T) {
+	registry := &Registry{}
+	for _, sig := range []Signature{
+		{Product: "Example MTA", Source: SourceBanner, Pattern: `ExampleMTA/(\S+)`, Version: "$1", Confidence: 50},
+		{Product: "Example MTA", Source: SourceCapability, Pattern: `^X-EXAMPLE$`, Confidence: 50},
+		{Product: "Other", Protocols: []string{ProtocolIMAP}, Source: SourceBanner, Pattern: `ExampleMTA`, Confidence: 90},
+	} {
+		if err := registry.Add(sig); err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+
+	matches := registry.Identify(Evidence{
+		Protocol:     ProtocolSMTP,
+		Banner:       "host ESMTP examplemta/1.2",
+		Capabilities: []string{"PIPELINING", "X-EXAMPLE"},
+	})
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1 (IMAP-only signature skipped): %v", len(matches), matches)
+	}
+	if got := matches[0]; got.Confidence != 75 || got.Version != "1.2" || len(got.Evidence) != 2 {
+		t.Errorf("match = %+v, want confidence 75, version 1.2 and two pieces of evidence", got)
+	}
+
+	if matches := registry.Identify(Evidence{Protocol: ProtocolSMTP}); len(matches) != 0 {
+		t.Errorf("empty evidence matched %v", matches)
+	}
+}
+
+func TestRegistry_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	custom := `[{"product": "Acme Mail", "protocols": ["pop3"], "source": "implementation", "pattern": "^AcmePOP (\\d+)", "version": "$1", "confidence": 80}]`
+	if err := os.WriteFile(path, []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewRegistry()
+	if err := registry.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error: %v", err)
+	}
+	matches := registry.Identify(Evidence{Protocol: ProtocolPOP3, Implementation: "AcmePOP 7"})
+	if len(matches) != 1 || matches[0].String() != "Acme Mail 7 (80%)" {
+		t.Errorf("Identify() = %v, want Acme Mail 7 (80%%)", matches)
+	}
+
+	invalid := map[string]string{
+		"not json":       `{`,
+		"no product":     `[{"source": "banner", "pattern": "x", "confidence": 50}]`,
+		"unknown source": `[{"product": "A", "source": "header", "pattern": "x", "confidence": 50}]`,
+		"bad protocol":   `[{"product": "A", "protocols": ["nntp"], "source": "banner", "pattern": "x", "confidence": 50}]`,
+		"bad pattern":    `[{"product": "A", "source": "banner", "pattern": "(", "confidence": 50}]`,
+		"bad confidence": `[{"product": "A", "source": "banner", "pattern": "x", "confidence": 150}]`,
+	}
+	for name, data := range invalid {
+		r := &Registry{}
+		if err := r.Load(strings.NewReader(data)); err == nil {
+			t.Errorf("Load(%s) succeeded, want error", name)
+		}
+		if len(r.signatures) != 0 {
+			t.Errorf("Load(%s) registered %d signatures", name, len(r.signatures))
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var buf bytes.Buffer
+	Print(&buf, nil)
+	if !strings.Contains(buf.String(), "No known server software") {
+		t.Errorf("Print(nil) = %q", buf.String())
+	}
+
+	buf.Reset()
+	matches := []Match{{Product: "Postfix", Confidence: 90, Evidence: []string{`banner "ESMTP Postfix"`}}}
+	Print(&buf, matches)
+	if !strings.Contains(buf.String(), "Postfix (90%)") || !strings.Contains(buf.String(), `banner "ESMTP Postfix"`) {
+		t.Errorf("Print() = %q", buf.String())
+	}
+	if Best(matches) != "Postfix (90%)" || Best(nil) != "" {
+		t.Errorf("Best() = %q", Best(matches))
+	}
+}
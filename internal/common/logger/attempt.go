@@ -0,0 +1,26 @@
+package logger
+
+import "fmt"
+
+// attemptLogger annotates the rows of one attempt of a retried action.
+type attemptLogger struct {
+	Logger
+	attempt  int
+	attempts int
+}
+
+// WithAttempt returns a Logger that prefixes the error column (the last
+// column) of each row with "attempt n/total: ", so the row written by each
+// attempt of a retried action can be told apart. Rows without an error are
+// written unchanged.
+func WithAttempt(l Logger, attempt, attempts int) Logger {
+	return &attemptLogger{Logger: l, attempt: attempt, attempts: attempts}
+}
+
+func (l *attemptLogger) WriteRow(row []string) error {
+	if len(row) > 0 && row[len(row)-1] != "" {
+		row = append([]string(nil), row...)
+		row[len(row)-1] = fmt.Sprintf("attempt %d/%d: %s", l.attempt, l.attempts, row[len(row)-1])
+	}
+	return l.Logger.WriteRow(row)
+}
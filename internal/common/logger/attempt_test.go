@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+// rowRecorder is a Logger that keeps the rows in memory.
+type rowRecorder struct {
+	rows [][]string
+}
+
+func (r *rowRecorder) WriteHeader(columns []string) error { return nil }
+func (r *rowRecorder) WriteRow(row []string) error        { r.rows = append(r.rows, row); return nil }
+func (r *rowRecorder) Close() error                       { return nil }
+func (r *rowRecorder) ShouldWriteHeader() (bool, error)   { return false, nil }
+
+func TestWithAttempt(t *testing.T) {
+	rec := &rowRecorder{}
+	l := WithAttempt(rec, 2, 4)
+
+	failed := []string{"testconnect", "FAILURE", "connection reset"}
+	l.WriteRow(failed)
+	l.WriteRow([]string{"testconnect", "SUCCESS", ""})
+
+	want := [][]string{
+		{"testconnect", "FAILURE", "attempt 2/4: connection reset"},
+		{"testconnect", "SUCCESS", ""},
+	}
+	if !reflect.DeepEqual(rec.rows, want) {
+		t.Errorf("rows = %q, want %q", rec.rows, want)
+	}
+	if failed[2] != "connection reset" {
+		t.Errorf("caller's row was modified: %q", failed)
+	}
+}
@@ -0,0 +1,36 @@
+package logger
+
+// columnsLogger inserts extra columns before the error column.
+type columnsLogger struct {
+	Logger
+	columns []string
+	values  func() []string
+}
+
+// WithColumns returns a Logger that inserts columns before the last column
+// (the error column) of the header, and the result of values before the last
+// column of each row. values is called for every row, so it can report state
+// that changes while the action runs, and must return len(columns) values.
+func WithColumns(l Logger, columns []string, values func() []string) Logger {
+	return &columnsLogger{Logger: l, columns: columns, values: values}
+}
+
+func (l *columnsLogger) WriteHeader(columns []string) error {
+	return l.Logger.WriteHeader(insertBeforeLast(columns, l.columns))
+}
+
+func (l *columnsLogger) WriteRow(row []string) error {
+	return l.Logger.WriteRow(insertBeforeLast(row, l.values()))
+}
+
+// insertBeforeLast returns a copy of s with extra inserted before its last
+// element, or appended when s is empty.
+func insertBeforeLast(s, extra []string) []string {
+	out := make([]string, 0, len(s)+len(extra))
+	if len(s) == 0 {
+		return append(out, extra...)
+	}
+	out = append(out, s[:len(s)-1]...)
+	out = append(out, extra...)
+	return append(out, s[len(s)-1])
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWithColumns(t *testing.T) {
+	rec := &headerRecorder{}
+	calls := 0
+	l := WithColumns(rec, []string{"DNS_ms", "TCP_ms"}, func() []string {
+		calls++
+		return []string{"1", fmt.Sprint(calls)}
+	})
+
+	header := []string{"Action", "Status", "Error"}
+	l.WriteHeader(header)
+	l.WriteRow([]string{"testconnect", "SUCCESS", ""})
+	l.WriteRow([]string{"testconnect", "FAILURE", "timeout"})
+
+	if want := []string{"Action", "Status", "DNS_ms", "TCP_ms", "Error"}; !reflect.DeepEqual(rec.header, want) {
+		t.Errorf("header = %q, want %q", rec.header, want)
+	}
+	want := [][]string{
+		{"testconnect", "SUCCESS", "1", "1", ""},
+		{"testconnect", "FAILURE", "1", "2", "timeout"},
+	}
+	if !reflect.DeepEqual(rec.rows, want) {
+		t.Errorf("rows = %q, want %q", rec.rows, want)
+	}
+	if header[2] != "Error" {
+		t.Errorf("caller's header was modified: %q", header)
+	}
+}
+
+// headerRecorder is a rowRecorder that also keeps the header.
+type headerRecorder struct {
+	rowRecorder
+	header []string
+}
+
+func (r *headerRecorder) WriteHeader(columns []string) error { r.header = columns; return nil }
@@ -0,0 +1,238 @@
+// Package proxy dials TCP connections directly or through a SOCKS5 or
+// HTTP CONNECT proxy, for the raw-TCP protocol clients (SMTP, IMAP, POP3).
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	xproxy "golang.org/x/net/proxy"
+
+	"msgraphtool/internal/common/timing"
+)
+
+// Dialer opens connections, either directly or through a proxy.
+// *net.Dialer satisfies it.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// defaultPorts are used when the proxy URL has no port.
+var defaultPorts = map[string]string{
+	"http":   "80",
+	"https":  "443",
+	"socks5": "1080",
+}
+
+// NewDialer returns a Dialer that connects through the proxy in proxyURL
+// (http://, https:// or socks5://, optionally with user:password), or
+// forward itself when proxyURL is empty. forward is used to reach the proxy;
+// its Timeout also bounds the proxy handshake. forward is instrumented with
+// timing.Instrument, so timing.Dial can tell the DNS lookup from the connect.
+//
+// The target host name is passed to the proxy unresolved, so it is looked up
+// by the proxy. This matters on networks that can only resolve and reach
+// external hosts through the proxy.
+func NewDialer(proxyURL string, forward *net.Dialer) (Dialer, error) {
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+	timing.Instrument(forward)
+	if proxyURL == "" {
+		return forward, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL format: %w", err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	port, ok := defaultPorts[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported proxy scheme: %s (supported: http, https, socks5)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL must include hostname")
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	address := net.JoinHostPort(u.Hostname(), port)
+
+	if scheme == "socks5" {
+		var auth *xproxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &xproxy.Auth{User: u.User.Username(), Password: password}
+		}
+		d, err := xproxy.SOCKS5("tcp", address, auth, forward)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOCKS5 proxy: %w", err)
+		}
+		cd, ok := d.(xproxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("SOCKS5 dialer does not support contexts")
+		}
+		return &socksDialer{dialer: cd, address: address, timeout: forward.Timeout}, nil
+	}
+
+	return &connectDialer{
+		address: address,
+		useTLS:  scheme == "https",
+		user:    u.User,
+		forward: forward,
+	}, nil
+}
+
+// socksDialer bounds the SOCKS5 handshake by the forward dialer's timeout and
+// names the proxy in errors.
+type socksDialer struct {
+	dialer  xproxy.ContextDialer
+	address string
+	timeout time.Duration
+}
+
+func (d *socksDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	conn, err := d.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy %s: %w", d.address, err)
+	}
+	return conn, nil
+}
+
+// connectDialer tunnels connections through an HTTP proxy with the CONNECT
+// method (RFC 9110 section 9.3.6).
+type connectDialer struct {
+	address string // Proxy host:port
+	useTLS  bool   // https:// proxy: TLS to the proxy itself
+	user    *url.Userinfo
+	forward *net.Dialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.forward.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.forward.Timeout)
+		defer cancel()
+	}
+
+	conn, err := d.forward.DialContext(ctx, "tcp", d.address)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP proxy %s: %w", d.address, err)
+	}
+	if d.useTLS {
+		host, _, _ := net.SplitHostPort(d.address)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("HTTP proxy %s: TLS handshake failed: %w", d.address, err)
+		}
+		conn = tlsConn
+	}
+
+	tunnel, err := d.connect(ctx, conn, address)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("HTTP proxy %s: %w", d.address, err)
+	}
+	return tunnel, nil
+}
+
+// connect sends the CONNECT request and reads the proxy's reply.
+func (d *connectDialer) connect(ctx context.Context, conn net.Conn, address string) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	// Unblock the exchange when the context is canceled
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if d.user != nil {
+		password, _ := d.user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("CONNECT request failed: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("invalid CONNECT response: %w", err)
+	}
+	// The body is not read: after a 200 the stream belongs to the target
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s refused: %s", address, resp.Status)
+	}
+
+	// Mail servers speak first; the greeting may already be buffered
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn returns bytes read past the CONNECT response before reading
+// from the connection again.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// DialTLS connects with d and performs a TLS client handshake, like
+// tls.DialWithDialer for implicit-TLS ports reached through a proxy. A
+// positive timeout bounds the connection and handshake together.
+// ServerName defaults to the host of address. The dial and the handshake are
+// recorded in the context's timing.Recorder.
+func DialTLS(ctx context.Context, d Dialer, network, address string, config *tls.Config, timeout time.Duration) (*tls.Conn, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	conn, err := timing.Dial(ctx, d, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName, _, _ = net.SplitHostPort(address)
+	}
+	tlsConn := tls.Client(conn, config)
+	start := time.Now()
+	err = tlsConn.HandshakeContext(ctx)
+	timing.FromContext(ctx).Since(timing.TLS, start)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProxy accepts one connection and runs handle on it.
+func fakeProxy(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}()
+	return ln.Addr().String()
+}
+
+// readBanner dials target through the proxy and reads the first line.
+func readBanner(t *testing.T, proxyURL, target string) (string, error) {
+	t.Helper()
+	d, err := NewDialer(proxyURL, &net.Dialer{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("NewDialer() error = %v", err)
+	}
+	conn, err := d.DialContext(context.Background(), "tcp", target)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return bufio.NewReader(conn).ReadString('\n')
+}
+
+func TestNewDialer(t *testing.T) {
+	forward := &net.Dialer{}
+	d, err := NewDialer("", forward)
+	if err != nil || d != forward {
+		t.Errorf("NewDialer(\"\") = %v, %v; want the forward dialer", d, err)
+	}
+
+	for _, proxyURL := range []string{"ftp://proxy.example.com", "http://", "socks4://proxy.example.com"} {
+		if _, err := NewDialer(proxyURL, forward); err == nil {
+			t.Errorf("NewDialer(%q) succeeded, want error", proxyURL)
+		}
+	}
+}
+
+func TestConnectDialer(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	addr := fakeProxy(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requests <- req
+		// The mail server greeting arrives with the CONNECT response
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n220 mail.example.com ESMTP\r\n")
+	})
+
+	banner, err := readBanner(t, "http://user:secret@"+addr, "mail.example.com:25")
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	if banner != "220 mail.example.com ESMTP\r\n" {
+		t.Errorf("banner = %q", banner)
+	}
+
+	req := <-requests
+	if req.Method != http.MethodConnect || req.Host != "mail.example.com:25" {
+		t.Errorf("request = %s %s, want CONNECT mail.example.com:25", req.Method, req.Host)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("credentials sent as Authorization instead of Proxy-Authorization")
+	}
+	if got := req.Header.Get("Proxy-Authorization"); got != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Errorf("Proxy-Authorization = %q", got)
+	}
+}
+
+func TestConnectDialer_Refused(t *testing.T) {
+	addr := fakeProxy(t, func(conn net.Conn) {
+		http.ReadRequest(bufio.NewReader(conn))
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+	})
+
+	_, err := readBanner(t, "http://"+addr, "mail.example.com:25")
+	if err == nil || !strings.Contains(err.Error(), "407") {
+		t.Errorf("DialContext() error = %v, want 407", err)
+	}
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	type handshake struct{ user, password, host string }
+	handshakes := make(chan handshake, 1)
+	addr := fakeProxy(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		readN := func(n int) []byte {
+			b := make([]byte, n)
+			io.ReadFull(r, b)
+			return b
+		}
+		// Greeting: version, method count, methods; select username/password
+		readN(int(readN(2)[1]))
+		conn.Write([]byte{5, 2})
+		// RFC 1929 authentication
+		readN(1)
+		user := string(readN(int(readN(1)[0])))
+		password := string(readN(int(readN(1)[0])))
+		conn.Write([]byte{1, 0})
+		// CONNECT request with a domain name (ATYP 3)
+		readN(4)
+		host := string(readN(int(readN(1)[0])))
+		port := binary.BigEndian.Uint16(readN(2))
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		handshakes <- handshake{user, password, net.JoinHostPort(host, strconv.Itoa(int(port)))}
+		io.WriteString(conn, "+OK POP3 ready\r\n")
+	})
+
+	banner, err := readBanner(t, "socks5://user:secret@"+addr, "pop.example.com:995")
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	if banner != "+OK POP3 ready\r\n" {
+		t.Errorf("banner = %q", banner)
+	}
+	if got := <-handshakes; got != (handshake{"user", "secret", "pop.example.com:995"}) {
+		t.Errorf("handshake = %+v", got)
+	}
+}
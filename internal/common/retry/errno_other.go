@@ -0,0 +1,14 @@
+//go:build !windows
+
+package retry
+
+import "syscall"
+
+// transientErrnos are the connection errors IsTransientError retries.
+var transientErrnos = []syscall.Errno{
+	syscall.ECONNREFUSED,
+	syscall.ECONNRESET,
+	syscall.ECONNABORTED,
+	syscall.EPIPE,
+	syscall.ETIMEDOUT,
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package retry
+
+import "syscall"
+
+// transientErrnos are the connection errors IsTransientError retries. Winsock
+// reports its own codes, which do not match the POSIX constants.
+var transientErrnos = []syscall.Errno{
+	10061, // WSAECONNREFUSED
+	syscall.WSAECONNRESET,
+	syscall.WSAECONNABORTED,
+	syscall.ERROR_BROKEN_PIPE,
+	10060, // WSAETIMEDOUT
+}
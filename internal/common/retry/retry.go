@@ -4,11 +4,47 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand/v2"
+	"net"
 	"strings"
 	"time"
 )
 
+// Error marks the error it wraps as retryable or permanent. It takes
+// precedence over every other classification, so callers can decide cases
+// the error type cannot, such as a lost reply after a message was sent.
+type Error struct {
+	Err       error
+	Retryable bool
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Transient marks err as worth retrying.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Err: err, Retryable: true}
+}
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Err: err, Retryable: false}
+}
+
+// temporary is implemented by protocol errors that know whether the server
+// reported a transient condition, such as POP3 [SYS/TEMP] responses.
+type temporary interface {
+	Temporary() bool
+}
+
 // IsRetryableError determines if an error is transient and worth retrying.
 // Returns true for network timeouts, connection errors, and temporary failures.
 // Returns false for context cancellation, permanent errors, and authentication failures.
@@ -46,6 +82,71 @@ func IsRetryableError(err error) bool {
 	return false
 }
 
+// IsTransientError determines if an error is transient and worth retrying,
+// for Do. Unlike IsRetryableError, the error types decide, never the message
+// text:
+//   - an *Error verdict (Transient, Permanent)
+//   - SMTP replies: the enhanced status class, or the reply code
+//   - DNS failures: timeouts and temporary failures, but not unknown hosts
+//   - timeouts, including a deadline of one attempt
+//   - refused, reset or aborted connections, and broken pipes
+//   - a connection closed by the server (EOF)
+//   - protocol errors with a Temporary method
+//
+// Context cancellation and all other errors (unreachable hosts, TLS and
+// certificate failures, authentication failures, permanent protocol replies)
+// are not transient.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var marked *Error
+	if errors.As(err, &marked) {
+		return marked.Retryable
+	}
+
+	// Never retry cancellation. A deadline is a timeout of one attempt; Do
+	// stops on its own when the caller's context expires.
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	// SMTP replies carry their own verdict: the enhanced status class, or the reply code
+	var reply smtpReply
+	if errors.As(err, &reply) {
+		return IsSMTPReplyRetryable(reply.SMTPCode(), reply.SMTPEnhancedCode())
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// The server or the path may recover from these; a *net.OpError alone
+	// is not enough, since it also wraps unreachable hosts and TLS failures
+	for _, errno := range transientErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var temp temporary
+	if errors.As(err, &temp) {
+		return temp.Temporary()
+	}
+
+	return false
+}
+
 // IsSMTPRetryableError determines if an SMTP error code is retryable.
 // Returns true for 4xx temporary SMTP errors.
 // Returns false for 5xx permanent SMTP errors and 2xx/3xx success codes.
@@ -59,59 +160,130 @@ func IsSMTPRetryableError(smtpCode int) bool {
 	return false
 }
 
-// RetryWithBackoff wraps an operation with exponential backoff retry logic.
-// The operation is retried up to maxRetries times with exponentially increasing delays.
-// Base delay doubles on each attempt (capped at 30 seconds).
-// Context cancellation is respected and will stop retries immediately.
-//
-// Example usage:
-//
-//	err := retry.RetryWithBackoff(ctx, 3, 2*time.Second, func() error {
-//	    return doSomethingThatMightFail()
-//	})
-func RetryWithBackoff(ctx context.Context, maxRetries int, baseDelay time.Duration, operation func() error) error {
-	var lastErr error
-
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		// Execute the operation
-		lastErr = operation()
-
-		// Success - return immediately
-		if lastErr == nil {
-			if attempt > 0 {
-				log.Printf("Operation succeeded after %d retries", attempt)
-			}
-			return nil
-		}
+// smtpReply is implemented by errors that carry an SMTP reply, such as
+// the SMTP protocol package's ReplyError.
+type smtpReply interface {
+	SMTPCode() int
+	SMTPEnhancedCode() string
+}
 
-		// Check if error is retryable
-		if !IsRetryableError(lastErr) {
-			// Non-retryable error - fail immediately
-			return lastErr
+// IsSMTPReplyRetryable determines if an SMTP reply is retryable. When the reply
+// carries an RFC 3463 enhanced status code (e.g. "4.7.1"), its class digit
+// decides: 4 (persistent transient failure) is retryable, 2 and 5 are not.
+// Otherwise the reply code decides, as in IsSMTPRetryableError.
+func IsSMTPReplyRetryable(smtpCode int, enhancedCode string) bool {
+	if len(enhancedCode) >= 2 && enhancedCode[1] == '.' {
+		switch enhancedCode[0] {
+		case '4':
+			return true
+		case '2', '5':
+			return false
 		}
+	}
+	return IsSMTPRetryableError(smtpCode)
+}
 
-		// Last attempt failed - return error
-		if attempt == maxRetries {
-			return fmt.Errorf("operation failed after %d retries: %w", maxRetries, lastErr)
-		}
+// DefaultMaxDelay caps the backoff delay when Policy.MaxDelay is zero.
+const DefaultMaxDelay = 30 * time.Second
+
+// jitter is the fraction by which delays are randomized, so that clients
+// that failed together do not retry in lockstep.
+const jitter = 0.2
+
+// Policy configures Do.
+type Policy struct {
+	MaxRetries int           // Retries after the first attempt (0 runs the operation once)
+	BaseDelay  time.Duration // Delay before the first retry, doubled for each further retry
+	MaxDelay   time.Duration // Delay cap (DefaultMaxDelay when zero)
+
+	// OnRetry, if set, is called before waiting for the next attempt with the
+	// failed attempt number (starting at 1), its error and the delay.
+	OnRetry func(attempt int, err error, delay time.Duration)
+
+	// Retryable decides which errors are retried (IsTransientError when nil).
+	Retryable func(err error) bool
+}
+
+// Backoff returns the delay before retry n (starting at 0): base doubled n
+// times, capped at max, then randomized by ±20%.
+func Backoff(base, max time.Duration, n int) time.Duration {
+	if max <= 0 {
+		max = DefaultMaxDelay
+	}
+	delay := base
+	for i := 0; i < n && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return time.Duration(float64(delay) * (1 - jitter + 2*jitter*rand.Float64()))
+}
 
-		// Calculate exponential backoff delay (cap at 30 seconds)
-		delay := baseDelay * time.Duration(1<<uint(attempt))
-		if delay > 30*time.Second {
-			delay = 30 * time.Second
+// Do runs op until it succeeds, fails with an error the policy does not
+// retry (see IsTransientError), or MaxRetries retries have failed; it returns
+// the last error.
+// op receives the attempt number, starting at 1. Canceling ctx stops the
+// wait between attempts.
+func Do(ctx context.Context, policy Policy, op func(attempt int) error) error {
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsTransientError
+	}
+	for attempt := 1; ; attempt++ {
+		err := op(attempt)
+		if err == nil || attempt > policy.MaxRetries || !retryable(err) || ctx.Err() != nil {
+			return err
 		}
 
-		log.Printf("Retryable error encountered (attempt %d/%d): %v. Retrying in %v...",
-			attempt+1, maxRetries, lastErr, delay)
+		delay := Backoff(policy.BaseDelay, policy.MaxDelay, attempt-1)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
 
-		// Wait with context cancellation support
+		timer := time.NewTimer(delay)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return fmt.Errorf("retry cancelled: %w", ctx.Err())
-		case <-time.After(delay):
-			// Continue to next retry attempt
+		case <-timer.C:
 		}
 	}
+}
 
-	return lastErr
+// RetryWithBackoff wraps an operation with exponential backoff retry logic.
+// The operation is retried up to maxRetries times with exponentially increasing delays.
+// Base delay doubles on each attempt (capped at 30 seconds) and is randomized by ±20%.
+// Context cancellation is respected and will stop retries immediately.
+//
+// Example usage:
+//
+//	err := retry.RetryWithBackoff(ctx, 3, 2*time.Second, func() error {
+//	    return doSomethingThatMightFail()
+//	})
+func RetryWithBackoff(ctx context.Context, maxRetries int, baseDelay time.Duration, operation func() error) error {
+	attempts := 0
+	err := Do(ctx, Policy{
+		MaxRetries: maxRetries,
+		BaseDelay:  baseDelay,
+		Retryable:  IsRetryableError,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			log.Printf("Retryable error encountered (attempt %d/%d): %v. Retrying in %v...",
+				attempt, maxRetries, err, delay)
+		},
+	}, func(attempt int) error {
+		attempts = attempt
+		return operation()
+	})
+
+	if err == nil {
+		if attempts > 1 {
+			log.Printf("Operation succeeded after %d retries", attempts-1)
+		}
+		return nil
+	}
+	if attempts > maxRetries && IsRetryableError(err) {
+		return fmt.Errorf("operation failed after %d retries: %w", maxRetries, err)
+	}
+	return err
 }
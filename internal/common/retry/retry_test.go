@@ -0,0 +1,235 @@
+package retry
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeReply is an error carrying an SMTP reply.
+type fakeReply struct {
+	code     int
+	enhanced string
+}
+
+func (e *fakeReply) Error() string            { return fmt.Sprintf("%d %s", e.code, e.enhanced) }
+func (e *fakeReply) SMTPCode() int            { return e.code }
+func (e *fakeReply) SMTPEnhancedCode() string { return e.enhanced }
+
+func TestIsSMTPReplyRetryable(t *testing.T) {
+	tests := []struct {
+		code     int
+		enhanced string
+		want     bool
+	}{
+		{421, "", true},
+		{550, "", false},
+		{451, "4.7.1", true},
+		{550, "4.7.1", true},  // Class digit decides
+		{451, "5.7.1", false}, // Class digit decides
+		{250, "2.0.0", false},
+		{450, "invalid", true},
+	}
+
+	for _, tt := range tests {
+		if got := IsSMTPReplyRetryable(tt.code, tt.enhanced); got != tt.want {
+			t.Errorf("IsSMTPReplyRetryable(%d, %q) = %v, want %v", tt.code, tt.enhanced, got, tt.want)
+		}
+	}
+}
+
+func TestIsTransientError_SMTPReply(t *testing.T) {
+	// The reply decides, also when wrapped
+	wrapped := fmt.Errorf("RCPT TO failed: %w", &fakeReply{code: 550, enhanced: "5.7.1"})
+	if IsTransientError(wrapped) {
+		t.Error("550 5.7.1 reply reported as transient")
+	}
+	if !IsTransientError(&fakeReply{code: 451, enhanced: "4.7.1"}) {
+		t.Error("451 4.7.1 reply reported as not transient")
+	}
+	if !IsTransientError(fmt.Errorf("read banner: %w", transientErrnos[1])) {
+		t.Error("connection reset reported as not transient")
+	}
+}
+
+// fakeTemporary is a protocol error that knows whether it is transient.
+type fakeTemporary bool
+
+func (e fakeTemporary) Error() string   { return "protocol error" }
+func (e fakeTemporary) Temporary() bool { return bool(e) }
+
+// opError returns a dial error with the given cause, as net.Dialer reports it.
+func opError(cause error) error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: cause}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", fmt.Errorf("dial: %w", context.Canceled), false},
+		{"attempt deadline", context.DeadlineExceeded, true},
+		{"refused", opError(os.NewSyscallError("connect", transientErrnos[0])), true},
+		{"reset while reading", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", transientErrnos[1])}, true},
+		// A *net.OpError is only transient when its cause is
+		{"no route to host", opError(os.NewSyscallError("connect", syscall.EHOSTUNREACH)), false},
+		{"closed connection", &net.OpError{Op: "read", Net: "tcp", Err: net.ErrClosed}, false},
+		{"TLS alert", &net.OpError{Op: "remote error", Err: errors.New("tls: handshake failure")}, false},
+		{"unknown host", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "x.invalid", IsNotFound: true}}, false},
+		{"DNS timeout", &net.DNSError{Err: "i/o timeout", IsTimeout: true}, true},
+		{"server closed", fmt.Errorf("failed to read banner: %w", io.EOF), true},
+		{"certificate", x509.UnknownAuthorityError{}, false},
+		{"temporary reply", fakeTemporary(true), true},
+		{"permanent reply", fakeTemporary(false), false},
+		{"marked permanent", Permanent(io.EOF), false},
+		{"marked transient", fmt.Errorf("wrapped: %w", Transient(errors.New("try later"))), true},
+		// The message text does not decide
+		{"untyped timeout text", errors.New("timeout"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientError(tt.err); got != tt.want {
+				t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIsRetryableError tests the message-based classification of RetryWithBackoff
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", fmt.Errorf("dial: %w", context.Canceled), false},
+		{"deadline", fmt.Errorf("request: %w", context.DeadlineExceeded), false},
+		{"timeout text", errors.New("i/o timeout"), true},
+		{"refused text", errors.New("dial tcp 192.0.2.1:443: connect: connection refused"), true},
+		{"no such host", errors.New("dial tcp: lookup x.invalid: no such host"), true},
+		{"typed reset without the text", errors.New("read: EOF"), false},
+		{"authentication", errors.New("401 Unauthorized"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryableError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{10, time.Second}, // Capped
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := Backoff(100*time.Millisecond, time.Second, tt.n)
+			if got < tt.want*8/10 || got > tt.want*12/10 {
+				t.Fatalf("Backoff(n=%d) = %v, want %v ±20%%", tt.n, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	transient := fmt.Errorf("dial: %w", io.EOF)
+
+	t.Run("retries until success", func(t *testing.T) {
+		var retried []int
+		calls := 0
+		err := Do(context.Background(), Policy{
+			MaxRetries: 3,
+			BaseDelay:  time.Millisecond,
+			OnRetry:    func(attempt int, err error, delay time.Duration) { retried = append(retried, attempt) },
+		}, func(attempt int) error {
+			calls++
+			if attempt < 3 {
+				return transient
+			}
+			return nil
+		})
+		if err != nil || calls != 3 || len(retried) != 2 || retried[1] != 2 {
+			t.Errorf("Do() = %v after %d calls, retried %v", err, calls, retried)
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), Policy{MaxRetries: 2, BaseDelay: time.Millisecond}, func(int) error {
+			calls++
+			return transient
+		})
+		if err != transient || calls != 3 {
+			t.Errorf("Do() = %v after %d calls, want the last error after 3", err, calls)
+		}
+	})
+
+	t.Run("permanent error", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), Policy{MaxRetries: 5, BaseDelay: time.Millisecond}, func(int) error {
+			calls++
+			return &fakeReply{code: 535, enhanced: "5.7.8"}
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("Do() = %v after %d calls, want 1 call", err, calls)
+		}
+	})
+
+	t.Run("unreachable host", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), Policy{MaxRetries: 5, BaseDelay: time.Millisecond}, func(int) error {
+			calls++
+			return opError(os.NewSyscallError("connect", syscall.EHOSTUNREACH))
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("Do() = %v after %d calls, want 1 call", err, calls)
+		}
+	})
+
+	t.Run("policy classification", func(t *testing.T) {
+		calls := 0
+		policy := Policy{MaxRetries: 2, BaseDelay: time.Millisecond, Retryable: IsRetryableError}
+		err := Do(context.Background(), policy, func(int) error {
+			calls++
+			return transient
+		})
+		if err != transient || calls != 1 {
+			t.Errorf("Do() = %v after %d calls, want 1 call: EOF text is not retryable for IsRetryableError", err, calls)
+		}
+	})
+
+	t.Run("canceled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		policy := Policy{
+			MaxRetries: 5,
+			BaseDelay:  time.Hour,
+			OnRetry:    func(int, error, time.Duration) { cancel() },
+		}
+		err := Do(ctx, policy, func(int) error { return transient })
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Do() = %v, want context.Canceled", err)
+		}
+	})
+}
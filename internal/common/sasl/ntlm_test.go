@@ -0,0 +1,87 @@
+package sasl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// testTargetInfo is the MS-NLMP 4.2.4 target information: NetBIOS domain
+// "Domain" and computer "Server".
+var testTargetInfo = append(append(append(
+	[]byte{2, 0, 12, 0}, ntlmUnicode("Domain")...),
+	append([]byte{1, 0, 12, 0}, ntlmUnicode("Server")...)...),
+	0, 0, 0, 0)
+
+// TestNTLMv2Responses tests response computation against the MS-NLMP 4.2.4 example
+func TestNTLMv2Responses(t *testing.T) {
+	serverChallenge, _ := hex.DecodeString("0123456789abcdef")
+	clientChallenge := bytes.Repeat([]byte{0xaa}, 8)
+
+	key := ntowfv2("User", "Password", "Domain")
+	if got := hex.EncodeToString(key); got != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("NTOWFv2 = %s", got)
+	}
+
+	ntResponse, lmResponse := ntlmv2Responses(key, serverChallenge, clientChallenge, make([]byte, 8), testTargetInfo)
+	if got := hex.EncodeToString(ntResponse[:16]); got != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Errorf("NTProofStr = %s", got)
+	}
+	if got := hex.EncodeToString(lmResponse); got != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Errorf("LMv2 response = %s", got)
+	}
+}
+
+// TestNTLMClient tests the negotiate/challenge/authenticate message exchange
+func TestNTLMClient(t *testing.T) {
+	client := NewNTLMClient(`Domain\User`, "Password")
+	mech, negotiate, err := client.Start()
+	if err != nil || mech != NTLM {
+		t.Fatalf("Start() = %q, %v", mech, err)
+	}
+	if !bytes.HasPrefix(negotiate, ntlmSignature) || binary.LittleEndian.Uint32(negotiate[8:]) != 1 {
+		t.Fatalf("negotiate message = %x", negotiate)
+	}
+
+	// CHALLENGE_MESSAGE with target info at offset 48
+	challenge := make([]byte, 48)
+	copy(challenge, ntlmSignature)
+	binary.LittleEndian.PutUint32(challenge[8:], 2)
+	binary.LittleEndian.PutUint32(challenge[20:], ntlmClientFlags|ntlmNegotiateTargetInfo)
+	copy(challenge[24:], []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	binary.LittleEndian.PutUint16(challenge[40:], uint16(len(testTargetInfo)))
+	binary.LittleEndian.PutUint32(challenge[44:], 48)
+	challenge = append(challenge, testTargetInfo...)
+
+	auth, err := client.Next(challenge)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if binary.LittleEndian.Uint32(auth[8:]) != 3 {
+		t.Fatalf("authenticate message type = %d", binary.LittleEndian.Uint32(auth[8:]))
+	}
+
+	field := func(i int) []byte {
+		pos := 12 + i*8
+		length := binary.LittleEndian.Uint16(auth[pos:])
+		offset := binary.LittleEndian.Uint32(auth[pos+4:])
+		return auth[offset : offset+uint32(length)]
+	}
+	if got := field(2); !bytes.Equal(got, ntlmUnicode("Domain")) {
+		t.Errorf("domain = %x", got)
+	}
+	if got := field(3); !bytes.Equal(got, ntlmUnicode("User")) {
+		t.Errorf("user = %x", got)
+	}
+	if got := field(1); !bytes.Contains(got, testTargetInfo) {
+		t.Error("NTLMv2 response does not embed the target information")
+	}
+
+	if _, err := client.Next(challenge); err == nil {
+		t.Error("second Next() expected error")
+	}
+	if _, err := NewNTLMClient("user", "pw").Next([]byte("not ntlm")); err == nil {
+		t.Error("Next() with invalid challenge expected error")
+	}
+}
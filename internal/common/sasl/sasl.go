@@ -0,0 +1,127 @@
+// Package sasl implements SASL client mechanisms that the standard library and
+// github.com/emersion/go-sasl do not provide: SCRAM (RFC 5802, RFC 7677) with
+// optional TLS channel binding, and NTLMv2 as used by on-premises Exchange.
+package sasl
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Mechanism names.
+const (
+	SCRAMSHA1       = "SCRAM-SHA-1"
+	SCRAMSHA1Plus   = "SCRAM-SHA-1-PLUS"
+	SCRAMSHA256     = "SCRAM-SHA-256"
+	SCRAMSHA256Plus = "SCRAM-SHA-256-PLUS"
+	NTLM            = "NTLM"
+)
+
+// PasswordPreference lists password-based mechanisms from strongest to weakest.
+// Channel-bound SCRAM comes first because it also detects TLS interception;
+// mechanisms that send the password itself come last.
+var PasswordPreference = []string{
+	SCRAMSHA256Plus,
+	SCRAMSHA1Plus,
+	SCRAMSHA256,
+	SCRAMSHA1,
+	NTLM,
+	"CRAM-MD5",
+	"PLAIN",
+	"LOGIN",
+}
+
+// Client is a client-side SASL mechanism. It has the same method set as
+// github.com/emersion/go-sasl's Client, so implementations can be passed to
+// go-imap directly.
+type Client interface {
+	// Start returns the mechanism name and the initial response (nil if none).
+	Start() (mech string, ir []byte, err error)
+
+	// Next returns the response to a server challenge.
+	Next(challenge []byte) (response []byte, err error)
+}
+
+// Completer is implemented by mechanisms in which the server proves its
+// identity in its last message (SCRAM). Complete is called once the server
+// reports success, with the additional data of the success reply (nil if
+// none); it fails if the server's proof never arrived or is invalid, in which
+// case the success must not be trusted.
+type Completer interface {
+	Complete(data []byte) error
+}
+
+// Channel binding types (RFC 5929, RFC 9266).
+const (
+	ChannelBindingTLSUnique   = "tls-unique"
+	ChannelBindingTLSExporter = "tls-exporter"
+)
+
+// ErrNoChannelBinding is returned when a -PLUS mechanism is used without TLS.
+var ErrNoChannelBinding = errors.New("channel binding requires a TLS connection")
+
+// IsChannelBinding returns true for mechanisms that bind to the TLS channel.
+func IsChannelBinding(mechanism string) bool {
+	return strings.HasSuffix(strings.ToUpper(mechanism), "-PLUS")
+}
+
+// IsSupported returns true if this package implements the mechanism.
+func IsSupported(mechanism string) bool {
+	switch strings.ToUpper(mechanism) {
+	case SCRAMSHA1, SCRAMSHA1Plus, SCRAMSHA256, SCRAMSHA256Plus, NTLM:
+		return true
+	}
+	return false
+}
+
+// Usable removes channel-binding mechanisms from an advertised list when the
+// connection is not encrypted, since they cannot succeed without TLS.
+func Usable(mechanisms []string, tlsActive bool) []string {
+	if tlsActive {
+		return mechanisms
+	}
+	usable := make([]string, 0, len(mechanisms))
+	for _, mech := range mechanisms {
+		if !IsChannelBinding(mech) {
+			usable = append(usable, mech)
+		}
+	}
+	return usable
+}
+
+// ChannelBinding returns the channel binding type and data for a TLS
+// connection: tls-exporter for TLS 1.3 (RFC 9266) and tls-unique for
+// earlier versions (RFC 5929).
+func ChannelBinding(state *tls.ConnectionState) (string, []byte, error) {
+	if state == nil || !state.HandshakeComplete {
+		return "", nil, ErrNoChannelBinding
+	}
+
+	if state.Version == tls.VersionTLS13 {
+		data, err := state.ExportKeyingMaterial("EXPORTER-Channel-Binding", nil, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("tls-exporter channel binding: %w", err)
+		}
+		return ChannelBindingTLSExporter, data, nil
+	}
+
+	if len(state.TLSUnique) == 0 {
+		return "", nil, errors.New("tls-unique channel binding is not available for this connection")
+	}
+	return ChannelBindingTLSUnique, state.TLSUnique, nil
+}
+
+// NewClient returns the client for a mechanism implemented by this package.
+// state is the TLS connection state, required for -PLUS mechanisms;
+// advertised lists the mechanisms the server offers.
+func NewClient(mechanism, username, password string, state *tls.ConnectionState, advertised []string) (Client, error) {
+	switch strings.ToUpper(mechanism) {
+	case NTLM:
+		return NewNTLMClient(username, password), nil
+	case SCRAMSHA1, SCRAMSHA1Plus, SCRAMSHA256, SCRAMSHA256Plus:
+		return NewSCRAMClient(mechanism, username, password, state, advertised)
+	}
+	return nil, fmt.Errorf("unsupported SASL mechanism: %s", mechanism)
+}
@@ -0,0 +1,222 @@
+package sasl
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// minSCRAMIterations rejects servers that ask for a trivially cheap key
+// derivation; RFC 5802 recommends at least 4096.
+const minSCRAMIterations = 1024
+
+// scramClient implements SCRAM-SHA-1 and SCRAM-SHA-256, with and without
+// channel binding. Passwords are used as UTF-8 without SASLprep normalization.
+type scramClient struct {
+	mechanism string
+	username  string
+	password  string
+	hash      func() hash.Hash
+
+	// Channel binding (-PLUS variants only)
+	cbType string
+	cbData []byte
+
+	// cbSupported is set when the client could bind to the channel but the
+	// server did not advertise the -PLUS variant (GS2 flag "y")
+	cbSupported bool
+
+	nonce           string // Client nonce (set in tests for fixed vectors)
+	clientFirstBare string
+	serverSignature []byte
+	step            int
+}
+
+// NewSCRAMClient returns a SCRAM client for SCRAM-SHA-1, SCRAM-SHA-256 or
+// their -PLUS variants. The -PLUS variants bind to the TLS channel in state.
+//
+// Without -PLUS, a client on a TLS connection whose server does not list the
+// -PLUS variant in advertised tells the server that it supports channel
+// binding (RFC 5802 section 6). A server that does support it then fails the
+// exchange, which detects an attacker who removed -PLUS from the list.
+func NewSCRAMClient(mechanism, username, password string, state *tls.ConnectionState, advertised []string) (Client, error) {
+	c := &scramClient{
+		mechanism: strings.ToUpper(mechanism),
+		username:  username,
+		password:  password,
+	}
+
+	switch strings.TrimSuffix(c.mechanism, "-PLUS") {
+	case SCRAMSHA1:
+		c.hash = sha1.New
+	case SCRAMSHA256:
+		c.hash = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported SCRAM mechanism: %s", mechanism)
+	}
+
+	if IsChannelBinding(c.mechanism) {
+		var err error
+		if c.cbType, c.cbData, err = ChannelBinding(state); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.mechanism, err)
+		}
+		return c, nil
+	}
+
+	if _, _, err := ChannelBinding(state); err == nil {
+		c.cbSupported = true
+		for _, mech := range advertised {
+			if strings.EqualFold(mech, c.mechanism+"-PLUS") {
+				c.cbSupported = false
+			}
+		}
+	}
+	return c, nil
+}
+
+// gs2Header returns the GS2 header announcing channel binding use.
+func (c *scramClient) gs2Header() string {
+	switch {
+	case c.cbType != "":
+		return "p=" + c.cbType + ",,"
+	case c.cbSupported:
+		return "y,,"
+	}
+	return "n,,"
+}
+
+func (c *scramClient) Start() (string, []byte, error) {
+	if c.nonce == "" {
+		b := make([]byte, 18)
+		if _, err := rand.Read(b); err != nil {
+			return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		c.nonce = base64.RawStdEncoding.EncodeToString(b)
+	}
+
+	c.clientFirstBare = "n=" + scramEscape(c.username) + ",r=" + c.nonce
+	c.step = 1
+	return c.mechanism, []byte(c.gs2Header() + c.clientFirstBare), nil
+}
+
+func (c *scramClient) Next(challenge []byte) ([]byte, error) {
+	switch c.step {
+	case 1:
+		c.step = 2
+		return c.clientFinal(string(challenge))
+	case 2:
+		return nil, c.verifyServerFinal(string(challenge))
+	}
+	return nil, errors.New("SCRAM: unexpected server challenge")
+}
+
+// Complete checks the server signature when the server sent server-final-
+// message as additional data of its success reply instead of a challenge,
+// and fails if the server never sent it.
+func (c *scramClient) Complete(data []byte) error {
+	switch {
+	case c.step == 3:
+		return nil
+	case c.step != 2:
+		return errors.New("SCRAM: server reported success before the exchange completed")
+	case len(data) == 0:
+		return errors.New("SCRAM: server reported success without proving its signature")
+	}
+	return c.verifyServerFinal(string(data))
+}
+
+// clientFinal parses server-first-message and computes client-final-message.
+func (c *scramClient) clientFinal(serverFirst string) ([]byte, error) {
+	attrs := scramAttributes(serverFirst)
+	if msg, ok := attrs["e"]; ok {
+		return nil, fmt.Errorf("SCRAM: server error: %s", msg)
+	}
+
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return nil, errors.New("SCRAM: server nonce does not extend the client nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("SCRAM: invalid salt")
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil {
+		return nil, errors.New("SCRAM: invalid iteration count")
+	}
+	if iterations < minSCRAMIterations {
+		return nil, fmt.Errorf("SCRAM: iteration count %d is below the minimum of %d", iterations, minSCRAMIterations)
+	}
+
+	saltedPassword, err := pbkdf2.Key(c.hash, c.password, salt, iterations, c.hash().Size())
+	if err != nil {
+		return nil, fmt.Errorf("SCRAM: key derivation failed: %w", err)
+	}
+
+	channelBinding := base64.StdEncoding.EncodeToString(append([]byte(c.gs2Header()), c.cbData...))
+	withoutProof := "c=" + channelBinding + ",r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + serverFirst + "," + withoutProof)
+
+	clientKey := c.hmac(saltedPassword, []byte("Client Key"))
+	h := c.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+	clientSignature := c.hmac(storedKey, authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	serverKey := c.hmac(saltedPassword, []byte("Server Key"))
+	c.serverSignature = c.hmac(serverKey, authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verifyServerFinal checks the server signature, which proves the server
+// knows the password (and, for -PLUS, saw the same TLS channel), and ends
+// the exchange.
+func (c *scramClient) verifyServerFinal(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if msg, ok := attrs["e"]; ok {
+		return fmt.Errorf("SCRAM: server error: %s", msg)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || subtle.ConstantTimeCompare(signature, c.serverSignature) != 1 {
+		return errors.New("SCRAM: invalid server signature")
+	}
+	c.step = 3
+	return nil
+}
+
+func (c *scramClient) hmac(key, data []byte) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// scramAttributes splits a SCRAM message into its attribute=value pairs.
+func scramAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(msg, ",") {
+		if key, value, ok := strings.Cut(part, "="); ok && len(key) == 1 {
+			attrs[key] = value
+		}
+	}
+	return attrs
+}
+
+// scramEscape encodes ',' and '=' in a username as required by RFC 5802.
+func scramEscape(username string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(username)
+}
@@ -0,0 +1,232 @@
+package sasl
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestSCRAMClient tests the exchange against the RFC 5802 and RFC 7677 examples
+func TestSCRAMClient(t *testing.T) {
+	tests := []struct {
+		mechanism   string
+		nonce       string
+		serverFirst string
+		clientFinal string
+		serverFinal string
+	}{
+		{
+			mechanism:   SCRAMSHA1,
+			nonce:       "fyko+d2lbbFgONRv9qkxdawL",
+			serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+			clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+			serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+		},
+		{
+			mechanism:   SCRAMSHA256,
+			nonce:       "rOprNGfwEbeRWgbNEkqO",
+			serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mechanism, func(t *testing.T) {
+			client, err := NewSCRAMClient(tt.mechanism, "user", "pencil", nil, nil)
+			if err != nil {
+				t.Fatalf("NewSCRAMClient() error = %v", err)
+			}
+			client.(*scramClient).nonce = tt.nonce
+
+			mech, ir, err := client.Start()
+			if err != nil || mech != tt.mechanism {
+				t.Fatalf("Start() = %q, %v", mech, err)
+			}
+			if want := "n,,n=user,r=" + tt.nonce; string(ir) != want {
+				t.Errorf("client-first = %q, want %q", ir, want)
+			}
+
+			resp, err := client.Next([]byte(tt.serverFirst))
+			if err != nil {
+				t.Fatalf("Next(server-first) error = %v", err)
+			}
+			if string(resp) != tt.clientFinal {
+				t.Errorf("client-final = %q, want %q", resp, tt.clientFinal)
+			}
+
+			if _, err := client.Next([]byte(tt.serverFinal)); err != nil {
+				t.Errorf("Next(server-final) error = %v", err)
+			}
+			if err := client.(Completer).Complete(nil); err != nil {
+				t.Errorf("Complete() after server-final error = %v", err)
+			}
+		})
+	}
+}
+
+// TestSCRAMComplete tests server-final-message sent with the success reply
+func TestSCRAMComplete(t *testing.T) {
+	const (
+		nonce       = "rOprNGfwEbeRWgbNEkqO"
+		serverFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	)
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "Signature with success", data: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="},
+		{name: "Success without signature", data: "", wantErr: true},
+		{name: "Forged signature with success", data: "v=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := NewSCRAMClient(SCRAMSHA256, "user", "pencil", nil, nil)
+			client.(*scramClient).nonce = nonce
+			_, _, _ = client.Start()
+			if _, err := client.Next([]byte(serverFirst)); err != nil {
+				t.Fatalf("Next(server-first) error = %v", err)
+			}
+
+			var data []byte
+			if tt.data != "" {
+				data = []byte(tt.data)
+			}
+			err := client.(Completer).Complete(data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Complete(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+
+	client, _ := NewSCRAMClient(SCRAMSHA256, "user", "pencil", nil, nil)
+	_, _, _ = client.Start()
+	if err := client.(Completer).Complete(nil); err == nil {
+		t.Error("Complete() before server-first expected error")
+	}
+}
+
+// TestSCRAMClientRejects tests that malformed or forged server messages fail
+func TestSCRAMClientRejects(t *testing.T) {
+	const nonce = "rOprNGfwEbeRWgbNEkqO"
+	tests := map[string][2]string{
+		"Server error":        {"e=unknown-user", ""},
+		"Nonce not extended":  {"r=" + nonce + ",s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", ""},
+		"Foreign nonce":       {"r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", ""},
+		"Low iteration count": {"r=" + nonce + "x,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=1", ""},
+		"Forged server signature": {
+			"r=" + nonce + "%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			"v=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		},
+	}
+
+	for name, msgs := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, _ := NewSCRAMClient(SCRAMSHA256, "user", "pencil", nil, nil)
+			client.(*scramClient).nonce = nonce
+			_, _, _ = client.Start()
+
+			_, err := client.Next([]byte(msgs[0]))
+			if msgs[1] != "" {
+				if err != nil {
+					t.Fatalf("Next(server-first) error = %v", err)
+				}
+				_, err = client.Next([]byte(msgs[1]))
+			}
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestSCRAMChannelBinding tests -PLUS variants over real TLS connections
+func TestSCRAMChannelBinding(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := NewSCRAMClient(SCRAMSHA256Plus, "user", "pencil", nil, nil); err == nil {
+		t.Error("NewSCRAMClient(-PLUS) without TLS expected error")
+	}
+
+	for version, wantType := range map[uint16]string{
+		tls.VersionTLS12: ChannelBindingTLSUnique,
+		tls.VersionTLS13: ChannelBindingTLSExporter,
+	} {
+		t.Run(wantType, func(t *testing.T) {
+			conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+				InsecureSkipVerify: true,
+				MinVersion:         version,
+				MaxVersion:         version,
+			})
+			if err != nil {
+				t.Fatalf("tls.Dial() error = %v", err)
+			}
+			defer conn.Close()
+			state := conn.ConnectionState()
+
+			cbType, cbData, err := ChannelBinding(&state)
+			if err != nil || cbType != wantType || len(cbData) == 0 {
+				t.Fatalf("ChannelBinding() = %q, %x, %v", cbType, cbData, err)
+			}
+
+			client, err := NewSCRAMClient(SCRAMSHA256Plus, "user", "pencil", &state, nil)
+			if err != nil {
+				t.Fatalf("NewSCRAMClient() error = %v", err)
+			}
+			client.(*scramClient).nonce = "abc"
+			_, ir, _ := client.Start()
+			if want := "p=" + wantType + ",,"; !strings.HasPrefix(string(ir), want) {
+				t.Errorf("client-first = %q, want prefix %q", ir, want)
+			}
+
+			resp, err := client.Next([]byte("r=abcdef,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+			if err != nil {
+				t.Fatalf("Next() error = %v", err)
+			}
+			cb, _ := base64.StdEncoding.DecodeString(scramAttributes(string(resp))["c"])
+			if want := "p=" + wantType + ",," + string(cbData); string(cb) != want {
+				t.Errorf("channel binding attribute = %q, want %q", cb, want)
+			}
+
+			// Without -PLUS, the GS2 flag says whether the server advertised it
+			for flag, advertised := range map[string][]string{
+				"y": {SCRAMSHA256},
+				"n": {SCRAMSHA256, SCRAMSHA256Plus},
+			} {
+				client, err := NewSCRAMClient(SCRAMSHA256, "user", "pencil", &state, advertised)
+				if err != nil {
+					t.Fatalf("NewSCRAMClient() error = %v", err)
+				}
+				client.(*scramClient).nonce = "abc"
+				_, ir, _ := client.Start()
+				if want := flag + ",,n=user"; !strings.HasPrefix(string(ir), want) {
+					t.Errorf("advertised %q: client-first = %q, want prefix %q", advertised, ir, want)
+				}
+				resp, err := client.Next([]byte("r=abcdef,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if got, want := scramAttributes(string(resp))["c"], base64.StdEncoding.EncodeToString([]byte(flag+",,")); got != want {
+					t.Errorf("advertised %q: channel binding attribute = %q, want %q", advertised, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestUsable tests filtering of channel-binding mechanisms
+func TestUsable(t *testing.T) {
+	advertised := []string{"SCRAM-SHA-256-PLUS", "SCRAM-SHA-256", "PLAIN"}
+	if got := Usable(advertised, true); len(got) != 3 {
+		t.Errorf("Usable(tls) = %v", got)
+	}
+	if got := Usable(advertised, false); strings.Join(got, ",") != "SCRAM-SHA-256,PLAIN" {
+		t.Errorf("Usable(plain) = %v", got)
+	}
+}
@@ -0,0 +1,339 @@
+// Package timing breaks the duration of a protocol test down into phases (DNS
+// lookup, TCP connect, TLS handshake, greeting, capabilities, authentication
+// and transaction), so a slow test shows where the time went.
+//
+// A Recorder travels in the context, like an httptrace.ClientTrace: a tool
+// attaches one per action with WithRecorder, and the protocol clients record
+// into FromContext(ctx). Recording into a nil Recorder does nothing, so
+// clients record unconditionally.
+package timing
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Phase is one part of a connection.
+type Phase int
+
+const (
+	DNS          Phase = iota // Host name lookup
+	TCP                       // TCP connect (including the proxy handshake through -proxy)
+	TLS                       // TLS handshake, including the STARTTLS/STLS command
+	Greeting                  // Time to the server's banner or greeting
+	Capabilities              // EHLO, CAPABILITY, CAPA or the JMAP session
+	Auth                      // Authentication exchange
+	Transaction               // Protocol commands of the action itself
+	numPhases
+)
+
+var phaseNames = [numPhases]string{
+	"DNS lookup", "TCP connect", "TLS handshake", "Greeting", "Capabilities", "Authentication", "Transaction",
+}
+
+var phaseColumns = [numPhases]string{
+	"DNS_ms", "TCP_ms", "TLS_ms", "Greeting_ms", "Capabilities_ms", "Auth_ms", "Transaction_ms",
+}
+
+// String returns the display name of the phase.
+func (p Phase) String() string {
+	if p < 0 || p >= numPhases {
+		return fmt.Sprintf("Phase(%d)", int(p))
+	}
+	return phaseNames[p]
+}
+
+// Step is one command of the transaction phase.
+type Step struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Recorder collects the phase durations of a connection. Durations of a
+// phase measured more than once (EHLO before and after STARTTLS) add up.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu        sync.Mutex
+	durations [numPhases]time.Duration
+	measured  [numPhases]bool
+	steps     []Step
+	dialStart time.Time
+	resolved  time.Time // End of the lookup of the dial in progress
+}
+
+// Add adds d to phase p.
+func (r *Recorder) Add(p Phase, d time.Duration) {
+	if r == nil || p < 0 || p >= numPhases {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.durations[p] += d
+	r.measured[p] = true
+}
+
+// Since adds the time elapsed since start to phase p.
+func (r *Recorder) Since(p Phase, start time.Time) {
+	r.Add(p, time.Since(start))
+}
+
+// Step records one transaction command. Steps are details for the text
+// output; the caller adds the transaction's total to the Transaction phase,
+// since pipelined commands overlap.
+func (r *Recorder) Step(name string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, Step{Name: name, Duration: d})
+}
+
+// Reset discards everything recorded, for a client that opens a new
+// connection: the recorder then describes the last connection.
+func (r *Recorder) Reset() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.durations = [numPhases]time.Duration{}
+	r.measured = [numPhases]bool{}
+	r.steps = nil
+}
+
+// Duration returns the time recorded for phase p, and whether it was measured.
+func (r *Recorder) Duration(p Phase) (time.Duration, bool) {
+	if r == nil || p < 0 || p >= numPhases {
+		return 0, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.durations[p], r.measured[p]
+}
+
+// Steps returns the recorded transaction steps in order.
+func (r *Recorder) Steps() []Step {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Step(nil), r.steps...)
+}
+
+// Empty reports whether no phase was measured.
+func (r *Recorder) Empty() bool {
+	if r == nil {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, measured := range r.measured {
+		if measured {
+			return false
+		}
+	}
+	return true
+}
+
+// Columns returns the log column names of the phases, in Phase order.
+func Columns() []string {
+	return append([]string(nil), phaseColumns[:]...)
+}
+
+// Values returns the phase durations in whole milliseconds for the log
+// columns, empty for phases that were not measured.
+func (r *Recorder) Values() []string {
+	values := make([]string, numPhases)
+	for p := Phase(0); p < numPhases; p++ {
+		if d, ok := r.Duration(p); ok {
+			values[p] = fmt.Sprintf("%d", d.Milliseconds())
+		}
+	}
+	return values
+}
+
+// Print writes the measured phases, the transaction steps and their total.
+func (r *Recorder) Print(w io.Writer) {
+	if r.Empty() {
+		return
+	}
+	fmt.Fprintln(w, "\nTiming:")
+	var total time.Duration
+	for p := Phase(0); p < numPhases; p++ {
+		d, ok := r.Duration(p)
+		if !ok {
+			continue
+		}
+		total += d
+		fmt.Fprintf(w, "  %-40s %10s\n", p, formatDuration(d))
+		if p == Transaction {
+			for _, step := range r.Steps() {
+				fmt.Fprintf(w, "    %-38s %10s\n", truncate(step.Name, 38), formatDuration(step.Duration))
+			}
+		}
+	}
+	fmt.Fprintf(w, "  %-40s %10s\n", "Total", formatDuration(total))
+}
+
+// formatDuration rounds d for display: microseconds below a millisecond,
+// otherwise a tenth of a millisecond.
+func formatDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(100 * time.Microsecond).String()
+}
+
+// truncate shortens long step names such as RCPT TO with a long address.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+type contextKey struct{}
+
+// WithRecorder returns a copy of ctx that carries r.
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the Recorder carried by ctx, or nil.
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(contextKey{}).(*Recorder)
+	return r
+}
+
+// ContextDialer is the dialer interface of net.Dialer and proxy.Dialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Instrument hooks forward so that dials through Dial can tell the DNS
+// lookup from the TCP connect: net.Dialer calls ControlContext once the
+// address is resolved, just before connecting. Any existing hook still runs.
+// forward is modified and returned.
+func Instrument(forward *net.Dialer) *net.Dialer {
+	control := forward.ControlContext
+	if control == nil && forward.Control != nil {
+		plain := forward.Control
+		control = func(_ context.Context, network, address string, c syscall.RawConn) error {
+			return plain(network, address, c)
+		}
+	}
+	forward.ControlContext = func(ctx context.Context, network, address string, c syscall.RawConn) error {
+		FromContext(ctx).markResolved()
+		if control != nil {
+			return control(ctx, network, address, c)
+		}
+		return nil
+	}
+	return forward
+}
+
+// Dial dials address with d and records the DNS and TCP phases in the
+// context's Recorder, also when the dial fails. Without an Instrument hook
+// on the dialer that opens the connection, the whole dial counts as TCP.
+// Through a proxy, DNS is the lookup of the proxy host and TCP includes the
+// proxy handshake; the target is resolved by the proxy.
+func Dial(ctx context.Context, d ContextDialer, network, address string) (net.Conn, error) {
+	r := FromContext(ctx)
+	r.startDial()
+	conn, err := d.DialContext(ctx, network, address)
+	r.endDial()
+	return conn, err
+}
+
+func (r *Recorder) startDial() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dialStart = time.Now()
+	r.resolved = time.Time{}
+}
+
+// markResolved records the end of the lookup; only the first connection
+// attempt of a dial counts.
+func (r *Recorder) markResolved() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dialStart.IsZero() && r.resolved.IsZero() {
+		r.resolved = time.Now()
+	}
+}
+
+func (r *Recorder) endDial() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	start, resolved := r.dialStart, r.resolved
+	r.dialStart = time.Time{}
+	r.mu.Unlock()
+	if start.IsZero() {
+		return
+	}
+	if resolved.IsZero() {
+		r.Add(TCP, time.Since(start))
+		return
+	}
+	r.Add(DNS, resolved.Sub(start))
+	r.Add(TCP, time.Since(resolved))
+}
+
+// ClientTrace returns an httptrace.ClientTrace that records the DNS lookup,
+// TCP connect and TLS handshake of new HTTP connections. Reused connections
+// record nothing.
+func (r *Recorder) ClientTrace() *httptrace.ClientTrace {
+	var mu sync.Mutex
+	var dnsStart, connectStart, tlsStart time.Time
+	started := func(t *time.Time) {
+		mu.Lock()
+		*t = time.Now()
+		mu.Unlock()
+	}
+	done := func(p Phase, t *time.Time) {
+		mu.Lock()
+		start := *t
+		*t = time.Time{}
+		mu.Unlock()
+		if !start.IsZero() {
+			r.Since(p, start)
+		}
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { started(&dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { done(DNS, &dnsStart) },
+		ConnectStart:      func(string, string) { started(&connectStart) },
+		ConnectDone:       func(string, string, error) { done(TCP, &connectStart) },
+		TLSHandshakeStart: func() { started(&tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { done(TLS, &tlsStart) },
+	}
+}
+
+// String returns the measured phases on one line, e.g. for log messages.
+func (r *Recorder) String() string {
+	var parts []string
+	for p := Phase(0); p < numPhases; p++ {
+		if d, ok := r.Duration(p); ok {
+			parts = append(parts, fmt.Sprintf("%s=%s", strings.TrimSuffix(phaseColumns[p], "_ms"), formatDuration(d)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,127 @@
+package timing
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	r := &Recorder{}
+	if !r.Empty() {
+		t.Error("new recorder is not empty")
+	}
+
+	r.Add(TCP, 20*time.Millisecond)
+	r.Add(Capabilities, 3*time.Millisecond)
+	r.Add(Capabilities, 4*time.Millisecond)
+	r.Add(Transaction, 12*time.Millisecond)
+	r.Step("MAIL FROM:<alice@example.com>", 5*time.Millisecond)
+	r.Step("RCPT TO:<bob@example.com>", 7*time.Millisecond)
+
+	if d, ok := r.Duration(Capabilities); !ok || d != 7*time.Millisecond {
+		t.Errorf("Duration(Capabilities) = %v, %v, want 7ms, true", d, ok)
+	}
+	want := []string{"", "20", "", "", "7", "", "12"}
+	if got := r.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %q, want %q", got, want)
+	}
+	if got := len(Columns()); got != len(want) {
+		t.Errorf("len(Columns()) = %d, want %d", got, len(want))
+	}
+	if got, want := r.String(), "TCP=20ms Capabilities=7ms Transaction=12ms"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	r.Print(&buf)
+	out := buf.String()
+	for _, line := range []string{"TCP connect", "Capabilities", "RCPT TO:<bob@example.com>", "Total"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Print() output lacks %q:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "DNS lookup") {
+		t.Errorf("Print() shows a phase that was not measured:\n%s", out)
+	}
+	if !strings.Contains(out, "39ms") {
+		t.Errorf("Print() total is not 39ms:\n%s", out)
+	}
+
+	r.Reset()
+	if !r.Empty() || len(r.Steps()) != 0 {
+		t.Error("Reset() kept measurements")
+	}
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var r *Recorder
+	r.Add(TCP, time.Second)
+	r.Step("QUIT", time.Second)
+	r.Reset()
+	if !r.Empty() || r.String() != "" {
+		t.Error("nil recorder reports measurements")
+	}
+	if got := r.Values(); len(got) != len(Columns()) {
+		t.Errorf("Values() = %q, want one empty value per column", got)
+	}
+
+	var buf bytes.Buffer
+	r.Print(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("Print() on nil recorder wrote %q", buf.String())
+	}
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext() without recorder is not nil")
+	}
+}
+
+func TestDial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	r := &Recorder{}
+	ctx := WithRecorder(context.Background(), r)
+	conn, err := Dial(ctx, Instrument(&net.Dialer{Timeout: 5 * time.Second}), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	conn.Close()
+
+	if _, ok := r.Duration(TCP); !ok {
+		t.Error("TCP connect was not recorded")
+	}
+	if _, ok := r.Duration(DNS); !ok {
+		t.Error("DNS lookup was not recorded by the instrumented dialer")
+	}
+}
+
+func TestDial_Refused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	r := &Recorder{}
+	ctx := WithRecorder(context.Background(), r)
+	if _, err := Dial(ctx, &net.Dialer{Timeout: 5 * time.Second}, "tcp", address); err == nil {
+		t.Fatal("Dial() to a closed port succeeded")
+	}
+	if _, ok := r.Duration(TCP); !ok {
+		t.Error("failed connect was not recorded")
+	}
+}
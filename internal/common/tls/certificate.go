@@ -4,6 +4,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"strings"
@@ -29,12 +30,36 @@ type CertificateInfo struct {
 	DaysUntilExpiry     int       // Days until expiration (negative if expired)
 	IsExpired           bool      // Certificate has expired
 	IsSelfSigned        bool      // Certificate is self-signed
+
+	// Revocation and Certificate Transparency
+	OCSPServers           []string            // OCSP responder URLs (Authority Information Access)
+	CRLDistributionPoints []string            // CRL URLs
+	OCSPStaple            *RevocationStatus   // Stapled OCSP response (nil if none was stapled)
+	Revocation            []*RevocationStatus // Online OCSP and CRL checks (see CheckRevocation)
+	SCTs                  []SCT               // Signed Certificate Timestamps (embedded and TLS extension)
+	SCTError              string              // Why the embedded SCT list could not be parsed
+}
+
+// IsRevoked reports whether the stapled response or an online check found
+// the certificate revoked.
+func (c *CertificateInfo) IsRevoked() bool {
+	if c.OCSPStaple != nil && c.OCSPStaple.Status == RevocationRevoked {
+		return true
+	}
+	for _, r := range c.Revocation {
+		if r.Status == RevocationRevoked {
+			return true
+		}
+	}
+	return false
 }
 
-// AnalyzeCertificateChain analyzes an entire certificate chain and returns detailed information.
-// The first certificate in the chain should be the leaf (server) certificate.
-// Returns information about the leaf certificate and chain details.
-func AnalyzeCertificateChain(certs []*x509.Certificate, hostname string) *CertificateInfo {
+// AnalyzeCertificateChain analyzes the certificate chain presented on a
+// connection and returns detailed information about the leaf (server)
+// certificate, including its stapled OCSP response and SCTs. Online
+// revocation checks are separate (see CheckRevocation).
+func AnalyzeCertificateChain(state *tls.ConnectionState, hostname string) *CertificateInfo {
+	certs := state.PeerCertificates
 	if len(certs) == 0 {
 		return &CertificateInfo{
 			VerificationStatus: "no_certificates",
@@ -75,7 +100,7 @@ func AnalyzeCertificateChain(certs []*x509.Certificate, hostname string) *Certif
 	// Determine public key size
 	publicKeySize := getPublicKeySize(leafCert)
 
-	return &CertificateInfo{
+	info := &CertificateInfo{
 		Subject:             leafCert.Subject.String(),
 		Issuer:              leafCert.Issuer.String(),
 		SerialNumber:        fmt.Sprintf("%X", leafCert.SerialNumber),
@@ -93,7 +118,34 @@ func AnalyzeCertificateChain(certs []*x509.Certificate, hostname string) *Certif
 		DaysUntilExpiry:     daysUntilExpiry,
 		IsExpired:           isExpired,
 		IsSelfSigned:        isSelfSigned,
+
+		OCSPServers:           leafCert.OCSPServer,
+		CRLDistributionPoints: leafCert.CRLDistributionPoints,
+	}
+
+	// Stapled OCSP response; its signature can only be checked with the issuer
+	if len(state.OCSPResponse) > 0 {
+		var issuer *x509.Certificate
+		if len(certs) > 1 {
+			issuer = certs[1]
+		}
+		info.OCSPStaple = ParseOCSPStaple(state.OCSPResponse, leafCert, issuer)
 	}
+
+	// Certificate Transparency: SCTs embedded in the certificate and sent in the handshake
+	scts, err := EmbeddedSCTs(leafCert)
+	if err != nil {
+		info.SCTError = err.Error()
+	}
+	info.SCTs = scts
+	for _, raw := range state.SignedCertificateTimestamps {
+		if sct, err := ParseSCT(raw); err == nil {
+			sct.Source = SCTSourceTLS
+			info.SCTs = append(info.SCTs, sct)
+		}
+	}
+
+	return info
 }
 
 // extractSANs extracts Subject Alternative Names from a certificate.
@@ -194,6 +246,27 @@ func extractExtKeyUsage(cert *x509.Certificate) []string {
 	return usage
 }
 
+// VerifyCertificateChain verifies the presented chain against roots (the
+// system pool when nil) and checks that the leaf is valid for hostname.
+// The first certificate is the leaf; the rest are used as intermediates.
+func VerifyCertificateChain(certs []*x509.Certificate, hostname string, roots *x509.CertPool) error {
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificates presented")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       hostname,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
 // verifyHostname checks if the certificate is valid for the given hostname.
 func verifyHostname(cert *x509.Certificate, hostname string) string {
 	// Try to verify hostname
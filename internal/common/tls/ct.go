@@ -0,0 +1,105 @@
+package tls
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// oidEmbeddedSCTList identifies the precertificate SCT list extension (RFC 6962 section 3.3).
+var oidEmbeddedSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// SCT sources.
+const (
+	SCTSourceEmbedded = "embedded" // X.509 extension in the certificate
+	SCTSourceTLS      = "tls"      // signed_certificate_timestamp TLS extension
+)
+
+var errMalformedSCT = errors.New("malformed signed certificate timestamp")
+
+// SCT is a Signed Certificate Timestamp: a Certificate Transparency log's
+// promise to publish the certificate. Signatures are not verified, since
+// that requires the log's public key from a trusted log list.
+type SCT struct {
+	LogID     string    // Base64 SHA-256 hash of the log's public key
+	Timestamp time.Time // When the log saw the certificate
+	Source    string    // embedded or tls
+}
+
+// EmbeddedSCTs returns the SCTs embedded in a certificate, if any.
+func EmbeddedSCTs(cert *x509.Certificate) ([]SCT, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidEmbeddedSCTList) {
+			continue
+		}
+		var list []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &list); err != nil || len(rest) > 0 {
+			return nil, errMalformedSCT
+		}
+		scts, err := ParseSCTList(list)
+		for i := range scts {
+			scts[i].Source = SCTSourceEmbedded
+		}
+		return scts, err
+	}
+	return nil, nil
+}
+
+// ParseSCTList parses a TLS-encoded SignedCertificateTimestampList (RFC 6962 section 3.3).
+func ParseSCTList(data []byte) ([]SCT, error) {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) || !s.Empty() {
+		return nil, errMalformedSCT
+	}
+
+	var scts []SCT
+	for !list.Empty() {
+		var raw cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&raw) {
+			return nil, errMalformedSCT
+		}
+		sct, err := ParseSCT(raw)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+// ParseSCT parses a single TLS-encoded v1 SignedCertificateTimestamp, the
+// format of each entry in tls.ConnectionState.SignedCertificateTimestamps.
+func ParseSCT(data []byte) (SCT, error) {
+	s := cryptobyte.String(data)
+	var version, hashAlg, sigAlg uint8
+	var logID []byte
+	var timestamp uint64
+	var extensions, signature cryptobyte.String
+
+	if !s.ReadUint8(&version) {
+		return SCT{}, errMalformedSCT
+	}
+	if version != 0 {
+		return SCT{}, fmt.Errorf("unsupported SCT version %d", version+1)
+	}
+	if !s.ReadBytes(&logID, 32) ||
+		!s.ReadUint64(&timestamp) ||
+		!s.ReadUint16LengthPrefixed(&extensions) ||
+		!s.ReadUint8(&hashAlg) ||
+		!s.ReadUint8(&sigAlg) ||
+		!s.ReadUint16LengthPrefixed(&signature) ||
+		!s.Empty() {
+		return SCT{}, errMalformedSCT
+	}
+
+	return SCT{
+		LogID:     base64.StdEncoding.EncodeToString(logID),
+		Timestamp: time.UnixMilli(int64(timestamp)).UTC(),
+	}, nil
+}
@@ -0,0 +1,141 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// buildSCT encodes a v1 SCT with a fixed log ID and signature.
+func buildSCT(logID byte, timestamp time.Time) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(0) // v1
+	b.AddBytes(bytes.Repeat([]byte{logID}, 32))
+	b.AddUint64(uint64(timestamp.UnixMilli()))
+	b.AddUint16LengthPrefixed(func(*cryptobyte.Builder) {}) // No extensions
+	b.AddUint8(4)                                           // SHA-256
+	b.AddUint8(3)                                           // ECDSA
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte{0x30, 0x00}) })
+	return b.BytesOrPanic()
+}
+
+func buildSCTList(scts ...[]byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sct) })
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+func TestParseSCTList(t *testing.T) {
+	ts := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	scts, err := ParseSCTList(buildSCTList(buildSCT(1, ts), buildSCT(2, ts.Add(time.Second))))
+	if err != nil {
+		t.Fatalf("ParseSCTList() error: %v", err)
+	}
+	if len(scts) != 2 {
+		t.Fatalf("got %d SCTs, want 2", len(scts))
+	}
+	if want := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)); scts[0].LogID != want {
+		t.Errorf("LogID = %q, want %q", scts[0].LogID, want)
+	}
+	if !scts[0].Timestamp.Equal(ts) || !scts[1].Timestamp.Equal(ts.Add(time.Second)) {
+		t.Errorf("timestamps = %v, %v", scts[0].Timestamp, scts[1].Timestamp)
+	}
+
+	valid := buildSCT(1, ts)
+	malformed := map[string][]byte{
+		"empty":          nil,
+		"truncated list": buildSCTList(valid)[:20],
+		"trailing data":  append(buildSCTList(valid), 0),
+	}
+	for name, data := range malformed {
+		if _, err := ParseSCTList(data); err == nil {
+			t.Errorf("ParseSCTList(%s) succeeded, want error", name)
+		}
+	}
+
+	v2 := append([]byte{1}, valid[1:]...)
+	if _, err := ParseSCT(v2); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("ParseSCT(v2) error = %v, want unsupported version", err)
+	}
+}
+
+func TestAnalyzeCertificateChain_SCTs(t *testing.T) {
+	ts := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	extValue, err := asn1.Marshal(buildSCTList(buildSCT(1, ts)))
+	if err != nil {
+		t.Fatalf("asn1.Marshal() error: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "mail.example.com"},
+		Issuer:          pkix.Name{CommonName: "mail.example.com"},
+		DNSNames:        []string{"mail.example.com"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidEmbeddedSCTList, Value: extValue}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	state := &tls.ConnectionState{
+		PeerCertificates:            []*x509.Certificate{cert},
+		SignedCertificateTimestamps: [][]byte{buildSCT(2, ts), []byte("garbage")},
+	}
+	info := AnalyzeCertificateChain(state, "mail.example.com")
+	if info.SCTError != "" {
+		t.Fatalf("SCTError = %q", info.SCTError)
+	}
+	if len(info.SCTs) != 2 {
+		t.Fatalf("got %d SCTs, want 2 (invalid TLS SCT skipped)", len(info.SCTs))
+	}
+	if info.SCTs[0].Source != SCTSourceEmbedded || info.SCTs[1].Source != SCTSourceTLS {
+		t.Errorf("sources = %q, %q", info.SCTs[0].Source, info.SCTs[1].Source)
+	}
+}
+
+func TestCheckTLSWarnings_CertificateTransparency(t *testing.T) {
+	tlsInfo := &TLSInfo{Version: "TLS 1.3", CipherSuiteStrength: "strong"}
+	now := time.Now()
+	certInfo := &CertificateInfo{ValidFrom: now, ValidTo: now.Add(90 * 24 * time.Hour), DaysUntilExpiry: 90, ChainLength: 2}
+
+	warnings := strings.Join(CheckTLSWarnings(tlsInfo, certInfo, false), "\n")
+	if !strings.Contains(warnings, "No Signed Certificate Timestamps") {
+		t.Errorf("warnings %q missing CT warning", warnings)
+	}
+
+	certInfo.SCTs = []SCT{{LogID: "AQ==", Timestamp: now, Source: SCTSourceEmbedded}}
+	if warnings := CheckTLSWarnings(tlsInfo, certInfo, false); len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %q", warnings)
+	}
+
+	// Self-signed certificates are never logged
+	certInfo.SCTs, certInfo.IsSelfSigned = nil, true
+	warnings = strings.Join(CheckTLSWarnings(tlsInfo, certInfo, false), "\n")
+	if strings.Contains(warnings, "Certificate Transparency") {
+		t.Errorf("self-signed certificate got CT warning: %q", warnings)
+	}
+}
@@ -0,0 +1,211 @@
+package tls
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ChainCapture records the certificates a server presents during the
+// handshake, also when verification fails, so they can be saved with Save.
+type ChainCapture struct {
+	State       *tls.ConnectionState  // Handshake state when the certificates arrived (nil until then)
+	ServerName  string                // Name the chain was verified against
+	Verified    [][]*x509.Certificate // Chains from the leaf to a trusted root
+	VerifyError error                 // Why no chain could be built
+}
+
+// Apply makes cfg record the peer chain. Verification moves from crypto/tls
+// into VerifyConnection, so the chain is captured before a verification
+// error aborts the handshake; cfg.RootCAs and cfg.ServerName (or the SNI
+// name when it is empty) are used as crypto/tls would. With
+// InsecureSkipVerify, chains are still built for the export but failures
+// are ignored. Call Apply after TrustOptions.Apply.
+func (c *ChainCapture) Apply(cfg *tls.Config) {
+	enforce := !cfg.InsecureSkipVerify
+	cfg.InsecureSkipVerify = true
+
+	next := cfg.VerifyConnection
+	cfg.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("server presented no certificates")
+		}
+		c.State = &state
+		c.ServerName = cfg.ServerName
+		if c.ServerName == "" {
+			c.ServerName = state.ServerName
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		c.Verified, c.VerifyError = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       c.ServerName,
+			Roots:         cfg.RootCAs,
+			Intermediates: intermediates,
+		})
+		if c.VerifyError != nil && enforce {
+			return &tls.CertificateVerificationError{UnverifiedCertificates: state.PeerCertificates, Err: c.VerifyError}
+		}
+
+		if next != nil {
+			return next(state)
+		}
+		return nil
+	}
+}
+
+// Save writes the captured chain to dir (see SaveChain). It fails if the
+// server never sent its certificates.
+func (c *ChainCapture) Save(dir, name string, info *CertificateInfo) ([]string, error) {
+	if c.State == nil {
+		return nil, errors.New("no certificates were captured")
+	}
+	if info == nil {
+		info = AnalyzeCertificateChain(c.State, c.ServerName)
+	}
+	return SaveChain(dir, name, c.State, c.Verified, c.VerifyError, info)
+}
+
+// SaveAndPrint saves the captured chain and prints where it was written or
+// why it was not. Saving is best effort and never fails the action.
+func (c *ChainCapture) SaveAndPrint(w io.Writer, dir, name string) {
+	files, err := c.Save(dir, name, nil)
+	if err != nil {
+		fmt.Fprintf(w, "⚠ Certificate chain not saved: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "✓ Certificate chain saved to %s (%d files)\n", dir, len(files))
+}
+
+// exportedCertificate describes one saved certificate in the chain summary.
+type exportedCertificate struct {
+	PEMFile      string
+	DERFile      string
+	Subject      string
+	Issuer       string
+	SerialNumber string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	SHA256       string // Fingerprint of the DER encoding
+}
+
+// chainSummary is the JSON file written next to the certificates.
+type chainSummary struct {
+	Name           string
+	ServerName     string
+	CapturedAt     time.Time
+	TLSVersion     string
+	CipherSuite    string
+	Peer           []exportedCertificate   // As presented by the server
+	VerifiedChains [][]exportedCertificate // Leaf to trusted root
+	VerifyError    string                  `json:",omitempty"`
+	Certificate    *CertificateInfo        // Leaf analysis
+}
+
+// SaveChain writes every presented certificate and every certificate of the
+// verified chains to dir as PEM and DER files, plus a JSON summary with
+// the leaf analysis. Files are named after name (e.g. host and port):
+// <name>-peer-<i>, <name>-verified-<chain>-<i> and <name>-summary.json.
+// It returns the paths of the files written.
+func SaveChain(dir, name string, state *tls.ConnectionState, verified [][]*x509.Certificate, verifyErr error, info *CertificateInfo) ([]string, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no certificates were presented")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create chain directory: %w", err)
+	}
+	name = safeFileName(name)
+	if info == nil {
+		info = AnalyzeCertificateChain(state, state.ServerName)
+	}
+
+	summary := chainSummary{
+		Name:        name,
+		ServerName:  state.ServerName,
+		CapturedAt:  time.Now().UTC(),
+		TLSVersion:  TLSVersionString(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		Certificate: info,
+	}
+	if verifyErr != nil {
+		summary.VerifyError = verifyErr.Error()
+	}
+
+	var files []string
+	write := func(cert *x509.Certificate, base string) (exportedCertificate, error) {
+		pemPath := filepath.Join(dir, base+".pem")
+		derPath := filepath.Join(dir, base+".der")
+		if err := os.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+			return exportedCertificate{}, fmt.Errorf("failed to write certificate: %w", err)
+		}
+		if err := os.WriteFile(derPath, cert.Raw, 0644); err != nil {
+			return exportedCertificate{}, fmt.Errorf("failed to write certificate: %w", err)
+		}
+		files = append(files, pemPath, derPath)
+
+		fingerprint := sha256.Sum256(cert.Raw)
+		return exportedCertificate{
+			PEMFile:      filepath.Base(pemPath),
+			DERFile:      filepath.Base(derPath),
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: fmt.Sprintf("%X", cert.SerialNumber),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+			SHA256:       hex.EncodeToString(fingerprint[:]),
+		}, nil
+	}
+
+	for i, cert := range state.PeerCertificates {
+		exported, err := write(cert, fmt.Sprintf("%s-peer-%d", name, i))
+		if err != nil {
+			return files, err
+		}
+		summary.Peer = append(summary.Peer, exported)
+	}
+	for i, chain := range verified {
+		var exportedChain []exportedCertificate
+		for j, cert := range chain {
+			exported, err := write(cert, fmt.Sprintf("%s-verified-%d-%d", name, i, j))
+			if err != nil {
+				return files, err
+			}
+			exportedChain = append(exportedChain, exported)
+		}
+		summary.VerifiedChains = append(summary.VerifiedChains, exportedChain)
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return files, fmt.Errorf("failed to encode chain summary: %w", err)
+	}
+	summaryPath := filepath.Join(dir, name+"-summary.json")
+	if err := os.WriteFile(summaryPath, append(data, '\n'), 0644); err != nil {
+		return files, fmt.Errorf("failed to write chain summary: %w", err)
+	}
+	return append(files, summaryPath), nil
+}
+
+// safeFileName replaces characters that are not safe in file names, such
+// as the colons of an IPv6 address.
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
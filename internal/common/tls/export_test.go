@@ -0,0 +1,127 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChainCapture(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	t.Run("verification failure is captured", func(t *testing.T) {
+		capture := &ChainCapture{}
+		cfg := &tls.Config{ServerName: "127.0.0.1"}
+		capture.Apply(cfg)
+		if _, err := tls.Dial("tcp", addr, cfg); err == nil {
+			t.Fatal("Dial() succeeded with an untrusted certificate, want error")
+		}
+		if capture.State == nil || len(capture.State.PeerCertificates) != 1 {
+			t.Fatalf("State = %+v, want the presented certificate", capture.State)
+		}
+		if capture.VerifyError == nil || len(capture.Verified) != 0 {
+			t.Errorf("Verified = %d chains, VerifyError = %v", len(capture.Verified), capture.VerifyError)
+		}
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		capture := &ChainCapture{}
+		cfg := &tls.Config{ServerName: "127.0.0.1", RootCAs: roots}
+		capture.Apply(cfg)
+		conn, err := tls.Dial("tcp", addr, cfg)
+		if err != nil {
+			t.Fatalf("Dial() error: %v", err)
+		}
+		conn.Close()
+		if capture.VerifyError != nil || len(capture.Verified) != 1 {
+			t.Errorf("Verified = %d chains, VerifyError = %v", len(capture.Verified), capture.VerifyError)
+		}
+	})
+
+	t.Run("skip verify", func(t *testing.T) {
+		capture := &ChainCapture{}
+		cfg := &tls.Config{ServerName: "127.0.0.1", InsecureSkipVerify: true}
+		capture.Apply(cfg)
+		conn, err := tls.Dial("tcp", addr, cfg)
+		if err != nil {
+			t.Fatalf("Dial() error: %v", err)
+		}
+		conn.Close()
+		if capture.State == nil || capture.VerifyError == nil {
+			t.Errorf("State = %v, VerifyError = %v; want captured chain and verification error", capture.State, capture.VerifyError)
+		}
+	})
+
+	t.Run("nothing captured", func(t *testing.T) {
+		if _, err := (&ChainCapture{}).Save(t.TempDir(), "host", nil); err == nil {
+			t.Error("Save() succeeded without a handshake, want error")
+		}
+	})
+}
+
+func TestSaveChain(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	capture := &ChainCapture{}
+	cfg := &tls.Config{ServerName: "127.0.0.1", RootCAs: roots}
+	capture.Apply(cfg)
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	conn.Close()
+
+	dir := filepath.Join(t.TempDir(), "chains")
+	files, err := capture.Save(dir, "::1:993", nil)
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if len(files) != 5 {
+		t.Errorf("wrote %d files, want 5: %v", len(files), files)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "__1_993-peer-0.pem"))
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" || !bytes.Equal(block.Bytes, server.Certificate().Raw) {
+		t.Error("peer PEM does not contain the server certificate")
+	}
+	der, err := os.ReadFile(filepath.Join(dir, "__1_993-verified-0-0.der"))
+	if err != nil || !bytes.Equal(der, server.Certificate().Raw) {
+		t.Errorf("verified DER does not contain the server certificate (err: %v)", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "__1_993-summary.json"))
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	var summary chainSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("summary is not valid JSON: %v", err)
+	}
+	if len(summary.Peer) != 1 || len(summary.VerifiedChains) != 1 || summary.Peer[0].PEMFile != "__1_993-peer-0.pem" {
+		t.Errorf("summary = %+v", summary)
+	}
+	if summary.Certificate == nil || summary.Certificate.Subject != server.Certificate().Subject.String() {
+		t.Errorf("summary certificate = %+v", summary.Certificate)
+	}
+	if summary.TLSVersion != "TLS 1.3" || summary.VerifyError != "" {
+		t.Errorf("TLSVersion = %q, VerifyError = %q", summary.TLSVersion, summary.VerifyError)
+	}
+}
@@ -0,0 +1,178 @@
+package tls
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Report is the analysis of one TLS connection as printed by the tools.
+type Report struct {
+	TLS             *TLSInfo
+	Certificate     *CertificateInfo
+	Warnings        []string
+	Recommendations []string
+}
+
+// NewReport analyzes the connection state, certificate chain and resulting
+// warnings and recommendations for a connection to hostname.
+func NewReport(state *tls.ConnectionState, hostname string, skipVerify bool) *Report {
+	tlsInfo := AnalyzeTLSConnection(state)
+	certInfo := AnalyzeCertificateChain(state, hostname)
+	return &Report{
+		TLS:             tlsInfo,
+		Certificate:     certInfo,
+		Warnings:        CheckTLSWarnings(tlsInfo, certInfo, skipVerify),
+		Recommendations: GetTLSRecommendations(tlsInfo),
+	}
+}
+
+// Print writes the full report: connection details, certificate, warnings
+// and recommendations.
+func (r *Report) Print(w io.Writer) {
+	PrintTLSInfo(w, r.TLS)
+	PrintCertificateInfo(w, r.Certificate)
+	PrintWarnings(w, r.Warnings)
+	PrintRecommendations(w, r.Recommendations)
+}
+
+// PrintTLSInfo writes TLS connection details.
+func PrintTLSInfo(w io.Writer, info *TLSInfo) {
+	fmt.Fprintln(w, "TLS Connection Details:")
+	fmt.Fprintln(w, strings.Repeat("═", 60))
+	fmt.Fprintf(w, "  Protocol Version:    %s\n", info.Version)
+	fmt.Fprintf(w, "  Cipher Suite:        %s\n", info.CipherSuite)
+	fmt.Fprintf(w, "  Cipher Strength:     %s\n", strings.ToUpper(info.CipherSuiteStrength))
+	if info.ServerName != "" {
+		fmt.Fprintf(w, "  Server Name (SNI):   %s\n", info.ServerName)
+	}
+	if info.NegotiatedProtocol != "" {
+		fmt.Fprintf(w, "  Negotiated Protocol: %s\n", info.NegotiatedProtocol)
+	}
+	fmt.Fprintln(w, strings.Repeat("═", 60))
+}
+
+// PrintCertificateInfo writes certificate details.
+func PrintCertificateInfo(w io.Writer, info *CertificateInfo) {
+	fmt.Fprintln(w, "\nCertificate Information:")
+	fmt.Fprintln(w, strings.Repeat("═", 60))
+	fmt.Fprintf(w, "  Subject:             %s\n", info.Subject)
+	fmt.Fprintf(w, "  Issuer:              %s\n", info.Issuer)
+	fmt.Fprintf(w, "  Serial Number:       %s\n", info.SerialNumber)
+	fmt.Fprintf(w, "  Valid From:          %s\n", info.ValidFrom.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(w, "  Valid To:            %s\n", info.ValidTo.Format("2006-01-02 15:04:05 MST"))
+
+	if info.IsExpired {
+		fmt.Fprintf(w, "  Status:              ⚠ EXPIRED\n")
+	} else {
+		fmt.Fprintf(w, "  Days Until Expiry:   %d\n", info.DaysUntilExpiry)
+	}
+
+	if len(info.SANs) > 0 {
+		fmt.Fprintln(w, "  Subject Alternative Names:")
+		for _, san := range info.SANs {
+			fmt.Fprintf(w, "    • %s\n", san)
+		}
+	}
+
+	fmt.Fprintf(w, "  Signature Algorithm: %s\n", info.SignatureAlgorithm)
+	fmt.Fprintf(w, "  Public Key:          %s (%d bits)\n", info.PublicKeyAlgorithm, info.PublicKeySize)
+
+	if len(info.KeyUsage) > 0 {
+		fmt.Fprintf(w, "  Key Usage:           %s\n", strings.Join(info.KeyUsage, ", "))
+	}
+	if len(info.ExtKeyUsage) > 0 {
+		fmt.Fprintf(w, "  Extended Key Usage:  %s\n", strings.Join(info.ExtKeyUsage, ", "))
+	}
+
+	fmt.Fprintf(w, "  Verification:        %s\n", strings.ToUpper(info.VerificationStatus))
+	fmt.Fprintf(w, "  Chain Length:        %d certificate(s)\n", info.ChainLength)
+
+	if info.IsSelfSigned {
+		fmt.Fprintln(w, "  ⚠ Self-signed certificate")
+	}
+
+	if info.OCSPStaple != nil {
+		fmt.Fprintf(w, "  OCSP Stapling:       %s\n", formatRevocation(info.OCSPStaple))
+	} else {
+		fmt.Fprintf(w, "  OCSP Stapling:       not stapled\n")
+	}
+	for _, r := range info.Revocation {
+		label := "OCSP Responder:"
+		if r.Source == RevocationSourceCRL {
+			label = "CRL:"
+		}
+		fmt.Fprintf(w, "  %-21s%s\n", label, formatRevocation(r))
+		fmt.Fprintf(w, "    %s\n", r.URL)
+	}
+
+	if len(info.SCTs) > 0 {
+		fmt.Fprintf(w, "  CT Timestamps:       %d SCT(s)\n", len(info.SCTs))
+		for _, sct := range info.SCTs {
+			fmt.Fprintf(w, "    • log %s at %s (%s)\n", sct.LogID, sct.Timestamp.Format("2006-01-02"), sct.Source)
+		}
+	} else {
+		fmt.Fprintf(w, "  CT Timestamps:       none\n")
+	}
+
+	fmt.Fprintln(w, strings.Repeat("═", 60))
+}
+
+// formatRevocation summarizes a revocation check for display.
+func formatRevocation(r *RevocationStatus) string {
+	switch r.Status {
+	case RevocationGood:
+		if r.IsStale() {
+			return fmt.Sprintf("⚠ GOOD but stale (next update was due %s)", r.NextUpdate.Format("2006-01-02"))
+		}
+		return fmt.Sprintf("✓ GOOD (produced %s)", r.ThisUpdate.Format("2006-01-02 15:04 MST"))
+	case RevocationRevoked:
+		return fmt.Sprintf("✗ REVOKED on %s (%s)", r.RevokedAt.Format("2006-01-02"), r.Reason)
+	case RevocationUnknown:
+		return "⚠ UNKNOWN (responder does not know the certificate)"
+	}
+	return fmt.Sprintf("⚠ ERROR: %s", r.Error)
+}
+
+// PrintWarnings writes the TLS warnings, if any.
+func PrintWarnings(w io.Writer, warnings []string) {
+	printList(w, "\n⚠ TLS Warnings:", warnings)
+}
+
+// PrintRecommendations writes the TLS recommendations, if any.
+func PrintRecommendations(w io.Writer, recommendations []string) {
+	printList(w, "\n💡 Recommendations:", recommendations)
+}
+
+func printList(w io.Writer, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, strings.Repeat("─", 60))
+	for _, item := range items {
+		fmt.Fprintf(w, "  • %s\n", item)
+	}
+	fmt.Fprintln(w, strings.Repeat("─", 60))
+}
+
+// CSVColumns names the fields returned by CSVFields.
+var CSVColumns = []string{"TLS_Version", "Cipher_Suite", "Cert_Subject", "Cert_Valid_To", "Verification_Status", "TLS_Warnings"}
+
+// CSVFields summarizes the report for CSV output. A nil report, i.e. a
+// connection without TLS, yields empty fields.
+func (r *Report) CSVFields() []string {
+	if r == nil {
+		return make([]string, len(CSVColumns))
+	}
+	return []string{
+		r.TLS.Version,
+		r.TLS.CipherSuite,
+		r.Certificate.Subject,
+		r.Certificate.ValidTo.Format(time.RFC3339),
+		r.Certificate.VerificationStatus,
+		strings.Join(r.Warnings, "; "),
+	}
+}
@@ -0,0 +1,60 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+
+	report := NewReport(&state, "mail.example.org", true)
+	if report.TLS.Version != "TLS 1.2" {
+		t.Errorf("TLS.Version = %q, want TLS 1.2", report.TLS.Version)
+	}
+	if report.Certificate.VerificationStatus != "hostname_mismatch" {
+		t.Errorf("VerificationStatus = %q, want hostname_mismatch", report.Certificate.VerificationStatus)
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	for _, want := range []string{"TLS Connection Details:", "Certificate Information:", "TLS Warnings:", "-skipverify"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Print() output missing %q", want)
+		}
+	}
+
+	fields := report.CSVFields()
+	if len(fields) != len(CSVColumns) {
+		t.Fatalf("CSVFields() returned %d fields, want %d", len(fields), len(CSVColumns))
+	}
+	if fields[0] != "TLS 1.2" || fields[4] != "hostname_mismatch" {
+		t.Errorf("CSVFields() = %q", fields)
+	}
+}
+
+func TestReportCSVFields_NoTLS(t *testing.T) {
+	var report *Report
+	fields := report.CSVFields()
+	if len(fields) != len(CSVColumns) {
+		t.Fatalf("CSVFields() returned %d fields, want %d", len(fields), len(CSVColumns))
+	}
+	for i, f := range fields {
+		if f != "" {
+			t.Errorf("field %d = %q, want empty", i, f)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Revocation check results.
+const (
+	RevocationGood    = "good"
+	RevocationRevoked = "revoked"
+	RevocationUnknown = "unknown" // The responder does not know the certificate
+	RevocationError   = "error"   // The check could not be completed
+)
+
+// Revocation check sources.
+const (
+	RevocationSourceStaple = "ocsp_staple"
+	RevocationSourceOCSP   = "ocsp"
+	RevocationSourceCRL    = "crl"
+)
+
+// maxRevocationResponse limits OCSP responses and CRLs read from the network.
+const maxRevocationResponse = 10 << 20
+
+// RevocationStatus is the result of one revocation check of the leaf certificate.
+type RevocationStatus struct {
+	Source     string    // ocsp_staple, ocsp or crl
+	URL        string    // Responder or CRL URL (empty for a staple)
+	Status     string    // good, revoked, unknown or error
+	RevokedAt  time.Time // Revocation time (revoked only)
+	Reason     string    // Revocation reason (revoked only)
+	ThisUpdate time.Time // When the status was produced
+	NextUpdate time.Time // When newer information will be available (zero if not given)
+	Error      string    // Why the check failed (error only)
+}
+
+// IsStale reports whether the response is past its NextUpdate time.
+func (s *RevocationStatus) IsStale() bool {
+	return !s.NextUpdate.IsZero() && time.Now().After(s.NextUpdate)
+}
+
+// RevocationOptions configures CheckRevocation. Empty URLs are taken from
+// the certificate's Authority Information Access and CRL Distribution Points.
+type RevocationOptions struct {
+	OCSPURL string        // Override for the OCSP responder
+	CRLURL  string        // Override for the CRL distribution point
+	Timeout time.Duration // Per-request timeout (default 10s)
+}
+
+// ParseOCSPStaple checks a stapled OCSP response against the leaf
+// certificate. The response signature is only verified when the issuer is
+// known, i.e. the server sent an intermediate certificate.
+func ParseOCSPStaple(raw []byte, leaf, issuer *x509.Certificate) *RevocationStatus {
+	status := parseOCSPResponse(raw, leaf, issuer)
+	status.Source = RevocationSourceStaple
+	return status
+}
+
+// CheckRevocation queries the OCSP responder and downloads the CRL for the
+// leaf certificate of a chain. The issuer (the second certificate) is
+// needed to build the OCSP request and to verify signatures.
+func CheckRevocation(ctx context.Context, certs []*x509.Certificate, opts RevocationOptions) []*RevocationStatus {
+	if len(certs) == 0 {
+		return nil
+	}
+	leaf := certs[0]
+	var issuer *x509.Certificate
+	if len(certs) > 1 {
+		issuer = certs[1]
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: opts.Timeout}
+
+	var results []*RevocationStatus
+
+	ocspURL := opts.OCSPURL
+	if ocspURL == "" && len(leaf.OCSPServer) > 0 {
+		ocspURL = leaf.OCSPServer[0]
+	}
+	if ocspURL != "" {
+		results = append(results, queryOCSP(ctx, client, ocspURL, leaf, issuer))
+	}
+
+	crlURL := opts.CRLURL
+	if crlURL == "" && len(leaf.CRLDistributionPoints) > 0 {
+		crlURL = leaf.CRLDistributionPoints[0]
+	}
+	if crlURL != "" {
+		results = append(results, checkCRL(ctx, client, crlURL, leaf, issuer))
+	}
+
+	return results
+}
+
+// queryOCSP sends an OCSP request for leaf (RFC 6960) by HTTP POST.
+func queryOCSP(ctx context.Context, client *http.Client, url string, leaf, issuer *x509.Certificate) *RevocationStatus {
+	fail := func(err error) *RevocationStatus {
+		return &RevocationStatus{Source: RevocationSourceOCSP, URL: url, Status: RevocationError, Error: err.Error()}
+	}
+	if issuer == nil {
+		return fail(errors.New("issuer certificate not presented; cannot build OCSP request"))
+	}
+
+	request, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return fail(fmt.Errorf("failed to create OCSP request: %w", err))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(request))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+
+	body, err := fetch(client, req)
+	if err != nil {
+		return fail(fmt.Errorf("OCSP request failed: %w", err))
+	}
+
+	status := parseOCSPResponse(body, leaf, issuer)
+	status.Source = RevocationSourceOCSP
+	status.URL = url
+	return status
+}
+
+// parseOCSPResponse converts an OCSP response for leaf into a RevocationStatus.
+func parseOCSPResponse(raw []byte, leaf, issuer *x509.Certificate) *RevocationStatus {
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return &RevocationStatus{Status: RevocationError, Error: fmt.Sprintf("invalid OCSP response: %v", err)}
+	}
+
+	status := &RevocationStatus{ThisUpdate: resp.ThisUpdate, NextUpdate: resp.NextUpdate}
+	switch resp.Status {
+	case ocsp.Good:
+		status.Status = RevocationGood
+	case ocsp.Revoked:
+		status.Status = RevocationRevoked
+		status.RevokedAt = resp.RevokedAt
+		status.Reason = RevocationReason(resp.RevocationReason)
+	default:
+		status.Status = RevocationUnknown
+	}
+	return status
+}
+
+// checkCRL downloads a CRL (DER or PEM) and looks up the leaf serial number.
+func checkCRL(ctx context.Context, client *http.Client, url string, leaf, issuer *x509.Certificate) *RevocationStatus {
+	fail := func(err error) *RevocationStatus {
+		return &RevocationStatus{Source: RevocationSourceCRL, URL: url, Status: RevocationError, Error: err.Error()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fail(err)
+	}
+	body, err := fetch(client, req)
+	if err != nil {
+		return fail(fmt.Errorf("CRL download failed: %w", err))
+	}
+	if block, _ := pem.Decode(body); block != nil {
+		body = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(body)
+	if err != nil {
+		return fail(fmt.Errorf("invalid CRL: %w", err))
+	}
+	if issuer != nil {
+		if err := crl.CheckSignatureFrom(issuer); err != nil {
+			return fail(fmt.Errorf("CRL signature does not verify against the issuer: %w", err))
+		}
+	}
+
+	status := &RevocationStatus{
+		Source:     RevocationSourceCRL,
+		URL:        url,
+		Status:     RevocationGood,
+		ThisUpdate: crl.ThisUpdate,
+		NextUpdate: crl.NextUpdate,
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			status.Status = RevocationRevoked
+			status.RevokedAt = entry.RevocationTime
+			status.Reason = RevocationReason(entry.ReasonCode)
+			break
+		}
+	}
+	return status
+}
+
+// fetch performs req and returns the body of a 200 response.
+func fetch(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRevocationResponse))
+}
+
+// revocationWarnings describes revoked certificates and failed, unknown or
+// stale revocation checks.
+func revocationWarnings(certInfo *CertificateInfo) []string {
+	checks := certInfo.Revocation
+	if certInfo.OCSPStaple != nil {
+		checks = append([]*RevocationStatus{certInfo.OCSPStaple}, checks...)
+	}
+
+	var warnings []string
+	for _, r := range checks {
+		label := RevocationSourceLabel(r.Source)
+		switch {
+		case r.Status == RevocationRevoked:
+			warnings = append(warnings, fmt.Sprintf("Certificate revoked on %s (%s, reason: %s)",
+				r.RevokedAt.Format("2006-01-02"), label, r.Reason))
+		case r.Status == RevocationUnknown:
+			warnings = append(warnings, fmt.Sprintf("%s reports the certificate status as unknown", label))
+		case r.Status == RevocationError:
+			warnings = append(warnings, fmt.Sprintf("%s check failed: %s", label, r.Error))
+		case r.IsStale():
+			warnings = append(warnings, fmt.Sprintf("%s is stale (next update was due %s)",
+				label, r.NextUpdate.Format("2006-01-02 15:04 MST")))
+		}
+	}
+	return warnings
+}
+
+// RevocationSourceLabel returns a display name for a revocation check source.
+func RevocationSourceLabel(source string) string {
+	switch source {
+	case RevocationSourceStaple:
+		return "Stapled OCSP response"
+	case RevocationSourceOCSP:
+		return "OCSP responder"
+	case RevocationSourceCRL:
+		return "CRL"
+	}
+	return source
+}
+
+// RevocationReason returns the name of a CRLReason code (RFC 5280 section 5.3.1).
+func RevocationReason(code int) string {
+	switch code {
+	case ocsp.Unspecified:
+		return "unspecified"
+	case ocsp.KeyCompromise:
+		return "keyCompromise"
+	case ocsp.CACompromise:
+		return "cACompromise"
+	case ocsp.AffiliationChanged:
+		return "affiliationChanged"
+	case ocsp.Superseded:
+		return "superseded"
+	case ocsp.CessationOfOperation:
+		return "cessationOfOperation"
+	case ocsp.CertificateHold:
+		return "certificateHold"
+	case ocsp.RemoveFromCRL:
+		return "removeFromCRL"
+	case ocsp.PrivilegeWithdrawn:
+		return "privilegeWithdrawn"
+	case ocsp.AACompromise:
+		return "aACompromise"
+	default:
+		return fmt.Sprintf("reason %d", code)
+	}
+}
@@ -0,0 +1,244 @@
+package tls
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// testPKI is a CA and a leaf certificate issued by it.
+type testPKI struct {
+	ca     *x509.Certificate
+	caKey  crypto.Signer
+	leaf   *x509.Certificate
+	chains []*x509.Certificate
+}
+
+func newTestPKI(t *testing.T, ocspURL, crlURL string) *testPKI {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate(CA) error: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject:      pkix.Name{CommonName: "mail.example.com"},
+		DNSNames:     []string{"mail.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ocspURL != "" {
+		leafTemplate.OCSPServer = []string{ocspURL}
+	}
+	if crlURL != "" {
+		leafTemplate.CRLDistributionPoints = []string{crlURL}
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate(leaf) error: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDER)
+
+	return &testPKI{ca: ca, caKey: caKey, leaf: leaf, chains: []*x509.Certificate{leaf, ca}}
+}
+
+// ocspResponse signs an OCSP response for the leaf with the CA key.
+func (p *testPKI) ocspResponse(t *testing.T, status int, nextUpdate time.Time) []byte {
+	t.Helper()
+	raw, err := ocsp.CreateResponse(p.ca, p.ca, ocsp.Response{
+		Status:           status,
+		SerialNumber:     p.leaf.SerialNumber,
+		ThisUpdate:       time.Now().Add(-time.Hour),
+		NextUpdate:       nextUpdate,
+		RevokedAt:        time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		RevocationReason: ocsp.KeyCompromise,
+	}, p.caKey)
+	if err != nil {
+		t.Fatalf("CreateResponse() error: %v", err)
+	}
+	return raw
+}
+
+// crl returns a CRL signed by the CA, revoking serial if it is not nil.
+func (p *testPKI) crl(t *testing.T, serial *big.Int) []byte {
+	t.Helper()
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	if serial != nil {
+		template.RevokedCertificateEntries = []x509.RevocationListEntry{
+			{SerialNumber: serial, RevocationTime: time.Now().Add(-time.Minute), ReasonCode: ocsp.Superseded},
+		}
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, p.ca, p.caKey)
+	if err != nil {
+		t.Fatalf("CreateRevocationList() error: %v", err)
+	}
+	return der
+}
+
+func TestAnalyzeCertificateChain_OCSPStaple(t *testing.T) {
+	pki := newTestPKI(t, "", "")
+
+	tests := []struct {
+		name        string
+		staple      []byte
+		chain       []*x509.Certificate
+		wantStatus  string
+		wantWarning string
+	}{
+		{"good", pki.ocspResponse(t, ocsp.Good, time.Now().Add(time.Hour)), pki.chains, RevocationGood, ""},
+		{"revoked", pki.ocspResponse(t, ocsp.Revoked, time.Now().Add(time.Hour)), pki.chains, RevocationRevoked, "Certificate revoked on 2026-03-01 (Stapled OCSP response, reason: keyCompromise)"},
+		{"stale", pki.ocspResponse(t, ocsp.Good, time.Now().Add(-time.Minute)), pki.chains, RevocationGood, "Stapled OCSP response is stale"},
+		{"garbage", []byte("not ocsp"), pki.chains, RevocationError, "Stapled OCSP response check failed"},
+		{"issuer not sent", pki.ocspResponse(t, ocsp.Good, time.Now().Add(time.Hour)), pki.chains[:1], RevocationGood, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &tls.ConnectionState{PeerCertificates: tt.chain, OCSPResponse: tt.staple}
+			info := AnalyzeCertificateChain(state, "mail.example.com")
+			if info.OCSPStaple == nil {
+				t.Fatal("OCSPStaple = nil")
+			}
+			if info.OCSPStaple.Status != tt.wantStatus {
+				t.Errorf("OCSPStaple.Status = %q (%s), want %q", info.OCSPStaple.Status, info.OCSPStaple.Error, tt.wantStatus)
+			}
+			if got := info.IsRevoked(); got != (tt.wantStatus == RevocationRevoked) {
+				t.Errorf("IsRevoked() = %v", got)
+			}
+
+			warnings := strings.Join(revocationWarnings(info), "\n")
+			if tt.wantWarning == "" && warnings != "" {
+				t.Errorf("unexpected warnings: %s", warnings)
+			}
+			if !strings.Contains(warnings, tt.wantWarning) {
+				t.Errorf("warnings %q do not contain %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+
+	// No staple
+	info := AnalyzeCertificateChain(&tls.ConnectionState{PeerCertificates: pki.chains}, "mail.example.com")
+	if info.OCSPStaple != nil {
+		t.Errorf("OCSPStaple = %+v, want nil", info.OCSPStaple)
+	}
+}
+
+func TestCheckRevocation(t *testing.T) {
+	var pki *testPKI
+	var ocspStatus int
+	var revokedSerial *big.Int
+
+	ocspServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if _, err := ocsp.ParseRequest(body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(pki.ocspResponse(t, ocspStatus, time.Now().Add(time.Hour)))
+	}))
+	defer ocspServer.Close()
+	crlServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pki.crl(t, revokedSerial))
+	}))
+	defer crlServer.Close()
+
+	pki = newTestPKI(t, ocspServer.URL, crlServer.URL)
+
+	t.Run("good", func(t *testing.T) {
+		ocspStatus, revokedSerial = ocsp.Good, big.NewInt(1)
+		results := CheckRevocation(context.Background(), pki.chains, RevocationOptions{})
+		if len(results) != 2 {
+			t.Fatalf("got %d results, want 2", len(results))
+		}
+		for _, r := range results {
+			if r.Status != RevocationGood {
+				t.Errorf("%s status = %q (%s), want good", r.Source, r.Status, r.Error)
+			}
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		ocspStatus, revokedSerial = ocsp.Revoked, pki.leaf.SerialNumber
+		results := CheckRevocation(context.Background(), pki.chains, RevocationOptions{})
+		if len(results) != 2 {
+			t.Fatalf("got %d results, want 2", len(results))
+		}
+		if results[0].Source != RevocationSourceOCSP || results[0].Status != RevocationRevoked || results[0].Reason != "keyCompromise" {
+			t.Errorf("OCSP result = %+v", results[0])
+		}
+		if results[1].Source != RevocationSourceCRL || results[1].Status != RevocationRevoked || results[1].Reason != "superseded" {
+			t.Errorf("CRL result = %+v", results[1])
+		}
+	})
+
+	t.Run("overridden URLs", func(t *testing.T) {
+		ocspStatus = ocsp.Good
+		results := CheckRevocation(context.Background(), pki.chains, RevocationOptions{
+			OCSPURL: ocspServer.URL + "/override",
+			CRLURL:  crlServer.URL + "/override",
+		})
+		if len(results) != 2 || results[0].URL != ocspServer.URL+"/override" || results[1].URL != crlServer.URL+"/override" {
+			t.Errorf("results = %+v", results)
+		}
+	})
+
+	t.Run("issuer missing", func(t *testing.T) {
+		results := CheckRevocation(context.Background(), pki.chains[:1], RevocationOptions{})
+		if results[0].Status != RevocationError || !strings.Contains(results[0].Error, "issuer") {
+			t.Errorf("OCSP result = %+v, want issuer error", results[0])
+		}
+	})
+
+	t.Run("responder error", func(t *testing.T) {
+		results := CheckRevocation(context.Background(), pki.chains, RevocationOptions{OCSPURL: ocspServer.URL, CRLURL: ocspServer.URL})
+		if results[1].Status != RevocationError || !strings.Contains(results[1].Error, "HTTP 400") {
+			t.Errorf("CRL result = %+v, want HTTP 400 error", results[1])
+		}
+	})
+
+	t.Run("no URLs", func(t *testing.T) {
+		plain := newTestPKI(t, "", "")
+		if results := CheckRevocation(context.Background(), plain.chains, RevocationOptions{}); len(results) != 0 {
+			t.Errorf("results = %+v, want none", results)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// SweepVersions are the protocol versions probed by Sweep, oldest first.
+var SweepVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// HandshakeError marks a failure of the TLS handshake itself, i.e. the server
+// refused the offered protocol version or cipher suites.
+type HandshakeError struct {
+	Err error
+}
+
+func (e *HandshakeError) Error() string { return e.Err.Error() }
+func (e *HandshakeError) Unwrap() error { return e.Err }
+
+// HandshakeFunc opens a new connection and performs a TLS handshake with cfg.
+// Handshake failures must be returned as *HandshakeError; any other error
+// means the server could not be reached and aborts the sweep.
+type HandshakeFunc func(ctx context.Context, cfg *tls.Config) (*tls.ConnectionState, error)
+
+// SweepResult is the outcome of one handshake of a sweep.
+type SweepResult struct {
+	Version     uint16
+	CipherSuite uint16 // Offered suite; for TLS 1.3 the negotiated one. 0 when a whole version was refused
+	Supported   bool
+	Strength    string // AnalyzeCipherStrength of the suite when supported
+	Err         error  // Handshake error when not supported
+}
+
+// Finding explains why an accepted combination should be disabled, or
+// returns "" when it is acceptable.
+func (r *SweepResult) Finding() string {
+	if !r.Supported {
+		return ""
+	}
+	if r.Version < tls.VersionTLS12 {
+		return fmt.Sprintf("legacy protocol %s is enabled", TLSVersionString(r.Version))
+	}
+	if r.Strength == "deprecated" {
+		return fmt.Sprintf("deprecated cipher suite %s is enabled", tls.CipherSuiteName(r.CipherSuite))
+	}
+	return ""
+}
+
+// SweepCipherSuites returns the TLS 1.0-1.2 cipher suites Go can offer for
+// version, including the insecure ones. TLS 1.3 suites cannot be restricted
+// in crypto/tls, so none are returned for TLS 1.3.
+func SweepCipherSuites(version uint16) []*tls.CipherSuite {
+	var suites []*tls.CipherSuite
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if slices.Contains(suite.SupportedVersions, version) && !slices.Contains(suite.SupportedVersions, tls.VersionTLS13) {
+			suites = append(suites, suite)
+		}
+	}
+	return suites
+}
+
+// Sweep probes every protocol version in SweepVersions and, for each version
+// the server accepts, every cipher suite from SweepCipherSuites with one
+// handshake each. Certificates are not verified: the sweep is about what the
+// server negotiates, not whom it claims to be.
+func Sweep(ctx context.Context, serverName string, handshake HandshakeFunc) ([]SweepResult, error) {
+	var results []SweepResult
+	for _, version := range SweepVersions {
+		suites := SweepCipherSuites(version)
+		var ids []uint16
+		for _, suite := range suites {
+			ids = append(ids, suite.ID)
+		}
+
+		// Protocol support with every suite offered
+		result, err := sweepProbe(ctx, serverName, handshake, version, ids)
+		if err != nil {
+			return results, err
+		}
+		if !result.Supported || version == tls.VersionTLS13 {
+			if !result.Supported {
+				result.CipherSuite = 0
+			}
+			results = append(results, result)
+			continue
+		}
+
+		for _, id := range ids {
+			result, err := sweepProbe(ctx, serverName, handshake, version, []uint16{id})
+			if err != nil {
+				return results, err
+			}
+			result.CipherSuite = id
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// sweepProbe performs one handshake offering exactly version and suites.
+func sweepProbe(ctx context.Context, serverName string, handshake HandshakeFunc, version uint16, suites []uint16) (SweepResult, error) {
+	result := SweepResult{Version: version}
+	state, err := handshake(ctx, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, // Probing protocol support only
+		MinVersion:         version,
+		MaxVersion:         version,
+		CipherSuites:       suites,
+	})
+
+	var handshakeErr *HandshakeError
+	switch {
+	case errors.As(err, &handshakeErr):
+		result.Err = handshakeErr.Err
+		return result, nil
+	case err != nil:
+		return result, err
+	}
+
+	result.Supported = true
+	result.CipherSuite = state.CipherSuite
+	result.Strength = AnalyzeCipherStrength(state.CipherSuite)
+	return result, nil
+}
+
+// VersionSupported returns true if any result shows version was accepted.
+func VersionSupported(results []SweepResult, version uint16) bool {
+	for _, r := range results {
+		if r.Version == version && r.Supported {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestSweep tests the support matrix against a server restricted to TLS 1.2
+// and two cipher suites
+func TestSweep(t *testing.T) {
+	accepted := []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12, CipherSuites: accepted}
+	server.StartTLS()
+	defer server.Close()
+
+	handshake := func(ctx context.Context, cfg *tls.Config) (*tls.ConnectionState, error) {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), cfg)
+		if err != nil {
+			return nil, &HandshakeError{Err: err}
+		}
+		defer conn.Close()
+		state := conn.ConnectionState()
+		return &state, nil
+	}
+
+	results, err := Sweep(context.Background(), "example.com", handshake)
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+
+	for _, version := range []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS13} {
+		if VersionSupported(results, version) {
+			t.Errorf("%s reported as supported", TLSVersionString(version))
+		}
+	}
+	if !VersionSupported(results, tls.VersionTLS12) {
+		t.Fatal("TLS 1.2 reported as not supported")
+	}
+
+	strengths := make(map[string]string)
+	for _, r := range results {
+		if r.Supported {
+			strengths[tls.CipherSuiteName(r.CipherSuite)] = r.Strength
+			if r.Finding() != "" {
+				t.Errorf("unexpected finding: %s", r.Finding())
+			}
+		}
+	}
+	if len(strengths) != 2 {
+		t.Fatalf("accepted suites = %v, want 2", strengths)
+	}
+	for name, strength := range strengths {
+		want := "weak"
+		if strings.Contains(name, "GCM") {
+			want = "strong"
+		}
+		if strength != want {
+			t.Errorf("%s strength = %q, want %q", name, strength, want)
+		}
+	}
+}
+
+// TestSweepAbortsOnConnectionError tests that non-handshake errors stop the sweep
+func TestSweepAbortsOnConnectionError(t *testing.T) {
+	calls := 0
+	_, err := Sweep(context.Background(), "example.com", func(ctx context.Context, cfg *tls.Config) (*tls.ConnectionState, error) {
+		calls++
+		return nil, errors.New("connection refused")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Sweep() error = %v after %d handshakes, want error after 1", err, calls)
+	}
+}
+
+// TestSweepResultFinding tests which accepted combinations are findings
+func TestSweepResultFinding(t *testing.T) {
+	tests := []struct {
+		name   string
+		result SweepResult
+		want   bool
+	}{
+		{"Refused TLS 1.0", SweepResult{Version: tls.VersionTLS10}, false},
+		{"Accepted TLS 1.1", SweepResult{Version: tls.VersionTLS11, Supported: true, Strength: "strong"}, true},
+		{"Deprecated suite", SweepResult{Version: tls.VersionTLS12, CipherSuite: tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA, Supported: true, Strength: "deprecated"}, true},
+		{"Weak suite", SweepResult{Version: tls.VersionTLS12, Supported: true, Strength: "weak"}, false},
+		{"TLS 1.3", SweepResult{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256, Supported: true, Strength: "strong"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.Finding() != ""; got != tt.want {
+				t.Errorf("Finding() = %q, want finding %v", tt.result.Finding(), tt.want)
+			}
+		})
+	}
+}
+
+// TestSweepCipherSuites tests that TLS 1.3 suites are never offered explicitly
+func TestSweepCipherSuites(t *testing.T) {
+	if suites := SweepCipherSuites(tls.VersionTLS13); len(suites) != 0 {
+		t.Errorf("SweepCipherSuites(TLS 1.3) returned %d suites", len(suites))
+	}
+	if suites := SweepCipherSuites(tls.VersionTLS12); len(suites) == 0 {
+		t.Error("SweepCipherSuites(TLS 1.2) returned no suites")
+	}
+}
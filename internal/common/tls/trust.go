@@ -0,0 +1,183 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/pkcs12"
+
+	"msgraphtool/internal/common/validation"
+)
+
+// TrustOptions configures which servers a client trusts and the certificate
+// it presents for mutual TLS.
+type TrustOptions struct {
+	CAFile             string   // PEM bundle that replaces the system roots
+	ClientCertFile     string   // PEM certificate (chain) or PKCS#12 (.pfx/.p12) bundle
+	ClientKeyFile      string   // PEM private key; not used with PKCS#12
+	ClientCertPassword string   // PKCS#12 password
+	Pins               []string // Base64 SHA-256 hashes of a certificate's SubjectPublicKeyInfo
+}
+
+// Apply loads the configured files into cfg. Pins are checked in
+// VerifyConnection, so they are enforced even with InsecureSkipVerify.
+func (o *TrustOptions) Apply(cfg *tls.Config) error {
+	if o.CAFile != "" {
+		roots, err := LoadCAFile(o.CAFile)
+		if err != nil {
+			return err
+		}
+		cfg.RootCAs = roots
+	}
+
+	if o.ClientCertFile != "" {
+		cert, err := LoadClientCertificate(o.ClientCertFile, o.ClientKeyFile, o.ClientCertPassword)
+		if err != nil {
+			return err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(o.Pins) > 0 {
+		pins, err := ParsePins(o.Pins)
+		if err != nil {
+			return err
+		}
+		next := cfg.VerifyConnection
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			if err := VerifyPins(state.PeerCertificates, pins); err != nil {
+				return err
+			}
+			if next != nil {
+				return next(state)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Validate checks the file paths and loads the files once, so a missing
+// file, wrong password or malformed pin is reported before connecting.
+func (o *TrustOptions) Validate() error {
+	if o.ClientKeyFile != "" && o.ClientCertFile == "" {
+		return errors.New("-clientkey requires -clientcert")
+	}
+	for _, f := range []struct{ path, name string }{
+		{o.CAFile, "CA bundle"},
+		{o.ClientCertFile, "Client certificate"},
+		{o.ClientKeyFile, "Client key"},
+	} {
+		if err := validation.ValidateFilePath(f.path, f.name); err != nil {
+			return err
+		}
+	}
+	return o.Apply(&tls.Config{})
+}
+
+// LoadCAFile reads a PEM bundle of trusted root certificates.
+func LoadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// LoadClientCertificate loads a client certificate and private key. A
+// PKCS#12 bundle (.pfx or .p12) contains both and is decrypted with password;
+// otherwise certFile and keyFile are PEM, and keyFile may be empty when the
+// key is in certFile.
+func LoadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+
+	lower := strings.ToLower(certFile)
+	if strings.HasSuffix(lower, ".pfx") || strings.HasSuffix(lower, ".p12") {
+		// ToPEM keeps intermediate certificates, which Decode rejects
+		blocks, err := pkcs12.ToPEM(data, password)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 client certificate: %w", err)
+		}
+		var buf bytes.Buffer
+		for _, block := range blocks {
+			if err := pem.Encode(&buf, block); err != nil {
+				return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 client certificate: %w", err)
+			}
+		}
+		data = buf.Bytes()
+		keyFile = ""
+	}
+
+	keyData := data
+	if keyFile != "" {
+		if keyData, err = os.ReadFile(keyFile); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to read client key: %w", err)
+		}
+	}
+
+	cert, err := tls.X509KeyPair(data, keyData)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid client certificate or key: %w", err)
+	}
+	return cert, nil
+}
+
+// SPKIPin returns the base64 SHA-256 hash of a certificate's public key,
+// the format used by HPKP and curl's --pinnedpubkey.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ParsePins normalizes pins given as base64 hashes, optionally prefixed
+// with "sha256/" or "sha256//".
+func ParsePins(pins []string) ([]string, error) {
+	parsed := make([]string, 0, len(pins))
+	for _, pin := range pins {
+		pin = strings.TrimSpace(pin)
+		if pin == "" {
+			continue
+		}
+		pin = strings.TrimPrefix(strings.TrimPrefix(pin, "sha256/"), "/")
+		if sum, err := base64.StdEncoding.DecodeString(pin); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid SPKI pin %q: must be a base64 SHA-256 hash", pin)
+		}
+		parsed = append(parsed, pin)
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("no SPKI pins given")
+	}
+	return parsed, nil
+}
+
+// VerifyPins returns nil if any certificate in the presented chain matches
+// one of the pins, so either the server or an issuing CA can be pinned.
+func VerifyPins(certs []*x509.Certificate, pins []string) error {
+	for _, cert := range certs {
+		pin := SPKIPin(cert)
+		for _, want := range pins {
+			if pin == want {
+				return nil
+			}
+		}
+	}
+	if len(certs) == 0 {
+		return errors.New("SPKI pin mismatch: no certificates presented")
+	}
+	return fmt.Errorf("SPKI pin mismatch: server key is sha256/%s", SPKIPin(certs[0]))
+}
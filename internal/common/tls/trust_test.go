@@ -0,0 +1,222 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert creates a self-signed client certificate and returns the
+// paths of its PEM certificate and key files.
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile, cert
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+}
+
+func TestTrustOptions_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+
+	opts := &TrustOptions{CAFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("Validate() error: %v", err)
+	}
+	cfg := &tls.Config{}
+	if err := opts.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET with client certificate failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Without the CA bundle the server certificate is not trusted
+	cfg = &tls.Config{}
+	if err := (&TrustOptions{ClientCertFile: certFile, ClientKeyFile: keyFile}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("GET without CA bundle succeeded, want certificate error")
+	}
+}
+
+func TestTrustOptions_Pins(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	pin := SPKIPin(server.Certificate())
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr bool
+	}{
+		{"matching pin", []string{"sha256//" + pin}, false},
+		{"one of several", []string{strings.Repeat("A", 43) + "=", pin}, false},
+		{"mismatch", []string{strings.Repeat("A", 43) + "="}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Pins apply even when chain verification is skipped
+			cfg := &tls.Config{InsecureSkipVerify: true}
+			if err := (&TrustOptions{Pins: tt.pins}).Apply(cfg); err != nil {
+				t.Fatalf("Apply() error: %v", err)
+			}
+			conn, err := tls.Dial("tcp", server.Listener.Addr().String(), cfg)
+			if err == nil {
+				conn.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Dial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && err != nil && !strings.Contains(err.Error(), "sha256/"+pin) {
+				t.Errorf("error %q does not report the server pin", err)
+			}
+		})
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	valid := strings.Repeat("A", 43) + "="
+	tests := []struct {
+		name    string
+		pins    []string
+		want    int
+		wantErr bool
+	}{
+		{"bare", []string{valid}, 1, false},
+		{"sha256/ prefix", []string{"sha256/" + valid}, 1, false},
+		{"sha256// prefix", []string{"sha256//" + valid}, 1, false},
+		{"blank entries skipped", []string{" ", valid + " ", ""}, 1, false},
+		{"not base64", []string{"not-a-pin!"}, 0, true},
+		{"wrong length", []string{"AAAA"}, 0, true},
+		{"empty", []string{""}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePins(tt.pins)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("ParsePins() = %q, want %d pins", got, tt.want)
+			}
+			for _, pin := range got {
+				if pin != valid {
+					t.Errorf("ParsePins() pin = %q, want %q", pin, valid)
+				}
+			}
+		})
+	}
+}
+
+func TestTrustOptions_Validate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeClientCert(t, dir)
+
+	// Key appended to the certificate file
+	combined := filepath.Join(dir, "combined.pem")
+	certPEM, _ := os.ReadFile(certFile)
+	keyPEM, _ := os.ReadFile(keyFile)
+	if err := os.WriteFile(combined, append(certPEM, keyPEM...), 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	badPFX := filepath.Join(dir, "client.pfx")
+	if err := os.WriteFile(badPFX, []byte("not pkcs12"), 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    TrustOptions
+		wantErr string
+	}{
+		{"empty", TrustOptions{}, ""},
+		{"separate key", TrustOptions{ClientCertFile: certFile, ClientKeyFile: keyFile}, ""},
+		{"key in certificate file", TrustOptions{ClientCertFile: combined}, ""},
+		{"key without certificate", TrustOptions{ClientKeyFile: keyFile}, "-clientkey requires -clientcert"},
+		{"certificate without key", TrustOptions{ClientCertFile: certFile}, "invalid client certificate"},
+		{"missing CA bundle", TrustOptions{CAFile: filepath.Join(dir, "missing.pem")}, "CA bundle"},
+		{"CA bundle without certificates", TrustOptions{CAFile: keyFile}, "no PEM certificates"},
+		{"invalid PKCS#12", TrustOptions{ClientCertFile: badPFX}, "PKCS#12"},
+		{"invalid pin", TrustOptions{Pins: []string{"bogus"}}, "invalid SPKI pin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
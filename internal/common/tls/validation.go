@@ -1,3 +1,5 @@
+// Package tls provides protocol-neutral analysis of TLS connections and
+// certificate chains, shared by the SMTP, IMAP, POP3 and JMAP tools.
 package tls
 
 import (
@@ -144,6 +146,16 @@ func CheckTLSWarnings(tlsInfo *TLSInfo, certInfo *CertificateInfo, skipVerify bo
 		if validityDays > 398 {
 			warnings = append(warnings, fmt.Sprintf("Certificate validity period exceeds 398 days (%d days) - may not be trusted by modern browsers", validityDays))
 		}
+
+		// Revocation (stapled OCSP response and online checks)
+		warnings = append(warnings, revocationWarnings(certInfo)...)
+
+		// Certificate Transparency
+		if certInfo.SCTError != "" {
+			warnings = append(warnings, fmt.Sprintf("Embedded SCT list could not be parsed: %s", certInfo.SCTError))
+		} else if len(certInfo.SCTs) == 0 && !certInfo.IsSelfSigned && certInfo.ChainLength > 0 {
+			warnings = append(warnings, "No Signed Certificate Timestamps - certificate is not logged in Certificate Transparency (expected only for private CAs)")
+		}
 	}
 
 	// Skip verify warning
@@ -7,10 +7,16 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
 )
 
 // ValidateEmail performs basic email format validation.
 // Checks for the presence of @ and validates the local and domain parts.
+// Internationalized addresses (RFC 6531) are accepted: the local part may
+// contain UTF-8 and a non-ASCII domain must be a valid IDN.
 func ValidateEmail(email string) error {
 	email = strings.TrimSpace(email)
 	if email == "" {
@@ -23,9 +29,32 @@ func ValidateEmail(email string) error {
 	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 		return fmt.Errorf("invalid email format: %s", email)
 	}
+	if !utf8.ValidString(email) {
+		return fmt.Errorf("invalid email format: %q (not valid UTF-8)", email)
+	}
+	for _, ch := range email {
+		if unicode.IsControl(ch) || unicode.IsSpace(ch) {
+			return fmt.Errorf("invalid email format: %q (contains whitespace or control characters)", email)
+		}
+	}
+	if !isASCII(parts[1]) {
+		if _, err := idna.Lookup.ToASCII(parts[1]); err != nil {
+			return fmt.Errorf("invalid email format: %s (invalid internationalized domain: %v)", email, err)
+		}
+	}
 	return nil
 }
 
+// isASCII reports whether s contains only 7-bit ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
 // ValidateEmails validates a slice of email addresses.
 // Returns an error if any email in the slice is invalid.
 func ValidateEmails(emails []string, fieldName string) error {
@@ -139,6 +168,38 @@ func ValidateFilePath(path, fieldName string) error {
 	return nil
 }
 
+// ValidateDirPath validates an output directory, such as the -savechain
+// target. The same traversal policy as ValidateFilePath applies; the
+// directory does not have to exist yet, but if the path exists it must be
+// a directory.
+//
+// Empty paths are allowed for optional fields (returns nil).
+func ValidateDirPath(path, fieldName string) error {
+	if path == "" {
+		return nil
+	}
+	if strings.ContainsRune(path, 0) {
+		return fmt.Errorf("%s: invalid path", fieldName)
+	}
+
+	cleanPath := filepath.Clean(path)
+	if !filepath.IsAbs(path) && strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("%s: relative paths with '..' are not allowed (use absolute path if directory is outside working directory)", fieldName)
+	}
+
+	fileInfo, err := os.Stat(cleanPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // Created when the first file is written
+		}
+		return fmt.Errorf("%s: cannot access directory: %w", fieldName, err)
+	}
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("%s: not a directory: %s", fieldName, path)
+	}
+	return nil
+}
+
 // ValidateHostname validates a hostname or IP address.
 // Accepts DNS names, IPv4 addresses, and IPv6 addresses.
 func ValidateHostname(hostname string) error {
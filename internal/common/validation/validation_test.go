@@ -71,6 +71,43 @@ func TestValidateFilePath(t *testing.T) {
 	}
 }
 
+// TestValidateDirPath tests output directory validation
+func TestValidateDirPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpFile := filepath.Join(tmpDir, "file.txt")
+	if err := os.WriteFile(tmpFile, nil, 0600); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+		errMsg  string
+	}{
+		{"Valid: Empty path (optional field)", "", false, ""},
+		{"Valid: Existing directory", tmpDir, false, ""},
+		{"Valid: Directory to be created", filepath.Join(tmpDir, "chains", "new"), false, ""},
+		{"Valid: Relative path", "chains", false, ""},
+		{"Security: Path traversal", "../../tmp/chains", true, "not allowed"},
+		{"Error: Path is a file", tmpFile, true, "not a directory"},
+		{"Error: Invalid path characters (NUL)", "dir\x00name", true, "invalid path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDirPath(tt.path, "TestDir")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDirPath() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateDirPath() error message = %v, should contain %v", err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
 // TestValidateEmail tests email validation including security checks for injection attacks
 func TestValidateEmail(t *testing.T) {
 	tests := []struct {
@@ -84,6 +121,9 @@ func TestValidateEmail(t *testing.T) {
 		{"Valid: Email with plus", "test.name+tag@example.co.uk", false, ""},
 		{"Valid: Email with dots", "first.last@sub.domain.com", false, ""},
 		{"Valid: Email with numbers", "user123@example456.com", false, ""},
+		{"Valid: UTF-8 local part", "用户@example.com", false, ""},
+		{"Valid: IDN domain", "user@bücher.example", false, ""},
+		{"Valid: UTF-8 local part and IDN domain", "josé@ñandú.example", false, ""},
 
 		// Invalid format
 		{"Error: Empty email", "", true, "empty"},
@@ -91,6 +131,9 @@ func TestValidateEmail(t *testing.T) {
 		{"Error: Multiple @ symbols", "user@@example.com", true, "invalid"},
 		{"Error: Empty local part", "@example.com", true, "invalid"},
 		{"Error: Empty domain", "user@", true, "invalid"},
+		{"Error: Space in local part", "first last@example.com", true, "whitespace"},
+		{"Error: Invalid IDN domain", "user@bü_cher.example", true, "internationalized domain"},
+		{"Error: Invalid UTF-8", "us\xffer@example.com", true, "UTF-8"},
 
 		// Security: Potential injection attempts
 		{"Security: CRLF injection attempt", "user@example.com\r\nBcc: attacker@evil.com", true, "invalid"},
@@ -29,6 +29,37 @@ func (r *POP3Response) Error() string {
 	return r.Message
 }
 
+// ResponseError is a -ERR reply to a command.
+type ResponseError struct {
+	Command string // Failed command or operation, e.g. "PASS"
+	Message string // Reply text after -ERR, including any response code
+}
+
+func (e *ResponseError) Error() string {
+	return e.Command + " failed: " + e.Message
+}
+
+// Code returns the extended response code (RFC 2449) at the start of the
+// reply text, e.g. "SYS/TEMP" for "-ERR [SYS/TEMP] try later", or "".
+func (e *ResponseError) Code() string {
+	if !strings.HasPrefix(e.Message, "[") {
+		return ""
+	}
+	end := strings.Index(e.Message, "]")
+	if end < 0 {
+		return ""
+	}
+	return strings.ToUpper(e.Message[1:end])
+}
+
+// Temporary reports whether the response code names a transient condition:
+// IN-USE (mailbox locked by another session), LOGIN-DELAY (RFC 2449) or
+// SYS/TEMP (RFC 3206). AUTH, SYS/PERM and replies without a code are not.
+func (e *ResponseError) Temporary() bool {
+	code := e.Code()
+	return code == "IN-USE" || code == "LOGIN-DELAY" || code == "SYS/TEMP" || strings.HasPrefix(code, "SYS/TEMP/")
+}
+
 // ReadResponse reads a single-line POP3 response.
 // POP3 responses start with +OK or -ERR followed by optional text.
 func ReadResponse(reader *bufio.Reader) (*POP3Response, error) {
@@ -0,0 +1,33 @@
+package protocol
+
+import "testing"
+
+func TestResponseError(t *testing.T) {
+	tests := []struct {
+		message   string
+		code      string
+		temporary bool
+	}{
+		{"[SYS/TEMP] Server busy, try later", "SYS/TEMP", true},
+		{"[in-use] Mailbox locked", "IN-USE", true},
+		{"[LOGIN-DELAY] Wait 5 minutes", "LOGIN-DELAY", true},
+		{"[AUTH] Invalid credentials", "AUTH", false},
+		{"[SYS/PERM] Mailbox disabled", "SYS/PERM", false},
+		{"Authentication failed", "", false},
+		{"[unterminated", "", false},
+	}
+
+	for _, tt := range tests {
+		err := &ResponseError{Command: "PASS", Message: tt.message}
+		if got := err.Code(); got != tt.code {
+			t.Errorf("Code(%q) = %q, want %q", tt.message, got, tt.code)
+		}
+		if got := err.Temporary(); got != tt.temporary {
+			t.Errorf("Temporary(%q) = %v, want %v", tt.message, got, tt.temporary)
+		}
+	}
+
+	if got := (&ResponseError{Command: "PASS", Message: "denied"}).Error(); got != "PASS failed: denied" {
+		t.Errorf("Error() = %q", got)
+	}
+}
@@ -0,0 +1,188 @@
+package dsn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// Report is a parsed Delivery Status Notification (RFC 3464).
+// A DSN is a multipart/report message with report-type=delivery-status whose
+// second part (message/delivery-status) holds one block of per-message fields
+// followed by one block of fields per recipient.
+type Report struct {
+	Subject       string            // Subject of the DSN message itself
+	ReportingMTA  string            // Reporting-MTA field (e.g., "dns; mx.example.com")
+	EnvelopeID    string            // Original-Envelope-Id (the ENVID sent with MAIL FROM)
+	ArrivalDate   string            // Arrival-Date field
+	HumanReadable string            // First (human-readable) part of the report
+	Recipients    []RecipientStatus // One entry per recipient block
+}
+
+// RecipientStatus holds the per-recipient fields of a DSN.
+type RecipientStatus struct {
+	FinalRecipient    string // Final-Recipient address (type prefix removed)
+	OriginalRecipient string // Original-Recipient address (the ORCPT sent with RCPT TO)
+	Action            string // failed, delayed, delivered, relayed, expanded
+	Status            string // RFC 3463 status code (e.g., 5.1.1)
+	RemoteMTA         string // Remote-MTA field
+	DiagnosticCode    string // Diagnostic-Code field (e.g., "smtp; 550 5.1.1 User unknown")
+	LastAttemptDate   string // Last-Attempt-Date field
+	WillRetryUntil    string // Will-Retry-Until field (delayed DSNs)
+}
+
+// IsFailure reports whether the recipient permanently failed.
+func (r RecipientStatus) IsFailure() bool {
+	return strings.EqualFold(r.Action, "failed")
+}
+
+// IsDelayed reports whether delivery to the recipient was delayed.
+func (r RecipientStatus) IsDelayed() bool {
+	return strings.EqualFold(r.Action, "delayed")
+}
+
+// ParseReport reads an RFC 5322 message (for example an .eml file) and
+// extracts the delivery status report it contains.
+func ParseReport(r io.Reader) (*Report, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Type: %w", err)
+	}
+	if mediaType != "multipart/report" {
+		return nil, fmt.Errorf("not a delivery status notification (Content-Type is %s, expected multipart/report)", mediaType)
+	}
+	if reportType := params["report-type"]; !strings.EqualFold(reportType, "delivery-status") {
+		return nil, fmt.Errorf("unsupported report-type: %q (expected delivery-status)", reportType)
+	}
+
+	report := &Report{Subject: decodeHeader(msg.Header.Get("Subject"))}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	foundStatus := false
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report part: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report part: %w", err)
+		}
+
+		switch strings.ToLower(partType) {
+		case "message/delivery-status", "message/global-delivery-status":
+			if err := parseDeliveryStatus(data, report); err != nil {
+				return nil, err
+			}
+			foundStatus = true
+		case "text/plain", "":
+			if report.HumanReadable == "" {
+				report.HumanReadable = strings.TrimSpace(string(data))
+			}
+		}
+	}
+
+	if !foundStatus {
+		return nil, fmt.Errorf("report does not contain a message/delivery-status part")
+	}
+
+	return report, nil
+}
+
+// parseDeliveryStatus parses the field blocks of a message/delivery-status part.
+// The first block holds per-message fields; every following block describes one recipient.
+func parseDeliveryStatus(data []byte, report *Report) error {
+	blocks, err := readFieldBlocks(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse delivery-status fields: %w", err)
+	}
+	if len(blocks) == 0 {
+		return fmt.Errorf("delivery-status part is empty")
+	}
+
+	perMessage := blocks[0]
+	report.ReportingMTA = perMessage.Get("Reporting-MTA")
+	report.EnvelopeID = perMessage.Get("Original-Envelope-Id")
+	report.ArrivalDate = perMessage.Get("Arrival-Date")
+
+	for _, block := range blocks[1:] {
+		report.Recipients = append(report.Recipients, RecipientStatus{
+			FinalRecipient:    stripAddressType(block.Get("Final-Recipient")),
+			OriginalRecipient: stripAddressType(block.Get("Original-Recipient")),
+			Action:            strings.ToLower(block.Get("Action")),
+			Status:            block.Get("Status"),
+			RemoteMTA:         block.Get("Remote-MTA"),
+			DiagnosticCode:    block.Get("Diagnostic-Code"),
+			LastAttemptDate:   block.Get("Last-Attempt-Date"),
+			WillRetryUntil:    block.Get("Will-Retry-Until"),
+		})
+	}
+
+	return nil
+}
+
+// readFieldBlocks splits header-style field groups separated by blank lines.
+func readFieldBlocks(data []byte) ([]textproto.MIMEHeader, error) {
+	var blocks []textproto.MIMEHeader
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+
+	for {
+		// Skip blank lines between blocks
+		for {
+			peek, err := reader.R.Peek(1)
+			if err != nil {
+				return blocks, nil
+			}
+			if peek[0] != '\r' && peek[0] != '\n' {
+				break
+			}
+			if _, err := reader.R.ReadByte(); err != nil {
+				return blocks, nil
+			}
+		}
+
+		block, err := reader.ReadMIMEHeader()
+		if len(block) > 0 {
+			blocks = append(blocks, block)
+		}
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// stripAddressType removes the address-type prefix ("rfc822;") from a recipient field.
+func stripAddressType(value string) string {
+	if _, addr, ok := strings.Cut(value, ";"); ok {
+		return strings.TrimSpace(addr)
+	}
+	return strings.TrimSpace(value)
+}
+
+// decodeHeader decodes RFC 2047 encoded-words, returning the raw value on failure.
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,121 @@
+//go:build !integration
+// +build !integration
+
+package dsn
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleDSN = "From: MAILER-DAEMON@mx.example.com\r\n" +
+	"To: sender@example.com\r\n" +
+	"Subject: =?UTF-8?Q?Undelivered_Mail_Returned_to_Sender?=\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"BOUNDARY\"\r\n" +
+	"\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: text/plain; charset=us-ascii\r\n" +
+	"\r\n" +
+	"This is the mail system at host mx.example.com.\r\n" +
+	"\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.example.com\r\n" +
+	"Original-Envelope-Id: test+20run\r\n" +
+	"Arrival-Date: Mon, 12 Jan 2026 10:00:00 +0000\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; missing@example.org\r\n" +
+	"Original-Recipient: rfc822;missing@example.org\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Remote-MTA: dns; mx.example.org\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 <missing@example.org>: Recipient\r\n" +
+	"    address rejected: User unknown\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; slow@example.net\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.4.1\r\n" +
+	"Will-Retry-Until: Fri, 16 Jan 2026 10:00:00 +0000\r\n" +
+	"\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"Subject: original\r\n" +
+	"\r\n" +
+	"--BOUNDARY--\r\n"
+
+// TestParseReport tests parsing of a multi-recipient DSN
+func TestParseReport(t *testing.T) {
+	report, err := ParseReport(strings.NewReader(sampleDSN))
+	if err != nil {
+		t.Fatalf("ParseReport() error = %v", err)
+	}
+
+	if report.Subject != "Undelivered Mail Returned to Sender" {
+		t.Errorf("Subject = %q", report.Subject)
+	}
+	if report.ReportingMTA != "dns; mx.example.com" {
+		t.Errorf("ReportingMTA = %q", report.ReportingMTA)
+	}
+	if report.EnvelopeID != "test+20run" {
+		t.Errorf("EnvelopeID = %q", report.EnvelopeID)
+	}
+	if !strings.Contains(report.HumanReadable, "mail system") {
+		t.Errorf("HumanReadable = %q", report.HumanReadable)
+	}
+	if len(report.Recipients) != 2 {
+		t.Fatalf("got %d recipients, want 2", len(report.Recipients))
+	}
+
+	failed := report.Recipients[0]
+	if failed.FinalRecipient != "missing@example.org" || failed.OriginalRecipient != "missing@example.org" {
+		t.Errorf("recipient addresses = %q / %q", failed.FinalRecipient, failed.OriginalRecipient)
+	}
+	if !failed.IsFailure() || failed.Status != "5.1.1" {
+		t.Errorf("failed recipient Action=%q Status=%q", failed.Action, failed.Status)
+	}
+	if !strings.Contains(failed.DiagnosticCode, "User unknown") {
+		t.Errorf("folded Diagnostic-Code not unfolded: %q", failed.DiagnosticCode)
+	}
+
+	delayed := report.Recipients[1]
+	if !delayed.IsDelayed() || delayed.Status != "4.4.1" || delayed.WillRetryUntil == "" {
+		t.Errorf("delayed recipient = %+v", delayed)
+	}
+}
+
+// TestParseReport_Errors tests rejection of non-DSN messages
+func TestParseReport_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		wantErr string
+	}{
+		{
+			name:    "Plain message",
+			message: "Subject: hi\r\nContent-Type: text/plain\r\n\r\nhello\r\n",
+			wantErr: "not a delivery status notification",
+		},
+		{
+			name:    "Wrong report type",
+			message: "Content-Type: multipart/report; report-type=disposition-notification; boundary=X\r\n\r\n--X--\r\n",
+			wantErr: "unsupported report-type",
+		},
+		{
+			name:    "Missing status part",
+			message: "Content-Type: multipart/report; report-type=delivery-status; boundary=X\r\n\r\n--X\r\nContent-Type: text/plain\r\n\r\nhi\r\n--X--\r\n",
+			wantErr: "does not contain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseReport(strings.NewReader(tt.message))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseReport() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package exchange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Build is a released Exchange Server build.
+type Build struct {
+	Number   string // Build number as shown in the banner, e.g. "15.2.1544.4"
+	Product  string // e.g. "Exchange 2019"
+	Update   string // Cumulative update ("RTM" for the first release)
+	Security string // Security or hotfix update, empty for the CU release itself
+	Released string // Release date (YYYY-MM-DD)
+}
+
+// Name returns the update and security update, e.g. "CU14 Nov24SUv2".
+func (b *Build) Name() string {
+	if b.Security == "" {
+		return b.Update
+	}
+	return b.Update + " " + b.Security
+}
+
+// builds lists the cumulative updates of Exchange 2013 and later, and the
+// security updates of recent ones, newest first per product. Source: "Exchange Server build numbers and release dates" on
+// Microsoft Learn; add new CUs and SUs at the top of their product.
+var builds = []Build{
+	{"15.2.2562.29", "Exchange Server SE", "RTM", "Oct25SU", "2025-10-14"},
+	{"15.2.2562.20", "Exchange Server SE", "RTM", "Aug25SU", "2025-08-12"},
+	{"15.2.2562.17", "Exchange Server SE", "RTM", "", "2025-07-01"},
+
+	{"15.2.1748.36", "Exchange 2019", "CU15", "Aug25SU", "2025-08-12"},
+	{"15.2.1748.24", "Exchange 2019", "CU15", "Apr25HU", "2025-04-18"},
+	{"15.2.1748.10", "Exchange 2019", "CU15", "", "2025-02-10"},
+	{"15.2.1544.25", "Exchange 2019", "CU14", "Apr25HU", "2025-04-18"},
+	{"15.2.1544.14", "Exchange 2019", "CU14", "Nov24SUv2", "2024-11-27"},
+	{"15.2.1544.4", "Exchange 2019", "CU14", "", "2024-02-13"},
+	{"15.2.1258.39", "Exchange 2019", "CU13", "Nov24SUv2", "2024-11-27"},
+	{"15.2.1258.12", "Exchange 2019", "CU13", "", "2023-05-03"},
+	{"15.2.1118.40", "Exchange 2019", "CU12", "Mar24SU", "2024-03-12"},
+	{"15.2.1118.30", "Exchange 2019", "CU12", "Jun23SU", "2023-06-13"},
+	{"15.2.1118.7", "Exchange 2019", "CU12", "", "2022-04-20"},
+	{"15.2.986.5", "Exchange 2019", "CU11", "", "2021-09-28"},
+	{"15.2.922.7", "Exchange 2019", "CU10", "", "2021-06-29"},
+	{"15.2.858.5", "Exchange 2019", "CU9", "", "2021-03-16"},
+	{"15.2.792.3", "Exchange 2019", "CU8", "", "2020-12-15"},
+	{"15.2.721.2", "Exchange 2019", "CU7", "", "2020-09-15"},
+	{"15.2.659.4", "Exchange 2019", "CU6", "", "2020-06-16"},
+	{"15.2.595.3", "Exchange 2019", "CU5", "", "2020-03-17"},
+	{"15.2.529.5", "Exchange 2019", "CU4", "", "2019-12-17"},
+	{"15.2.464.5", "Exchange 2019", "CU3", "", "2019-09-17"},
+	{"15.2.397.3", "Exchange 2019", "CU2", "", "2019-06-18"},
+	{"15.2.330.5", "Exchange 2019", "CU1", "", "2019-02-12"},
+	{"15.2.221.12", "Exchange 2019", "RTM", "", "2018-10-22"},
+
+	{"15.1.2507.55", "Exchange 2016", "CU23", "Apr25HU", "2025-04-18"},
+	{"15.1.2507.44", "Exchange 2016", "CU23", "Nov24SUv2", "2024-11-27"},
+	{"15.1.2507.6", "Exchange 2016", "CU23", "", "2022-04-20"},
+	{"15.1.2375.7", "Exchange 2016", "CU22", "", "2021-09-28"},
+	{"15.1.2308.8", "Exchange 2016", "CU21", "", "2021-06-29"},
+	{"15.1.2242.4", "Exchange 2016", "CU20", "", "2021-03-16"},
+	{"15.1.2176.2", "Exchange 2016", "CU19", "", "2020-12-15"},
+	{"15.1.2106.2", "Exchange 2016", "CU18", "", "2020-09-15"},
+	{"15.1.2044.4", "Exchange 2016", "CU17", "", "2020-06-16"},
+	{"15.1.1979.3", "Exchange 2016", "CU16", "", "2020-03-17"},
+	{"15.1.1913.5", "Exchange 2016", "CU15", "", "2019-12-17"},
+	{"15.1.1847.3", "Exchange 2016", "CU14", "", "2019-09-17"},
+	{"15.1.1779.2", "Exchange 2016", "CU13", "", "2019-06-18"},
+	{"15.1.1713.5", "Exchange 2016", "CU12", "", "2019-02-12"},
+
+	{"15.0.1497.48", "Exchange 2013", "CU23", "Mar23SU", "2023-03-14"},
+	{"15.0.1497.2", "Exchange 2013", "CU23", "", "2019-06-18"},
+
+	{"14.3.123.4", "Exchange 2010", "SP3", "", "2013-02-12"},
+}
+
+// endOfSupport is the end of extended support of each product. Exchange
+// Server SE follows the Modern Lifecycle Policy: only the two latest CUs
+// are supported.
+var endOfSupport = map[string]string{
+	"Exchange 2010": "2020-10-13",
+	"Exchange 2013": "2023-04-11",
+	"Exchange 2016": "2025-10-14",
+	"Exchange 2019": "2025-10-14",
+}
+
+// productLines names the product of each Exchange 2010 and later version
+// line (major.minor), for builds older than every row of their line.
+var productLines = map[[2]int]string{
+	{14, 0}: "Exchange 2010",
+	{14, 1}: "Exchange 2010",
+	{14, 2}: "Exchange 2010",
+	{14, 3}: "Exchange 2010",
+	{15, 0}: "Exchange 2013",
+	{15, 1}: "Exchange 2016",
+	{15, 2}: "Exchange 2019",
+}
+
+// BuildInfo describes where a build stands against the build table.
+type BuildInfo struct {
+	Number  string // Build number from the banner
+	Product string
+	Update  string // Name of the matching build, e.g. "CU14 Nov24SUv2"
+	Exact   bool   // The build is in the table; otherwise Update is the closest older build
+
+	LatestSecurity *Build // Newest build of the same CU, nil for builds older than the table
+	LatestCU       *Build // Newest build of the product
+
+	OutOfSupport     bool   // The product or its CU is no longer supported
+	EndOfSupport     string // When support ended (YYYY-MM-DD), if known
+	BelowLatestBuild bool   // A newer security update exists for the CU
+}
+
+// LookupBuild places a build number in the build table. It returns nil if
+// the number is not a four-part Exchange 2010 or later build. A build older
+// than every row of its product line is reported as out of support. now
+// decides whether support of the product has ended.
+func LookupBuild(number string, now time.Time) *BuildInfo {
+	version, ok := parseBuild(number)
+	if !ok || version[0] < 14 {
+		return nil
+	}
+
+	// Closest known build at or below the number in the same product line
+	var match *Build
+	for i := range builds {
+		b := &builds[i]
+		v, _ := parseBuild(b.Number)
+		if v[0] != version[0] || v[1] != version[1] || compareBuilds(v, version) > 0 {
+			continue
+		}
+		if match == nil || compareBuilds(v, mustParseBuild(match.Number)) > 0 {
+			match = b
+		}
+	}
+	if match == nil {
+		return lookupOlderBuild(number, version, now)
+	}
+
+	info := &BuildInfo{
+		Number:  number,
+		Product: match.Product,
+		Update:  match.Name(),
+		Exact:   match.Number == number,
+	}
+	if !info.Exact {
+		info.Update = match.Update
+		if match.Security != "" {
+			info.Update += " (newer than " + match.Security + ")"
+		}
+	}
+
+	var cus []string
+	for i := range builds {
+		b := &builds[i]
+		if b.Product != match.Product {
+			continue
+		}
+		if info.LatestCU == nil {
+			info.LatestCU = b // Table is newest first
+		}
+		if b.Update == match.Update && info.LatestSecurity == nil {
+			info.LatestSecurity = b
+		}
+		if len(cus) == 0 || cus[len(cus)-1] != b.Update {
+			cus = append(cus, b.Update)
+		}
+	}
+	info.BelowLatestBuild = compareBuilds(version, mustParseBuild(info.LatestSecurity.Number)) < 0
+
+	if end, ok := endOfSupport[match.Product]; ok {
+		if supportEnded(end, now) {
+			info.OutOfSupport = true
+			info.EndOfSupport = end
+		}
+	} else if len(cus) > 2 && match.Update != cus[0] && match.Update != cus[1] {
+		info.OutOfSupport = true
+	}
+	return info
+}
+
+// lookupOlderBuild describes a build older than every row of its product
+// line, such as Exchange 2016 CU11. Its update is older than any listed, so
+// it is out of support even while the product is not.
+func lookupOlderBuild(number string, version [4]int, now time.Time) *BuildInfo {
+	product, ok := productLines[[2]int{version[0], version[1]}]
+	if !ok {
+		return nil
+	}
+
+	info := &BuildInfo{Number: number, Product: product, OutOfSupport: true}
+	for i := range builds {
+		b := &builds[i]
+		if b.Product != product {
+			continue
+		}
+		if info.LatestCU == nil {
+			info.LatestCU = b // Table is newest first
+		}
+		info.Update = "older than " + b.Update
+	}
+	if info.LatestCU == nil {
+		return nil
+	}
+	if end, ok := endOfSupport[product]; ok && supportEnded(end, now) {
+		info.EndOfSupport = end
+	}
+	return info
+}
+
+// supportEnded reports whether the end of support date end (YYYY-MM-DD) has
+// been reached at now.
+func supportEnded(end string, now time.Time) bool {
+	endDate, err := time.Parse("2006-01-02", end)
+	return err == nil && !now.Before(endDate)
+}
+
+// Advice returns patch-level recommendations for the build, none if it is
+// current.
+func (info *BuildInfo) Advice() []string {
+	var advice []string
+	switch {
+	case info.OutOfSupport && info.EndOfSupport != "":
+		advice = append(advice, fmt.Sprintf("%s reached end of support on %s and no longer receives security updates - upgrade to Exchange Server SE", info.Product, info.EndOfSupport))
+	case info.OutOfSupport:
+		advice = append(advice, fmt.Sprintf("%s %s is no longer supported - install %s %s", info.Product, info.Update, info.LatestCU.Product, info.LatestCU.Name()))
+	}
+	if info.BelowLatestBuild {
+		advice = append(advice, fmt.Sprintf("Build %s is below the latest update for %s known to this tool: %s (%s, released %s)",
+			info.Number, info.LatestSecurity.Update, info.LatestSecurity.Name(), info.LatestSecurity.Number, info.LatestSecurity.Released))
+	}
+	if !info.OutOfSupport && info.LatestCU.Update != info.LatestSecurity.Update {
+		advice = append(advice, fmt.Sprintf("A newer cumulative update is available: %s (%s)", info.LatestCU.Name(), info.LatestCU.Number))
+	}
+	return advice
+}
+
+// parseBuild parses a four-part build number.
+func parseBuild(number string) ([4]int, bool) {
+	var v [4]int
+	parts := strings.Split(number, ".")
+	if len(parts) != 4 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func mustParseBuild(number string) [4]int {
+	v, _ := parseBuild(number)
+	return v
+}
+
+// compareBuilds returns -1, 0 or 1 as a is older than, equal to or newer than b.
+func compareBuilds(a, b [4]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -4,15 +4,25 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"msgraphtool/internal/smtp/protocol"
 )
 
+// Exchange deployment categories.
+const (
+	CategoryServer = "Exchange Server"            // On-premises Exchange
+	CategoryOnline = "Exchange Online"            // Microsoft 365 client submission and mailbox front ends
+	CategoryEOP    = "Exchange Online Protection" // Microsoft 365 inbound MX
+)
+
 // ExchangeInfo holds information about a detected Exchange server.
 type ExchangeInfo struct {
-	IsExchange bool   // Whether the server is Exchange
-	Version    string // Exchange version (if detectable)
-	Banner     string // SMTP banner text
+	IsExchange bool       // Whether the server is Exchange
+	Category   string     // Server, Online or EOP (empty if not Exchange)
+	Version    string     // Exchange version (if detectable)
+	Build      *BuildInfo // Patch level (nil if the banner has no known build number)
+	Banner     string     // SMTP banner text
 }
 
 // DetectExchange checks if the SMTP server is Microsoft Exchange.
@@ -26,11 +36,27 @@ func DetectExchange(banner string, capabilities protocol.Capabilities) *Exchange
 
 	bannerLower := strings.ToLower(banner)
 
+	// Microsoft 365 front ends identify themselves by host name
+	// (*.mail.protection.outlook.com, *.prod.outlook.com, outlook.office365.com)
+	switch {
+	case strings.Contains(bannerLower, "protection.outlook.com"):
+		info.IsExchange = true
+		info.Category = CategoryEOP
+		info.Version = CategoryEOP
+		return info
+	case strings.Contains(bannerLower, "outlook.com") || strings.Contains(bannerLower, "outlook.office365.com"):
+		info.IsExchange = true
+		info.Category = CategoryOnline
+		info.Version = CategoryOnline
+		return info
+	}
+
 	// Check banner for Exchange signatures
 	if strings.Contains(bannerLower, "microsoft esmtp mail service") ||
 		strings.Contains(bannerLower, "microsoft exchange") {
 		info.IsExchange = true
-		info.Version = extractExchangeVersion(banner)
+		info.Category = CategoryServer
+		info.Version, info.Build = extractExchangeVersion(banner)
 		return info
 	}
 
@@ -39,8 +65,9 @@ func DetectExchange(banner string, capabilities protocol.Capabilities) *Exchange
 	for _, ext := range exchangeExtensions {
 		if capabilities.Has(ext) {
 			info.IsExchange = true
+			info.Category = CategoryServer
 			// Try to extract version from banner even if not in typical format
-			info.Version = extractExchangeVersion(banner)
+			info.Version, info.Build = extractExchangeVersion(banner)
 			return info
 		}
 	}
@@ -48,32 +75,40 @@ func DetectExchange(banner string, capabilities protocol.Capabilities) *Exchange
 	return info
 }
 
-// extractExchangeVersion attempts to parse the Exchange version from the banner.
+// extractExchangeVersion attempts to parse the Exchange version from the banner
+// and, for full build numbers, looks up the patch level.
 // Returns "Unknown" if version cannot be determined.
-func extractExchangeVersion(banner string) string {
+func extractExchangeVersion(banner string) (string, *BuildInfo) {
 	// Pattern 1: "Microsoft ESMTP MAIL Service, Version: 10.0.14393.0"
 	re1 := regexp.MustCompile(`Version:\s*(\d+\.\d+\.\d+\.\d+)`)
 	if matches := re1.FindStringSubmatch(banner); len(matches) > 1 {
-		return mapVersionNumber(matches[1])
+		build := LookupBuild(matches[1], time.Now())
+		return mapVersionNumber(matches[1], build), build
 	}
 
 	// Pattern 2: "Microsoft Exchange Server 2019"
 	re2 := regexp.MustCompile(`Microsoft Exchange Server (\d+)`)
 	if matches := re2.FindStringSubmatch(banner); len(matches) > 1 {
-		return "Exchange " + matches[1]
+		return "Exchange " + matches[1], nil
 	}
 
 	// Pattern 3: Version number in parentheses
-	re3 := regexp.MustCompile(`\((\d+\.\d+\.\d+)`)
+	re3 := regexp.MustCompile(`\((\d+\.\d+\.\d+(?:\.\d+)?)`)
 	if matches := re3.FindStringSubmatch(banner); len(matches) > 1 {
-		return mapVersionNumber(matches[1])
+		build := LookupBuild(matches[1], time.Now())
+		return mapVersionNumber(matches[1], build), build
 	}
 
-	return "Unknown"
+	return "Unknown", nil
 }
 
-// mapVersionNumber maps Exchange build numbers to friendly version names.
-func mapVersionNumber(version string) string {
+// mapVersionNumber maps Exchange build numbers to friendly version names,
+// with the cumulative and security update if the build is known.
+func mapVersionNumber(version string, build *BuildInfo) string {
+	if build != nil {
+		return fmt.Sprintf("%s %s (%s)", build.Product, build.Update, version)
+	}
+
 	// Major Exchange versions by build number
 	switch {
 	case strings.HasPrefix(version, "15.2."):
@@ -188,6 +223,23 @@ func GetExchangeRecommendations(port int, capabilities protocol.Capabilities) []
 	return recommendations
 }
 
+// GetOnlineNotes returns notes for Exchange Online and EOP endpoints, which
+// Microsoft patches and whose relay behavior is set by connectors.
+func GetOnlineNotes(category string) []string {
+	if category == CategoryEOP {
+		return []string{
+			"Exchange Online Protection is the inbound MX of Microsoft 365 - it does not accept client submission (use smtp.office365.com:587)",
+			"Relaying through EOP requires an inbound connector matching the sending IP address or certificate",
+			"Patching is done by Microsoft; no build-level action is needed",
+		}
+	}
+	return []string{
+		"SMTP AUTH client submission (smtp.office365.com:587) must be enabled for the tenant and the mailbox",
+		"Basic authentication for SMTP AUTH is being retired - use OAuth 2.0 (XOAUTH2)",
+		"Patching is done by Microsoft; no build-level action is needed",
+	}
+}
+
 // FormatExchangeInfo returns a formatted string with Exchange server information.
 func FormatExchangeInfo(info *ExchangeInfo, capabilities protocol.Capabilities) string {
 	if !info.IsExchange {
@@ -197,7 +249,14 @@ func FormatExchangeInfo(info *ExchangeInfo, capabilities protocol.Capabilities)
 	var result strings.Builder
 	result.WriteString("\n")
 	result.WriteString("═══════════════════════════════════════════════════════════\n")
-	result.WriteString("  Microsoft Exchange Server Detected\n")
+	switch info.Category {
+	case CategoryEOP:
+		result.WriteString("  Exchange Online Protection (EOP) Detected\n")
+	case CategoryOnline:
+		result.WriteString("  Exchange Online (Microsoft 365) Detected\n")
+	default:
+		result.WriteString("  Microsoft Exchange Server Detected\n")
+	}
 	result.WriteString("═══════════════════════════════════════════════════════════\n")
 	result.WriteString(fmt.Sprintf("Version: %s\n", info.Version))
 	result.WriteString(fmt.Sprintf("Banner:  %s\n", info.Banner))
@@ -213,6 +272,28 @@ func FormatExchangeInfo(info *ExchangeInfo, capabilities protocol.Capabilities)
 		result.WriteString("\n")
 	}
 
+	if info.Category == CategoryEOP || info.Category == CategoryOnline {
+		result.WriteString(fmt.Sprintf("%s Notes:\n", info.Category))
+		for _, note := range GetOnlineNotes(info.Category) {
+			result.WriteString(fmt.Sprintf("  ⚠ %s\n", note))
+		}
+		result.WriteString("═══════════════════════════════════════════════════════════\n")
+		return result.String()
+	}
+
+	// Patch level
+	result.WriteString("Patch Level:\n")
+	if info.Build == nil {
+		result.WriteString("  • Build number not shown in the banner - run Get-ExchangeServer | Format-List Name,AdminDisplayVersion to check it\n")
+	} else if advice := info.Build.Advice(); len(advice) == 0 {
+		result.WriteString("  ✓ Build is current according to the build table of this tool\n")
+	} else {
+		for _, a := range advice {
+			result.WriteString(fmt.Sprintf("  ⚠ %s\n", a))
+		}
+	}
+	result.WriteString("\n")
+
 	// Warnings
 	result.WriteString("Exchange Notes:\n")
 	warnings := GetExchangeWarnings()
@@ -0,0 +1,134 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"msgraphtool/internal/smtp/protocol"
+)
+
+func TestDetectExchange_Categories(t *testing.T) {
+	tests := []struct {
+		name         string
+		banner       string
+		caps         protocol.Capabilities
+		wantExchange bool
+		wantCategory string
+	}{
+		{"on-premises banner", "mail.contoso.com Microsoft ESMTP MAIL Service ready at Mon, 12 Oct 2026", nil, true, CategoryServer},
+		{"on-premises extension", "mail.contoso.com ready", protocol.Capabilities{"X-ANONYMOUSTLS": nil}, true, CategoryServer},
+		{"exchange online protection", "AM4PEPF00027A6C.mail.protection.outlook.com Microsoft ESMTP MAIL Service ready", nil, true, CategoryEOP},
+		{"exchange online submission", "FR0P281CA0012.outlook.office365.com Microsoft ESMTP MAIL Service ready", nil, true, CategoryOnline},
+		{"exchange online mailbox front end", "DB9PR05CA0001.eurprd05.prod.outlook.com Microsoft ESMTP MAIL Service ready", nil, true, CategoryOnline},
+		{"postfix", "mail.example.com ESMTP Postfix", nil, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := DetectExchange(tt.banner, tt.caps)
+			if info.IsExchange != tt.wantExchange || info.Category != tt.wantCategory {
+				t.Errorf("DetectExchange() = IsExchange %t, Category %q; want %t, %q", info.IsExchange, info.Category, tt.wantExchange, tt.wantCategory)
+			}
+		})
+	}
+}
+
+func TestDetectExchange_BuildFromBanner(t *testing.T) {
+	info := DetectExchange("mail.contoso.com Microsoft ESMTP MAIL Service, Version: 15.2.1118.30 ready", nil)
+	if info.Version != "Exchange 2019 CU12 Jun23SU (15.2.1118.30)" {
+		t.Errorf("Version = %q", info.Version)
+	}
+	if info.Build == nil || !info.Build.Exact {
+		t.Fatalf("Build = %+v, want exact match", info.Build)
+	}
+}
+
+func TestLookupBuild(t *testing.T) {
+	before := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		number           string
+		now              time.Time
+		wantProduct      string
+		wantUpdate       string
+		wantBelow        bool
+		wantOutOfSupport bool
+	}{
+		{"latest 2019 CU15 build before end of support", "15.2.1748.36", before, "Exchange 2019", "CU15 Aug25SU", false, false},
+		{"2019 CU15 without security update", "15.2.1748.10", before, "Exchange 2019", "CU15", true, false},
+		{"2019 after end of support", "15.2.1748.36", after, "Exchange 2019", "CU15 Aug25SU", false, true},
+		{"unlisted build between updates", "15.2.1544.20", before, "Exchange 2019", "CU14 (newer than Nov24SUv2)", true, false},
+		{"subscription edition", "15.2.2562.17", after, "Exchange Server SE", "RTM", true, false},
+		{"exchange 2013", "15.0.1497.2", before, "Exchange 2013", "CU23", true, true},
+		{"2016 older than the table", "15.1.225.42", before, "Exchange 2016", "older than CU12", false, true},
+		{"2013 older than the table", "15.0.1395.4", before, "Exchange 2013", "older than CU23", false, true},
+		{"exchange 2010 SP1", "14.1.438.0", before, "Exchange 2010", "older than SP3", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := LookupBuild(tt.number, tt.now)
+			if info == nil {
+				t.Fatalf("LookupBuild(%q) = nil", tt.number)
+			}
+			if info.Product != tt.wantProduct || info.Update != tt.wantUpdate {
+				t.Errorf("LookupBuild() = %s %s, want %s %s", info.Product, info.Update, tt.wantProduct, tt.wantUpdate)
+			}
+			if info.BelowLatestBuild != tt.wantBelow {
+				t.Errorf("BelowLatestBuild = %t, want %t", info.BelowLatestBuild, tt.wantBelow)
+			}
+			if info.OutOfSupport != tt.wantOutOfSupport {
+				t.Errorf("OutOfSupport = %t, want %t", info.OutOfSupport, tt.wantOutOfSupport)
+			}
+		})
+	}
+
+	for _, number := range []string{"15.2.1118", "10.0.14393.0", "15.3.100.1", "not a build"} {
+		if info := LookupBuild(number, before); info != nil {
+			t.Errorf("LookupBuild(%q) = %+v, want nil", number, info)
+		}
+	}
+}
+
+func TestBuildInfo_Advice(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	advice := strings.Join(LookupBuild("15.1.2507.6", now).Advice(), "\n")
+	if !strings.Contains(advice, "reached end of support on 2025-10-14") || !strings.Contains(advice, "CU23 Apr25HU") {
+		t.Errorf("Exchange 2016 advice = %q", advice)
+	}
+
+	before := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	advice = strings.Join(LookupBuild("15.1.1591.10", before).Advice(), "\n")
+	if !strings.Contains(advice, "Exchange 2016 older than CU12 is no longer supported - install Exchange 2016 CU23 Apr25HU") {
+		t.Errorf("Exchange 2016 CU11 advice = %q", advice)
+	}
+	if advice := LookupBuild("15.1.1591.10", now).Advice(); len(advice) != 1 || !strings.Contains(advice[0], "reached end of support") {
+		t.Errorf("Exchange 2016 CU11 advice after end of support = %q", advice)
+	}
+
+	if advice := LookupBuild("15.2.2562.29", now).Advice(); len(advice) != 0 {
+		t.Errorf("latest build advice = %q, want none", advice)
+	}
+}
+
+func TestFormatExchangeInfo(t *testing.T) {
+	caps := protocol.Capabilities{"STARTTLS": nil}
+
+	eop := FormatExchangeInfo(DetectExchange("X.mail.protection.outlook.com Microsoft ESMTP MAIL Service ready", caps), caps)
+	if !strings.Contains(eop, "Exchange Online Protection (EOP) Detected") || strings.Contains(eop, "Patch Level") {
+		t.Errorf("EOP output:\n%s", eop)
+	}
+
+	server := FormatExchangeInfo(DetectExchange("mail.contoso.com Microsoft ESMTP MAIL Service ready", caps), caps)
+	if !strings.Contains(server, "Patch Level:") || !strings.Contains(server, "Get-ExchangeServer") {
+		t.Errorf("server output without build:\n%s", server)
+	}
+
+	if FormatExchangeInfo(DetectExchange("mail.example.com ESMTP Postfix", caps), caps) != "" {
+		t.Error("non-Exchange server produced output")
+	}
+}
@@ -0,0 +1,199 @@
+package mtasts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WellKnownPath is the location of the policy file on the policy host (RFC 8461 section 3.2).
+const WellKnownPath = "/.well-known/mta-sts.txt"
+
+// maxPolicySize caps the policy body; RFC 8461 section 3.3 suggests 64 KiB.
+const maxPolicySize = 64 * 1024
+
+// Policy modes (RFC 8461 section 5).
+const (
+	ModeEnforce = "enforce"
+	ModeTesting = "testing"
+	ModeNone    = "none"
+)
+
+// Policy is a parsed MTA-STS policy file (RFC 8461 section 3.2).
+type Policy struct {
+	Version string   // Always "STSv1"
+	Mode    string   // enforce, testing or none
+	MX      []string // MX host patterns; "*.example.com" matches a single leftmost label
+	MaxAge  int      // Cache lifetime in seconds
+}
+
+// Matches reports whether an MX host name is covered by one of the policy's
+// mx patterns (RFC 8461 section 4.1).
+func (p *Policy) Matches(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.MX {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			label, rest, found := strings.Cut(host, ".")
+			if found && label != "" && rest == suffix {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTXTRecord finds the STSv1 record among the TXT strings published at
+// _mta-sts.<domain> and returns its id (RFC 8461 section 3.1).
+// Records that do not start with v=STSv1 are ignored; more than one STSv1
+// record means the domain has no valid policy.
+func ParseTXTRecord(records []string) (string, error) {
+	var id string
+	found := 0
+	for _, record := range records {
+		fields := parseFields(record, ";", "=")
+		if len(fields) == 0 || fields[0][0] != "v" || fields[0][1] != "STSv1" {
+			continue
+		}
+		found++
+		for _, f := range fields[1:] {
+			if f[0] == "id" {
+				id = f[1]
+			}
+		}
+	}
+
+	switch {
+	case found == 0:
+		return "", errors.New("no v=STSv1 TXT record found")
+	case found > 1:
+		return "", errors.New("multiple v=STSv1 TXT records found")
+	case id == "" || len(id) > 32 || !isAlphanumeric(id):
+		return "", fmt.Errorf("STSv1 TXT record has an invalid id %q", id)
+	}
+	return id, nil
+}
+
+// ParsePolicy parses a policy file: "key: value" lines separated by LF or CRLF.
+// Unknown keys are ignored; mx may repeat.
+func ParsePolicy(r io.Reader) (*Policy, error) {
+	policy := &Policy{MaxAge: -1}
+	scanner := bufio.NewScanner(io.LimitReader(r, maxPolicySize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid policy line: %q", line)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "version":
+			policy.Version = value
+		case "mode":
+			policy.Mode = value
+		case "mx":
+			policy.MX = append(policy.MX, value)
+		case "max_age":
+			maxAge, err := strconv.Atoi(value)
+			if err != nil || maxAge < 0 {
+				return nil, fmt.Errorf("invalid max_age: %q", value)
+			}
+			policy.MaxAge = maxAge
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	if policy.Version != "STSv1" {
+		return nil, fmt.Errorf("unsupported policy version: %q", policy.Version)
+	}
+	switch policy.Mode {
+	case ModeEnforce, ModeTesting, ModeNone:
+	default:
+		return nil, fmt.Errorf("invalid policy mode: %q", policy.Mode)
+	}
+	if policy.MaxAge < 0 {
+		return nil, errors.New("policy has no max_age")
+	}
+	if len(policy.MX) == 0 && policy.Mode != ModeNone {
+		return nil, errors.New("policy has no mx entries")
+	}
+	return policy, nil
+}
+
+// PolicyURL returns the policy URL for domain. baseURL replaces the default
+// https://mta-sts.<domain> (for example to point at a local stand-in).
+func PolicyURL(baseURL, domain string) string {
+	if baseURL == "" {
+		baseURL = "https://mta-sts." + strings.TrimSuffix(domain, ".")
+	}
+	return strings.TrimSuffix(baseURL, "/") + WellKnownPath
+}
+
+// FetchPolicy downloads and parses the policy at url. Redirects are not
+// followed and only a 200 text/plain response is accepted (RFC 8461 section 3.3).
+// The client's TLS configuration decides how the policy host is authenticated.
+func FetchPolicy(ctx context.Context, client *http.Client, url string) (*Policy, error) {
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy URL: %w", err)
+	}
+	resp, err := noRedirect.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("policy fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("policy fetch returned HTTP %s", resp.Status)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || mediaType != "text/plain" {
+		return nil, fmt.Errorf("policy has Content-Type %q, want text/plain", resp.Header.Get("Content-Type"))
+	}
+	return ParsePolicy(resp.Body)
+}
+
+// parseFields splits "k1=v1; k2=v2" into trimmed key/value pairs.
+func parseFields(s, sep, kv string) [][2]string {
+	var fields [][2]string
+	for _, part := range strings.Split(s, sep) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, kv)
+		fields = append(fields, [2]string{strings.TrimSpace(key), strings.TrimSpace(value)})
+	}
+	return fields
+}
+
+// isAlphanumeric reports whether s contains only ASCII letters and digits.
+func isAlphanumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
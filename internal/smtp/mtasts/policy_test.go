@@ -0,0 +1,124 @@
+package mtasts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPolicy = "version: STSv1\r\nmode: enforce\r\nmx: mail.example.com\r\nmx: *.example.net\r\nmax_age: 604800\r\n"
+
+// TestParsePolicy tests policy file parsing
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatalf("ParsePolicy() error = %v", err)
+	}
+	if policy.Mode != ModeEnforce || policy.MaxAge != 604800 || len(policy.MX) != 2 {
+		t.Errorf("ParsePolicy() = %+v", policy)
+	}
+
+	invalid := map[string]string{
+		"Wrong version":  "version: STSv2\nmode: enforce\nmx: a.example.com\nmax_age: 1\n",
+		"Invalid mode":   "version: STSv1\nmode: strict\nmx: a.example.com\nmax_age: 1\n",
+		"Missing mx":     "version: STSv1\nmode: enforce\nmax_age: 1\n",
+		"Missing maxage": "version: STSv1\nmode: enforce\nmx: a.example.com\n",
+		"Bad maxage":     "version: STSv1\nmode: enforce\nmx: a.example.com\nmax_age: soon\n",
+		"Malformed line": "version STSv1\n",
+	}
+	for name, input := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParsePolicy(strings.NewReader(input)); err == nil {
+				t.Errorf("ParsePolicy() expected error for %q", input)
+			}
+		})
+	}
+}
+
+// TestPolicyMatches tests MX pattern matching including wildcards
+func TestPolicyMatches(t *testing.T) {
+	policy := &Policy{MX: []string{"mail.example.com", "*.example.net"}}
+	tests := map[string]bool{
+		"mail.example.com":      true,
+		"MAIL.example.com.":     true,
+		"mx1.example.net":       true,
+		"example.net":           false,
+		"a.b.example.net":       false,
+		"backup.example.com":    false,
+		"mail.example.com.evil": false,
+	}
+	for host, want := range tests {
+		if got := policy.Matches(host); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+// TestParseTXTRecord tests _mta-sts TXT record parsing
+func TestParseTXTRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		want    string
+		wantErr bool
+	}{
+		{"Valid", []string{"v=STSv1; id=20260101T000000;"}, "20260101T000000", false},
+		{"Ignores other records", []string{"some-verification=abc", "v=STSv1;id=abc123"}, "abc123", false},
+		{"No record", []string{"v=spf1 -all"}, "", true},
+		{"Multiple records", []string{"v=STSv1; id=a", "v=STSv1; id=b"}, "", true},
+		{"Missing id", []string{"v=STSv1;"}, "", true},
+		{"Invalid id", []string{"v=STSv1; id=not-valid"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTXTRecord(tt.records)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseTXTRecord() = %q, %v; want %q, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestFetchPolicy tests policy retrieval from an HTTPS stand-in
+func TestFetchPolicy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case WellKnownPath:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(testPolicy))
+		case "/redirect" + WellKnownPath:
+			http.Redirect(w, r, WellKnownPath, http.StatusFound)
+		case "/html" + WellKnownPath:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(testPolicy))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	if got := PolicyURL("", "example.com"); got != "https://mta-sts.example.com/.well-known/mta-sts.txt" {
+		t.Errorf("PolicyURL() = %q", got)
+	}
+
+	policy, err := FetchPolicy(context.Background(), server.Client(), PolicyURL(server.URL+"/", "example.com"))
+	if err != nil {
+		t.Fatalf("FetchPolicy() error = %v", err)
+	}
+	if policy.Mode != ModeEnforce {
+		t.Errorf("FetchPolicy() mode = %q", policy.Mode)
+	}
+
+	for _, base := range []string{server.URL + "/redirect", server.URL + "/html", server.URL + "/missing"} {
+		if _, err := FetchPolicy(context.Background(), server.Client(), PolicyURL(base, "example.com")); err == nil {
+			t.Errorf("FetchPolicy(%s) expected error", base)
+		}
+	}
+
+	// The default client does not trust the stand-in's certificate
+	if _, err := FetchPolicy(context.Background(), http.DefaultClient, PolicyURL(server.URL, "example.com")); err == nil {
+		t.Error("FetchPolicy() expected certificate error with the default client")
+	}
+}
@@ -123,11 +123,28 @@ func (c Capabilities) SupportsChunking() bool {
 	return c.Has("CHUNKING")
 }
 
+// SupportsBinaryMIME checks if the server accepts BODY=BINARYMIME (RFC 3030).
+// BINARYMIME content can only be transferred with BDAT.
+func (c Capabilities) SupportsBinaryMIME() bool {
+	return c.Has("BINARYMIME")
+}
+
 // SupportsSMTPUTF8 checks if the server supports UTF-8 email addresses.
 func (c Capabilities) SupportsSMTPUTF8() bool {
 	return c.Has("SMTPUTF8")
 }
 
+// SupportsDSN checks if the server supports Delivery Status Notifications (RFC 3461).
+func (c Capabilities) SupportsDSN() bool {
+	return c.Has("DSN")
+}
+
+// SupportsEnhancedStatusCodes checks if replies start with an RFC 3463
+// enhanced status code (RFC 2034).
+func (c Capabilities) SupportsEnhancedStatusCodes() bool {
+	return c.Has("ENHANCEDSTATUSCODES")
+}
+
 // String returns a formatted string representation of all capabilities.
 func (c Capabilities) String() string {
 	var result []string
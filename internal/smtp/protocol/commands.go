@@ -64,12 +64,48 @@ func RCPTTO(address string) string {
 	return fmt.Sprintf("RCPT TO:<%s>\r\n", sanitizeCRLF(address))
 }
 
+// MAILFROMWithParams sends MAIL FROM with ESMTP parameters (RFC 5321 section 4.1.2).
+// Parameters are appended space-separated after the address.
+// Example: MAIL FROM:<sender@example.com> RET=HDRS ENVID=abc123
+func MAILFROMWithParams(address string, params []string) string {
+	return fmt.Sprintf("MAIL FROM:<%s>%s\r\n", sanitizeCRLF(address), formatParams(params))
+}
+
+// RCPTTOWithParams sends RCPT TO with ESMTP parameters (RFC 5321 section 4.1.2).
+// Example: RCPT TO:<recipient@example.com> NOTIFY=FAILURE,DELAY ORCPT=rfc822;recipient@example.com
+func RCPTTOWithParams(address string, params []string) string {
+	return fmt.Sprintf("RCPT TO:<%s>%s\r\n", sanitizeCRLF(address), formatParams(params))
+}
+
+// formatParams joins ESMTP parameters with a leading space, sanitizing each one.
+func formatParams(params []string) string {
+	var b strings.Builder
+	for _, p := range params {
+		if p = sanitizeCRLF(p); p != "" {
+			b.WriteString(" ")
+			b.WriteString(p)
+		}
+	}
+	return b.String()
+}
+
 // DATA sends the DATA command to begin message transmission.
 // After receiving a 354 response, send the message body followed by <CRLF>.<CRLF>
 func DATA() string {
 	return "DATA\r\n"
 }
 
+// BDAT sends a chunk header for the CHUNKING extension (RFC 3030).
+// The command is followed by exactly size octets of message data; no
+// dot-stuffing or end-of-data marker is used. The final chunk carries LAST.
+// Example: BDAT 1024 LAST
+func BDAT(size int, last bool) string {
+	if last {
+		return fmt.Sprintf("BDAT %d LAST\r\n", size)
+	}
+	return fmt.Sprintf("BDAT %d\r\n", size)
+}
+
 // RSET sends the RESET command to abort the current mail transaction.
 // This resets the SMTP session state without closing the connection.
 func RSET() string {
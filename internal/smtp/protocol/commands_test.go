@@ -206,6 +206,29 @@ func TestDATA(t *testing.T) {
 	}
 }
 
+// TestBDAT tests the BDAT chunk header builder
+func TestBDAT(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		last bool
+		want string
+	}{
+		{"Intermediate chunk", 65536, false, "BDAT 65536\r\n"},
+		{"Last chunk", 1024, true, "BDAT 1024 LAST\r\n"},
+		{"Empty last chunk", 0, true, "BDAT 0 LAST\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BDAT(tt.size, tt.last)
+			if got != tt.want {
+				t.Errorf("BDAT(%d, %v) = %q, want %q", tt.size, tt.last, got, tt.want)
+			}
+		})
+	}
+}
+
 // TestRSET tests the RSET command (static)
 func TestRSET(t *testing.T) {
 	want := "RSET\r\n"
@@ -305,6 +328,7 @@ func TestCommandCRLFEndings(t *testing.T) {
 		{"MAILFROM", MAILFROM("test@example.com")},
 		{"RCPTTO", RCPTTO("test@example.com")},
 		{"DATA", DATA()},
+		{"BDAT", BDAT(10, true)},
 		{"RSET", RSET()},
 		{"NOOP", NOOP()},
 		{"QUIT", QUIT()},
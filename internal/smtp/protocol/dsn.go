@@ -0,0 +1,133 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DSN (Delivery Status Notification) envelope parameters, RFC 3461.
+//
+// When the server advertises the DSN extension, the client may request
+// notifications per recipient (NOTIFY), record the original recipient address
+// (ORCPT), choose how much of the message is returned in a bounce (RET), and
+// tag the transaction with an envelope identifier (ENVID) that is echoed back
+// in every DSN generated for the message.
+
+// Valid NOTIFY keywords.
+const (
+	DSNNotifyNever   = "NEVER"
+	DSNNotifySuccess = "SUCCESS"
+	DSNNotifyFailure = "FAILURE"
+	DSNNotifyDelay   = "DELAY"
+)
+
+// Valid RET keywords.
+const (
+	DSNRetFull = "FULL"
+	DSNRetHdrs = "HDRS"
+)
+
+// DSNOptions holds the DSN parameters requested for a mail transaction.
+// Empty fields are omitted from the generated commands.
+type DSNOptions struct {
+	Notify []string // NOTIFY keywords (NEVER, or any of SUCCESS, FAILURE, DELAY)
+	Ret    string   // RET keyword (FULL or HDRS)
+	EnvID  string   // ENVID value (plain text, xtext-encoded on the wire)
+}
+
+// IsEmpty reports whether no DSN parameters were requested.
+func (o *DSNOptions) IsEmpty() bool {
+	return o == nil || (len(o.Notify) == 0 && o.Ret == "" && o.EnvID == "")
+}
+
+// MailParams returns the MAIL FROM parameters (RET, ENVID).
+func (o *DSNOptions) MailParams() []string {
+	if o == nil {
+		return nil
+	}
+	var params []string
+	if o.Ret != "" {
+		params = append(params, "RET="+strings.ToUpper(o.Ret))
+	}
+	if o.EnvID != "" {
+		params = append(params, "ENVID="+EncodeXText(o.EnvID))
+	}
+	return params
+}
+
+// RcptParams returns the RCPT TO parameters (NOTIFY, ORCPT) for a recipient.
+// ORCPT is always sent when any DSN option is set so that bounces can be
+// correlated with the address the client actually submitted. Internationalized
+// recipients use the utf-8 address type (RFC 6533).
+func (o *DSNOptions) RcptParams(recipient string) []string {
+	if o.IsEmpty() {
+		return nil
+	}
+	var params []string
+	if len(o.Notify) > 0 {
+		params = append(params, "NOTIFY="+strings.ToUpper(strings.Join(o.Notify, ",")))
+	}
+	if IsASCII(recipient) {
+		params = append(params, "ORCPT=rfc822;"+EncodeXText(recipient))
+	} else {
+		params = append(params, "ORCPT=utf-8;"+EncodeUTF8AddrXText(recipient))
+	}
+	return params
+}
+
+// ParseDSNNotify parses a comma-separated NOTIFY list and validates it.
+// NEVER must appear alone; the other keywords may be combined.
+func ParseDSNNotify(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var notify []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		keyword := strings.ToUpper(strings.TrimSpace(item))
+		switch keyword {
+		case DSNNotifyNever, DSNNotifySuccess, DSNNotifyFailure, DSNNotifyDelay:
+		case "":
+			continue
+		default:
+			return nil, fmt.Errorf("invalid NOTIFY keyword: %s (valid: NEVER, SUCCESS, FAILURE, DELAY)", item)
+		}
+		if !seen[keyword] {
+			seen[keyword] = true
+			notify = append(notify, keyword)
+		}
+	}
+
+	if seen[DSNNotifyNever] && len(notify) > 1 {
+		return nil, fmt.Errorf("NOTIFY=NEVER cannot be combined with other keywords")
+	}
+
+	return notify, nil
+}
+
+// ParseDSNRet validates a RET keyword and returns it upper-cased.
+func ParseDSNRet(value string) (string, error) {
+	ret := strings.ToUpper(strings.TrimSpace(value))
+	switch ret {
+	case "", DSNRetFull, DSNRetHdrs:
+		return ret, nil
+	default:
+		return "", fmt.Errorf("invalid RET value: %s (valid: FULL, HDRS)", value)
+	}
+}
+
+// EncodeXText encodes a string as xtext (RFC 3461 section 4).
+// Characters outside "!" through "~", plus "+" and "=", are encoded as "+XX".
+func EncodeXText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch < '!' || ch > '~' || ch == '+' || ch == '=' {
+			fmt.Fprintf(&b, "+%02X", ch)
+		} else {
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+//go:build !integration
+// +build !integration
+
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestEncodeXText tests xtext encoding for ENVID and ORCPT values
+func TestEncodeXText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Plain address", "user@example.com", "user@example.com"},
+		{"Plus sign", "user+tag@example.com", "user+2Btag@example.com"},
+		{"Equals sign", "a=b", "a+3Db"},
+		{"Space", "env id", "env+20id"},
+		{"Security: CRLF encoded", "id\r\nRCPT TO:<x>", "id+0D+0ARCPT+20TO:<x>"},
+		{"Empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeXText(tt.input); got != tt.want {
+				t.Errorf("EncodeXText(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseDSNNotify tests NOTIFY keyword parsing and validation
+func TestParseDSNNotify(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"Empty", "", nil, false},
+		{"Single", "failure", []string{"FAILURE"}, false},
+		{"Combined", "SUCCESS, FAILURE,DELAY", []string{"SUCCESS", "FAILURE", "DELAY"}, false},
+		{"Duplicates removed", "FAILURE,failure", []string{"FAILURE"}, false},
+		{"Never alone", "NEVER", []string{"NEVER"}, false},
+		{"Never combined", "NEVER,FAILURE", nil, true},
+		{"Invalid keyword", "ALWAYS", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDSNNotify(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDSNNotify(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDSNNotify(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseDSNRet tests RET keyword validation
+func TestParseDSNRet(t *testing.T) {
+	for _, valid := range []string{"", "full", "HDRS"} {
+		if _, err := ParseDSNRet(valid); err != nil {
+			t.Errorf("ParseDSNRet(%q) unexpected error: %v", valid, err)
+		}
+	}
+	if _, err := ParseDSNRet("BODY"); err == nil {
+		t.Error("ParseDSNRet(\"BODY\") expected error, got nil")
+	}
+}
+
+// TestDSNOptionsParams tests MAIL FROM and RCPT TO parameter generation
+func TestDSNOptionsParams(t *testing.T) {
+	opts := &DSNOptions{
+		Notify: []string{"FAILURE", "DELAY"},
+		Ret:    "hdrs",
+		EnvID:  "test 1",
+	}
+
+	if got, want := opts.MailParams(), []string{"RET=HDRS", "ENVID=test+201"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MailParams() = %v, want %v", got, want)
+	}
+
+	got := opts.RcptParams("user+a@example.com")
+	want := []string{"NOTIFY=FAILURE,DELAY", "ORCPT=rfc822;user+2Ba@example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RcptParams() = %v, want %v", got, want)
+	}
+
+	got = opts.RcptParams("josé@example.com")
+	want = []string{"NOTIFY=FAILURE,DELAY", `ORCPT=utf-8;jos\x{E9}@example.com`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RcptParams() for UTF-8 recipient = %v, want %v", got, want)
+	}
+
+	var empty *DSNOptions
+	if !empty.IsEmpty() || empty.MailParams() != nil || empty.RcptParams("a@b.c") != nil {
+		t.Error("nil DSNOptions should produce no parameters")
+	}
+}
+
+// TestCommandsWithParams tests MAIL FROM / RCPT TO builders with ESMTP parameters
+func TestCommandsWithParams(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"MAIL FROM no params", MAILFROMWithParams("a@example.com", nil), "MAIL FROM:<a@example.com>\r\n"},
+		{"MAIL FROM params", MAILFROMWithParams("a@example.com", []string{"RET=FULL", "ENVID=x"}), "MAIL FROM:<a@example.com> RET=FULL ENVID=x\r\n"},
+		{"RCPT TO params", RCPTTOWithParams("b@example.com", []string{"NOTIFY=NEVER"}), "RCPT TO:<b@example.com> NOTIFY=NEVER\r\n"},
+		{"Security: param injection", MAILFROMWithParams("a@example.com", []string{"RET=FULL\r\nRCPT TO:<x>"}), "MAIL FROM:<a@example.com> RET=FULLRCPT TO:<x>\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
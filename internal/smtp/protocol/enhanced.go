@@ -0,0 +1,440 @@
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Enhanced status codes, RFC 3463.
+//
+// A server that advertises ENHANCEDSTATUSCODES (RFC 2034) starts the text of
+// every reply with a class.subject.detail code. The class repeats the reply
+// code's verdict (2 success, 4 persistent transient failure, 5 permanent
+// failure) while subject and detail say what went wrong. Large providers
+// extend the registry with their own detail codes, so the catalog below also
+// knows the codes documented by Microsoft 365 and Gmail.
+
+// Vendors whose own enhanced status codes are recognized.
+const (
+	VendorMicrosoft = "Microsoft 365"
+	VendorGmail     = "Gmail"
+)
+
+// EnhancedStatus is a parsed class.subject.detail code.
+type EnhancedStatus struct {
+	Class   int // 2, 4 or 5
+	Subject int
+	Detail  int
+}
+
+// ParseEnhancedStatus parses a code such as "5.1.1".
+func ParseEnhancedStatus(code string) (EnhancedStatus, bool) {
+	parts := strings.Split(code, ".")
+	if len(parts) != 3 || len(parts[0]) != 1 || !strings.Contains("245", parts[0]) {
+		return EnhancedStatus{}, false
+	}
+	var n [3]int
+	for i, part := range parts {
+		if len(part) == 0 || len(part) > 3 {
+			return EnhancedStatus{}, false
+		}
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return EnhancedStatus{}, false
+			}
+		}
+		n[i], _ = strconv.Atoi(part)
+	}
+	return EnhancedStatus{Class: n[0], Subject: n[1], Detail: n[2]}, true
+}
+
+// String returns the code in class.subject.detail form.
+func (s EnhancedStatus) String() string {
+	return fmt.Sprintf("%d.%d.%d", s.Class, s.Subject, s.Detail)
+}
+
+// IsTransient reports whether the code is a persistent transient failure
+// (class 4) that may succeed when retried.
+func (s EnhancedStatus) IsTransient() bool {
+	return s.Class == 4
+}
+
+// IsPermanent reports whether the code is a permanent failure (class 5).
+func (s EnhancedStatus) IsPermanent() bool {
+	return s.Class == 5
+}
+
+var statusClasses = map[int]string{
+	2: "Success",
+	4: "Persistent transient failure",
+	5: "Permanent failure",
+}
+
+var statusSubjects = map[int]string{
+	0: "Other or undefined status",
+	1: "Addressing status",
+	2: "Mailbox status",
+	3: "Mail system status",
+	4: "Network and routing status",
+	5: "Mail delivery protocol status",
+	6: "Message content or media status",
+	7: "Security or policy status",
+}
+
+// statusDetails holds the subject.detail codes of the IANA "Enumerated
+// Status Codes" registry (RFC 3463, RFC 5248 and later additions).
+var statusDetails = map[string]string{
+	"0.0":  "Other undefined status",
+	"1.0":  "Other address status",
+	"1.1":  "Bad destination mailbox address",
+	"1.2":  "Bad destination system address",
+	"1.3":  "Bad destination mailbox address syntax",
+	"1.4":  "Destination mailbox address ambiguous",
+	"1.5":  "Destination address valid",
+	"1.6":  "Destination mailbox has moved, no forwarding address",
+	"1.7":  "Bad sender's mailbox address syntax",
+	"1.8":  "Bad sender's system address",
+	"1.9":  "Message relayed to non-compliant mailer",
+	"1.10": "Recipient address has null MX",
+	"2.0":  "Other or undefined mailbox status",
+	"2.1":  "Mailbox disabled, not accepting messages",
+	"2.2":  "Mailbox full",
+	"2.3":  "Message length exceeds administrative limit",
+	"2.4":  "Mailing list expansion problem",
+	"3.0":  "Other or undefined mail system status",
+	"3.1":  "Mail system full",
+	"3.2":  "System not accepting network messages",
+	"3.3":  "System not capable of selected features",
+	"3.4":  "Message too big for system",
+	"3.5":  "System incorrectly configured",
+	"3.6":  "Requested priority was changed",
+	"4.0":  "Other or undefined network or routing status",
+	"4.1":  "No answer from host",
+	"4.2":  "Bad connection",
+	"4.3":  "Directory server failure",
+	"4.4":  "Unable to route",
+	"4.5":  "Mail system congestion",
+	"4.6":  "Routing loop detected",
+	"4.7":  "Delivery time expired",
+	"4.8":  "Recipient domain requires REQUIRETLS",
+	"5.0":  "Other or undefined protocol status",
+	"5.1":  "Invalid command",
+	"5.2":  "Syntax error",
+	"5.3":  "Too many recipients",
+	"5.4":  "Invalid command arguments",
+	"5.5":  "Wrong protocol version",
+	"5.6":  "Authentication exchange line is too long",
+	"6.0":  "Other or undefined media error",
+	"6.1":  "Media not supported",
+	"6.2":  "Conversion required and prohibited",
+	"6.3":  "Conversion required but not supported",
+	"6.4":  "Conversion with loss performed",
+	"6.5":  "Conversion failed",
+	"6.6":  "Message content not available",
+	"6.7":  "Non-ASCII addresses not permitted for that sender/recipient",
+	"6.8":  "UTF-8 string reply is required, but not permitted by the SMTP client",
+	"6.9":  "UTF-8 header message cannot be transferred to one or more recipients",
+	"7.0":  "Other or undefined security status",
+	"7.1":  "Delivery not authorized, message refused",
+	"7.2":  "Mailing list expansion prohibited",
+	"7.3":  "Security conversion required but not possible",
+	"7.4":  "Security features not supported",
+	"7.5":  "Cryptographic failure",
+	"7.6":  "Cryptographic algorithm not supported",
+	"7.7":  "Message integrity failure",
+	"7.8":  "Authentication credentials invalid",
+	"7.9":  "Authentication mechanism is too weak",
+	"7.10": "Encryption needed",
+	"7.11": "Encryption required for requested authentication mechanism",
+	"7.12": "A password transition is needed",
+	"7.13": "User account disabled",
+	"7.14": "Trust relationship required",
+	"7.15": "Priority level is too low",
+	"7.16": "Message is too big for the specified priority",
+	"7.17": "Mailbox owner has changed",
+	"7.18": "Domain owner has changed",
+	"7.19": "RRVS test cannot be completed",
+	"7.20": "No passing DKIM signature found",
+	"7.21": "No acceptable DKIM signature found",
+	"7.22": "No valid author-matched DKIM signature found",
+	"7.23": "SPF validation failed",
+	"7.24": "SPF validation error",
+	"7.25": "Reverse DNS validation failed",
+	"7.26": "Multiple authentication checks failed",
+	"7.27": "Sender address has null MX",
+	"7.28": "Mail flood detected",
+	"7.29": "ARC validation failure",
+	"7.30": "REQUIRETLS support required",
+}
+
+// vendorStatus is a code (or range of detail codes) documented by a provider.
+type vendorStatus struct {
+	vendor    string
+	code      string // "5.7.57", or a detail range such as "5.7.606-649"
+	meaning   string
+	rateLimit bool // The code reports throttling
+}
+
+// vendorStatuses lists the provider-specific codes. Sources: "Email
+// non-delivery reports and SMTP errors in Exchange Online" on Microsoft
+// Learn and "SMTP errors and codes" in the Google Workspace Admin Help.
+var vendorStatuses = []vendorStatus{
+	{VendorMicrosoft, "4.4.7", "Message expired in the queue - the destination did not accept it in time", false},
+	{VendorMicrosoft, "4.7.26", "Message sent over IPv6 must pass SPF or DKIM", false},
+	{VendorMicrosoft, "4.7.500-699", "Sending IP temporarily throttled - reduce the sending rate and retry later", true},
+	{VendorMicrosoft, "5.1.8", "Sending account is blocked for sending suspected spam (bad outbound sender)", false},
+	{VendorMicrosoft, "5.1.10", "Recipient not found - check the address or the recipient's mail-enabled object", false},
+	{VendorMicrosoft, "5.2.121", "Recipient's per-hour limit for messages from this sender exceeded", true},
+	{VendorMicrosoft, "5.2.122", "Recipient's per-hour limit for messages from all senders exceeded", true},
+	{VendorMicrosoft, "5.4.1", "Recipient address rejected: access denied (directory-based edge blocking)", false},
+	{VendorMicrosoft, "5.4.14", "Hop count exceeded - possible mail loop", false},
+	{VendorMicrosoft, "5.4.300", "Message expired in the queue", false},
+	{VendorMicrosoft, "5.6.11", "Message contains bare line feeds or invalid characters", false},
+	{VendorMicrosoft, "5.7.1", "Sender not permitted - restricted recipient, anonymous relay or mail flow rule", false},
+	{VendorMicrosoft, "5.7.3", "Authentication unsuccessful - check the user name and password", false},
+	{VendorMicrosoft, "5.7.23", "Sender's SPF record does not authorize the sending IP", false},
+	{VendorMicrosoft, "5.7.54", "Unable to relay to a non-accepted domain - authenticate or configure a relay connector", false},
+	{VendorMicrosoft, "5.7.57", "Client was not authenticated to send anonymous mail - authenticate (SMTP AUTH) before MAIL FROM", false},
+	{VendorMicrosoft, "5.7.60", "Authenticated user has no Send As permission for the sender address", false},
+	{VendorMicrosoft, "5.7.64", "Relay access denied - no matching inbound connector for the tenant (TenantAttribution)", false},
+	{VendorMicrosoft, "5.7.124", "Sender is not in the group's allowed-senders list", false},
+	{VendorMicrosoft, "5.7.133", "Group accepts mail only from authenticated senders", false},
+	{VendorMicrosoft, "5.7.134", "Mailbox accepts mail only from authenticated senders", false},
+	{VendorMicrosoft, "5.7.139", "Authentication unsuccessful - SMTP AUTH (basic authentication) is disabled for the tenant or user", false},
+	{VendorMicrosoft, "5.7.321", "Destination requires TLS (starttls-not-supported)", false},
+	{VendorMicrosoft, "5.7.501-503", "Access denied - spam abuse detected or sender banned", false},
+	{VendorMicrosoft, "5.7.509", "Sending domain does not pass DMARC verification", false},
+	{VendorMicrosoft, "5.7.510-511", "Access denied - sender banned; request delisting through the sender portal", false},
+	{VendorMicrosoft, "5.7.520", "Organization does not allow external forwarding", false},
+	{VendorMicrosoft, "5.7.606-649", "Sending IP is blocked - request delisting at sender.office.com", false},
+	{VendorMicrosoft, "5.7.700-749", "Tenant exceeded its sending threshold or is not allowed to send", true},
+	{VendorMicrosoft, "5.7.750", "Client blocked from sending from unregistered domains", false},
+
+	{VendorGmail, "4.2.1", "Recipient is receiving mail too quickly - slow down and retry later", true},
+	{VendorGmail, "4.2.2", "Recipient mailbox is over quota", false},
+	{VendorGmail, "4.3.0", "Mail server temporarily rejected the message", false},
+	{VendorGmail, "4.4.2", "Connection timed out", false},
+	{VendorGmail, "4.7.0", "Temporarily blocked - suspicious activity, missing PTR or TLS required", true},
+	{VendorGmail, "4.7.28", "Unusual rate of unsolicited mail from the IP or domain - rate limited", true},
+	{VendorGmail, "5.2.1", "Recipient account is disabled", false},
+	{VendorGmail, "5.4.5", "Daily sending limit exceeded", true},
+	{VendorGmail, "5.7.1", "Message rejected as likely unsolicited, or the recipient does not accept mail from the sender", false},
+	{VendorGmail, "5.7.8", "Username and password not accepted - use an app password or OAuth (XOAUTH2)", false},
+	{VendorGmail, "5.7.9", "Application-specific password required", false},
+	{VendorGmail, "5.7.14", "Sign in through a web browser first, or use OAuth", false},
+	{VendorGmail, "5.7.26", "Sender is unauthenticated or fails the domain's DMARC policy - publish SPF and DKIM", false},
+	{VendorGmail, "5.7.27", "Sender domain does not pass SPF", false},
+}
+
+// matches reports whether the code falls into the entry's code or range.
+func (v *vendorStatus) matches(s EnhancedStatus) bool {
+	code, to, isRange := strings.Cut(v.code, "-")
+	base, ok := ParseEnhancedStatus(code)
+	if !ok || base.Class != s.Class || base.Subject != s.Subject {
+		return false
+	}
+	if !isRange {
+		return base.Detail == s.Detail
+	}
+	last, err := strconv.Atoi(to)
+	return err == nil && s.Detail >= base.Detail && s.Detail <= last
+}
+
+// DetectVendor recognizes Microsoft 365 and Gmail from the reply text,
+// which names their hosts (e.g. "...prod.outlook.com" or "- gsmtp").
+// Returns "" for other servers.
+func DetectVendor(message string) string {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "gsmtp") || strings.Contains(lower, "support.google.com"):
+		return VendorGmail
+	case strings.Contains(lower, "outlook.com") || strings.Contains(lower, "office365") ||
+		strings.Contains(lower, "aka.ms/") || strings.Contains(lower, "exchangelabs"):
+		return VendorMicrosoft
+	}
+	return ""
+}
+
+// lookupVendorStatus returns the provider meaning of a code. Codes that
+// reuse a registered meaning only match when the reply comes from that
+// provider; unregistered codes (e.g. 5.7.57) match without vendor markers.
+func lookupVendorStatus(s EnhancedStatus, vendor string) *vendorStatus {
+	_, registered := statusDetails[fmt.Sprintf("%d.%d", s.Subject, s.Detail)]
+	for i := range vendorStatuses {
+		v := &vendorStatuses[i]
+		if !v.matches(s) {
+			continue
+		}
+		if v.vendor == vendor || (vendor == "" && !registered) {
+			return v
+		}
+	}
+	return nil
+}
+
+// StatusExplanation describes an enhanced status code.
+type StatusExplanation struct {
+	Status       EnhancedStatus
+	Class        string // e.g. "Permanent failure"
+	Subject      string // e.g. "Security or policy status"
+	Detail       string // Registered meaning, empty when the detail is not registered
+	Vendor       string // Provider whose meaning applies, empty if none
+	VendorDetail string // Provider-specific meaning
+	RateLimited  bool   // The code reports throttling
+}
+
+// ExplainEnhancedStatus explains a code in the context of the reply text it
+// came with (used to recognize the provider). Returns nil for an invalid code.
+func ExplainEnhancedStatus(code, message string) *StatusExplanation {
+	s, ok := ParseEnhancedStatus(code)
+	if !ok {
+		return nil
+	}
+	e := &StatusExplanation{
+		Status:  s,
+		Class:   statusClasses[s.Class],
+		Subject: statusSubjects[s.Subject],
+		Detail:  statusDetails[fmt.Sprintf("%d.%d", s.Subject, s.Detail)],
+	}
+	if e.Subject == "" {
+		e.Subject = "Unknown subject"
+	}
+	if v := lookupVendorStatus(s, DetectVendor(message)); v != nil {
+		e.Vendor, e.VendorDetail, e.RateLimited = v.vendor, v.meaning, v.rateLimit
+	}
+	if s.Class == 4 && (s.Subject == 4 && s.Detail == 5 || s.Subject == 7 && s.Detail == 28) {
+		e.RateLimited = true
+	}
+	return e
+}
+
+// String returns a one-line explanation, e.g. "5.1.1 Permanent failure,
+// addressing status: Bad destination mailbox address".
+func (e *StatusExplanation) String() string {
+	text := fmt.Sprintf("%s %s, %s", e.Status, e.Class, strings.ToLower(e.Subject))
+	switch {
+	case e.Detail != "" && e.VendorDetail != "":
+		text += fmt.Sprintf(": %s; %s: %s", e.Detail, e.Vendor, e.VendorDetail)
+	case e.VendorDetail != "":
+		text += fmt.Sprintf(": %s: %s", e.Vendor, e.VendorDetail)
+	case e.Detail != "":
+		text += ": " + e.Detail
+	}
+	return text
+}
+
+// replyCodeMeanings are the RFC 5321 meanings of failure reply codes, used
+// when a reply carries no enhanced status code.
+var replyCodeMeanings = map[int]string{
+	421: "Service not available, closing transmission channel",
+	450: "Mailbox unavailable (busy or temporarily blocked)",
+	451: "Local error in processing",
+	452: "Insufficient system storage",
+	454: "TLS or authentication temporarily unavailable",
+	455: "Server unable to accommodate parameters",
+	500: "Syntax error, command unrecognized",
+	501: "Syntax error in parameters or arguments",
+	502: "Command not implemented",
+	503: "Bad sequence of commands",
+	504: "Command parameter not implemented",
+	521: "Server does not accept mail",
+	523: "Message exceeds the server's size limit",
+	530: "Authentication required",
+	534: "Authentication mechanism is too weak",
+	535: "Authentication credentials invalid",
+	538: "Encryption required for requested authentication mechanism",
+	550: "Mailbox unavailable or policy rejection",
+	551: "User not local",
+	552: "Exceeded storage allocation",
+	553: "Mailbox name not allowed",
+	554: "Transaction failed",
+	555: "MAIL FROM/RCPT TO parameters not recognized or not implemented",
+	556: "Domain does not accept mail",
+}
+
+// ReplyCodeMeaning returns the RFC 5321 meaning of a failure reply code, or
+// "" if the code is not a known failure code.
+func ReplyCodeMeaning(code int) string {
+	return replyCodeMeanings[code]
+}
+
+// Explain returns a one-line explanation of the reply: the enhanced status
+// code's meaning when the reply carries one (see Enhanced), otherwise the
+// reply code's meaning. Returns "" for successful or unknown replies.
+func (r *SMTPResponse) Explain() string {
+	if r.Enhanced != "" {
+		if e := ExplainEnhancedStatus(r.Enhanced, r.Message); e != nil {
+			return e.String()
+		}
+	}
+	if meaning := ReplyCodeMeaning(r.Code); meaning != "" {
+		return fmt.Sprintf("%d %s", r.Code, meaning)
+	}
+	return ""
+}
+
+// ReplyError is a failure reply (4xx or 5xx) to an SMTP command. Its message
+// includes the explanation of the reply, and Temporary decides retries from
+// the enhanced status class when the server sent one.
+type ReplyError struct {
+	Code     int
+	Message  string
+	Enhanced string // Enhanced status code, set only when ENHANCEDSTATUSCODES was advertised
+	Err      error  // Underlying error (e.g. *textproto.Error), may be nil
+}
+
+// NewReplyError returns the error for a failure reply.
+func NewReplyError(resp *SMTPResponse) *ReplyError {
+	return &ReplyError{Code: resp.Code, Message: resp.Message, Enhanced: resp.Enhanced}
+}
+
+// Error returns the reply followed by its explanation.
+func (e *ReplyError) Error() string {
+	text := strings.TrimSpace(fmt.Sprintf("%d %s", e.Code, strings.ReplaceAll(e.Message, "\n", " ")))
+	resp := &SMTPResponse{Code: e.Code, Message: e.Message, Enhanced: e.Enhanced}
+	if explanation := resp.Explain(); explanation != "" {
+		text += " (" + explanation + ")"
+	}
+	return text
+}
+
+// Unwrap returns the underlying error.
+func (e *ReplyError) Unwrap() error {
+	return e.Err
+}
+
+// Explanation returns the enhanced status explanation, or nil when the
+// reply carried no enhanced status code.
+func (e *ReplyError) Explanation() *StatusExplanation {
+	if e.Enhanced == "" {
+		return nil
+	}
+	return ExplainEnhancedStatus(e.Enhanced, e.Message)
+}
+
+// Temporary reports whether the failure is transient. The enhanced status
+// class digit decides when present; the reply code is the fallback.
+func (e *ReplyError) Temporary() bool {
+	if s, ok := ParseEnhancedStatus(e.Enhanced); ok {
+		return s.IsTransient()
+	}
+	return e.Code >= 400 && e.Code < 500
+}
+
+// Greylisted reports whether the reply looks like greylisting; see
+// SMTPResponse.IsGreylisted.
+func (e *ReplyError) Greylisted() bool {
+	resp := &SMTPResponse{Code: e.Code, Message: e.Message, Enhanced: e.Enhanced}
+	return resp.IsGreylisted()
+}
+
+// SMTPCode returns the reply code.
+func (e *ReplyError) SMTPCode() int {
+	return e.Code
+}
+
+// SMTPEnhancedCode returns the enhanced status code, "" if there is none.
+func (e *ReplyError) SMTPEnhancedCode() string {
+	return e.Enhanced
+}
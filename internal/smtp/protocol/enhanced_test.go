@@ -0,0 +1,135 @@
+//go:build !integration
+// +build !integration
+
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseEnhancedStatus(t *testing.T) {
+	tests := []struct {
+		code string
+		want EnhancedStatus
+		ok   bool
+	}{
+		{"5.1.1", EnhancedStatus{5, 1, 1}, true},
+		{"4.7.650", EnhancedStatus{4, 7, 650}, true},
+		{"2.0.0", EnhancedStatus{2, 0, 0}, true},
+		{"3.1.1", EnhancedStatus{}, false},
+		{"5.1", EnhancedStatus{}, false},
+		{"5.1.1234", EnhancedStatus{}, false},
+		{"5.+1.1", EnhancedStatus{}, false},
+		{"", EnhancedStatus{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, ok := ParseEnhancedStatus(tt.code)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("ParseEnhancedStatus(%q) = %v, %v, want %v, %v", tt.code, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExplainEnhancedStatus(t *testing.T) {
+	tests := []struct {
+		name            string
+		code            string
+		message         string
+		wantDetail      string
+		wantVendor      string
+		wantRateLimited bool
+	}{
+		{"registered code", "5.1.1", "5.1.1 User unknown", "Bad destination mailbox address", "", false},
+		{"congestion", "4.4.5", "4.4.5 Try later", "Mail system congestion", "", true},
+		{"unregistered microsoft code without markers", "5.7.57", "5.7.57 Client not authenticated", "", VendorMicrosoft, false},
+		{"microsoft throttling range", "4.7.650", "4.7.650 The mail server [192.0.2.1] has been temporarily rate limited [BN1NAM02FT012.eop-nam02.prod.protection.outlook.com]", "", VendorMicrosoft, true},
+		{"gmail reuse of registered code", "4.2.1", "4.2.1 The user you are trying to contact is receiving mail too quickly - gsmtp", "Mailbox disabled, not accepting messages", VendorGmail, true},
+		{"registered code from another server", "4.2.1", "4.2.1 Mailbox temporarily disabled", "Mailbox disabled, not accepting messages", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ExplainEnhancedStatus(tt.code, tt.message)
+			if e == nil {
+				t.Fatalf("ExplainEnhancedStatus(%q) = nil", tt.code)
+			}
+			if e.Detail != tt.wantDetail || e.Vendor != tt.wantVendor || e.RateLimited != tt.wantRateLimited {
+				t.Errorf("ExplainEnhancedStatus(%q) = %+v, want detail %q, vendor %q, rate limited %v",
+					tt.code, e, tt.wantDetail, tt.wantVendor, tt.wantRateLimited)
+			}
+			if !strings.HasPrefix(e.String(), tt.code+" ") {
+				t.Errorf("String() = %q, want it to start with the code", e.String())
+			}
+		})
+	}
+
+	if ExplainEnhancedStatus("550", "") != nil {
+		t.Error("ExplainEnhancedStatus(550) should be nil")
+	}
+}
+
+func TestSMTPResponseEnhancedClassification(t *testing.T) {
+	tests := []struct {
+		name            string
+		code            int
+		enhanced        string
+		message         string
+		wantUnavailable bool
+		wantRateLimited bool
+	}{
+		{"550 without enhanced code", 550, "", "Rejected", true, false},
+		{"550 relay denied", 550, "5.7.54", "5.7.54 Unable to relay", false, false},
+		{"550 bad mailbox", 550, "5.1.1", "5.1.1 User unknown", true, false},
+		{"451 local error", 451, "4.3.0", "4.3.0 Local error", false, false},
+		{"451 without enhanced code", 451, "", "Local error", false, true},
+		{"450 mail flood", 450, "4.7.28", "4.7.28 Too much mail", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &SMTPResponse{Code: tt.code, Message: tt.message, Lines: []string{tt.message}, Enhanced: tt.enhanced}
+			if got := resp.IsMailboxUnavailable(); got != tt.wantUnavailable {
+				t.Errorf("IsMailboxUnavailable() = %v, want %v", got, tt.wantUnavailable)
+			}
+			if got := resp.IsRateLimited(); got != tt.wantRateLimited {
+				t.Errorf("IsRateLimited() = %v, want %v", got, tt.wantRateLimited)
+			}
+		})
+	}
+}
+
+func TestReplyError(t *testing.T) {
+	underlying := errors.New("550 5.1.1 User unknown")
+	err := &ReplyError{Code: 550, Message: "5.1.1 User unknown", Enhanced: "5.1.1", Err: underlying}
+
+	if !strings.Contains(err.Error(), "Bad destination mailbox address") {
+		t.Errorf("Error() = %q, want explanation", err.Error())
+	}
+	if !errors.Is(err, underlying) {
+		t.Error("Unwrap() does not return the underlying error")
+	}
+	if err.Temporary() {
+		t.Error("5.1.1 reported as temporary")
+	}
+
+	// The class digit wins over the reply code
+	if !(&ReplyError{Code: 550, Enhanced: "4.7.1"}).Temporary() {
+		t.Error("550 4.7.1 should be temporary")
+	}
+	if (&ReplyError{Code: 451, Enhanced: "5.7.1"}).Temporary() {
+		t.Error("451 5.7.1 should be permanent")
+	}
+	if !(&ReplyError{Code: 421}).Temporary() {
+		t.Error("421 without enhanced code should be temporary")
+	}
+
+	plain := &ReplyError{Code: 554, Message: "No thanks"}
+	if got := plain.Error(); got != "554 No thanks (554 Transaction failed)" || plain.Explanation() != nil {
+		t.Errorf("Error() = %q, Explanation() = %v", got, plain.Explanation())
+	}
+}
@@ -16,9 +16,10 @@ const DefaultResponseTimeout = 30 * time.Second
 // SMTP responses consist of a 3-digit code and optional message text.
 // Multiline responses are supported (indicated by a hyphen after the code).
 type SMTPResponse struct {
-	Code    int      // 3-digit response code (e.g., 220, 250, 550)
-	Message string   // Full response message (multiline responses joined with \n)
-	Lines   []string // Individual lines of the response message
+	Code     int      // 3-digit response code (e.g., 220, 250, 550)
+	Message  string   // Full response message (multiline responses joined with \n)
+	Lines    []string // Individual lines of the response message
+	Enhanced string   // RFC 3463 enhanced status code, set by the client when the server advertises ENHANCEDSTATUSCODES
 }
 
 // ReadResponse reads and parses an SMTP response from the provided reader.
@@ -160,13 +161,88 @@ func (r *SMTPResponse) IsAuthRequired() bool {
 }
 
 // IsMailboxUnavailable checks if the response indicates the mailbox doesn't exist.
-// Common codes: 550 (Mailbox unavailable), 551 (User not local)
+// With an enhanced status code: X.1.1 (Bad mailbox), X.1.6 (Mailbox moved) or
+// 5.2.1 (Mailbox disabled). Otherwise 550 (Mailbox unavailable) or 551 (User not local).
 func (r *SMTPResponse) IsMailboxUnavailable() bool {
+	if s, ok := ParseEnhancedStatus(r.Enhanced); ok {
+		switch {
+		case s.Subject == 1 && (s.Detail == 1 || s.Detail == 6):
+			return true
+		case s.Class == 5 && s.Subject == 2 && s.Detail == 1:
+			return true
+		}
+		return false
+	}
 	return r.Code == 550 || r.Code == 551
 }
 
 // IsRateLimited checks if the response indicates rate limiting.
-// Common codes: 421 (Service not available), 450 (Mailbox busy)
+// With an enhanced status code, the catalog decides (e.g. 4.4.5 congestion,
+// 4.7.28 mail flood, Microsoft 365 4.7.5xx throttling). Otherwise
+// 421 (Service not available), 450 (Mailbox busy) or 451 (Local error).
 func (r *SMTPResponse) IsRateLimited() bool {
+	if e := ExplainEnhancedStatus(r.Enhanced, r.Message); e != nil {
+		return e.RateLimited
+	}
 	return r.Code == 421 || r.Code == 450 || r.Code == 451
 }
+
+// greylistKeywords name greylisting outright (Postgrey, SQLgrey,
+// milter-greylist, rspamd, Exim greylistd).
+var greylistKeywords = []string{
+	"greylist", "graylist", "grey-list", "gray-list", "grey list", "gray list",
+}
+
+// deferralPhrases are greylisters' wording that does not name greylisting,
+// as in "451 4.7.1 Please come back later" or "450 Triplet not yet seen".
+// Generic requests such as "try again later" are not among them: rate
+// limiting and local errors use them too.
+var deferralPhrases = []string{
+	"come back later", "triplet",
+}
+
+// IsGreylisted checks if a temporary failure looks like greylisting: the
+// server defers a new sender/recipient/client combination and accepts the
+// same message when it is retried after a delay. Any 4xx reply naming
+// greylisting matches; otherwise a 450/451 reply needs greylisting-specific
+// wording and, when the server advertised ENHANCEDSTATUSCODES (Enhanced is
+// set), a 4.2.0, 4.7.0 or 4.7.1 status. Rate limiting and congestion (e.g.
+// 4.4.5, 4.7.28) do not match. A code-like prefix of an unadvertised reply
+// is not trusted.
+func (r *SMTPResponse) IsGreylisted() bool {
+	if r.Code < 400 || r.Code >= 500 {
+		return false
+	}
+	text := strings.ToLower(r.Message)
+	for _, keyword := range greylistKeywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	if r.Code != 450 && r.Code != 451 {
+		return false
+	}
+	switch r.Enhanced {
+	case "", "4.2.0", "4.7.0", "4.7.1":
+		for _, phrase := range deferralPhrases {
+			if strings.Contains(text, phrase) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EnhancedCode returns the RFC 3463 enhanced status code (e.g. "5.1.1") that
+// starts the reply text, or "" when the reply does not carry one.
+func (r *SMTPResponse) EnhancedCode() string {
+	text := r.Message
+	if len(r.Lines) > 0 {
+		text = r.Lines[0]
+	}
+	field, _, _ := strings.Cut(strings.TrimSpace(text), " ")
+	if _, ok := ParseEnhancedStatus(field); !ok {
+		return ""
+	}
+	return field
+}
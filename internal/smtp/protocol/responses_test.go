@@ -295,6 +295,73 @@ func TestSMTPResponseString(t *testing.T) {
 	}
 }
 
+// TestSMTPResponseIsGreylisted tests greylisting detection from reply codes and text
+func TestSMTPResponseIsGreylisted(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     int
+		enhanced string
+		message  string
+		want     bool
+	}{
+		{"postgrey", 450, "4.2.0", "4.2.0 <bob@example.com>: Recipient address rejected: Greylisted, see http://postgrey.schweikert.ch/help/example.com.html", true},
+		{"milter-greylist", 451, "", "4.7.1 Greylisting in action, please come back later", true},
+		{"keyword without enhanced code", 450, "", "Temporary failure: graylisted for 5 minutes", true},
+		{"come back later", 451, "4.7.1", "4.7.1 Please come back later", true},
+		{"triplet without enhanced codes", 450, "", "Triplet not yet seen, retry in 5 minutes", true},
+		{"generic deferral", 451, "4.7.1", "4.7.1 Try again later", false},
+		{"generic deferral without enhanced codes", 451, "", "Please try again later", false},
+		{"unadvertised code-like text", 451, "", "4.7.1 Please try again later", false},
+		{"come back later with rate limit code", 451, "4.7.28", "4.7.28 Too many messages, come back later", false},
+		{"mailbox busy", 450, "4.2.1", "4.2.1 Mailbox temporarily unavailable, retry later", false},
+		{"throttling", 451, "4.7.500", "4.7.500 Server busy. Please try again later", false},
+		{"congestion", 421, "4.4.5", "4.4.5 Too many connections, try again later", false},
+		{"local error", 451, "4.3.0", "4.3.0 Local error in processing, try again later", false},
+		{"no retry request", 450, "4.7.1", "4.7.1 Client host rejected: cannot find your hostname", false},
+		{"permanent", 550, "5.7.1", "5.7.1 Greylisted sender blocked", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &SMTPResponse{Code: tt.code, Message: tt.message, Enhanced: tt.enhanced}
+			if got := resp.IsGreylisted(); got != tt.want {
+				t.Errorf("IsGreylisted() = %v, want %v", got, tt.want)
+			}
+			replyErr := &ReplyError{Code: tt.code, Message: tt.message, Enhanced: tt.enhanced}
+			if got := replyErr.Greylisted(); got != tt.want {
+				t.Errorf("ReplyError.Greylisted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSMTPResponseEnhancedCode tests extraction of RFC 3463 enhanced status codes
+func TestSMTPResponseEnhancedCode(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"5.1.1 User unknown", "5.1.1"},
+		{"2.1.5 Recipient OK", "2.1.5"},
+		{"4.7.1 Greylisted, try again later", "4.7.1"},
+		{"5.7.54 Unable to relay", "5.7.54"},
+		{"OK", ""},
+		{"1.2.3 Not a valid class", ""},
+		{"5.1 Missing detail", ""},
+		{"5.1.1234 Detail too long", ""},
+		{"Requested action taken 2.0.0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			resp := &SMTPResponse{Code: 550, Message: tt.message, Lines: []string{tt.message}}
+			if got := resp.EnhancedCode(); got != tt.want {
+				t.Errorf("EnhancedCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 // neverEndingReader is a reader that blocks forever (simulates hanging server)
 type neverEndingReader struct{}
 
@@ -0,0 +1,108 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Internationalized email (EAI) support, RFC 6531.
+//
+// A server that advertises SMTPUTF8 accepts UTF-8 in envelope addresses when
+// the client adds the SMTPUTF8 parameter to MAIL FROM. Without the extension,
+// internationalized domain names can still be sent in their IDNA A-label
+// (punycode) form, but a UTF-8 local part cannot be expressed at all.
+
+// SMTPUTF8Param is the MAIL FROM parameter that declares an internationalized transaction.
+const SMTPUTF8Param = "SMTPUTF8"
+
+// ErrNonASCIILocalPart is returned when an address with a UTF-8 local part
+// must be sent to a server that does not support SMTPUTF8.
+var ErrNonASCIILocalPart = errors.New("address has a UTF-8 local part, which requires SMTPUTF8")
+
+// IsASCII reports whether s contains only 7-bit ASCII characters.
+func IsASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// NeedsSMTPUTF8 reports whether an address can only be transmitted with
+// SMTPUTF8, i.e. its local part contains non-ASCII characters.
+// A non-ASCII domain alone can be converted with AddressToASCII instead.
+func NeedsSMTPUTF8(address string) bool {
+	local, _ := splitAddress(address)
+	return !IsASCII(local)
+}
+
+// AddressToASCII converts the domain of an address to its IDNA A-label form
+// (e.g. user@bücher.example -> user@xn--bcher-kva.example) so it can be sent
+// to servers without SMTPUTF8. ASCII addresses are returned unchanged.
+func AddressToASCII(address string) (string, error) {
+	if IsASCII(address) {
+		return address, nil
+	}
+
+	local, domain := splitAddress(address)
+	if !IsASCII(local) {
+		return "", fmt.Errorf("%s: %w", address, ErrNonASCIILocalPart)
+	}
+
+	asciiDomain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized domain %q: %w", domain, err)
+	}
+	return local + "@" + asciiDomain, nil
+}
+
+// EncodeUTF8AddrXText encodes an address as utf-8-addr-xtext (RFC 6533
+// section 3) for use in ORCPT=utf-8;<address>. Non-ASCII characters, "+",
+// "=", "\" and characters outside "!" through "~" are written as \x{HEX}.
+func EncodeUTF8AddrXText(address string) string {
+	var b strings.Builder
+	for _, r := range address {
+		if r < '!' || r > '~' || r == '+' || r == '=' || r == '\\' {
+			fmt.Fprintf(&b, "\\x{%X}", r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// EAIRejectionReason explains a reply that indicates the server refused an
+// internationalized transaction. Returns "" when the reply is unrelated to EAI.
+// Enhanced status codes are from RFC 6531 section 3.7.4 and RFC 5248; only a
+// code that starts the reply text counts, not digits elsewhere in it.
+func EAIRejectionReason(code int, message string) string {
+	status, ok := ParseEnhancedStatus((&SMTPResponse{Code: code, Message: message}).EnhancedCode())
+	eai := ok && status.Subject == 6
+	switch {
+	case eai && status.Detail == 7:
+		return "server does not permit non-ASCII addresses for this sender or recipient (X.6.7)"
+	case eai && status.Detail == 8:
+		return "server requires UTF-8 replies that the client did not request (X.6.8)"
+	case eai && status.Detail == 9:
+		return "message with UTF-8 headers cannot be delivered to one or more recipients (X.6.9)"
+	case code == 555:
+		return "server did not recognize the SMTPUTF8 parameter despite advertising it (555)"
+	case code == 553:
+		return "server rejected the internationalized mailbox name (553)"
+	}
+	return ""
+}
+
+// splitAddress splits an address at its last "@". The domain is empty when
+// there is no "@".
+func splitAddress(address string) (local, domain string) {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return address, ""
+	}
+	return address[:at], address[at+1:]
+}
@@ -0,0 +1,117 @@
+//go:build !integration
+// +build !integration
+
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestNeedsSMTPUTF8 tests detection of addresses that require SMTPUTF8
+func TestNeedsSMTPUTF8(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"user@example.com", false},
+		{"user@bücher.example", false},
+		{"用户@example.com", true},
+		{"josé@ñandú.example", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := NeedsSMTPUTF8(tt.address); got != tt.want {
+				t.Errorf("NeedsSMTPUTF8(%q) = %v, want %v", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAddressToASCII tests IDNA conversion of internationalized domains
+func TestAddressToASCII(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr error
+	}{
+		{"ASCII unchanged", "user@example.com", "user@example.com", nil},
+		{"IDN domain", "user@bücher.example", "user@xn--bcher-kva.example", nil},
+		{"IDN subdomain", "info@mail.münchen.de", "info@mail.xn--mnchen-3ya.de", nil},
+		{"UTF-8 local part", "用户@example.com", "", ErrNonASCIILocalPart},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AddressToASCII(tt.address)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("AddressToASCII(%q) error = %v, want %v", tt.address, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddressToASCII(%q) unexpected error: %v", tt.address, err)
+			}
+			if got != tt.want {
+				t.Errorf("AddressToASCII(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEncodeUTF8AddrXText tests utf-8-addr-xtext encoding for ORCPT
+func TestEncodeUTF8AddrXText(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"user@example.com", "user@example.com"},
+		{"josé@example.com", `jos\x{E9}@example.com`},
+		{"a+b=c@example.com", `a\x{2B}b\x{3D}c@example.com`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := EncodeUTF8AddrXText(tt.input); got != tt.want {
+				t.Errorf("EncodeUTF8AddrXText(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEAIRejectionReason tests explanations for EAI-related rejections
+func TestEAIRejectionReason(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		message string
+		want    string
+	}{
+		{"Non-ASCII address not permitted", 553, "5.6.7 Non-ASCII addresses not permitted", "X.6.7"},
+		{"UTF-8 header undeliverable", 550, "5.6.9 UTF-8 header message cannot be transferred", "X.6.9"},
+		{"Parameter not recognized", 555, "MAIL FROM parameters not recognized", "SMTPUTF8 parameter"},
+		{"Unrelated rejection", 550, "5.1.1 User unknown", ""},
+		{"Digits in an IP address", 550, "5.7.1 Relaying from 10.6.7.1 denied", ""},
+		{"Digits without an enhanced code", 550, "Client host 192.0.2.6.9 blocked", ""},
+		{"UTF-8 replies required", 553, "5.6.8 UTF-8 reply required", "X.6.8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EAIRejectionReason(tt.code, tt.message)
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("EAIRejectionReason() = %q, want empty", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("EAIRejectionReason() = %q, want containing %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Package tls implements SMTP-specific TLS checks such as DANE (RFC 7672).
+// Protocol-neutral analysis lives in msgraphtool/internal/common/tls.
+package tls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"fmt"
+
+	"msgraphtool/internal/common/dns"
+)
+
+// TLSA certificate usages (RFC 6698 section 2.1.1).
+const (
+	DANEUsagePKIXTA = 0 // CA constraint, requires PKIX validation
+	DANEUsagePKIXEE = 1 // Service certificate constraint, requires PKIX validation
+	DANEUsageDANETA = 2 // Trust anchor assertion
+	DANEUsageDANEEE = 3 // Domain-issued certificate
+)
+
+// DANE verification results.
+const (
+	DANEStatusEE       = "dane-ee"           // Leaf certificate matched a DANE-EE record
+	DANEStatusTA       = "dane-ta"           // Chain matched a DANE-TA record and the leaf chains to it
+	DANEStatusMismatch = "mismatch"          // Usable records exist but none matched
+	DANEStatusUnusable = "no_usable_records" // Only PKIX or unsupported records were published
+	DANEStatusNone     = "no_records"        // No TLSA records published
+)
+
+// DANEResult holds the outcome of matching a certificate chain against TLSA records.
+type DANEResult struct {
+	Status        string          // One of the DANEStatus* values
+	Records       int             // Number of TLSA records published
+	UsableRecords int             // Records with a usage and parameters usable for SMTP
+	Matched       *dns.TLSARecord // Record that authenticated the chain (nil unless verified)
+	Details       []string        // Why individual records were unusable or did not match
+}
+
+// Verified reports whether a usable TLSA record authenticated the chain.
+func (r *DANEResult) Verified() bool {
+	return r.Status == DANEStatusEE || r.Status == DANEStatusTA
+}
+
+// VerifyDANE matches a presented certificate chain against the TLSA records
+// of an SMTP server following RFC 7672. DANE-EE(3) records match the leaf
+// without name or expiry checks; DANE-TA(2) records must match a certificate
+// in the chain, from which the leaf must validate for hostname. PKIX-TA(0)
+// and PKIX-EE(1) records are unusable for SMTP (RFC 7672 section 3.1.3).
+// The first certificate is the leaf.
+func VerifyDANE(certs []*x509.Certificate, hostname string, records []dns.TLSARecord) *DANEResult {
+	result := &DANEResult{Status: DANEStatusNone, Records: len(records)}
+	if len(records) == 0 {
+		return result
+	}
+
+	for i := range records {
+		rec := &records[i]
+		switch rec.Usage {
+		case DANEUsageDANEEE, DANEUsageDANETA:
+		case DANEUsagePKIXTA, DANEUsagePKIXEE:
+			result.Details = append(result.Details, fmt.Sprintf("%d %d %d: PKIX usage is not used with SMTP", rec.Usage, rec.Selector, rec.MatchingType))
+			continue
+		default:
+			result.Details = append(result.Details, fmt.Sprintf("%d %d %d: unknown certificate usage", rec.Usage, rec.Selector, rec.MatchingType))
+			continue
+		}
+		if _, err := tlsaAssociation(rec, nil); err != nil {
+			result.Details = append(result.Details, fmt.Sprintf("%d %d %d: %v", rec.Usage, rec.Selector, rec.MatchingType, err))
+			continue
+		}
+		result.UsableRecords++
+
+		if len(certs) == 0 {
+			continue
+		}
+		if rec.Usage == DANEUsageDANEEE {
+			if tlsaMatches(rec, certs[0]) {
+				result.Status = DANEStatusEE
+				result.Matched = rec
+				return result
+			}
+			result.Details = append(result.Details, fmt.Sprintf("%d %d %d: does not match the server certificate", rec.Usage, rec.Selector, rec.MatchingType))
+			continue
+		}
+
+		if err := verifyDANETA(rec, certs, hostname); err != nil {
+			result.Details = append(result.Details, fmt.Sprintf("%d %d %d: %v", rec.Usage, rec.Selector, rec.MatchingType, err))
+			continue
+		}
+		result.Status = DANEStatusTA
+		result.Matched = rec
+		return result
+	}
+
+	if result.UsableRecords == 0 {
+		result.Status = DANEStatusUnusable
+	} else {
+		result.Status = DANEStatusMismatch
+	}
+	return result
+}
+
+// verifyDANETA finds the chain certificate matching a DANE-TA record and
+// validates the leaf against it as the only trust anchor. A record may match
+// the leaf itself (a self-signed certificate), which is then its own anchor.
+func verifyDANETA(rec *dns.TLSARecord, certs []*x509.Certificate, hostname string) error {
+	for i, cert := range certs {
+		if !tlsaMatches(rec, cert) {
+			continue
+		}
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:max(i, 1)] {
+			intermediates.AddCert(c)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{
+			DNSName:       hostname,
+			Roots:         roots,
+			Intermediates: intermediates,
+		}); err != nil {
+			return fmt.Errorf("trust anchor matched but the chain does not validate: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no certificate in the presented chain matches")
+}
+
+// tlsaMatches reports whether cert matches the association data of rec.
+func tlsaMatches(rec *dns.TLSARecord, cert *x509.Certificate) bool {
+	data, err := tlsaAssociation(rec, cert)
+	return err == nil && bytes.Equal(data, rec.Data)
+}
+
+// tlsaAssociation computes the association data of cert for the selector and
+// matching type of rec. A nil cert only validates the record parameters.
+func tlsaAssociation(rec *dns.TLSARecord, cert *x509.Certificate) ([]byte, error) {
+	var selected []byte
+	switch rec.Selector {
+	case 0:
+		if cert != nil {
+			selected = cert.Raw
+		}
+	case 1:
+		if cert != nil {
+			selected = cert.RawSubjectPublicKeyInfo
+		}
+	default:
+		return nil, fmt.Errorf("unsupported selector %d", rec.Selector)
+	}
+
+	switch rec.MatchingType {
+	case 0:
+		return selected, nil
+	case 1:
+		sum := sha256.Sum256(selected)
+		return sum[:], nil
+	case 2:
+		sum := sha512.Sum512(selected)
+		return sum[:], nil
+	default:
+		return nil, fmt.Errorf("unsupported matching type %d", rec.MatchingType)
+	}
+}
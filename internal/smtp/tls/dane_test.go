@@ -0,0 +1,104 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"msgraphtool/internal/common/dns"
+)
+
+// newTestChain returns a leaf for hostname issued by a fresh CA.
+func newTestChain(t *testing.T, hostname string) (leaf, ca *x509.Certificate) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ = x509.ParseCertificate(caDER)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: hostname},
+		DNSNames:     []string{hostname},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ = x509.ParseCertificate(leafDER)
+	return leaf, ca
+}
+
+// TestVerifyDANE tests TLSA matching for the usages used with SMTP
+func TestVerifyDANE(t *testing.T) {
+	leaf, ca := newTestChain(t, "mx.example.test")
+	chain := []*x509.Certificate{leaf, ca}
+
+	leafSPKI := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+	leafCert := sha512.Sum512(leaf.Raw)
+	caSPKI := sha256.Sum256(ca.RawSubjectPublicKeyInfo)
+
+	tests := []struct {
+		name     string
+		hostname string
+		records  []dns.TLSARecord
+		want     string
+	}{
+		{"No records", "mx.example.test", nil, DANEStatusNone},
+		{"DANE-EE SPKI SHA-256", "mx.example.test", []dns.TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: leafSPKI[:]}}, DANEStatusEE},
+		{"DANE-EE full cert SHA-512", "mx.example.test", []dns.TLSARecord{{Usage: 3, Selector: 0, MatchingType: 2, Data: leafCert[:]}}, DANEStatusEE},
+		{"DANE-EE ignores name", "other.example.test", []dns.TLSARecord{{Usage: 3, Selector: 0, MatchingType: 0, Data: leaf.Raw}}, DANEStatusEE},
+		{"DANE-TA", "mx.example.test", []dns.TLSARecord{{Usage: 2, Selector: 1, MatchingType: 1, Data: caSPKI[:]}}, DANEStatusTA},
+		{"DANE-TA matching the leaf", "mx.example.test", []dns.TLSARecord{{Usage: 2, Selector: 1, MatchingType: 1, Data: leafSPKI[:]}}, DANEStatusTA},
+		{"DANE-TA matching the leaf, wrong name", "other.example.test", []dns.TLSARecord{{Usage: 2, Selector: 1, MatchingType: 1, Data: leafSPKI[:]}}, DANEStatusMismatch},
+		{"DANE-TA wrong name", "other.example.test", []dns.TLSARecord{{Usage: 2, Selector: 1, MatchingType: 1, Data: caSPKI[:]}}, DANEStatusMismatch},
+		{"DANE-EE mismatch", "mx.example.test", []dns.TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: caSPKI[:]}}, DANEStatusMismatch},
+		{"Second record matches", "mx.example.test", []dns.TLSARecord{
+			{Usage: 3, Selector: 1, MatchingType: 1, Data: caSPKI[:]},
+			{Usage: 3, Selector: 1, MatchingType: 1, Data: leafSPKI[:]},
+		}, DANEStatusEE},
+		{"PKIX usages unusable", "mx.example.test", []dns.TLSARecord{{Usage: 1, Selector: 1, MatchingType: 1, Data: leafSPKI[:]}}, DANEStatusUnusable},
+		{"Unsupported matching type", "mx.example.test", []dns.TLSARecord{{Usage: 3, Selector: 1, MatchingType: 9, Data: leafSPKI[:]}}, DANEStatusUnusable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := VerifyDANE(chain, tt.hostname, tt.records)
+			if result.Status != tt.want {
+				t.Errorf("VerifyDANE() status = %q, want %q (details: %v)", result.Status, tt.want, result.Details)
+			}
+			if result.Verified() != (result.Matched != nil) {
+				t.Errorf("Verified() = %v but Matched = %v", result.Verified(), result.Matched)
+			}
+		})
+	}
+}